@@ -31,22 +31,45 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"reflect"
+	"strings"
 	"sync"
 	"syscall"
 
 	_ "github.com/nadzzz/switchyard/docs" // generated swagger docs
 
+	"github.com/nadzzz/switchyard/internal/async"
+	"github.com/nadzzz/switchyard/internal/audiofetch"
+	"github.com/nadzzz/switchyard/internal/audiostore"
+	"github.com/nadzzz/switchyard/internal/audit"
+	"github.com/nadzzz/switchyard/internal/cmdschema"
+	"github.com/nadzzz/switchyard/internal/cmdtransform"
 	"github.com/nadzzz/switchyard/internal/config"
 	"github.com/nadzzz/switchyard/internal/dispatch"
 	"github.com/nadzzz/switchyard/internal/health"
 	"github.com/nadzzz/switchyard/internal/interpreter"
+	chunkedinterp "github.com/nadzzz/switchyard/internal/interpreter/chunked"
+	compositeinterp "github.com/nadzzz/switchyard/internal/interpreter/composite"
+	deepgraminterp "github.com/nadzzz/switchyard/internal/interpreter/deepgram"
+	geminiinterp "github.com/nadzzz/switchyard/internal/interpreter/gemini"
 	localinterp "github.com/nadzzz/switchyard/internal/interpreter/local"
+	mockinterp "github.com/nadzzz/switchyard/internal/interpreter/mock"
 	openaiinterp "github.com/nadzzz/switchyard/internal/interpreter/openai"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/ratelimit"
+	"github.com/nadzzz/switchyard/internal/session"
 	"github.com/nadzzz/switchyard/internal/transport"
+	exectransport "github.com/nadzzz/switchyard/internal/transport/exec"
+	filetransport "github.com/nadzzz/switchyard/internal/transport/file"
 	grpctransport "github.com/nadzzz/switchyard/internal/transport/grpc"
 	httptransport "github.com/nadzzz/switchyard/internal/transport/http"
 	mqtttransport "github.com/nadzzz/switchyard/internal/transport/mqtt"
+	stdouttransport "github.com/nadzzz/switchyard/internal/transport/stdout"
+	wstransport "github.com/nadzzz/switchyard/internal/transport/ws"
 	"github.com/nadzzz/switchyard/internal/tts"
+	ttscache "github.com/nadzzz/switchyard/internal/tts/cache"
+	elevenlabstts "github.com/nadzzz/switchyard/internal/tts/elevenlabs"
+	openaitts "github.com/nadzzz/switchyard/internal/tts/openai"
 	pipertts "github.com/nadzzz/switchyard/internal/tts/piper"
 )
 
@@ -79,36 +102,78 @@ func main() {
 		syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	// Initialize the interpreter backend.
-	var interp interpreter.Interpreter
-	switch cfg.Interpreter.Backend {
-	case "openai":
-		interp = openaiinterp.New(cfg.Interpreter.OpenAI)
-		slog.Info("using OpenAI interpreter",
-			"transcription_model", cfg.Interpreter.OpenAI.TranscriptionModel,
-			"completion_model", cfg.Interpreter.OpenAI.CompletionModel)
-	case "local":
-		interp = localinterp.New(cfg.Interpreter.Local)
-		slog.Info("using local interpreter",
-			"whisper", cfg.Interpreter.Local.WhisperEndpoint,
-			"llm", cfg.Interpreter.Local.LLMEndpoint)
-	default:
-		slog.Error("unknown interpreter backend", "backend", cfg.Interpreter.Backend)
+	// Listeners run until in-flight dispatches have drained, which outlasts
+	// the signal context.
+	runCtx, stopListeners := context.WithCancel(context.Background())
+	defer stopListeners()
+
+	// Initialize the interpreter backend(s).
+	interp, err := buildInterpreter(cfg.Interpreter)
+	if err != nil {
+		slog.Error("failed to create interpreter", "error", err)
+		os.Exit(1)
+	}
+	named, err := buildNamedInterpreters(cfg.Interpreter)
+	if err != nil {
+		slog.Error("failed to create interpreter", "error", err)
 		os.Exit(1)
 	}
-	defer interp.Close()
+
+	// Response audio is either embedded in results or stored for fetching
+	// from the HTTP transport.
+	var audioStore *audiostore.Store
+	if cfg.TTS.Enabled && cfg.TTS.Delivery == "url" {
+		if !cfg.Transports.HTTP.Enabled {
+			slog.Warn("tts delivery is \"url\" but the http transport is disabled, audio will be returned inline")
+		} else {
+			audioStore = audiostore.New(cfg.TTS.AudioStore.TTL, cfg.TTS.AudioStore.MaxEntries, cfg.TTS.AudioStore.MaxBytes)
+			slog.Info("TTS audio delivered by URL", "ttl", audioStore.TTL())
+		}
+	}
+
+	// Async callbacks are delivered by the dispatcher for every transport;
+	// the HTTP transport also queues callback requests and answers 202.
+	var asyncQueue *async.Queue
+	if cfg.Async.Enabled {
+		asyncQueue = async.New(cfg.Async)
+		slog.Info("async dispatch enabled", "workers", cfg.Async.Workers,
+			"callback_hosts", len(cfg.Async.CallbackHosts), "signed", cfg.Async.CallbackSecret != "")
+	}
 
 	// Initialize enabled transports.
 	var transports []transport.Transport
 
 	if cfg.Transports.GRPC.Enabled {
-		transports = append(transports, grpctransport.New(cfg.Transports.GRPC.Port))
+		transports = append(transports, grpctransport.New(cfg.Transports.GRPC))
 	}
 	if cfg.Transports.HTTP.Enabled {
-		transports = append(transports, httptransport.New(cfg.Transports.HTTP.Port))
+		transports = append(transports, httptransport.New(cfg.Transports.HTTP, httptransport.Options{
+			AudioStore: audioStore,
+			Async:      asyncQueue,
+		}))
 	}
 	if cfg.Transports.MQTT.Enabled {
-		transports = append(transports, mqtttransport.New(cfg.Transports.MQTT.Broker, cfg.Transports.MQTT.Topic))
+		transports = append(transports, mqtttransport.New(cfg.Transports.MQTT))
+	}
+	if cfg.Transports.Stdout.Enabled {
+		transports = append(transports, stdouttransport.New(cfg.Transports.Stdout))
+	}
+	if cfg.Transports.File.Enabled {
+		// Send-only: registered with the dispatcher rather than listening.
+		dispatch.RegisterSender("file", filetransport.New(cfg.Transports.File))
+		slog.Info("file sink enabled", "dir", cfg.Transports.File.Dir)
+	}
+	var wsSender *wstransport.Sender
+	if cfg.Transports.WS.Enabled {
+		// Send-only, like the file sink.
+		wsSender = wstransport.New(cfg.Transports.WS)
+		dispatch.RegisterSender("ws", wsSender)
+		slog.Info("websocket sender enabled", "queue_size", cfg.Transports.WS.QueueSize)
+	}
+	if cfg.Transports.Exec.Enabled {
+		slog.Warn("exec transport enabled — allow-listed commands can be run by any dispatch",
+			"commands", len(cfg.Transports.Exec.Commands))
+		transports = append(transports, exectransport.New(cfg.Transports.Exec))
 	}
 
 	if len(transports) == 0 {
@@ -116,30 +181,79 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize TTS (text-to-speech) if enabled.
-	var synthesizer tts.Synthesizer
-	if cfg.TTS.Enabled {
-		switch cfg.TTS.Backend {
-		case "piper":
-			synthesizer = pipertts.New(cfg.TTS.Piper)
-			slog.Info("TTS enabled", "backend", "piper",
-				"endpoint", cfg.TTS.Piper.Endpoint,
-				"language_endpoints", len(cfg.TTS.Piper.Endpoints))
-		default:
-			slog.Warn("unknown TTS backend, TTS disabled", "backend", cfg.TTS.Backend)
+	// Initialize TTS (text-to-speech) if enabled. The dispatcher skips
+	// synthesis when it gets a nil synthesizer.
+	synthesizer := buildSynthesizer(cfg.TTS)
+	ttsCfg := cfg.TTS
+
+	// Create the dispatcher.
+	opts, err := dispatchOptions(cfg, audioStore)
+	if err != nil {
+		slog.Error("invalid command settings", "error", err)
+		os.Exit(1)
+	}
+	if cfg.AudioFetch.Enabled {
+		opts.AudioFetcher = audiofetch.New(cfg.AudioFetch)
+		slog.Info("audio input by URL enabled", "allowed_hosts", cfg.AudioFetch.AllowedHosts)
+	}
+	if cfg.Dedup.Enabled {
+		opts.DedupWindow = cfg.Dedup.Window
+		opts.DedupMaxEntries = cfg.Dedup.MaxEntries
+	}
+	opts.MaxConcurrent = cfg.Server.MaxConcurrentDispatches
+	opts.RejectWhenBusy = cfg.Server.OnBusy == "reject"
+	if r := cfg.Server.RateLimit; r.Enabled {
+		opts.RateLimiter = ratelimit.New(r.RequestsPerSecond, r.Burst, ratelimit.BySource)
+		slog.Info("rate limiting enabled", "requests_per_second", r.RequestsPerSecond, "burst", r.Burst)
+	}
+	opts.MaxAudioBytes = cfg.Server.MaxAudioBytes
+	opts.MaxAudioDuration = cfg.Server.MaxAudioDuration
+	opts.SilenceThreshold = cfg.Server.SilenceThreshold
+	opts.DebugEcho = cfg.Server.DebugEcho
+	if opts.DebugEcho {
+		slog.Warn("debug echo enabled — failed results include the message text and instruction")
+	}
+	opts.Callbacks = asyncQueue
+	opts.Interpreters = named
+	if cfg.Audit.Enabled {
+		if cfg.Audit.Path != "" {
+			auditFile, err := audit.NewFile(cfg.Audit)
+			if err != nil {
+				slog.Error("failed to open audit file", "error", err)
+				os.Exit(1)
+			}
+			defer auditFile.Close()
+			opts.AuditFile = auditFile
 		}
+		opts.AuditTarget = cfg.Audit.Target
+		slog.Info("audit trail enabled", "path", cfg.Audit.Path, "target", cfg.Audit.Target)
 	}
-	if synthesizer != nil {
-		defer synthesizer.Close()
+	if cfg.Sessions.Enabled {
+		opts.Sessions = session.NewMemory(cfg.Sessions.MaxTurns, cfg.Sessions.TTL)
 	}
+	dispatcher := dispatch.New(interp, transports, synthesizer, opts)
 
-	// Create the dispatcher.
-	dispatcher := dispatch.New(interp, transports, synthesizer)
+	for _, t := range transports {
+		if s, ok := t.(transport.Streamer); ok {
+			s.SetStreamHandler(dispatcher.HandleStream)
+		}
+		if s, ok := t.(transport.Speaker); ok {
+			s.SetSpeechHandler(dispatcher.Speak)
+		}
+		if s, ok := t.(transport.TargetAdminServer); ok {
+			s.SetTargetAdmin(dispatcher)
+		}
+	}
 
 	// Start health check server.
-	healthServer := health.New(cfg.Server.HealthPort)
+	healthServer := health.New(cfg.Server.HealthPort, dispatcher)
+	healthServer.SetBackends(backends(cfg, interp))
+	if cfg.Server.HealthChecks.Enabled {
+		setHealthChecks(healthServer, interp, synthesizer)
+		go healthServer.RunChecks(runCtx, cfg.Server.HealthChecks.Interval, cfg.Server.HealthChecks.Timeout)
+	}
 	go func() {
-		if err := healthServer.ListenAndServe(ctx); err != nil {
+		if err := healthServer.ListenAndServe(runCtx); err != nil {
 			slog.Error("health server failed", "error", err)
 		}
 	}()
@@ -151,7 +265,7 @@ func main() {
 		go func(t transport.Transport) {
 			defer wg.Done()
 			slog.Info("starting transport", "name", t.Name())
-			if err := t.Listen(ctx, dispatcher.Handle); err != nil {
+			if err := t.Listen(runCtx, dispatcher.Handle); err != nil {
 				slog.Error("transport failed", "name", t.Name(), "error", err)
 			}
 		}(t)
@@ -163,17 +277,393 @@ func main() {
 		"transports", len(transports),
 		"health_port", cfg.Server.HealthPort)
 
-	// Block until shutdown signal.
-	<-ctx.Done()
-	slog.Info("shutdown signal received, draining...")
+	// Block until shutdown signal, reloading the config on SIGHUP.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-hup:
+			slog.Info("SIGHUP received, reloading configuration")
+			next, nextInterp, nextNamed, err := reload(*configFile, cfg)
+			if err != nil {
+				slog.Error("config reload failed, keeping current configuration", "error", err)
+				continue
+			}
+			nextOpts, err := dispatchOptions(next, audioStore)
+			if err != nil {
+				closeComponents(nextInterp, nextNamed, nil)
+				slog.Error("config reload failed, keeping current configuration", "error", err)
+				continue
+			}
+			nextOpts.Interpreters = nextNamed
+			nextSynth, rebuilt := reloadSynthesizer(synthesizer, ttsCfg, next.TTS)
+			dispatcher.Reload(nextInterp, nextSynth, nextOpts)
+			config.SetLogLevel(next.Logging.Level)
+			config.SetLogRedact(next.Logging.Redact)
+			// In-flight dispatches may still hold the old components; the
+			// backends' Close only releases idle resources, so this is safe.
+			oldSynth := synthesizer
+			if !rebuilt {
+				oldSynth = nil
+			}
+			closeComponents(interp, named, oldSynth)
+			interp, named, synthesizer, ttsCfg = nextInterp, nextNamed, nextSynth, next.TTS
+			healthServer.SetBackends(backends(next, interp))
+			if cfg.Server.HealthChecks.Enabled {
+				setHealthChecks(healthServer, interp, synthesizer)
+			}
+			slog.Info("configuration reloaded")
+		}
+	}
+	// Restore default signal handling so a second signal stops at once.
+	cancel()
+	slog.Info("shutdown signal received, draining...",
+		"pending", dispatcher.Pending(), "grace_period", cfg.Server.ShutdownGracePeriod)
+
+	// Stop taking traffic: load balancers see /readyz fail, and new messages
+	// are rejected while in-flight ones finish.
+	healthServer.SetReady(false)
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.Server.ShutdownGracePeriod)
+	// Async messages already answered with 202 are dispatched before the
+	// dispatcher stops taking messages; new ones are refused.
+	if asyncQueue != nil {
+		if err := asyncQueue.Drain(drainCtx); err != nil {
+			slog.Warn("grace period expired, abandoning queued async dispatches")
+		}
+	}
+	if err := dispatcher.Wait(drainCtx); err != nil {
+		slog.Warn("grace period expired, abandoning in-flight dispatches", "pending", dispatcher.Pending())
+	}
+	// Results of finished dispatches may still be on their way to callbacks.
+	if asyncQueue != nil {
+		if err := asyncQueue.WaitCallbacks(drainCtx); err != nil {
+			slog.Warn("grace period expired, abandoning callback deliveries")
+		}
+	}
+	cancelDrain()
 
 	// Close all transports gracefully.
+	stopListeners()
 	for _, t := range transports {
 		if err := t.Close(); err != nil {
 			slog.Error("transport close error", "name", t.Name(), "error", err)
 		}
 	}
+	if wsSender != nil {
+		_ = wsSender.Close()
+	}
 
 	wg.Wait()
+	// The interpreters and synthesizer may have been replaced by a reload;
+	// close whichever are current.
+	closeComponents(interp, named, synthesizer)
 	slog.Info("switchyard stopped")
 }
+
+// reload loads the config file again and builds the interpreters, which can
+// be swapped at runtime. Settings that need new listeners are compared against
+// the running config and only reported; they take effect after a restart.
+func reload(configFile string, running *config.Config) (*config.Config, interpreter.Interpreter, map[string]interpreter.Interpreter, error) {
+	next, err := config.Load(configFile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, section := range running.RestartRequired(next) {
+		slog.Warn("config change requires a restart, ignoring", "section", section)
+	}
+
+	// TTS stays in its startup state (enabled or not); only its settings change.
+	next.TTS.Enabled = running.TTS.Enabled
+
+	interp, err := buildInterpreter(next.Interpreter)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	named, err := buildNamedInterpreters(next.Interpreter)
+	if err != nil {
+		closeComponents(interp, nil, nil)
+		return nil, nil, nil, err
+	}
+	return next, interp, named, nil
+}
+
+// reloadSynthesizer returns the synthesizer for the reloaded TTS settings and
+// whether it is a new one. Rebuilding empties the cache of synthesized audio,
+// so the current synthesizer is kept while its settings are unchanged.
+func reloadSynthesizer(current tts.Synthesizer, running, next config.TTSConfig) (tts.Synthesizer, bool) {
+	if reflect.DeepEqual(running, next) {
+		return current, false
+	}
+	return buildSynthesizer(next), true
+}
+
+// setHealthChecks registers dependency probes for the components that
+// support them. The interpreter is critical; TTS is not, since dispatches
+// still succeed without audio.
+func setHealthChecks(s *health.Server, interp interpreter.Interpreter, synth tts.Synthesizer) {
+	c, _ := interp.(health.Checker)
+	s.SetCheck("interpreter", c, true)
+
+	var tc health.Checker
+	if synth != nil {
+		tc, _ = synth.(health.Checker)
+	}
+	s.SetCheck("tts", tc, false)
+}
+
+// backends names the configured backends for the health server's /status.
+func backends(cfg *config.Config, interp interpreter.Interpreter) health.Backends {
+	b := health.Backends{Interpreter: interp.Name()}
+	if cfg.TTS.Enabled {
+		b.TTS = cfg.TTS.Backend
+	}
+	return b
+}
+
+// dispatchOptions builds the dispatcher options from config. It fails if a
+// command schema does not compile.
+func dispatchOptions(cfg *config.Config, audioStore *audiostore.Store) (dispatch.Options, error) {
+	schemas, err := cmdschema.New(cfg.Commands.Schemas)
+	if err != nil {
+		return dispatch.Options{}, err
+	}
+	return dispatch.Options{
+		Prompts:               cfg.Interpreter.Prompts,
+		TranscriptionPrompts:  cfg.Interpreter.TranscriptionPrompts,
+		MinConfidence:         cfg.Interpreter.MinConfidence,
+		NormalizeNumbers:      cfg.Interpreter.NormalizeNumbers,
+		Targets:               configuredTargets(cfg.Targets),
+		Routes:                routes(cfg.Routes),
+		DefaultResponseFormat: cfg.Interpreter.DefaultCommandFormat,
+		DefaultPrompt:         cfg.Interpreter.DefaultPrompt,
+		Transformers:          commandTransformers(cfg.Commands),
+		AllowedActions:        cfg.Commands.AllowedActions,
+		DeniedActions:         cfg.Commands.DeniedActions,
+		CommandSchemas:        schemas,
+		AudioStore:            audioStore,
+		AudioBaseURL:          cfg.TTS.AudioStore.BaseURL,
+		Response: dispatch.ResponseConstraints{
+			MaxWords: cfg.Interpreter.Response.MaxWords,
+			MaxChars: cfg.Interpreter.Response.MaxChars,
+			Style:    cfg.Interpreter.Response.Style,
+			Language: cfg.Interpreter.Response.Language,
+			Truncate: cfg.Interpreter.Response.Truncate,
+		},
+	}, nil
+}
+
+// closeComponents closes the default and named interpreters and an optional
+// synthesizer, logging any errors.
+func closeComponents(interp interpreter.Interpreter, named map[string]interpreter.Interpreter, synth tts.Synthesizer) {
+	if err := interp.Close(); err != nil {
+		slog.Error("interpreter close error", "error", err)
+	}
+	for name, n := range named {
+		if err := n.Close(); err != nil {
+			slog.Error("interpreter close error", "interpreter", name, "error", err)
+		}
+	}
+	if synth != nil {
+		if err := synth.Close(); err != nil {
+			slog.Error("synthesizer close error", "error", err)
+		}
+	}
+}
+
+// buildInterpreter creates the interpreter, wrapped to transcribe long audio
+// in windows if chunking is enabled.
+func buildInterpreter(cfg config.InterpreterConfig) (interpreter.Interpreter, error) {
+	interp, err := buildBackends(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Chunking.Enabled {
+		interp = chunkedinterp.New(interp, cfg.Chunking)
+	}
+	return interp, nil
+}
+
+// buildNamedInterpreters creates the named interpreters messages can select
+// instead of the default one.
+func buildNamedInterpreters(cfg config.InterpreterConfig) (map[string]interpreter.Interpreter, error) {
+	named := make(map[string]interpreter.Interpreter, len(cfg.Named))
+	for name := range cfg.Named {
+		slog.Info("building named interpreter", "interpreter", name)
+		interp, err := buildInterpreter(cfg.ForNamed(name))
+		if err != nil {
+			for _, n := range named {
+				_ = n.Close()
+			}
+			return nil, fmt.Errorf("interpreter %q: %w", name, err)
+		}
+		named[name] = interp
+	}
+	return named, nil
+}
+
+// buildBackends creates the interpreter backend(s). Transcription and
+// interpretation default to the same backend but can be split across two.
+func buildBackends(cfg config.InterpreterConfig) (interpreter.Interpreter, error) {
+	transcriptionBackend := cfg.TranscriptionBackend
+	if transcriptionBackend == "" {
+		transcriptionBackend = cfg.Backend
+	}
+	completionBackend := cfg.CompletionBackend
+	if completionBackend == "" {
+		completionBackend = cfg.Backend
+	}
+
+	if transcriptionBackend == completionBackend {
+		return newInterpreter(transcriptionBackend, cfg)
+	}
+
+	transcriber, err := newInterpreter(transcriptionBackend, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("transcription backend: %w", err)
+	}
+	completer, err := newInterpreter(completionBackend, cfg)
+	if err != nil {
+		_ = transcriber.Close()
+		return nil, fmt.Errorf("completion backend: %w", err)
+	}
+	slog.Info("using split interpreter",
+		"transcription_backend", transcriptionBackend,
+		"completion_backend", completionBackend)
+	return compositeinterp.New(transcriber, completer), nil
+}
+
+// buildSynthesizer creates the TTS backend, wrapped in a cache if enabled.
+// It returns nil when TTS is disabled or the backend is unknown.
+func buildSynthesizer(cfg config.TTSConfig) tts.Synthesizer {
+	if !cfg.Enabled {
+		slog.Info("TTS disabled")
+		return nil
+	}
+	synth, err := newSynthesizer(cfg)
+	if err != nil {
+		slog.Warn("TTS disabled", "error", err)
+		return nil
+	}
+	if cfg.Cache.Enabled {
+		synth = ttscache.New(synth, cfg.Cache.MaxEntries, cfg.Cache.MaxBytes)
+		slog.Info("TTS cache enabled", "max_entries", cfg.Cache.MaxEntries,
+			"max_bytes", cfg.Cache.MaxBytes)
+	}
+	return synth
+}
+
+// newSynthesizer constructs the TTS backend selected in config.
+func newSynthesizer(cfg config.TTSConfig) (tts.Synthesizer, error) {
+	switch cfg.Backend {
+	case "piper":
+		slog.Info("TTS enabled", "backend", "piper",
+			"endpoint", cfg.Piper.Endpoint,
+			"language_endpoints", len(cfg.Piper.Endpoints))
+		return pipertts.New(cfg.Piper), nil
+	case "openai":
+		slog.Info("TTS enabled", "backend", "openai",
+			"model", cfg.OpenAI.Model,
+			"voice", cfg.OpenAI.Voice,
+			"format", cfg.OpenAI.Format)
+		return openaitts.New(cfg.OpenAI), nil
+	case "elevenlabs":
+		if cfg.ElevenLabs.APIKey == "" {
+			return nil, fmt.Errorf("tts.elevenlabs.api_key is required")
+		}
+		slog.Info("TTS enabled", "backend", "elevenlabs",
+			"model", cfg.ElevenLabs.Model,
+			"voice", cfg.ElevenLabs.Voice,
+			"format", cfg.ElevenLabs.Format)
+		return elevenlabstts.New(cfg.ElevenLabs), nil
+	default:
+		return nil, fmt.Errorf("unknown TTS backend %q", cfg.Backend)
+	}
+}
+
+// configuredTargets converts the config file's targets for the dispatcher.
+func configuredTargets(targets map[string]config.Target) map[string]message.Target {
+	out := make(map[string]message.Target, len(targets))
+	for name, t := range targets {
+		out[name] = message.Target{
+			ServiceName:    name,
+			Endpoint:       t.Endpoint,
+			Protocol:       t.Protocol,
+			Token:          t.Token,
+			AuthHeader:     t.AuthHeader,
+			AuthScheme:     t.AuthScheme,
+			Headers:        t.Headers,
+			GRPCMode:       t.GRPCMode,
+			GRPCMethod:     t.GRPCMethod,
+			TLS:            t.TLS,
+			AllowedActions: t.AllowedActions,
+		}
+	}
+	return out
+}
+
+// commandTransformers builds the built-in command transformers enabled in
+// config: alias mapping, then default params.
+func commandTransformers(cfg config.CommandsConfig) []dispatch.Transformer {
+	var out []dispatch.Transformer
+	if len(cfg.Aliases) > 0 {
+		out = append(out, dispatch.Transformer{Name: "aliases", Transform: cmdtransform.Aliases(cfg.Aliases)})
+	}
+	if len(cfg.Defaults) > 0 {
+		out = append(out, dispatch.Transformer{Name: "defaults", Transform: cmdtransform.Defaults(cfg.Defaults)})
+	}
+	return out
+}
+
+// routes converts the config file's source routes for the dispatcher. Their
+// targets are named only; the dispatcher completes them from the configured
+// targets.
+func routes(routes map[string]config.Route) map[string]dispatch.Route {
+	out := make(map[string]dispatch.Route, len(routes))
+	for source, r := range routes {
+		targets := make([]message.Target, len(r.Targets))
+		for i, name := range r.Targets {
+			targets[i] = message.Target{ServiceName: name}
+		}
+		out[strings.ToLower(source)] = dispatch.Route{
+			Targets:        targets,
+			ResponseFormat: r.ResponseFormat,
+			Prompt:         r.Prompt,
+		}
+	}
+	return out
+}
+
+// newInterpreter constructs a single interpreter backend by name.
+func newInterpreter(backend string, cfg config.InterpreterConfig) (interpreter.Interpreter, error) {
+	switch backend {
+	case "openai":
+		slog.Info("using OpenAI interpreter",
+			"provider", cfg.OpenAI.Provider,
+			"transcription_model", cfg.OpenAI.TranscriptionModel,
+			"completion_model", cfg.OpenAI.CompletionModel)
+		return openaiinterp.New(cfg.OpenAI), nil
+	case "local":
+		slog.Info("using local interpreter",
+			"whisper", cfg.Local.WhisperEndpoint,
+			"llm", cfg.Local.LLMEndpoint)
+		return localinterp.New(cfg.Local), nil
+	case "deepgram":
+		slog.Info("using Deepgram transcriber", "model", cfg.Deepgram.Model)
+		return deepgraminterp.New(cfg.Deepgram), nil
+	case "gemini":
+		if cfg.Gemini.APIKey == "" {
+			return nil, fmt.Errorf("interpreter.gemini.api_key is required")
+		}
+		slog.Info("using Gemini interpreter", "model", cfg.Gemini.Model)
+		return geminiinterp.New(cfg.Gemini), nil
+	case "mock":
+		slog.Warn("using mock interpreter: commands come from keyword rules, not a model")
+		return mockinterp.New(cfg.Mock), nil
+	default:
+		return nil, fmt.Errorf("unknown interpreter backend %q", backend)
+	}
+}
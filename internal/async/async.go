@@ -0,0 +1,273 @@
+// Package async runs dispatches in the background and delivers their results
+// to a client-supplied callback URL.
+//
+// Transports accept a message, Submit it, and answer the sender right away.
+// A fixed pool of workers runs the dispatch. The dispatcher then hands the
+// DispatchResult to Notify, which POSTs it to the message's
+// Instruction.CallbackURL in the background, retrying transient failures.
+// Transports that dispatch synchronously (MQTT, gRPC) get the same callback.
+// Callback URLs are checked against a host allow-list so clients cannot make
+// switchyard issue requests to arbitrary internal services, and bodies are
+// signed with HMAC-SHA256 when a secret is configured.
+package async
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/hostlist"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// ErrQueueFull is returned by Submit when no more dispatches can be queued.
+var ErrQueueFull = errors.New("async queue is full")
+
+// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" on callback
+// requests when a callback secret is configured.
+const SignatureHeader = "X-Switchyard-Signature"
+
+// Queue is a bounded background dispatch queue.
+type Queue struct {
+	workers  int
+	timeout  time.Duration
+	attempts int
+	hosts    hostlist.List
+	secret   []byte
+	queue    chan *message.Message
+	client   *http.Client
+
+	mu         sync.Mutex
+	draining   bool     // Submit rejects messages once set
+	jobs       inflight // messages submitted and not yet dispatched
+	deliveries inflight // callback deliveries not yet finished
+}
+
+// New creates a queue from config. Call Run to start processing.
+func New(cfg config.AsyncConfig) *Queue {
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 4
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	return &Queue{
+		workers:  workers,
+		timeout:  timeout,
+		attempts: max(cfg.CallbackAttempts, 1),
+		hosts:    hostlist.New(cfg.CallbackHosts),
+		secret:   []byte(cfg.CallbackSecret),
+		queue:    make(chan *message.Message, max(cfg.QueueSize, 1)),
+		client: &http.Client{
+			Timeout: 30 * time.Second,
+			// Redirects could point outside the allow-list.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// CheckCallback validates a callback URL: it must be http(s) and its host
+// must match the allow-list.
+func (q *Queue) CheckCallback(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid callback url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("callback url must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("callback url has no host")
+	}
+	if !q.hosts.Allows(u) {
+		return fmt.Errorf("callback host %q is not allowed", u.Host)
+	}
+	return nil
+}
+
+// Submit queues msg for background dispatch. msg.ID is set if empty so the
+// caller can return it to the sender. Once Drain has been called it fails
+// with transport.ErrShuttingDown.
+func (q *Queue) Submit(msg *message.Message) error {
+	if msg.ID == "" {
+		msg.ID = newID()
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.draining {
+		return transport.ErrShuttingDown
+	}
+	q.jobs.add()
+	select {
+	case q.queue <- msg:
+		return nil
+	default:
+		q.jobs.done()
+		return ErrQueueFull
+	}
+}
+
+// Drain stops Submit from accepting messages and waits until the queued ones
+// have been dispatched or ctx is done. Run must keep going meanwhile, so call
+// it on shutdown before the dispatcher stops taking messages and before the
+// context passed to Run is cancelled.
+func (q *Queue) Drain(ctx context.Context) error {
+	q.mu.Lock()
+	q.draining = true
+	q.mu.Unlock()
+	return q.jobs.wait(ctx)
+}
+
+// Run processes queued messages with handler until ctx is cancelled.
+// Dispatches in flight at shutdown are cancelled.
+func (q *Queue) Run(ctx context.Context, handler transport.Handler) {
+	var wg sync.WaitGroup
+	for range q.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case msg := <-q.queue:
+					q.process(ctx, handler, msg)
+					q.jobs.done()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// process runs one dispatch. The handler (the dispatcher) notifies the
+// callback with the result, including failures.
+func (q *Queue) process(ctx context.Context, handler transport.Handler, msg *message.Message) {
+	dctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+	if _, err := handler(dctx, msg); err != nil {
+		slog.Error("async dispatch failed", "message_id", msg.ID, "error", err)
+	}
+}
+
+// Notify POSTs result to msg's callback URL in the background. Delivery is
+// detached from the dispatch context, so it outlives the request that
+// produced the result; WaitCallbacks waits for it on shutdown. Messages
+// without a callback URL are ignored.
+func (q *Queue) Notify(msg *message.Message, result *message.DispatchResult) {
+	callbackURL := msg.Instruction.CallbackURL
+	if callbackURL == "" {
+		return
+	}
+	logger := slog.With("message_id", msg.ID, "callback", callbackURL)
+
+	// Transports other than HTTP do not check the URL up front.
+	if err := q.CheckCallback(callbackURL); err != nil {
+		logger.Warn("not delivering callback", "error", err)
+		return
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("encoding callback result", "error", err)
+		return
+	}
+
+	q.deliveries.add()
+	go func() {
+		defer q.deliveries.done()
+		if err := q.deliver(context.Background(), callbackURL, payload); err != nil {
+			logger.Error("callback delivery failed", "error", err)
+			return
+		}
+		logger.Info("callback delivered")
+	}()
+}
+
+// WaitCallbacks waits until every callback handed to Notify has been
+// delivered or has given up, or until ctx is done. Call it on shutdown, after
+// the dispatcher has drained, so results are not lost when the process exits.
+func (q *Queue) WaitCallbacks(ctx context.Context) error {
+	return q.deliveries.wait(ctx)
+}
+
+// deliver POSTs payload to callbackURL, retrying network errors, 429 and 5xx
+// with exponential backoff.
+func (q *Queue) deliver(ctx context.Context, callbackURL string, payload []byte) error {
+	delay := time.Second
+	var lastErr error
+	for attempt := 1; attempt <= q.attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, time.Minute)
+		}
+
+		retry, err := q.post(ctx, callbackURL, payload)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+		slog.Debug("retrying callback", "callback", callbackURL, "attempt", attempt, "error", err)
+	}
+	return lastErr
+}
+
+// post makes one delivery attempt and reports whether a failure is retryable.
+func (q *Queue) post(ctx context.Context, callbackURL string, payload []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("creating callback request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(q.secret) > 0 {
+		req.Header.Set(SignatureHeader, sign(q.secret, payload))
+	}
+
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("posting callback: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return false, nil
+}
+
+// sign returns the SignatureHeader value for payload.
+func sign(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,130 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+func TestWaitCallbacks(t *testing.T) {
+	release := make(chan struct{})
+	bodies := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		body, _ := io.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer srv.Close()
+
+	q := New(config.AsyncConfig{CallbackHosts: []string{"127.0.0.1"}})
+	msg := &message.Message{ID: "m1", Instruction: message.Instruction{CallbackURL: srv.URL}}
+	q.Notify(msg, &message.DispatchResult{MessageID: "m1"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := q.WaitCallbacks(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitCallbacks() = %v while the delivery is blocked, want context.DeadlineExceeded", err)
+	}
+
+	close(release)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := q.WaitCallbacks(ctx); err != nil {
+		t.Fatalf("WaitCallbacks() = %v after the delivery finished", err)
+	}
+	select {
+	case body := <-bodies:
+		if !strings.Contains(body, `"message_id":"m1"`) {
+			t.Errorf("callback body = %s", body)
+		}
+	default:
+		t.Error("callback was not delivered before WaitCallbacks returned")
+	}
+}
+
+func TestWaitCallbacksIdle(t *testing.T) {
+	q := New(config.AsyncConfig{})
+	if err := q.WaitCallbacks(context.Background()); err != nil {
+		t.Errorf("WaitCallbacks() = %v with nothing to deliver", err)
+	}
+}
+
+func TestDrainDispatchesQueuedMessages(t *testing.T) {
+	q := New(config.AsyncConfig{Workers: 1, QueueSize: 4})
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled []string
+	handler := func(ctx context.Context, msg *message.Message) (*message.DispatchResult, error) {
+		<-release
+		mu.Lock()
+		handled = append(handled, msg.ID)
+		mu.Unlock()
+		return &message.DispatchResult{MessageID: msg.ID}, nil
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go q.Run(ctx, handler)
+
+	for _, id := range []string{"a", "b", "c"} {
+		if err := q.Submit(&message.Message{ID: id}); err != nil {
+			t.Fatalf("Submit(%s): %v", id, err)
+		}
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- q.Drain(context.Background()) }()
+
+	for draining := false; !draining; {
+		time.Sleep(time.Millisecond)
+		q.mu.Lock()
+		draining = q.draining
+		q.mu.Unlock()
+	}
+	// New messages are refused while the queued ones are still waiting.
+	if err := q.Submit(&message.Message{ID: "late"}); !errors.Is(err, transport.ErrShuttingDown) {
+		t.Fatalf("Submit during drain = %v, want transport.ErrShuttingDown", err)
+	}
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned %v before the queued messages ran", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Fatalf("Drain: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Drain did not return")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 3 {
+		t.Errorf("handled %v, want a, b and c", handled)
+	}
+}
+
+func TestDrainTimesOut(t *testing.T) {
+	q := New(config.AsyncConfig{Workers: 1})
+	if err := q.Submit(&message.Message{ID: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing runs the queue.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Package audio provides small helpers for working with raw PCM and WAV data.
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// EncodeWAV wraps raw little-endian PCM data in a WAV container.
+func EncodeWAV(pcm []byte, sampleRate, channels, bytesPerSample int) []byte {
+	buf := &bytes.Buffer{}
+	buf.Grow(44 + len(pcm))
+	writeWAVHeader(buf, uint32(len(pcm)), sampleRate, channels, bytesPerSample)
+	buf.Write(pcm)
+	return buf.Bytes()
+}
+
+// StreamingWAVHeader returns a WAV header for PCM whose length is not known
+// yet, to be followed by the PCM as it is produced. The RIFF and data sizes
+// are set to the maximum, which players treat as "read until the end".
+func StreamingWAVHeader(sampleRate, channels, bytesPerSample int) []byte {
+	buf := &bytes.Buffer{}
+	writeWAVHeader(buf, math.MaxUint32-36, sampleRate, channels, bytesPerSample)
+	return buf.Bytes()
+}
+
+// writeWAVHeader writes the canonical 44-byte header for dataLen bytes of PCM.
+func writeWAVHeader(buf *bytes.Buffer, dataLen uint32, sampleRate, channels, bytesPerSample int) {
+	fileLen := 36 + dataLen // 44-byte header minus 8 bytes for RIFF header = 36
+
+	// RIFF header
+	buf.WriteString("RIFF")
+	_ = binary.Write(buf, binary.LittleEndian, fileLen)
+	buf.WriteString("WAVE")
+
+	// fmt subchunk
+	buf.WriteString("fmt ")
+	_ = binary.Write(buf, binary.LittleEndian, uint32(16))         // subchunk1 size
+	_ = binary.Write(buf, binary.LittleEndian, uint16(1))          // audio format (PCM)
+	_ = binary.Write(buf, binary.LittleEndian, uint16(channels))   // channels
+	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate)) // sample rate
+	byteRate := sampleRate * channels * bytesPerSample
+	_ = binary.Write(buf, binary.LittleEndian, uint32(byteRate)) // byte rate
+	blockAlign := channels * bytesPerSample
+	_ = binary.Write(buf, binary.LittleEndian, uint16(blockAlign))       // block align
+	_ = binary.Write(buf, binary.LittleEndian, uint16(bytesPerSample*8)) // bits per sample
+
+	// data subchunk
+	buf.WriteString("data")
+	_ = binary.Write(buf, binary.LittleEndian, dataLen)
+}
+
+// PCMDuration returns the playback duration of raw PCM data.
+func PCMDuration(pcmBytes, sampleRate, channels, bytesPerSample int) time.Duration {
+	bytesPerSecond := sampleRate * channels * bytesPerSample
+	if bytesPerSecond <= 0 {
+		return 0
+	}
+	return time.Duration(int64(pcmBytes) * int64(time.Second) / int64(bytesPerSecond))
+}
+
+// ScalePCM16 multiplies 16-bit little-endian PCM samples by gain in place,
+// clipping at the sample limits. A gain of 1 leaves the audio unchanged.
+func ScalePCM16(pcm []byte, gain float64) {
+	if gain == 1 {
+		return
+	}
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) * gain
+		v = max(min(v, math.MaxInt16), math.MinInt16)
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(v)))
+	}
+}
+
+// RMS16 returns the root-mean-square level of 16-bit little-endian PCM as a
+// fraction of full scale: 0 for digital silence, about 0.7 for a full-scale
+// sine wave.
+func RMS16(pcm []byte) float64 {
+	n := len(pcm) / 2
+	if n == 0 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i+1 < len(pcm); i += 2 {
+		v := float64(int16(binary.LittleEndian.Uint16(pcm[i:]))) / 32768
+		sum += v * v
+	}
+	return math.Sqrt(sum / float64(n))
+}
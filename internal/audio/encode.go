@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Output formats for synthesized audio.
+const (
+	FormatWAV  = "wav"
+	FormatMP3  = "mp3"
+	FormatOpus = "opus" // Opus in an Ogg container
+)
+
+// ContentType returns the MIME type for an output format, or "" if the
+// format is not supported.
+func ContentType(format string) string {
+	switch format {
+	case FormatWAV, "":
+		return "audio/wav"
+	case FormatMP3:
+		return "audio/mpeg"
+	case FormatOpus:
+		return "audio/ogg"
+	}
+	return ""
+}
+
+// ffmpegArgs holds the encoder arguments for each compressed format.
+var ffmpegArgs = map[string][]string{
+	FormatMP3:  {"-c:a", "libmp3lame", "-b:a", "64k", "-f", "mp3"},
+	FormatOpus: {"-c:a", "libopus", "-b:a", "32k", "-f", "ogg"},
+}
+
+// EncodeWithFFmpeg converts WAV audio to format by piping it through ffmpeg.
+// ffmpegPath defaults to "ffmpeg" on $PATH.
+func EncodeWithFFmpeg(ctx context.Context, ffmpegPath string, wav []byte, format string) ([]byte, error) {
+	codec, ok := ffmpegArgs[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported audio format %q", format)
+	}
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-f", "wav", "-i", "pipe:0"}, codec...)
+	args = append(args, "pipe:1")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	cmd.Stdin = bytes.NewReader(wav)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("encoding %s with ffmpeg: %w: %s", format, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// DecodeWithFFmpeg converts audio in any format ffmpeg reads (WebM/Opus,
+// Ogg, MP3, ...) to 16-bit mono PCM WAV at sampleRate.
+// ffmpegPath defaults to "ffmpeg" on $PATH.
+func DecodeWithFFmpeg(ctx context.Context, ffmpegPath string, data []byte, sampleRate int) ([]byte, error) {
+	if ffmpegPath == "" {
+		ffmpegPath = "ffmpeg"
+	}
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", "pipe:0",
+		"-ac", "1", "-ar", strconv.Itoa(sampleRate), "-c:a", "pcm_s16le", "-f", "wav", "pipe:1"}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("decoding audio with ffmpeg: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
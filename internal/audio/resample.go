@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Sample rates accepted by ResamplePCM16.
+const (
+	MinSampleRate = 8000
+	MaxSampleRate = 48000
+)
+
+// resampleZeroCrossings is the number of sinc zero crossings on each side of
+// the filter kernel. More gives a sharper cutoff at a higher cost.
+const resampleZeroCrossings = 16
+
+// ResamplePCM16 converts interleaved 16-bit little-endian PCM from one sample
+// rate to another with a Blackman-windowed sinc filter. When downsampling,
+// the filter cutoff is lowered to the new Nyquist frequency so content above
+// it is removed rather than aliased. The input is returned as is when the
+// rates match.
+func ResamplePCM16(pcm []byte, channels, from, to int) ([]byte, error) {
+	if from == to {
+		return pcm, nil
+	}
+	if channels <= 0 || from <= 0 {
+		return nil, fmt.Errorf("invalid PCM format: %d channels at %d Hz", channels, from)
+	}
+	if to < MinSampleRate || to > MaxSampleRate {
+		return nil, fmt.Errorf("sample rate %d Hz out of range (%d-%d)", to, MinSampleRate, MaxSampleRate)
+	}
+
+	frames := len(pcm) / (2 * channels)
+	outFrames := int(int64(frames) * int64(to) / int64(from))
+	out := make([]byte, outFrames*2*channels)
+
+	step := float64(from) / float64(to)         // input frames per output frame
+	cutoff := min(1, float64(to)/float64(from)) // fraction of the input Nyquist frequency kept
+	halfWidth := resampleZeroCrossings / cutoff // kernel half-width in input frames
+
+	sample := func(frame, ch int) float64 {
+		i := (frame*channels + ch) * 2
+		return float64(int16(binary.LittleEndian.Uint16(pcm[i:])))
+	}
+
+	for n := range outFrames {
+		t := float64(n) * step
+		first := max(int(math.Ceil(t-halfWidth)), 0)
+		last := min(int(math.Floor(t+halfWidth)), frames-1)
+		for ch := range channels {
+			var acc, norm float64
+			for k := first; k <= last; k++ {
+				x := t - float64(k)
+				w := cutoff * sinc(cutoff*x) * blackman(x/halfWidth)
+				acc += sample(k, ch) * w
+				norm += w
+			}
+			v := 0.0
+			if norm != 0 {
+				v = acc / norm // unity gain, also near the edges
+			}
+			v = max(min(math.Round(v), math.MaxInt16), math.MinInt16)
+			binary.LittleEndian.PutUint16(out[(n*channels+ch)*2:], uint16(int16(v)))
+		}
+	}
+	return out, nil
+}
+
+// sinc is the normalized sinc function sin(πx)/(πx).
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// blackman is the Blackman window over [-1, 1], zero outside it.
+func blackman(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	p := math.Pi * (x + 1) // 0..2π across the window
+	return 0.42 - 0.5*math.Cos(p) + 0.08*math.Cos(2*p)
+}
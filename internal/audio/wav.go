@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedEncoding is wrapped by ParseWAV errors for well-formed WAV
+// files whose samples are not integer PCM (e.g., IEEE float or A-law).
+var ErrUnsupportedEncoding = errors.New("unsupported wav encoding")
+
+// WAVFormat describes the PCM data in a WAV file.
+type WAVFormat struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// Duration returns the playing time of pcm in this format.
+func (f WAVFormat) Duration(pcm []byte) time.Duration {
+	return PCMDuration(len(pcm), f.SampleRate, f.Channels, f.BitsPerSample/8)
+}
+
+// BlockAlign returns the size in bytes of one frame (one sample per channel).
+func (f WAVFormat) BlockAlign() int {
+	return f.Channels * f.BitsPerSample / 8
+}
+
+// ParseWAV validates a RIFF/WAVE container holding uncompressed PCM and
+// returns its format and the PCM payload (a sub-slice of data). Chunks other
+// than "fmt " and "data" are skipped.
+func ParseWAV(data []byte) (WAVFormat, []byte, error) {
+	var f WAVFormat
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return f, nil, errors.New("not a RIFF/WAVE file")
+	}
+
+	haveFmt := false
+	for rest := data[12:]; len(rest) >= 8; {
+		id := string(rest[0:4])
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		body := rest[8:]
+
+		if id == "data" {
+			if !haveFmt {
+				return f, nil, errors.New("wav data chunk before fmt chunk")
+			}
+			// Streaming writers often leave the size unset; take what's there.
+			if size > len(body) || size == 0 {
+				size = len(body)
+			}
+			pcm := body[:size]
+			pcm = pcm[:len(pcm)-len(pcm)%f.BlockAlign()]
+			return f, pcm, nil
+		}
+		if size > len(body) {
+			return f, nil, fmt.Errorf("wav %q chunk is truncated", id)
+		}
+		if id == "fmt " {
+			if size < 16 {
+				return f, nil, errors.New("wav fmt chunk is too short")
+			}
+			audioFormat := binary.LittleEndian.Uint16(body[0:2])
+			// 1 = PCM, 0xFFFE = WAVE_FORMAT_EXTENSIBLE (PCM sub-format assumed).
+			if audioFormat != 1 && audioFormat != 0xFFFE {
+				return f, nil, fmt.Errorf("%w %d (only PCM is supported)", ErrUnsupportedEncoding, audioFormat)
+			}
+			f.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			f.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			f.BitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+			if f.Channels == 0 || f.SampleRate == 0 || f.BitsPerSample == 0 || f.BitsPerSample%8 != 0 {
+				return f, nil, fmt.Errorf("invalid wav format: %d Hz, %d channels, %d bits", f.SampleRate, f.Channels, f.BitsPerSample)
+			}
+			haveFmt = true
+		}
+		// Chunks are padded to an even size.
+		size += size % 2
+		if size > len(body) {
+			size = len(body)
+		}
+		rest = body[size:]
+	}
+	return f, nil, errors.New("wav file has no data chunk")
+}
@@ -0,0 +1,98 @@
+// Package audiofetch downloads input audio that a sender passes by URL
+// (e.g., a pre-signed S3 link) instead of embedding it in the message.
+//
+// Fetching a client-supplied URL lets clients make switchyard issue requests
+// on their behalf, so only http(s) URLs whose host is on the configured
+// allow-list are fetched, redirects are not followed, and both the download
+// time and size are capped.
+package audiofetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/hostlist"
+)
+
+// MaxBytes is the default size cap, matching the HTTP transport's limit on
+// inline audio uploads.
+const MaxBytes = 25 << 20
+
+// Fetcher downloads audio from allow-listed URLs.
+type Fetcher struct {
+	hosts    hostlist.List
+	maxBytes int64
+	client   *http.Client
+}
+
+// New creates a Fetcher from config.
+func New(cfg config.AudioFetchConfig) *Fetcher {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	maxBytes := int64(cfg.MaxBytes)
+	if maxBytes <= 0 {
+		maxBytes = MaxBytes
+	}
+	return &Fetcher{
+		hosts:    hostlist.New(cfg.AllowedHosts),
+		maxBytes: maxBytes,
+		client: &http.Client{
+			Timeout: timeout,
+			// Redirects could point outside the allow-list.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Fetch downloads the audio at rawURL and returns it with the media type
+// reported by the server ("" if none).
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) ([]byte, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid audio url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, "", fmt.Errorf("audio url must be http or https, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("audio url has no host")
+	}
+	if !f.hosts.Allows(u) {
+		return nil, "", fmt.Errorf("audio host %q is not allowed", u.Host)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating audio request: %w", err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching audio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetching audio: status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, "", fmt.Errorf("audio is %d bytes, limit is %d", resp.ContentLength, f.maxBytes)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("reading audio: %w", err)
+	}
+	if int64(len(data)) > f.maxBytes {
+		return nil, "", fmt.Errorf("audio exceeds %d bytes", f.maxBytes)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return data, contentType, nil
+}
@@ -0,0 +1,126 @@
+// Package audiostore holds synthesized response audio in memory for a short
+// time so clients can fetch it by URL instead of receiving it inline.
+//
+// Entries expire after a TTL. The store is bounded by entry count and total
+// bytes; when full, the oldest entries are evicted first.
+package audiostore
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Entry is a stored audio clip.
+type Entry struct {
+	Data        []byte
+	ContentType string
+	Expires     time.Time
+}
+
+// Store is a bounded, expiring in-memory audio store. It is safe for
+// concurrent use.
+type Store struct {
+	ttl        time.Duration
+	maxEntries int
+	maxBytes   int
+
+	mu    sync.Mutex
+	order *list.List               // oldest at front; values are string keys
+	items map[string]*list.Element // key -> element in order
+	data  map[string]*Entry
+	bytes int
+}
+
+// New creates a store. Zero limits fall back to defaults (5 minutes,
+// 256 entries, 64 MB).
+func New(ttl time.Duration, maxEntries, maxBytes int) *Store {
+	if ttl <= 0 {
+		ttl = 5 * time.Minute
+	}
+	if maxEntries <= 0 {
+		maxEntries = 256
+	}
+	if maxBytes <= 0 {
+		maxBytes = 64 << 20
+	}
+	return &Store{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		order:      list.New(),
+		items:      make(map[string]*list.Element),
+		data:       make(map[string]*Entry),
+	}
+}
+
+// Put stores audio and returns the random, unguessable key to fetch it with.
+// Clips larger than the store's byte limit are not stored and Put returns "".
+func (s *Store) Put(data []byte, contentType string) string {
+	if len(data) > s.maxBytes {
+		return ""
+	}
+	key := newKey()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+	for s.order.Len() >= s.maxEntries || s.bytes+len(data) > s.maxBytes {
+		s.removeLocked(s.order.Front())
+	}
+
+	s.items[key] = s.order.PushBack(key)
+	s.data[key] = &Entry{Data: data, ContentType: contentType, Expires: time.Now().Add(s.ttl)}
+	s.bytes += len(data)
+	return key
+}
+
+// Get returns the entry for key if it exists and has not expired.
+func (s *Store) Get(key string) (*Entry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.data[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.Expires) {
+		s.removeLocked(s.items[key])
+		return nil, false
+	}
+	return e, true
+}
+
+// TTL returns how long entries are kept.
+func (s *Store) TTL() time.Duration { return s.ttl }
+
+// expireLocked drops expired entries from the front of the queue. Entries are
+// inserted in expiry order, so it stops at the first live one.
+func (s *Store) expireLocked(now time.Time) {
+	for el := s.order.Front(); el != nil; el = s.order.Front() {
+		if !now.After(s.data[el.Value.(string)].Expires) {
+			return
+		}
+		s.removeLocked(el)
+	}
+}
+
+func (s *Store) removeLocked(el *list.Element) {
+	if el == nil {
+		return
+	}
+	key := el.Value.(string)
+	s.order.Remove(el)
+	s.bytes -= len(s.data[key].Data)
+	delete(s.items, key)
+	delete(s.data, key)
+}
+
+func newKey() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
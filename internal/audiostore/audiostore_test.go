@@ -0,0 +1,77 @@
+package audiostore
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPutGet(t *testing.T) {
+	s := New(time.Minute, 0, 0)
+	key := s.Put([]byte("RIFFdata"), "audio/wav")
+	if len(key) != 32 {
+		t.Fatalf("key = %q, want 32 hex characters", key)
+	}
+	if other := s.Put([]byte("RIFFdata"), "audio/wav"); other == key {
+		t.Fatal("two clips got the same key")
+	}
+
+	e, ok := s.Get(key)
+	if !ok {
+		t.Fatal("stored clip not found")
+	}
+	if !bytes.Equal(e.Data, []byte("RIFFdata")) || e.ContentType != "audio/wav" {
+		t.Errorf("entry = %q %q", e.Data, e.ContentType)
+	}
+	if until := time.Until(e.Expires); until <= 0 || until > time.Minute {
+		t.Errorf("expires in %s, want within the TTL", until)
+	}
+
+	if _, ok := s.Get("unknown"); ok {
+		t.Error("unknown key found")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := New(10*time.Millisecond, 0, 0)
+	key := s.Put([]byte("a"), "audio/wav")
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := s.Get(key); ok {
+		t.Fatal("clip still served after its TTL")
+	}
+	if len(s.data) != 0 || s.bytes != 0 {
+		t.Errorf("expired clip kept: %d entries, %d bytes", len(s.data), s.bytes)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int
+		sizes      []int
+		kept       []bool // per clip, in insertion order
+	}{
+		{name: "entry cap", maxEntries: 2, sizes: []int{1, 1, 1}, kept: []bool{false, true, true}},
+		{name: "byte cap", maxBytes: 10, sizes: []int{4, 4, 4}, kept: []bool{false, true, true}},
+		{name: "large clip evicts several", maxBytes: 10, sizes: []int{3, 3, 9}, kept: []bool{false, false, true}},
+		{name: "clip over the byte cap", maxBytes: 10, sizes: []int{4, 11}, kept: []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(time.Minute, tt.maxEntries, tt.maxBytes)
+			keys := make([]string, len(tt.sizes))
+			for i, n := range tt.sizes {
+				keys[i] = s.Put(make([]byte, n), "audio/wav")
+			}
+			for i, key := range keys {
+				if _, ok := s.Get(key); ok != tt.kept[i] {
+					t.Errorf("clip %d kept = %v, want %v", i, ok, tt.kept[i])
+				}
+			}
+			if tt.maxBytes > 0 && s.bytes > tt.maxBytes {
+				t.Errorf("store holds %d bytes, cap %d", s.bytes, tt.maxBytes)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+// Package audit keeps a durable record of every dispatch, separate from the
+// debug log: one JSON object per dispatch with the input, transcript,
+// commands, routing outcome and timing.
+//
+// Input audio is never written; records carry its size and SHA-256 so a
+// recording can still be matched against the log.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Record is one audited dispatch.
+type Record struct {
+	Time        time.Time                 `json:"time"`
+	MessageID   string                    `json:"message_id"`
+	TraceID     string                    `json:"trace_id,omitempty"`
+	Source      string                    `json:"source,omitempty"`
+	SessionID   string                    `json:"session_id,omitempty"`
+	Text        string                    `json:"text,omitempty"` // Text input, when the message had no audio
+	AudioURL    string                    `json:"audio_url,omitempty"`
+	AudioBytes  int                       `json:"audio_bytes,omitempty"`
+	AudioSHA256 string                    `json:"audio_sha256,omitempty"`
+	ContentType string                    `json:"content_type,omitempty"`
+	Transcript  string                    `json:"transcript,omitempty"`
+	Language    string                    `json:"language,omitempty"`
+	Confidence  float64                   `json:"confidence,omitempty"`
+	Commands    []message.Command         `json:"commands,omitempty"`
+	Rejected    []message.RejectedCommand `json:"rejected,omitempty"`
+	Targets     []string                  `json:"targets,omitempty"` // Targets named by the instruction
+	RoutedTo    []string                  `json:"routed_to,omitempty"`
+	DryRun      bool                      `json:"dry_run,omitempty"`
+	Usage       message.Usage             `json:"usage"`
+	Error       string                    `json:"error,omitempty"`
+	ErrorStage  string                    `json:"error_stage,omitempty"`
+	ErrorCode   string                    `json:"error_code,omitempty"`
+	DurationMs  int64                     `json:"duration_ms"`
+}
+
+// NewRecord builds the record of a dispatch that started at start. result
+// may be nil if the dispatch could not run, in which case err is recorded.
+func NewRecord(msg *message.Message, result *message.DispatchResult, err error, start time.Time) *Record {
+	rec := &Record{
+		Time:        start.UTC(),
+		MessageID:   msg.ID,
+		TraceID:     msg.TraceID,
+		Source:      msg.Source,
+		SessionID:   msg.SessionID,
+		AudioURL:    msg.AudioURL,
+		ContentType: msg.ContentType,
+		DurationMs:  time.Since(start).Milliseconds(),
+	}
+	if msg.HasAudio() {
+		rec.AudioBytes = len(msg.Audio)
+		rec.AudioSHA256 = msg.AudioSHA256()
+	} else {
+		rec.Text = msg.Text
+	}
+	for _, t := range msg.Instruction.Targets {
+		rec.Targets = append(rec.Targets, t.ServiceName)
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if result == nil {
+		return rec
+	}
+	if result.MessageID != "" {
+		rec.MessageID = result.MessageID
+	}
+	rec.Transcript = result.Transcript
+	rec.Language = result.Language
+	rec.Confidence = result.Confidence
+	rec.Commands = result.Commands
+	rec.Rejected = result.Rejected
+	rec.RoutedTo = result.RoutedTo
+	rec.DryRun = result.DryRun
+	rec.Usage = result.Usage
+	if result.Error != "" {
+		rec.Error = result.Error
+	}
+	rec.ErrorStage = result.ErrorStage
+	rec.ErrorCode = result.ErrorCode
+	return rec
+}
+
+// File appends records as JSON lines to a file, rotating it by size.
+type File struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFile opens (or creates) the audit file from config.
+func NewFile(cfg config.AuditConfig) (*File, error) {
+	a := &File{
+		path:       cfg.Path,
+		maxBytes:   cfg.MaxBytes,
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := a.open(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Write appends rec as one line, rotating first if the line would push the
+// file past its size limit.
+func (a *File) Write(rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("encoding audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return fmt.Errorf("audit file %s is closed", a.path)
+	}
+	if a.maxBytes > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+		if err := a.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := a.f.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit file.
+func (a *File) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.f == nil {
+		return nil
+	}
+	err := a.f.Close()
+	a.f = nil
+	return err
+}
+
+func (a *File) open() error {
+	f, err := os.OpenFile(a.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	a.f, a.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N (dropping the oldest), moves the current
+// file to path.1 and starts a new one. With no backups kept, the current
+// file is simply truncated.
+func (a *File) rotate() error {
+	if err := a.f.Close(); err != nil {
+		return fmt.Errorf("closing audit file: %w", err)
+	}
+	a.f = nil
+	if a.maxBackups > 0 {
+		for i := a.maxBackups - 1; i >= 1; i-- {
+			_ = os.Rename(fmt.Sprintf("%s.%d", a.path, i), fmt.Sprintf("%s.%d", a.path, i+1))
+		}
+		if err := os.Rename(a.path, a.path+".1"); err != nil {
+			// Keep appending to the current file rather than losing records.
+			return errors.Join(fmt.Errorf("rotating audit file: %w", err), a.open())
+		}
+	} else if err := os.Truncate(a.path, 0); err != nil {
+		return errors.Join(fmt.Errorf("truncating audit file: %w", err), a.open())
+	}
+	return a.open()
+}
@@ -0,0 +1,107 @@
+// Package cmdschema validates interpreted command params against JSON
+// Schemas configured per action, so malformed commands (e.g. set_temperature
+// without a numeric value) are rejected instead of routed.
+package cmdschema
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// key identifies a schema by response format and action.
+type key struct {
+	format string
+	action string
+}
+
+// Validator holds compiled schemas. A nil *Validator accepts everything.
+type Validator struct {
+	schemas map[key]*jsonschema.Schema
+}
+
+// New compiles the configured schemas. It returns nil if there are none.
+func New(entries []config.CommandSchema) (*Validator, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	c := jsonschema.NewCompiler()
+	v := &Validator{schemas: make(map[key]*jsonschema.Schema, len(entries))}
+	for i, e := range entries {
+		if e.Action == "" {
+			return nil, fmt.Errorf("commands.schemas[%d]: action is required", i)
+		}
+		raw := e.Schema
+		if e.SchemaFile != "" {
+			if raw != "" {
+				return nil, fmt.Errorf("commands.schemas[%d]: set schema or schema_file, not both", i)
+			}
+			data, err := os.ReadFile(e.SchemaFile)
+			if err != nil {
+				return nil, fmt.Errorf("commands.schemas[%d]: reading schema file: %w", i, err)
+			}
+			raw = string(data)
+		}
+		doc, err := jsonschema.UnmarshalJSON(strings.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("commands.schemas[%d] (%s): parsing schema: %w", i, e.Action, err)
+		}
+		loc := fmt.Sprintf("mem:///commands/%d.json", i)
+		if err := c.AddResource(loc, doc); err != nil {
+			return nil, fmt.Errorf("commands.schemas[%d] (%s): %w", i, e.Action, err)
+		}
+		sch, err := c.Compile(loc)
+		if err != nil {
+			return nil, fmt.Errorf("commands.schemas[%d] (%s): compiling schema: %w", i, e.Action, err)
+		}
+		k := key{format: e.Format, action: e.Action}
+		if _, dup := v.schemas[k]; dup {
+			return nil, fmt.Errorf("commands.schemas[%d]: duplicate schema for action %q and format %q", i, e.Action, e.Format)
+		}
+		v.schemas[k] = sch
+	}
+	return v, nil
+}
+
+// Validate checks cmd.Params against the schema for cmd.Action under format,
+// falling back to the format-less schema. Commands without a schema pass.
+func (v *Validator) Validate(format string, cmd message.Command) error {
+	if v == nil {
+		return nil
+	}
+	sch, ok := v.schemas[key{format: format, action: cmd.Action}]
+	if !ok {
+		if sch, ok = v.schemas[key{action: cmd.Action}]; !ok {
+			return nil
+		}
+	}
+
+	// Params are decoded from JSON, so they are already in the form the
+	// validator expects; absent params are validated as an empty object.
+	var params any = map[string]any{}
+	if cmd.Params != nil {
+		params = cmd.Params
+	}
+	if err := sch.Validate(params); err != nil {
+		return fmt.Errorf("params do not match schema: %s", summarize(err))
+	}
+	return nil
+}
+
+// summarize flattens the library's multi-line validation error, dropping
+// the header line that only names the in-memory schema location.
+func summarize(err error) string {
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) > 1 {
+		lines = lines[1:]
+	}
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(strings.TrimSpace(l), "- ")
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,99 @@
+// Package cmdtransform provides built-in command transformers that rewrite
+// interpreted commands before routing, e.g. so "lounge" reaches Home
+// Assistant as "living_room" without changing the prompt.
+package cmdtransform
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Func rewrites the commands of one dispatch. It matches
+// dispatch.CommandTransformer.
+type Func = func(ctx context.Context, cmds []message.Command) ([]message.Command, error)
+
+// Aliases returns a transformer that replaces string param values found in
+// aliases (matched case-insensitively) with their canonical value. Values
+// nested in objects and arrays are replaced too.
+func Aliases(aliases map[string]string) Func {
+	lower := make(map[string]string, len(aliases))
+	for from, to := range aliases {
+		lower[strings.ToLower(from)] = to
+	}
+	return func(_ context.Context, cmds []message.Command) ([]message.Command, error) {
+		for i := range cmds {
+			for key, value := range cmds[i].Params {
+				cmds[i].Params[key] = replaceAliases(value, lower)
+			}
+		}
+		return cmds, nil
+	}
+}
+
+// replaceAliases returns v with aliased strings replaced.
+func replaceAliases(v any, aliases map[string]string) any {
+	switch v := v.(type) {
+	case string:
+		if to, ok := aliases[strings.ToLower(v)]; ok {
+			return to
+		}
+	case []any:
+		for i := range v {
+			v[i] = replaceAliases(v[i], aliases)
+		}
+	case map[string]any:
+		for key := range v {
+			v[key] = replaceAliases(v[key], aliases)
+		}
+	}
+	return v
+}
+
+// Defaults returns a transformer that adds params missing from commands,
+// per action name (matched case-insensitively). Params the interpreter set
+// are kept.
+func Defaults(defaults map[string]map[string]any) Func {
+	lower := make(map[string]map[string]any, len(defaults))
+	for action, params := range defaults {
+		lower[strings.ToLower(action)] = params
+	}
+	return func(_ context.Context, cmds []message.Command) ([]message.Command, error) {
+		for i := range cmds {
+			params, ok := lower[strings.ToLower(cmds[i].Action)]
+			if !ok {
+				continue
+			}
+			if cmds[i].Params == nil {
+				cmds[i].Params = make(map[string]any, len(params))
+			}
+			for key, value := range params {
+				if _, set := cmds[i].Params[key]; !set {
+					cmds[i].Params[key] = deepCopy(value)
+				}
+			}
+		}
+		return cmds, nil
+	}
+}
+
+// deepCopy copies nested objects and arrays, so commands never share them
+// with the configured defaults.
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i := range v {
+			out[i] = deepCopy(v[i])
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = deepCopy(value)
+		}
+		return out
+	}
+	return v
+}
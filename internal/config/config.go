@@ -2,10 +2,16 @@
 package config
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"path"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,53 +22,323 @@ type Config struct {
 	Transports  TransportsConfig  `mapstructure:"transports"`
 	Interpreter InterpreterConfig `mapstructure:"interpreter"`
 	TTS         TTSConfig         `mapstructure:"tts"`
+	Async       AsyncConfig       `mapstructure:"async"`
+	AudioFetch  AudioFetchConfig  `mapstructure:"audio_fetch"`
+	Dedup       DedupConfig       `mapstructure:"dedup"`
+	Sessions    SessionsConfig    `mapstructure:"sessions"`
+	Commands    CommandsConfig    `mapstructure:"commands"`
+	Audit       AuditConfig       `mapstructure:"audit"`
 	Targets     map[string]Target `mapstructure:"targets"`
+	Routes      map[string]Route  `mapstructure:"routes"`
 	Logging     LoggingConfig     `mapstructure:"logging"`
 }
 
 // ServerConfig holds the health check server settings.
 type ServerConfig struct {
-	HealthPort int `mapstructure:"health_port"`
+	HealthPort   int                `mapstructure:"health_port"`
+	HealthChecks HealthChecksConfig `mapstructure:"health_checks"`
+
+	MaxConcurrentDispatches int    `mapstructure:"max_concurrent_dispatches"` // Messages processed at once (0 = unlimited)
+	OnBusy                  string `mapstructure:"on_busy"`                   // At the limit: "wait" for a free slot or "reject" (HTTP 429)
+
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+	ShutdownGracePeriod time.Duration `mapstructure:"shutdown_grace_period"` // On SIGTERM, how long in-flight dispatches may finish before transports close
+
+	MaxAudioBytes    int           `mapstructure:"max_audio_bytes"`    // Reject larger input audio before transcription (0 = no limit)
+	MaxAudioDuration time.Duration `mapstructure:"max_audio_duration"` // Reject longer PCM WAV input before transcription (0 = no limit)
+	SilenceThreshold float64       `mapstructure:"silence_threshold"`  // Reject 16-bit PCM WAV input quieter than this RMS level (fraction of full scale, 0 = off)
+
+	DebugEcho bool `mapstructure:"debug_echo"` // Echo failed messages (audio as size and hash) and their resolved instruction in the result
+}
+
+// RateLimitConfig throttles each message source with a token bucket.
+// Over-limit messages are rejected (HTTP 429, gRPC RESOURCE_EXHAUSTED).
+type RateLimitConfig struct {
+	Enabled           bool    `mapstructure:"enabled"`
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"` // Steady rate allowed per source
+	Burst             int     `mapstructure:"burst"`               // Messages a source may send at once above the steady rate
+}
+
+// HealthChecksConfig configures active dependency probes. When enabled, the
+// interpreter and TTS endpoints are dialed periodically and /readyz reports
+// not ready while the interpreter is unreachable.
+type HealthChecksConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Interval time.Duration `mapstructure:"interval"` // Time between probes
+	Timeout  time.Duration `mapstructure:"timeout"`  // Max time per probe
 }
 
 // TransportsConfig holds the configuration for each transport layer.
 type TransportsConfig struct {
-	GRPC GRPCConfig `mapstructure:"grpc"`
-	HTTP HTTPConfig `mapstructure:"http"`
-	MQTT MQTTConfig `mapstructure:"mqtt"`
+	GRPC   GRPCConfig   `mapstructure:"grpc"`
+	HTTP   HTTPConfig   `mapstructure:"http"`
+	MQTT   MQTTConfig   `mapstructure:"mqtt"`
+	Stdout StdoutConfig `mapstructure:"stdout"`
+	File   FileConfig   `mapstructure:"file"`
+	Exec   ExecConfig   `mapstructure:"exec"`
+	WS     WSConfig     `mapstructure:"ws"`
 }
 
 // GRPCConfig configures the gRPC transport.
 type GRPCConfig struct {
-	Enabled bool `mapstructure:"enabled"`
-	Port    int  `mapstructure:"port"`
+	Enabled       bool `mapstructure:"enabled"`
+	Port          int  `mapstructure:"port"`
+	MaxAudioBytes int  `mapstructure:"max_audio_bytes"` // Max audio per Dispatch request or StreamDispatch stream
+
+	TLS       GRPCTLSConfig       `mapstructure:"tls"`
+	ClientTLS GRPCClientTLSConfig `mapstructure:"client_tls"`
+}
+
+// GRPCTLSConfig enables TLS on the gRPC server. The server stays plaintext
+// when CertFile is empty. With ClientCAFile set, clients must present a
+// certificate signed by that CA (mutual TLS), and the certificate's identity
+// becomes the message source when the client doesn't set one.
+type GRPCTLSConfig struct {
+	CertFile     string `mapstructure:"cert_file"`      // PEM server certificate (chain)
+	KeyFile      string `mapstructure:"key_file"`       // PEM private key for CertFile
+	ClientCAFile string `mapstructure:"client_ca_file"` // PEM CA bundle that client certificates must chain to (optional)
+}
+
+// GRPCClientTLSConfig configures TLS for sends to gRPC targets that set
+// tls. Without it, the system roots verify the target's certificate.
+type GRPCClientTLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`              // PEM CA bundle that target certificates must chain to (optional)
+	CertFile           string `mapstructure:"cert_file"`            // PEM client certificate, for targets that require one (optional)
+	KeyFile            string `mapstructure:"key_file"`             // PEM private key for CertFile
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // Do not verify target certificates (testing only)
 }
 
 // HTTPConfig configures the HTTP/WebSocket transport.
 type HTTPConfig struct {
-	Enabled bool `mapstructure:"enabled"`
-	Port    int  `mapstructure:"port"`
+	Enabled      bool            `mapstructure:"enabled"`
+	Port         int             `mapstructure:"port"`
+	MaxBodyBytes int64           `mapstructure:"max_body_bytes"` // Larger request bodies are rejected with 413
+	Auth         HTTPAuthConfig  `mapstructure:"auth"`
+	WebSocket    WebSocketConfig `mapstructure:"websocket"`
+}
+
+// HTTPAuthConfig configures bearer-token authentication for the HTTP transport.
+// Auth is enabled when at least one token is set; requests to /dispatch,
+// /dispatch/stream and /ws must then carry "Authorization: Bearer <token>".
+type HTTPAuthConfig struct {
+	Token  string   `mapstructure:"token"`  // Single shared secret (supports "${ENV_VAR}")
+	Tokens []string `mapstructure:"tokens"` // Additional accepted tokens, e.g. one per client (supports "${ENV_VAR}")
+}
+
+// AcceptedTokens returns every configured token, skipping empty entries.
+func (a HTTPAuthConfig) AcceptedTokens() []string {
+	var tokens []string
+	for _, t := range append([]string{a.Token}, a.Tokens...) {
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// WebSocketConfig configures the streaming audio endpoint (GET /ws).
+//
+// Clients stream 16-bit mono PCM as binary frames. An utterance ends when the
+// client sends an "end" frame or no audio arrives for SilenceTimeout. The
+// buffer caps (MaxBufferBytes, MaxDuration) bound memory regardless of the
+// silence window: whichever cap is hit first ends the utterance early.
+type WebSocketConfig struct {
+	SampleRate     int           `mapstructure:"sample_rate"`      // Sample rate of incoming PCM in Hz
+	SilenceTimeout time.Duration `mapstructure:"silence_timeout"`  // End of speech after this long without audio
+	MaxBufferBytes int           `mapstructure:"max_buffer_bytes"` // Max buffered PCM bytes per utterance (0 = no cap)
+	MaxDuration    time.Duration `mapstructure:"max_duration"`     // Max buffered audio duration per utterance (0 = no cap)
+	OnOverflow     string        `mapstructure:"on_overflow"`      // "finalize" (dispatch what was buffered) or "error" (close with an error frame)
 }
 
 // MQTTConfig configures the MQTT transport.
 type MQTTConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Broker   string `mapstructure:"broker"`    // tcp://, mqtt://, ws:// URL of an MQTT v5 broker; ssl://, tls://, mqtts:// or wss:// for TLS
+	Topic    string `mapstructure:"topic"`     // Subscription receiving JSON messages (wildcards allowed)
+	ClientID string `mapstructure:"client_id"` // MQTT client identifier
+	QoS      int    `mapstructure:"qos"`       // QoS of the subscription, replies and target publishes (0-2)
+	Username string `mapstructure:"username"`  // Broker login (empty = anonymous)
+	Password string `mapstructure:"password"`  // Supports "${ENV_VAR}"
+
+	TLS   MQTTTLSConfig   `mapstructure:"tls"`
+	Reply MQTTReplyConfig `mapstructure:"reply"`
+}
+
+// MQTTTLSConfig configures TLS for ssl://, tls://, mqtts:// and wss://
+// brokers. Without it, the system roots verify the broker's certificate.
+type MQTTTLSConfig struct {
+	CAFile             string `mapstructure:"ca_file"`              // PEM CA bundle that the broker certificate must chain to (optional)
+	CertFile           string `mapstructure:"cert_file"`            // PEM client certificate, for brokers that require one (optional)
+	KeyFile            string `mapstructure:"key_file"`             // PEM private key for CertFile
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"` // Do not verify the broker certificate (testing only)
+}
+
+// MQTTReplyConfig selects where dispatch results are published. Replies
+// carry the request's MQTT v5 Correlation Data, so senders can match them.
+type MQTTReplyConfig struct {
+	Mode          string `mapstructure:"mode"`           // "response_topic" (the request's v5 Response Topic, else TopicTemplate) or "template"
+	TopicTemplate string `mapstructure:"topic_template"` // e.g. "switchyard-replies/{source}"; placeholders {topic}, {source}, {message_id}; empty = no reply
+}
+
+// StdoutConfig configures the stdout sink transport.
+// Targets with protocol "stdout" have their payload written as one line of JSON.
+type StdoutConfig struct {
 	Enabled bool   `mapstructure:"enabled"`
-	Broker  string `mapstructure:"broker"`
-	Topic   string `mapstructure:"topic"`
+	Path    string `mapstructure:"path"` // Append to this file instead of stdout (optional)
+}
+
+// writesStdout reports whether the stdout transport is enabled and writes
+// to the process's stdout.
+func (c StdoutConfig) writesStdout() bool {
+	return c.Enabled && c.Path == ""
+}
+
+// FileConfig configures the file sink. Targets with protocol "file" have
+// their payload appended as one line of JSON to the file their endpoint
+// names, relative to Dir.
+type FileConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Dir     string `mapstructure:"dir"` // Directory holding the sink files
+}
+
+// ExecConfig configures the exec sink transport.
+//
+// Exec runs a local command with the payload on stdin. Because this executes
+// programs on the host, it is disabled by default and only commands listed in
+// Commands can be run: a target selects one by name via its endpoint, and the
+// argv is taken verbatim from config (no shell, no templating).
+type ExecConfig struct {
+	Enabled  bool                `mapstructure:"enabled"`
+	Commands map[string][]string `mapstructure:"commands"` // Allow-list: name -> argv
+	Timeout  time.Duration       `mapstructure:"timeout"`  // Per-invocation timeout
+}
+
+// WSConfig configures the WebSocket sender. Targets with protocol "ws" get
+// their payload as a text frame over a connection kept open per endpoint and credentials
+// (ws:// or wss://).
+type WSConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	QueueSize    int           `mapstructure:"queue_size"`    // Payloads waiting per endpoint before sends fail
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`  // Connect and handshake timeout
+	WriteTimeout time.Duration `mapstructure:"write_timeout"` // Per-frame write timeout
 }
 
 // InterpreterConfig selects and configures the LLM backend.
+//
+// Backend selects one backend for both transcription and interpretation.
+// TranscriptionBackend and CompletionBackend, when set, override it for their
+// respective stage so the two can be served by different backends.
 type InterpreterConfig struct {
-	Backend string       `mapstructure:"backend"` // "openai" or "local"
-	OpenAI  OpenAIConfig `mapstructure:"openai"`
-	Local   LocalConfig  `mapstructure:"local"`
+	Backend              string            `mapstructure:"backend"`                // "openai", "local" or "gemini" ("deepgram" transcribes only)
+	TranscriptionBackend string            `mapstructure:"transcription_backend"`  // Overrides Backend for transcription (optional)
+	CompletionBackend    string            `mapstructure:"completion_backend"`     // Overrides Backend for interpretation (optional)
+	Prompts              map[string]string `mapstructure:"prompts"`                // ISO-639-1 code (or "default") -> extra prompt context
+	TranscriptionPrompts map[string]string `mapstructure:"transcription_prompts"`  // ISO-639-1 code (or "default") -> vocabulary hint for speech-to-text
+	NormalizeNumbers     []string          `mapstructure:"normalize_numbers"`      // Languages whose spelled-out numbers/units are rewritten as digits/symbols
+	MinConfidence        float64           `mapstructure:"min_confidence"`         // Fail transcripts scored below this (0-1); 0 = off
+	DefaultCommandFormat string            `mapstructure:"default_command_format"` // response_format for instructions (and routes) that set none
+	DefaultPrompt        string            `mapstructure:"default_prompt"`         // prompt for instructions (and routes) that set none
+	Response             ResponseConfig    `mapstructure:"response"`
+	Chunking             ChunkingConfig    `mapstructure:"chunking"`
+	OpenAI               OpenAIConfig      `mapstructure:"openai"`
+	Local                LocalConfig       `mapstructure:"local"`
+	Deepgram             DeepgramConfig    `mapstructure:"deepgram"`
+	Gemini               GeminiConfig      `mapstructure:"gemini"`
+	Mock                 MockConfig        `mapstructure:"mock"`
+
+	// Named are alternative interpreters that messages select by name with
+	// Instruction.Interpreter. They share the backend settings above.
+	Named map[string]NamedInterpreterConfig `mapstructure:"named"`
+}
+
+// ResponseConfig constrains the spoken confirmation (response_text) of
+// instructions that set no constraints of their own.
+type ResponseConfig struct {
+	MaxWords int    `mapstructure:"max_words"` // Ask for at most this many words (0 = no limit)
+	MaxChars int    `mapstructure:"max_chars"` // Ask for at most this many characters (0 = no limit)
+	Style    string `mapstructure:"style"`     // Style hint, e.g. "terse" or "friendly"
+	Language string `mapstructure:"language"`  // "match" (the user's language), "auto" (model's choice) or an ISO-639-1 code
+	Truncate bool   `mapstructure:"truncate"`  // Cut longer responses to the limits before TTS
+}
+
+// NamedInterpreterConfig selects the backends of a named interpreter, like
+// the fields of the same name in InterpreterConfig.
+type NamedInterpreterConfig struct {
+	Backend              string `mapstructure:"backend"`
+	TranscriptionBackend string `mapstructure:"transcription_backend"`
+	CompletionBackend    string `mapstructure:"completion_backend"`
+}
+
+// ForNamed returns the interpreter config of the named interpreter name:
+// this config with its backends replaced.
+func (c InterpreterConfig) ForNamed(name string) InterpreterConfig {
+	n := c.Named[name]
+	c.Backend = n.Backend
+	c.TranscriptionBackend = n.TranscriptionBackend
+	c.CompletionBackend = n.CompletionBackend
+	c.Named = nil
+	return c
+}
+
+// MockConfig configures the mock backend, which answers without any model
+// server for CI and demos. Every audio message transcribes to Transcript,
+// and text is interpreted by keyword rules.
+type MockConfig struct {
+	Transcript string     `mapstructure:"transcript"` // Returned for any audio
+	Language   string     `mapstructure:"language"`   // Reported for audio when the instruction sets no language
+	Rules      []MockRule `mapstructure:"rules"`      // Keyword rules; empty = built-in on/off rules
+}
+
+// MockRule turns text containing one of its keywords into a command. Every
+// matching rule contributes a command, in order.
+type MockRule struct {
+	Keywords     []string       `mapstructure:"keywords"`      // Words or phrases, matched case-insensitively on word boundaries
+	Action       string         `mapstructure:"action"`        // Command action (e.g., "turn_on")
+	Params       map[string]any `mapstructure:"params"`        // Command params (optional)
+	ResponseText string         `mapstructure:"response_text"` // Spoken confirmation (optional)
+}
+
+// ChunkingConfig splits long WAV recordings into overlapping windows that are
+// transcribed one after another, for audio beyond a backend's upload limit.
+// Audio that fits in a single window is sent as is.
+type ChunkingConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	MaxBytes int           `mapstructure:"max_bytes"` // Largest WAV sent per transcription request
+	Window   time.Duration `mapstructure:"window"`    // Longest audio sent per transcription request
+	Overlap  time.Duration `mapstructure:"overlap"`   // Audio repeated at each window boundary so words aren't cut
 }
 
 // OpenAIConfig holds OpenAI API settings.
 type OpenAIConfig struct {
-	APIKey             string `mapstructure:"api_key"`
-	TranscriptionModel string `mapstructure:"transcription_model"`
-	CompletionModel    string `mapstructure:"completion_model"`
+	APIKey               string            `mapstructure:"api_key"`
+	Provider             string            `mapstructure:"provider"` // "openai" (default) or "groq": sets the base URL, default models and API quirks
+	BaseURL              string            `mapstructure:"base_url"` // Replaces https://api.openai.com for proxies and compatible gateways
+	TranscriptionModel   string            `mapstructure:"transcription_model"`
+	CompletionModel      string            `mapstructure:"completion_model"`
+	TranscriptionTimeout time.Duration     `mapstructure:"transcription_timeout"` // Per-request timeout for transcription calls
+	CompletionTimeout    time.Duration     `mapstructure:"completion_timeout"`    // Per-request timeout for chat calls
+	MaxAttempts          int               `mapstructure:"max_attempts"`          // Attempts per call, retrying 429/5xx/connection errors (1 = no retry)
+	RateLimits           map[string]int    `mapstructure:"rate_limits"`           // Model -> max requests per minute; requests queue instead of exceeding it
+	ToolCalling          string            `mapstructure:"tool_calling"`          // "auto" (tools for models that support them), "on" or "off" (json_object)
+	Temperature          float64           `mapstructure:"temperature"`           // Sampling temperature for interpretation (0-2); instructions may override it
+	Seed                 *int              `mapstructure:"seed"`                  // Sent with chat requests for best-effort reproducible output (optional)
+	Azure                AzureOpenAIConfig `mapstructure:"azure"`                 // Use Azure OpenAI instead of api.openai.com
+}
+
+// providerModels holds the default models of each interpreter.openai.provider.
+var providerModels = map[string]struct{ transcription, completion string }{
+	"openai": {"gpt-4o-transcribe", "gpt-4o"},
+	"groq":   {"whisper-large-v3-turbo", "llama-3.3-70b-versatile"},
+}
+
+// AzureOpenAIConfig points the openai backend at an Azure OpenAI resource.
+// When Endpoint is set, requests go to the resource's deployments with an
+// "api-key" header, and transcription_model and completion_model name
+// deployments rather than models.
+type AzureOpenAIConfig struct {
+	Endpoint   string `mapstructure:"endpoint"`    // https://{resource}.openai.azure.com (empty = stock OpenAI)
+	APIVersion string `mapstructure:"api_version"` // api-version query parameter (default "2024-10-21")
 }
 
 // LocalConfig holds self-hosted LLM settings.
@@ -72,21 +348,133 @@ type LocalConfig struct {
 	LLMEndpoint     string `mapstructure:"llm_endpoint"`
 	LLMModel        string `mapstructure:"llm_model"` // Ollama model name (e.g., "llama3.2:1b")
 	VADFilter       bool   `mapstructure:"vad_filter"`
-	Language        string `mapstructure:"language"` // ISO-639-1 default language (e.g., "en", "fr")
+	Language        string `mapstructure:"language"`    // ISO-639-1 default language (e.g., "en", "fr")
+	Transcode       bool   `mapstructure:"transcode"`   // Convert non-WAV input (WebM/Opus, Ogg, MP3) to 16 kHz mono WAV with ffmpeg before upload
+	FFmpegPath      string `mapstructure:"ffmpeg_path"` // ffmpeg binary used by Transcode (default "ffmpeg" on $PATH)
+
+	TranscriptionTimeout time.Duration `mapstructure:"transcription_timeout"` // Per-request timeout for whisper calls
+	CompletionTimeout    time.Duration `mapstructure:"completion_timeout"`    // Per-request timeout for LLM calls
+	MaxAttempts          int           `mapstructure:"max_attempts"`          // Attempts per call, retrying 429/5xx/connection errors (1 = no retry)
+	Temperature          float64       `mapstructure:"temperature"`           // Sampling temperature for interpretation (0-2); instructions may override it
+}
+
+// DeepgramConfig holds Deepgram speech-to-text settings. Deepgram only
+// transcribes; select it with transcription_backend.
+type DeepgramConfig struct {
+	APIKey               string        `mapstructure:"api_key"`
+	Endpoint             string        `mapstructure:"endpoint"`              // Pre-recorded audio endpoint (default https://api.deepgram.com/v1/listen)
+	Model                string        `mapstructure:"model"`                 // e.g., "nova-3"
+	TranscriptionTimeout time.Duration `mapstructure:"transcription_timeout"` // Per-request timeout
+	MaxAttempts          int           `mapstructure:"max_attempts"`          // Attempts per call, retrying 429/5xx/connection errors (1 = no retry)
+}
+
+// GeminiConfig holds Google Gemini settings. One multimodal model both
+// transcribes (audio sent inline) and interprets.
+type GeminiConfig struct {
+	APIKey               string        `mapstructure:"api_key"`
+	Endpoint             string        `mapstructure:"endpoint"`              // API base URL (default https://generativelanguage.googleapis.com)
+	Model                string        `mapstructure:"model"`                 // e.g., "gemini-2.5-flash"; instructions may override it for interpretation
+	TranscriptionTimeout time.Duration `mapstructure:"transcription_timeout"` // Per-request timeout for transcription
+	CompletionTimeout    time.Duration `mapstructure:"completion_timeout"`    // Per-request timeout for interpretation
+	MaxAttempts          int           `mapstructure:"max_attempts"`          // Attempts per call, retrying 429/5xx/connection errors (1 = no retry)
+	Temperature          float64       `mapstructure:"temperature"`           // Sampling temperature for interpretation (0-2); instructions may override it
 }
 
 // Target defines a downstream service in the config file.
+//
+// Instruction targets whose service_name matches a configured target inherit
+// its endpoint and protocol when they omit them, and its token and headers
+// when they use the configured endpoint.
 type Target struct {
-	Endpoint string `mapstructure:"endpoint"`
-	Protocol string `mapstructure:"protocol"`
-	Token    string `mapstructure:"token"`
+	Endpoint   string `mapstructure:"endpoint"`
+	Protocol   string `mapstructure:"protocol"`
+	Token      string `mapstructure:"token"`
+	AuthHeader string `mapstructure:"auth_header"` // Header carrying Token (default "Authorization")
+	AuthScheme string `mapstructure:"auth_scheme"` // Token prefix (default "Bearer" for the Authorization header, none otherwise)
+
+	Headers map[string]string `mapstructure:"headers"` // Extra request headers for http targets (values support "${ENV_VAR}")
+
+	GRPCMode   string `mapstructure:"grpc_mode"`   // gRPC send mode: "native" (default), "bytes" or "metadata"
+	GRPCMethod string `mapstructure:"grpc_method"` // Full method name for the "bytes" and "metadata" modes
+	TLS        bool   `mapstructure:"tls"`         // Dial this gRPC target over TLS (see transports.grpc.client_tls)
+
+	AllowedActions []string `mapstructure:"allowed_actions"` // Actions this target accepts; others are dropped for it (empty = all)
+}
+
+// Route sets instruction defaults for messages from one source, keyed by
+// source name ("default" for all other sources). Each applies only where the
+// caller's instruction leaves the field empty.
+type Route struct {
+	Targets        []string `mapstructure:"targets"`         // Configured target names, used when the instruction has no targets
+	ResponseFormat string   `mapstructure:"response_format"` // Used when the instruction has none
+	Prompt         string   `mapstructure:"prompt"`          // Used when the instruction has none
+}
+
+// AuditConfig configures the audit trail: one JSON record per dispatch,
+// written to a size-rotated file and/or sent to a configured target.
+// Input audio is recorded only as a byte count and SHA-256.
+type AuditConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Path       string `mapstructure:"path"`        // JSON-lines file (empty = no file)
+	MaxBytes   int64  `mapstructure:"max_bytes"`   // Rotate when the file would exceed this size (0 = never)
+	MaxBackups int    `mapstructure:"max_backups"` // Rotated files kept as path.1 … path.N (0 = truncate instead)
+	Target     string `mapstructure:"target"`      // Name of a configured target that also receives each record
+}
+
+// CommandsConfig filters interpreted commands by action before routing.
+// Entries are action names and may use path.Match wildcards ("light.*").
+// Dropped commands are reported in DispatchResult.Rejected.
+type CommandsConfig struct {
+	AllowedActions []string        `mapstructure:"allowed_actions"` // If set, only these actions are routed
+	DeniedActions  []string        `mapstructure:"denied_actions"`  // Never routed, even if allowed
+	Schemas        []CommandSchema `mapstructure:"schemas"`         // JSON Schemas for command params
+
+	// Aliases and Defaults rewrite commands before they are filtered and
+	// routed: aliased string param values (matched case-insensitively) are
+	// replaced, then default params are added per action where missing.
+	Aliases  map[string]string         `mapstructure:"aliases"`  // e.g., lounge: living_room
+	Defaults map[string]map[string]any `mapstructure:"defaults"` // Action -> params added when the command lacks them
+}
+
+// CommandSchema is a JSON Schema that the params of one action must match.
+// A schema for the instruction's response_format takes precedence over one
+// without a format. Commands whose action has no schema are not validated.
+type CommandSchema struct {
+	Action     string `mapstructure:"action"`      // Exact action name
+	Format     string `mapstructure:"format"`      // Instruction response_format it applies to (empty = any)
+	Schema     string `mapstructure:"schema"`      // Inline JSON Schema document...
+	SchemaFile string `mapstructure:"schema_file"` // ...or a path to one
 }
 
 // TTSConfig selects and configures the text-to-speech backend.
 type TTSConfig struct {
-	Enabled bool        `mapstructure:"enabled"`
-	Backend string      `mapstructure:"backend"` // "piper"
-	Piper   PiperConfig `mapstructure:"piper"`
+	Enabled    bool             `mapstructure:"enabled"`
+	Backend    string           `mapstructure:"backend"`  // "piper", "openai" or "elevenlabs"
+	Delivery   string           `mapstructure:"delivery"` // "inline" (base64 in the result) or "url" (fetch from GET /audio/{id})
+	AudioStore AudioStoreConfig `mapstructure:"audio_store"`
+	Piper      PiperConfig      `mapstructure:"piper"`
+	OpenAI     OpenAITTSConfig  `mapstructure:"openai"`
+	ElevenLabs ElevenLabsConfig `mapstructure:"elevenlabs"`
+	Cache      TTSCacheConfig   `mapstructure:"cache"`
+}
+
+// TTSCacheConfig configures the LRU cache of synthesized audio. Identical
+// responses (same text, language, voice, format and prosody) are served from
+// memory instead of being synthesized again.
+type TTSCacheConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MaxEntries int  `mapstructure:"max_entries"` // Max cached clips
+	MaxBytes   int  `mapstructure:"max_bytes"`   // Max total cached audio bytes
+}
+
+// AudioStoreConfig bounds the in-memory store that holds response audio when
+// TTS delivery is "url". Entries are served by the HTTP transport until they
+// expire or are evicted to make room.
+type AudioStoreConfig struct {
+	TTL        time.Duration `mapstructure:"ttl"`         // How long audio stays fetchable
+	MaxEntries int           `mapstructure:"max_entries"` // Max stored clips
+	MaxBytes   int           `mapstructure:"max_bytes"`   // Max total stored bytes
+	BaseURL    string        `mapstructure:"base_url"`    // Prefix for ResponseAudioURL (e.g., "https://switchyard.local:8080"); empty = relative path
 }
 
 // PiperConfig holds Piper TTS settings (Wyoming protocol).
@@ -99,12 +487,99 @@ type PiperConfig struct {
 	Endpoint  string            `mapstructure:"endpoint"`  // Default Wyoming TCP endpoint (host:port)
 	Endpoints map[string]string `mapstructure:"endpoints"` // ISO-639-1 language code -> Wyoming TCP endpoint
 	Voices    map[string]string `mapstructure:"voices"`    // ISO-639-1 language code -> Piper voice model name
+
+	// Fallbacks lists, per language, the languages whose voices to try when
+	// it has none (e.g., nb: [sv, da]). English is tried last unless
+	// EnglishFallback is false, in which case synthesis fails instead.
+	Fallbacks       map[string][]string `mapstructure:"fallbacks"`
+	EnglishFallback bool                `mapstructure:"english_fallback"`
+
+	MaxIdleConns int           `mapstructure:"max_idle_conns"` // Idle connections kept per endpoint (0 = dial per request)
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`   // Discard idle connections older than this
+	MaxAttempts  int           `mapstructure:"max_attempts"`   // Connection attempts per synthesis, retrying unreachable servers (1 = no retry)
+	RetryDelay   time.Duration `mapstructure:"retry_delay"`    // Backoff before the first retry; doubles each attempt
+
+	FFmpegPath string `mapstructure:"ffmpeg_path"` // ffmpeg binary used to encode MP3/Opus output (default "ffmpeg" on $PATH)
+}
+
+// AsyncConfig configures asynchronous dispatch. When enabled, clients can set a
+// callback URL on the instruction and the result is POSTed to it once the
+// dispatch completes. HTTP requests with a callback return 202 immediately;
+// other transports (MQTT, gRPC, WebSocket) dispatch as usual and also notify
+// the callback.
+type AsyncConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Workers          int           `mapstructure:"workers"`           // Concurrent background dispatches
+	QueueSize        int           `mapstructure:"queue_size"`        // Pending dispatches before requests are rejected with 503
+	Timeout          time.Duration `mapstructure:"timeout"`           // Max time for one background dispatch
+	CallbackAttempts int           `mapstructure:"callback_attempts"` // Delivery attempts per callback (retries 5xx/429/network errors)
+	CallbackHosts    []string      `mapstructure:"callback_hosts"`    // Allowed callback hosts ("host", "host:port" or "*.domain"); empty rejects all
+	CallbackSecret   string        `mapstructure:"callback_secret"`   // HMAC-SHA256 key for the X-Switchyard-Signature header; empty = unsigned
+}
+
+// AudioFetchConfig controls input audio passed by URL (Message.AudioURL)
+// instead of inline bytes. Switchyard downloads the URL itself, so only hosts
+// on AllowedHosts are fetched to keep clients from reaching internal services.
+type AudioFetchConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	AllowedHosts []string      `mapstructure:"allowed_hosts"` // Allowed hosts ("host", "host:port" or "*.domain"); empty rejects all
+	Timeout      time.Duration `mapstructure:"timeout"`       // Max time to download one file
+	MaxBytes     int           `mapstructure:"max_bytes"`     // Max audio size
+}
+
+// DedupConfig makes dispatch idempotent per source and message ID. A message
+// whose ID was dispatched for the same source within Window is answered with
+// the earlier result instead of being processed (and sent to targets) again,
+// e.g. after an MQTT client re-delivers it on reconnect. Messages without an ID are always processed.
+type DedupConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Window     time.Duration `mapstructure:"window"`      // How long a message ID is remembered
+	MaxEntries int           `mapstructure:"max_entries"` // Max remembered IDs; the oldest are forgotten first
+}
+
+// SessionsConfig enables multi-turn conversations. Messages that share a
+// session_id have their recent turns passed to the interpreter, so a
+// follow-up can refer to an earlier command.
+type SessionsConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	MaxTurns int           `mapstructure:"max_turns"` // Turns remembered per session
+	TTL      time.Duration `mapstructure:"ttl"`       // A session is forgotten after this long without activity
+}
+
+// OpenAITTSConfig holds settings for the OpenAI speech API, or any server
+// that implements POST /v1/audio/speech.
+type OpenAITTSConfig struct {
+	APIKey   string            `mapstructure:"api_key"`  // Defaults to interpreter.openai.api_key
+	Endpoint string            `mapstructure:"endpoint"` // Speech endpoint URL
+	Model    string            `mapstructure:"model"`    // e.g., "tts-1", "gpt-4o-mini-tts"
+	Voice    string            `mapstructure:"voice"`    // Default voice for all languages
+	Voices   map[string]string `mapstructure:"voices"`   // ISO-639-1 language code -> voice override
+	Format   string            `mapstructure:"format"`   // Default output format: "wav", "mp3" or "opus"
+	Timeout  time.Duration     `mapstructure:"timeout"`  // Per-request timeout
+}
+
+// ElevenLabsConfig holds ElevenLabs TTS settings.
+type ElevenLabsConfig struct {
+	APIKey     string            `mapstructure:"api_key"`
+	Endpoint   string            `mapstructure:"endpoint"`    // API base URL
+	Model      string            `mapstructure:"model"`       // model_id, e.g. "eleven_multilingual_v2"
+	Voice      string            `mapstructure:"voice"`       // Default voice ID for all languages
+	Voices     map[string]string `mapstructure:"voices"`      // ISO-639-1 language code -> voice ID override
+	Format     string            `mapstructure:"format"`      // Default output format: "mp3", "wav" or "opus"
+	Timeout    time.Duration     `mapstructure:"timeout"`     // Per-request timeout
+	FFmpegPath string            `mapstructure:"ffmpeg_path"` // ffmpeg binary used to encode Opus output
 }
 
 // LoggingConfig holds structured logging settings.
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`  // debug, info, warn, error
 	Format string `mapstructure:"format"` // json, text
+	Redact bool   `mapstructure:"redact"` // Log transcripts and prompts as their length only
+
+	// Output is "stdout" or "stderr". It defaults to stdout, or to stderr
+	// when the stdout transport writes payloads to stdout, so log lines and
+	// payloads never share a stream.
+	Output string `mapstructure:"output"`
 }
 
 // Load reads the configuration from file, environment variables, and defaults.
@@ -115,25 +590,132 @@ func Load(configFile string) (*Config, error) {
 
 	// Defaults
 	v.SetDefault("server.health_port", 8081)
+	v.SetDefault("server.health_checks.enabled", false)
+	v.SetDefault("server.health_checks.interval", "30s")
+	v.SetDefault("server.health_checks.timeout", "5s")
+	v.SetDefault("server.max_concurrent_dispatches", 0)
+	v.SetDefault("server.on_busy", "wait")
+	v.SetDefault("server.shutdown_grace_period", "30s")
+	v.SetDefault("server.rate_limit.enabled", false)
+	v.SetDefault("server.rate_limit.requests_per_second", 1)
+	v.SetDefault("server.rate_limit.burst", 5)
+	v.SetDefault("server.max_audio_bytes", 0)
+	v.SetDefault("server.max_audio_duration", "0s")
+	v.SetDefault("server.silence_threshold", 0.001)
+	v.SetDefault("server.debug_echo", false)
 	v.SetDefault("transports.grpc.enabled", true)
 	v.SetDefault("transports.grpc.port", 50051)
+	v.SetDefault("transports.grpc.max_audio_bytes", 25<<20)
 	v.SetDefault("transports.http.enabled", true)
 	v.SetDefault("transports.http.port", 8080)
+	v.SetDefault("transports.http.max_body_bytes", 25<<20)
+	v.SetDefault("transports.http.auth.token", "")
+	v.SetDefault("transports.http.websocket.sample_rate", 16000)
+	v.SetDefault("transports.http.websocket.silence_timeout", "1s")
+	v.SetDefault("transports.http.websocket.max_buffer_bytes", 10<<20)
+	v.SetDefault("transports.http.websocket.max_duration", "60s")
+	v.SetDefault("transports.http.websocket.on_overflow", "finalize")
 	v.SetDefault("transports.mqtt.enabled", false)
 	v.SetDefault("transports.mqtt.broker", "tcp://localhost:1883")
 	v.SetDefault("transports.mqtt.topic", "switchyard/#")
+	v.SetDefault("transports.mqtt.client_id", "switchyard")
+	v.SetDefault("transports.mqtt.qos", 1)
+	v.SetDefault("transports.mqtt.reply.mode", "response_topic")
+	v.SetDefault("transports.mqtt.reply.topic_template", "")
+	v.SetDefault("transports.stdout.enabled", false)
+	v.SetDefault("audit.enabled", false)
+	v.SetDefault("audit.max_bytes", 100<<20)
+	v.SetDefault("audit.max_backups", 5)
+	v.SetDefault("transports.file.enabled", false)
+	v.SetDefault("transports.file.dir", ".")
+	v.SetDefault("transports.exec.enabled", false)
+	v.SetDefault("transports.exec.timeout", "10s")
+	v.SetDefault("transports.ws.enabled", false)
+	v.SetDefault("transports.ws.queue_size", 64)
+	v.SetDefault("transports.ws.dial_timeout", "10s")
+	v.SetDefault("transports.ws.write_timeout", "10s")
 	v.SetDefault("interpreter.backend", "openai")
-	v.SetDefault("interpreter.openai.transcription_model", "gpt-4o-transcribe")
-	v.SetDefault("interpreter.openai.completion_model", "gpt-4o")
+	v.SetDefault("interpreter.response.truncate", false)
+	v.SetDefault("interpreter.response.language", "match")
+	v.SetDefault("interpreter.chunking.enabled", false)
+	v.SetDefault("interpreter.chunking.max_bytes", 24<<20)
+	v.SetDefault("interpreter.chunking.window", "10m")
+	v.SetDefault("interpreter.chunking.overlap", "5s")
+	v.SetDefault("interpreter.openai.provider", "openai")
+	// The default models depend on the provider and are filled in after
+	// unmarshalling; the empty defaults keep the keys settable from env.
+	v.SetDefault("interpreter.openai.transcription_model", "")
+	v.SetDefault("interpreter.openai.completion_model", "")
+	v.SetDefault("interpreter.openai.transcription_timeout", "60s")
+	v.SetDefault("interpreter.openai.completion_timeout", "30s")
+	v.SetDefault("interpreter.openai.max_attempts", 3)
+	v.SetDefault("interpreter.openai.tool_calling", "auto")
+	v.SetDefault("interpreter.openai.temperature", 0.2)
+	v.SetDefault("interpreter.openai.azure.api_version", "2024-10-21")
 	v.SetDefault("interpreter.local.whisper_endpoint", "http://localhost:8000/v1/audio/transcriptions")
 	v.SetDefault("interpreter.local.whisper_type", "openai")
+	v.SetDefault("interpreter.mock.transcript", "turn on the lights")
+	v.SetDefault("interpreter.mock.language", "en")
 	v.SetDefault("interpreter.local.llm_endpoint", "http://localhost:11434/api/generate")
 	v.SetDefault("interpreter.local.llm_model", "llama3")
 	v.SetDefault("interpreter.local.vad_filter", false)
 	v.SetDefault("interpreter.local.language", "")
+	v.SetDefault("interpreter.local.transcode", false)
+	v.SetDefault("interpreter.local.ffmpeg_path", "ffmpeg")
+	v.SetDefault("interpreter.local.transcription_timeout", "60s")
+	v.SetDefault("interpreter.local.completion_timeout", "30s")
+	v.SetDefault("interpreter.local.max_attempts", 3)
+	v.SetDefault("interpreter.local.temperature", 0.2)
+	v.SetDefault("interpreter.deepgram.endpoint", "https://api.deepgram.com/v1/listen")
+	v.SetDefault("interpreter.deepgram.model", "nova-3")
+	v.SetDefault("interpreter.deepgram.transcription_timeout", "60s")
+	v.SetDefault("interpreter.deepgram.max_attempts", 3)
+	v.SetDefault("interpreter.gemini.endpoint", "https://generativelanguage.googleapis.com")
+	v.SetDefault("interpreter.gemini.model", "gemini-2.5-flash")
+	v.SetDefault("interpreter.gemini.transcription_timeout", "60s")
+	v.SetDefault("interpreter.gemini.completion_timeout", "30s")
+	v.SetDefault("interpreter.gemini.max_attempts", 3)
+	v.SetDefault("interpreter.gemini.temperature", 0.2)
 	v.SetDefault("tts.enabled", false)
 	v.SetDefault("tts.backend", "piper")
+	v.SetDefault("tts.delivery", "inline")
+	v.SetDefault("tts.cache.enabled", false)
+	v.SetDefault("tts.cache.max_entries", 128)
+	v.SetDefault("tts.cache.max_bytes", 32<<20)
+	v.SetDefault("tts.audio_store.ttl", "5m")
+	v.SetDefault("tts.audio_store.max_entries", 256)
+	v.SetDefault("tts.audio_store.max_bytes", 64<<20)
 	v.SetDefault("tts.piper.endpoint", "localhost:10200")
+	v.SetDefault("tts.piper.english_fallback", true)
+	v.SetDefault("tts.piper.max_idle_conns", 2)
+	v.SetDefault("tts.piper.idle_timeout", "30s")
+	v.SetDefault("tts.piper.max_attempts", 3)
+	v.SetDefault("tts.piper.retry_delay", "250ms")
+	v.SetDefault("tts.openai.endpoint", "https://api.openai.com/v1/audio/speech")
+	v.SetDefault("tts.openai.model", "tts-1")
+	v.SetDefault("tts.openai.voice", "alloy")
+	v.SetDefault("tts.openai.format", "wav")
+	v.SetDefault("tts.openai.timeout", "30s")
+	v.SetDefault("tts.elevenlabs.endpoint", "https://api.elevenlabs.io")
+	v.SetDefault("tts.elevenlabs.model", "eleven_multilingual_v2")
+	v.SetDefault("tts.elevenlabs.voice", "21m00Tcm4TlvDq8ikWAM")
+	v.SetDefault("tts.elevenlabs.format", "mp3")
+	v.SetDefault("tts.elevenlabs.timeout", "30s")
+	v.SetDefault("tts.elevenlabs.ffmpeg_path", "ffmpeg")
+	v.SetDefault("async.enabled", false)
+	v.SetDefault("async.workers", 4)
+	v.SetDefault("async.queue_size", 64)
+	v.SetDefault("async.timeout", "5m")
+	v.SetDefault("async.callback_attempts", 5)
+	v.SetDefault("audio_fetch.enabled", false)
+	v.SetDefault("audio_fetch.timeout", "30s")
+	v.SetDefault("audio_fetch.max_bytes", 25<<20)
+	v.SetDefault("dedup.enabled", false)
+	v.SetDefault("dedup.window", "10m")
+	v.SetDefault("dedup.max_entries", 1024)
+	v.SetDefault("sessions.enabled", false)
+	v.SetDefault("sessions.max_turns", 5)
+	v.SetDefault("sessions.ttl", "10m")
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
 
@@ -167,50 +749,332 @@ func Load(configFile string) (*Config, error) {
 	if err := v.Unmarshal(&cfg); err != nil {
 		return nil, fmt.Errorf("unmarshalling config: %w", err)
 	}
+	if cfg.Logging.Output == "" {
+		cfg.Logging.Output = "stdout"
+		if cfg.Transports.Stdout.writesStdout() {
+			cfg.Logging.Output = "stderr"
+		}
+	}
+	if m, ok := providerModels[cfg.Interpreter.OpenAI.Provider]; ok {
+		cfg.Interpreter.OpenAI.TranscriptionModel = cmp.Or(cfg.Interpreter.OpenAI.TranscriptionModel, m.transcription)
+		cfg.Interpreter.OpenAI.CompletionModel = cmp.Or(cfg.Interpreter.OpenAI.CompletionModel, m.completion)
+	}
 
-	// Resolve env var references in sensitive fields (e.g., "${OPENAI_API_KEY}")
-	cfg.Interpreter.OpenAI.APIKey = resolveEnvRef(cfg.Interpreter.OpenAI.APIKey)
+	// Resolve env var and secret file references in sensitive fields
+	// (e.g., "${OPENAI_API_KEY}", "${file:/run/secrets/openai_key}").
+	var errs []error
+	resolve := func(field string, val *string) {
+		resolved, err := resolveEnvRef(*val)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+			return
+		}
+		*val = resolved
+		registerSecret(resolved)
+	}
+	resolve("interpreter.openai.api_key", &cfg.Interpreter.OpenAI.APIKey)
+	resolve("interpreter.deepgram.api_key", &cfg.Interpreter.Deepgram.APIKey)
+	resolve("interpreter.gemini.api_key", &cfg.Interpreter.Gemini.APIKey)
+	resolve("tts.openai.api_key", &cfg.TTS.OpenAI.APIKey)
+	resolve("tts.elevenlabs.api_key", &cfg.TTS.ElevenLabs.APIKey)
+	if cfg.TTS.OpenAI.APIKey == "" {
+		cfg.TTS.OpenAI.APIKey = cfg.Interpreter.OpenAI.APIKey
+	}
+	resolve("transports.http.auth.token", &cfg.Transports.HTTP.Auth.Token)
+	for i := range cfg.Transports.HTTP.Auth.Tokens {
+		resolve(fmt.Sprintf("transports.http.auth.tokens[%d]", i), &cfg.Transports.HTTP.Auth.Tokens[i])
+	}
+	resolve("transports.mqtt.password", &cfg.Transports.MQTT.Password)
+	resolve("async.callback_secret", &cfg.Async.CallbackSecret)
 	for name, target := range cfg.Targets {
-		target.Token = resolveEnvRef(target.Token)
+		resolve("targets."+name+".token", &target.Token)
+		for key, value := range target.Headers {
+			resolve("targets."+name+".headers."+key, &value)
+			target.Headers[key] = value
+		}
 		cfg.Targets[name] = target
 	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("resolving secrets: %w", err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
 	return &cfg, nil
 }
 
-// resolveEnvRef replaces "${VAR_NAME}" patterns with the corresponding env var value.
-func resolveEnvRef(val string) string {
-	if strings.HasPrefix(val, "${") && strings.HasSuffix(val, "}") {
-		envKey := val[2 : len(val)-1]
-		if envVal := os.Getenv(envKey); envVal != "" {
-			return envVal
+// Validate checks settings that would otherwise fail later at runtime.
+func (c *Config) Validate() error {
+	backends := map[string]bool{"openai": true, "local": true, "deepgram": true, "gemini": true, "mock": true}
+	for key, backend := range map[string]string{
+		"interpreter.backend":               c.Interpreter.Backend,
+		"interpreter.transcription_backend": c.Interpreter.TranscriptionBackend,
+		"interpreter.completion_backend":    c.Interpreter.CompletionBackend,
+	} {
+		if backend != "" && !backends[backend] {
+			return fmt.Errorf("%s: unknown interpreter backend %q", key, backend)
+		}
+	}
+	for name, n := range c.Interpreter.Named {
+		if name == "" || name == "default" {
+			return fmt.Errorf("interpreter.named: %q is reserved for the default interpreter", name)
+		}
+		if n.Backend == "" && (n.TranscriptionBackend == "" || n.CompletionBackend == "") {
+			return fmt.Errorf("interpreter.named.%s: backend is required", name)
+		}
+		for key, backend := range map[string]string{
+			"backend":               n.Backend,
+			"transcription_backend": n.TranscriptionBackend,
+			"completion_backend":    n.CompletionBackend,
+		} {
+			if backend != "" && !backends[backend] {
+				return fmt.Errorf("interpreter.named.%s.%s: unknown interpreter backend %q", name, key, backend)
+			}
+		}
+		if cmp.Or(n.CompletionBackend, n.Backend) == "deepgram" {
+			return fmt.Errorf("interpreter.named.%s: deepgram only transcribes; set completion_backend to \"openai\", \"local\" or \"gemini\"", name)
+		}
+	}
+	if m := c.Interpreter.MinConfidence; m < 0 || m > 1 {
+		return fmt.Errorf("interpreter.min_confidence: must be between 0 and 1, got %v", m)
+	}
+	if r := c.Interpreter.Response; r.MaxWords < 0 || r.MaxChars < 0 {
+		return fmt.Errorf("interpreter.response: max_words and max_chars must not be negative")
+	}
+	if l := c.Interpreter.Response.Language; l != "" && l != "match" && l != "auto" && len(l) != 2 {
+		return fmt.Errorf("interpreter.response.language: must be \"match\", \"auto\" or an ISO-639-1 code, got %q", l)
+	}
+	if completion := cmp.Or(c.Interpreter.CompletionBackend, c.Interpreter.Backend); completion == "deepgram" {
+		return fmt.Errorf("interpreter: deepgram only transcribes; set completion_backend to \"openai\", \"local\" or \"gemini\"")
+	}
+	for key, t := range map[string]float64{
+		"interpreter.openai.temperature": c.Interpreter.OpenAI.Temperature,
+		"interpreter.local.temperature":  c.Interpreter.Local.Temperature,
+		"interpreter.gemini.temperature": c.Interpreter.Gemini.Temperature,
+	} {
+		if t < 0 || t > 2 {
+			return fmt.Errorf("%s: must be between 0 and 2, got %v", key, t)
+		}
+	}
+	if m := c.Interpreter.OpenAI.ToolCalling; m != "auto" && m != "on" && m != "off" {
+		return fmt.Errorf("interpreter.openai.tool_calling: must be \"auto\", \"on\" or \"off\", got %q", m)
+	}
+	for i, r := range c.Interpreter.Mock.Rules {
+		if r.Action == "" || len(r.Keywords) == 0 {
+			return fmt.Errorf("interpreter.mock.rules[%d]: action and keywords are required", i)
+		}
+	}
+	if p := c.Interpreter.OpenAI.Provider; p != "openai" && p != "groq" {
+		return fmt.Errorf("interpreter.openai.provider: must be \"openai\" or \"groq\", got %q", p)
+	}
+	if c.Interpreter.OpenAI.Provider == "groq" && c.Interpreter.OpenAI.Azure.Endpoint != "" {
+		return fmt.Errorf("interpreter.openai: azure.endpoint cannot be used with provider \"groq\"")
+	}
+	if b := c.Interpreter.OpenAI.BaseURL; b != "" {
+		if u, err := url.Parse(b); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("interpreter.openai.base_url: must be an http(s) URL, got %q", b)
+		}
+		if c.Interpreter.OpenAI.Azure.Endpoint != "" {
+			return fmt.Errorf("interpreter.openai: set base_url or azure.endpoint, not both")
+		}
+	}
+	if e := c.Interpreter.OpenAI.Azure.Endpoint; e != "" {
+		if u, err := url.Parse(e); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("interpreter.openai.azure.endpoint: must be an http(s) URL, got %q", e)
+		}
+	}
+	if d := c.TTS.Delivery; d != "inline" && d != "url" {
+		return fmt.Errorf("tts.delivery: must be \"inline\" or \"url\", got %q", d)
+	}
+	if b := c.Server.OnBusy; b != "wait" && b != "reject" {
+		return fmt.Errorf("server.on_busy: must be \"wait\" or \"reject\", got %q", b)
+	}
+	if r := c.Server.RateLimit; r.Enabled && (r.RequestsPerSecond <= 0 || r.Burst < 1) {
+		return fmt.Errorf("server.rate_limit: requests_per_second must be positive and burst at least 1")
+	}
+	if c.Server.ShutdownGracePeriod < 0 {
+		return fmt.Errorf("server.shutdown_grace_period must not be negative")
+	}
+	if c.Server.MaxAudioBytes < 0 || c.Server.MaxAudioDuration < 0 {
+		return fmt.Errorf("server.max_audio_bytes and server.max_audio_duration must not be negative")
+	}
+	if t := c.Server.SilenceThreshold; t < 0 || t >= 1 {
+		return fmt.Errorf("server.silence_threshold must be in [0, 1), got %g", t)
+	}
+	if t := c.Transports.GRPC.TLS; (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("transports.grpc.tls: cert_file and key_file must be set together")
+	} else if t.ClientCAFile != "" && t.CertFile == "" {
+		return fmt.Errorf("transports.grpc.tls.client_ca_file: requires cert_file and key_file")
+	}
+	if t := c.Transports.GRPC.ClientTLS; (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("transports.grpc.client_tls: cert_file and key_file must be set together")
+	}
+	if a := c.Audit; a.Enabled {
+		if a.Path == "" && a.Target == "" {
+			return fmt.Errorf("audit: path or target is required when enabled")
+		}
+		if _, ok := c.Targets[a.Target]; a.Target != "" && !ok {
+			return fmt.Errorf("audit.target: no configured target named %q", a.Target)
+		}
+	}
+	for source, r := range c.Routes {
+		for _, name := range r.Targets {
+			if _, ok := c.Targets[name]; !ok {
+				return fmt.Errorf("routes.%s.targets: no configured target named %q", source, name)
+			}
 		}
 	}
-	return val
+	patterns := map[string][]string{
+		"commands.allowed_actions": c.Commands.AllowedActions,
+		"commands.denied_actions":  c.Commands.DeniedActions,
+	}
+	for name, t := range c.Targets {
+		patterns["targets."+name+".allowed_actions"] = t.AllowedActions
+	}
+	for key, list := range patterns {
+		for _, p := range list {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("%s: invalid pattern %q: %w", key, p, err)
+			}
+		}
+	}
+	if c.Transports.HTTP.MaxBodyBytes <= 0 {
+		return fmt.Errorf("transports.http.max_body_bytes: must be positive, got %d", c.Transports.HTTP.MaxBodyBytes)
+	}
+	if o := c.Transports.HTTP.WebSocket.OnOverflow; o != "finalize" && o != "error" {
+		return fmt.Errorf("transports.http.websocket.on_overflow: must be \"finalize\" or \"error\", got %q", o)
+	}
+	if m := c.Transports.MQTT.Reply.Mode; m != "response_topic" && m != "template" {
+		return fmt.Errorf("transports.mqtt.reply.mode: must be \"response_topic\" or \"template\", got %q", m)
+	}
+	if t := c.Transports.MQTT.TLS; (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("transports.mqtt.tls: cert_file and key_file must be set together")
+	}
+	if q := c.Transports.MQTT.QoS; q < 0 || q > 2 {
+		return fmt.Errorf("transports.mqtt.qos: must be 0, 1 or 2, got %d", q)
+	}
+	if w := c.Transports.WS; w.Enabled && w.QueueSize <= 0 {
+		return fmt.Errorf("transports.ws.queue_size: must be positive, got %d", w.QueueSize)
+	}
+	switch o := strings.ToLower(c.Logging.Output); {
+	case o != "" && o != "stdout" && o != "stderr":
+		return fmt.Errorf("logging.output: must be \"stdout\" or \"stderr\", got %q", c.Logging.Output)
+	case o == "stdout" && c.Transports.Stdout.writesStdout():
+		return fmt.Errorf("logging.output: the stdout transport writes payloads to stdout; log to \"stderr\" or set transports.stdout.path")
+	}
+	return nil
+}
+
+// RestartRequired lists the top-level settings that differ between c and
+// next but can only take effect after a restart (listeners, transports and
+// stores created at startup).
+func (c *Config) RestartRequired(next *Config) []string {
+	var changed []string
+	if !reflect.DeepEqual(c.Server, next.Server) {
+		changed = append(changed, "server")
+	}
+	if !reflect.DeepEqual(c.Transports, next.Transports) {
+		changed = append(changed, "transports")
+	}
+	if !reflect.DeepEqual(c.Audit, next.Audit) {
+		changed = append(changed, "audit")
+	}
+	if !reflect.DeepEqual(c.Async, next.Async) {
+		changed = append(changed, "async")
+	}
+	if !reflect.DeepEqual(c.AudioFetch, next.AudioFetch) {
+		changed = append(changed, "audio_fetch")
+	}
+	if c.Dedup != next.Dedup {
+		changed = append(changed, "dedup")
+	}
+	if c.Sessions != next.Sessions {
+		changed = append(changed, "sessions")
+	}
+	if c.TTS.Enabled != next.TTS.Enabled || c.TTS.Delivery != next.TTS.Delivery ||
+		!reflect.DeepEqual(c.TTS.AudioStore, next.TTS.AudioStore) {
+		changed = append(changed, "tts.enabled/delivery/audio_store")
+	}
+	if c.Logging.Format != next.Logging.Format || c.Logging.Output != next.Logging.Output {
+		changed = append(changed, "logging.format/output")
+	}
+	return changed
 }
 
+// resolveEnvRef expands a secret reference:
+//
+//   - "${file:/path}" is replaced by the contents of the file, with
+//     surrounding whitespace trimmed (Docker/Kubernetes secret mounts).
+//   - "${VAR_NAME}" is replaced by the env var's value. If VAR_NAME is unset
+//     and VAR_NAME_FILE is set, the file it names is read instead.
+//
+// Other values, and references to unset variables, are returned unchanged.
+func resolveEnvRef(val string) (string, error) {
+	if !strings.HasPrefix(val, "${") || !strings.HasSuffix(val, "}") {
+		return val, nil
+	}
+	ref := val[2 : len(val)-1]
+
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		return readSecretFile(path)
+	}
+	if envVal := os.Getenv(ref); envVal != "" {
+		return envVal, nil
+	}
+	if path := os.Getenv(ref + "_FILE"); path != "" {
+		return readSecretFile(path)
+	}
+	return val, nil
+}
+
+// readSecretFile reads a secret from a file, trimming trailing newlines and
+// surrounding whitespace.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// logLevel is shared by the installed handler so SetLogLevel can change the
+// level of the running logger.
+var logLevel slog.LevelVar
+
 // SetupLogging configures the global slog logger based on config.
 func SetupLogging(cfg LoggingConfig) {
-	var level slog.Level
-	switch strings.ToLower(cfg.Level) {
-	case "debug":
-		level = slog.LevelDebug
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
-		level = slog.LevelInfo
-	}
+	SetLogLevel(cfg.Level)
+	SetLogRedact(cfg.Redact)
+
+	opts := &slog.HandlerOptions{Level: &logLevel, ReplaceAttr: redactAttr}
 
-	opts := &slog.HandlerOptions{Level: level}
+	out := os.Stdout
+	if strings.ToLower(cfg.Output) == "stderr" {
+		out = os.Stderr
+	}
 
 	var handler slog.Handler
 	if strings.ToLower(cfg.Format) == "text" {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+		handler = slog.NewTextHandler(out, opts)
 	} else {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
+		handler = slog.NewJSONHandler(out, opts)
 	}
 
 	slog.SetDefault(slog.New(handler))
 }
+
+// SetLogLevel changes the level of the logger installed by SetupLogging.
+func SetLogLevel(level string) {
+	switch strings.ToLower(level) {
+	case "debug":
+		logLevel.Set(slog.LevelDebug)
+	case "warn":
+		logLevel.Set(slog.LevelWarn)
+	case "error":
+		logLevel.Set(slog.LevelError)
+	default:
+		logLevel.Set(slog.LevelInfo)
+	}
+}
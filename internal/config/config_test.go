@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// loadYAML writes yaml to a temporary config file and loads it.
+func loadYAML(t *testing.T, yaml string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "switchyard.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoggingOutputDefault(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"no stdout sink", "logging:\n  level: info\n", "stdout"},
+		{"stdout sink to stdout", "transports:\n  stdout:\n    enabled: true\n", "stderr"},
+		{"stdout sink to file", "transports:\n  stdout:\n    enabled: true\n    path: /tmp/out.jsonl\n", "stdout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadYAML(t, tt.yaml)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Logging.Output != tt.want {
+				t.Errorf("logging.output = %q, want %q", cfg.Logging.Output, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggingOutputConflictsWithStdoutSink(t *testing.T) {
+	_, err := loadYAML(t, "transports:\n  stdout:\n    enabled: true\nlogging:\n  output: stdout\n")
+	if err == nil || !strings.Contains(err.Error(), "logging.output") {
+		t.Fatalf("Load error = %v, want a logging.output error", err)
+	}
+}
+
+func TestResolveEnvRef(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	if err := os.WriteFile(secret, []byte("  s3cret\n\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SWITCHYARD_TEST_KEY", "from-env")
+	t.Setenv("SWITCHYARD_TEST_FILE_KEY_FILE", secret)
+	t.Setenv("SWITCHYARD_TEST_BOTH", "env-wins")
+	t.Setenv("SWITCHYARD_TEST_BOTH_FILE", secret)
+
+	tests := []struct {
+		name, in, want string
+	}{
+		{"literal", "sk-literal", "sk-literal"},
+		{"file trims trailing newlines", "${file:" + secret + "}", "s3cret"},
+		{"env var", "${SWITCHYARD_TEST_KEY}", "from-env"},
+		{"env var _FILE form", "${SWITCHYARD_TEST_FILE_KEY}", "s3cret"},
+		{"env var wins over _FILE", "${SWITCHYARD_TEST_BOTH}", "env-wins"},
+		{"unset env var is kept", "${SWITCHYARD_TEST_UNSET}", "${SWITCHYARD_TEST_UNSET}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveEnvRef(tt.in)
+			if err != nil {
+				t.Fatalf("resolveEnvRef(%q): %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveEnvRef(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveEnvRefMissingFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	if _, err := resolveEnvRef("${file:" + missing + "}"); err == nil {
+		t.Error("missing ${file:} secret: want error")
+	}
+	t.Setenv("SWITCHYARD_TEST_MISSING_FILE", missing)
+	if _, err := resolveEnvRef("${SWITCHYARD_TEST_MISSING}"); err == nil {
+		t.Error("missing _FILE secret: want error")
+	}
+}
+
+func TestLoadMissingSecretFileNamesField(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	_, err := loadYAML(t, "interpreter:\n  openai:\n    api_key: \"${file:"+missing+"}\"\n")
+	if err == nil || !strings.Contains(err.Error(), "interpreter.openai.api_key") {
+		t.Errorf("Load() = %v, want an error naming interpreter.openai.api_key", err)
+	}
+}
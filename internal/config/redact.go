@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// redacted replaces secrets and credentials in log output.
+const redacted = "REDACTED"
+
+// minSecretLen keeps very short values (which would match everywhere) out of
+// the secret registry.
+const minSecretLen = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string // resolved secret values, scrubbed from all log output
+
+	// logRedact hides message text (transcripts, prompts) in logs.
+	logRedact atomic.Bool
+)
+
+// sensitiveParams are query parameter names whose values are credentials.
+var sensitiveParams = []string{"token", "key", "secret", "password", "passwd", "auth", "signature", "sig", "credential"}
+
+// textKeys are log attributes that carry user speech or text. Their values
+// are replaced by their length when logging.redact is set.
+var textKeys = map[string]bool{"transcript": true, "text": true, "response_text": true, "prompt": true}
+
+// urlPattern finds URLs embedded in free-form strings such as errors.
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// registerSecret adds a resolved secret to the values scrubbed from logs.
+func registerSecret(s string) {
+	if len(s) < minSecretLen {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, known := range secrets {
+		if known == s {
+			return
+		}
+	}
+	secrets = append(secrets, s)
+}
+
+// Scrub removes secrets from s: configured tokens and keys are replaced, and
+// URLs lose their user info and credential-like query parameters. Use it on
+// anything logged that may contain config values, such as a config dump.
+func Scrub(s string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	secretsMu.RUnlock()
+	if strings.Contains(s, "://") {
+		s = urlPattern.ReplaceAllStringFunc(s, RedactURL)
+	}
+	return s
+}
+
+// RedactURL hides the password or token in a URL's user info and the values
+// of query parameters whose names suggest credentials (token, api_key, sig…).
+// Strings that don't parse as URLs are returned unchanged.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		return raw
+	}
+	changed := false
+	if u.User != nil {
+		u.User = url.User(redacted)
+		changed = true
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for name := range q {
+			if isSensitiveParam(name) {
+				q.Set(name, redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	if !changed {
+		return raw
+	}
+	return u.String()
+}
+
+func isSensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range sensitiveParams {
+		if strings.Contains(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetLogRedact turns hiding of message text in logs on or off.
+func SetLogRedact(on bool) {
+	logRedact.Store(on)
+}
+
+// redactAttr is the slog ReplaceAttr hook installed by SetupLogging. Secrets
+// are always scrubbed and raw bytes (audio) are never logged; text
+// attributes are reduced to their length when logging.redact is set.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		s := v.String()
+		if logRedact.Load() && textKeys[a.Key] {
+			return slog.String(a.Key, fmt.Sprintf("[%d chars]", len(s)))
+		}
+		if scrubbed := Scrub(s); scrubbed != s {
+			return slog.String(a.Key, scrubbed)
+		}
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case []byte:
+			return slog.String(a.Key, fmt.Sprintf("[%d bytes]", len(x)))
+		case error:
+			if s := x.Error(); Scrub(s) != s {
+				return slog.String(a.Key, Scrub(s))
+			}
+		case fmt.Stringer:
+			if s := x.String(); Scrub(s) != s {
+				return slog.String(a.Key, Scrub(s))
+			}
+		}
+	}
+	return a
+}
@@ -0,0 +1,118 @@
+package dispatch
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// dedupCache remembers the results of recently dispatched message IDs so a
+// re-delivered message (e.g., after an MQTT reconnect) is answered from the
+// cache instead of re-running the pipeline and re-sending to targets.
+// It is bounded by entry count and entries expire after window.
+type dedupCache struct {
+	window     time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	order   *list.List               // oldest at front; values are *dedupEntry
+	entries map[string]*list.Element // dedupKey -> element in order
+}
+
+// dedupEntry tracks one message ID. done is closed once result is set, so
+// duplicates that arrive while the first dispatch is running wait for it.
+type dedupEntry struct {
+	key     string
+	done    chan struct{}
+	result  *message.DispatchResult
+	expires time.Time
+}
+
+func newDedupCache(window time.Duration, maxEntries int) *dedupCache {
+	return &dedupCache{
+		window:     window,
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// dedupKey scopes a message ID to its source. IDs are chosen by clients, so
+// two sources may use the same one for different messages.
+func dedupKey(msg *message.Message) string {
+	return msg.Source + "\x00" + msg.ID
+}
+
+// claim registers key. If key is new, first is true and the caller must run
+// the dispatch and then call finish with the returned entry. Otherwise the
+// existing entry is returned; its result is ready once done is closed.
+func (c *dedupCache) claim(key string) (entry *dedupEntry, first bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Completed entries are ordered by expiry, so the sweep stops at the
+	// first live one. Entries still running have no expiry yet; skip them.
+	now := time.Now()
+	for e := c.order.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*dedupEntry); entry.result != nil {
+			if now.Before(entry.expires) {
+				break
+			}
+			c.removeLocked(e)
+		}
+		e = next
+	}
+	if e, ok := c.entries[key]; ok {
+		return e.Value.(*dedupEntry), false
+	}
+
+	for c.order.Len() >= c.maxEntries {
+		c.removeLocked(c.order.Front())
+	}
+	entry = &dedupEntry{key: key, done: make(chan struct{})}
+	c.entries[key] = c.order.PushBack(entry)
+	return entry, true
+}
+
+// finish publishes the result of a claimed entry to waiting duplicates.
+// Results that remember rejects are dropped so a retry runs the pipeline
+// again.
+func (c *dedupCache) finish(entry *dedupEntry, result *message.DispatchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry.result = result
+	entry.expires = time.Now().Add(c.window)
+	close(entry.done)
+
+	e, ok := c.entries[entry.key]
+	if !ok || e.Value != entry {
+		return // evicted while running
+	}
+	if !remember(result) {
+		c.removeLocked(e)
+		return
+	}
+	// Keep completed entries ordered by expiry for the sweep in claim.
+	c.order.MoveToBack(e)
+}
+
+// remember reports whether a duplicate may be answered with result. Failures
+// before routing and dry runs are not remembered, since nothing reached the
+// targets; routing failures are, so a retry doesn't repeat the commands that
+// were delivered.
+func remember(result *message.DispatchResult) bool {
+	if result == nil || result.DryRun {
+		return false
+	}
+	return result.Error == "" || result.ErrorStage == message.ErrorStageRouting ||
+		result.ErrorStage == message.ErrorStageSynthesis
+}
+
+func (c *dedupCache) removeLocked(e *list.Element) {
+	c.order.Remove(e)
+	delete(c.entries, e.Value.(*dedupEntry).key)
+}
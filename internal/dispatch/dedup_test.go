@@ -0,0 +1,48 @@
+package dispatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+func TestDedupPerSource(t *testing.T) {
+	interp := &fakeInterpreter{}
+	tr := &fakeTransport{name: "http"}
+	d := New(interp, []transport.Transport{tr}, nil, Options{DedupWindow: time.Minute})
+
+	for _, source := range []string{"kitchen", "garage", "kitchen"} {
+		msg := textMessage("turn on the light")
+		msg.Source = source
+		if result := handle(t, d, msg); result.Error != "" {
+			t.Fatalf("source %s: error %q", source, result.Error)
+		}
+	}
+	// The repeated kitchen message is a duplicate; the garage one is not.
+	if got := len(interp.interpreted); got != 2 {
+		t.Errorf("interpreted %d messages, want 2", got)
+	}
+	if got := len(tr.payloads(t)); got != 2 {
+		t.Errorf("sent %d payloads, want 2", got)
+	}
+}
+
+func TestDedupSweepSkipsRunning(t *testing.T) {
+	c := newDedupCache(time.Millisecond, 10)
+	if _, first := c.claim("running"); !first {
+		t.Fatal("running: not first")
+	}
+	done, _ := c.claim("done")
+	c.finish(done, &message.DispatchResult{})
+	time.Sleep(5 * time.Millisecond)
+
+	c.claim("next")
+	if _, ok := c.entries["done"]; ok {
+		t.Error("expired entry behind a running one was not swept")
+	}
+	if _, ok := c.entries["running"]; !ok {
+		t.Error("running entry was swept")
+	}
+}
@@ -9,57 +9,511 @@ package dispatch
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/nadzzz/switchyard/internal/async"
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/audiofetch"
+	"github.com/nadzzz/switchyard/internal/audiostore"
+	"github.com/nadzzz/switchyard/internal/audit"
+	"github.com/nadzzz/switchyard/internal/cmdschema"
 	"github.com/nadzzz/switchyard/internal/interpreter"
 	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/normalize"
+	"github.com/nadzzz/switchyard/internal/ratelimit"
+	"github.com/nadzzz/switchyard/internal/session"
 	"github.com/nadzzz/switchyard/internal/transport"
 	"github.com/nadzzz/switchyard/internal/tts"
 )
 
+// Options holds optional dispatcher settings.
+type Options struct {
+	// Interpreters are alternatives to the default interpreter, selected by
+	// name with Instruction.Interpreter. Messages naming an unknown
+	// interpreter fail.
+	Interpreters map[string]interpreter.Interpreter
+
+	// Prompts maps ISO-639-1 codes to extra interpretation context. A region
+	// tag such as "fr-CA" uses its base language's entry, and the "default"
+	// entry is used when no language-specific entry exists.
+	Prompts map[string]string
+
+	// TranscriptionPrompts maps ISO-639-1 codes to vocabulary hints (device
+	// and room names) passed to transcription, selected by the instruction's
+	// language with the same fallbacks. The instruction's own prompt is
+	// appended.
+	TranscriptionPrompts map[string]string
+
+	// MinConfidence fails dispatches whose transcript confidence is below it
+	// instead of interpreting them. Transcripts from backends that report no
+	// confidence are never gated. Zero disables the check.
+	MinConfidence float64
+
+	// NormalizeNumbers lists the languages whose transcripts have spelled-out
+	// numbers and units rewritten as digits and symbols before interpretation.
+	NormalizeNumbers []string
+
+	// Targets are the server-configured targets, keyed by service name.
+	// Instruction targets with a matching name are completed from them.
+	Targets map[string]message.Target
+
+	// Routes maps lowercased message sources to instruction defaults for
+	// them. The "default" entry applies to sources without one.
+	Routes map[string]Route
+
+	// DefaultResponseFormat and DefaultPrompt fill in instructions that
+	// neither the caller nor the source's route give a value.
+	DefaultResponseFormat string
+	DefaultPrompt         string
+
+	// Response constrains response texts of instructions that set no
+	// limits or style of their own.
+	Response ResponseConstraints
+
+	// Transformers rewrite interpreted commands, in order, before they are
+	// filtered, validated and routed. An error fails the dispatch at the
+	// "transform" stage.
+	Transformers []Transformer
+
+	// AllowedActions and DeniedActions filter interpreted commands before
+	// routing (path.Match patterns). Dropped commands are reported in
+	// DispatchResult.Rejected. Targets can narrow this further with
+	// message.Target.AllowedActions.
+	AllowedActions []string
+	DeniedActions  []string
+
+	// CommandSchemas, when set, validates the params of interpreted commands
+	// per action and instruction response format. Commands that fail are
+	// reported in DispatchResult.Rejected instead of being routed.
+	CommandSchemas *cmdschema.Validator
+
+	// AudioStore, when set, holds synthesized audio for fetching by URL
+	// instead of embedding it in the result.
+	AudioStore *audiostore.Store
+
+	// AudioBaseURL prefixes the "/audio/{id}" path in ResponseAudioURL.
+	AudioBaseURL string
+
+	// AudioFetcher, when set, downloads input audio for messages that carry
+	// an AudioURL instead of inline audio. Such messages are rejected if nil.
+	AudioFetcher *audiofetch.Fetcher
+
+	// DedupWindow, when positive, makes dispatch idempotent per source and
+	// message ID: a message whose ID was handled for the same source within
+	// the window gets the earlier result without re-running the pipeline or
+	// re-sending to targets.
+	// Messages without an ID are never deduplicated.
+	DedupWindow time.Duration
+
+	// DedupMaxEntries bounds the number of remembered IDs (default 1024).
+	DedupMaxEntries int
+
+	// Sessions, when set, keeps recent turns per Message.SessionID and passes
+	// them to the interpreter as conversation history.
+	Sessions session.Store
+
+	// MaxConcurrent bounds the number of messages dispatched at once
+	// (0 = unlimited). Beyond it, messages wait for a free slot, or fail
+	// with transport.ErrBusy if RejectWhenBusy is set.
+	MaxConcurrent  int
+	RejectWhenBusy bool
+
+	// RateLimiter, when set, rejects messages from senders over their rate
+	// with transport.ErrRateLimited before any work is done.
+	RateLimiter *ratelimit.Limiter
+
+	// MaxAudioBytes and MaxAudioDuration reject larger or longer input audio
+	// before it is transcribed (0 = no limit). Duration is only known, and
+	// checked, for PCM WAV.
+	MaxAudioBytes    int
+	MaxAudioDuration time.Duration
+
+	// SilenceThreshold fails 16-bit PCM WAV input whose RMS level (as a
+	// fraction of full scale) is below it, without transcribing it.
+	// Zero disables the check.
+	SilenceThreshold float64
+
+	// Callbacks, when set, receives the result of every dispatched message
+	// that has an Instruction.CallbackURL, whichever transport it came from.
+	// Duplicates answered from the dedup cache are not notified again.
+	Callbacks *async.Queue
+
+	// AuditFile, when set, receives one record per dispatch. AuditTarget,
+	// when set, names a configured target that is also sent each record.
+	AuditFile   *audit.File
+	AuditTarget string
+
+	// DebugEcho attaches the received message, without its audio, and the
+	// resolved instruction to failed results, for bug reports.
+	DebugEcho bool
+}
+
 // Dispatcher is the central routing engine.
 type Dispatcher struct {
+	transports map[string]transport.Transport
+	audioStore *audiostore.Store
+	audioBase  string
+	fetcher    *audiofetch.Fetcher
+	dedup      *dedupCache        // nil if deduplication is disabled
+	sessions   session.Store      // nil if sessions are disabled
+	callbacks  *async.Queue       // nil if async callbacks are disabled
+	auditFile  *audit.File        // nil if the audit file is disabled
+	limiter    *ratelimit.Limiter // nil if rate limiting is disabled
+	maxAudio   int                // bytes, 0 = no limit
+	maxLength  time.Duration      // WAV duration, 0 = no limit
+	silence    float64            // WAV RMS level, 0 = no check
+	auditTo    string             // configured target receiving audit records
+	slots      chan struct{}      // concurrency semaphore; nil if unlimited
+	rejectBusy bool
+	debugEcho  bool
+	inFlight   atomic.Int64
+	drain      drainState
+	stats      stats
+
+	// pipeline holds everything that can be swapped by Reload. Each dispatch
+	// loads it once, so a reload never mixes old and new settings mid-message.
+	pipeline atomic.Pointer[pipeline]
+}
+
+// pipeline is the reloadable part of the dispatcher.
+type pipeline struct {
 	interpreter interpreter.Interpreter
-	transports  map[string]transport.Transport
+	named       map[string]interpreter.Interpreter
 	synthesizer tts.Synthesizer // nil if TTS is disabled
+	prompts     map[string]string
+	vocab       map[string]string // transcription prompts by language
+	minConf     float64           // 0 = no confidence gate
+	normalize   map[string]bool   // languages with number normalization enabled
+	targets     map[string]message.Target
+	routes      map[string]Route
+	defaults    Route // server-wide ResponseFormat and Prompt, after routes
+	response    ResponseConstraints
+	transforms  []Transformer
+	actions     actionFilter
+	schemas     *cmdschema.Validator // nil if no schemas are configured
 }
 
 // New creates a new Dispatcher with the given interpreter and transports.
-func New(interp interpreter.Interpreter, transports []transport.Transport, synthesizer tts.Synthesizer) *Dispatcher {
+func New(interp interpreter.Interpreter, transports []transport.Transport, synthesizer tts.Synthesizer, opts Options) *Dispatcher {
 	tm := make(map[string]transport.Transport, len(transports))
 	for _, t := range transports {
 		tm[t.Name()] = t
 	}
-	return &Dispatcher{
+	d := &Dispatcher{
+		transports: tm,
+		audioStore: opts.AudioStore,
+		audioBase:  strings.TrimSuffix(opts.AudioBaseURL, "/"),
+		fetcher:    opts.AudioFetcher,
+		sessions:   opts.Sessions,
+		callbacks:  opts.Callbacks,
+		auditFile:  opts.AuditFile,
+		auditTo:    opts.AuditTarget,
+		rejectBusy: opts.RejectWhenBusy,
+		debugEcho:  opts.DebugEcho,
+		limiter:    opts.RateLimiter,
+		maxAudio:   opts.MaxAudioBytes,
+		maxLength:  opts.MaxAudioDuration,
+		silence:    opts.SilenceThreshold,
+	}
+	if opts.MaxConcurrent > 0 {
+		d.slots = make(chan struct{}, opts.MaxConcurrent)
+	}
+	if opts.DedupWindow > 0 {
+		maxEntries := opts.DedupMaxEntries
+		if maxEntries <= 0 {
+			maxEntries = 1024
+		}
+		d.dedup = newDedupCache(opts.DedupWindow, maxEntries)
+	}
+	d.Reload(interp, synthesizer, opts)
+	return d
+}
+
+// Reload atomically replaces the interpreters, synthesizer, prompts, the
+// confidence threshold, number normalization, configured targets, routes,
+// command transformers, action filters and command schemas.
+// Dispatches already in flight finish with the previous settings.
+// AudioStore, AudioBaseURL, AudioFetcher, Sessions, Callbacks, the audit,
+// dedup, concurrency and debug echo settings are fixed at creation and
+// ignored here.
+func (d *Dispatcher) Reload(interp interpreter.Interpreter, synthesizer tts.Synthesizer, opts Options) {
+	norm := make(map[string]bool, len(opts.NormalizeNumbers))
+	for _, lang := range opts.NormalizeNumbers {
+		if !normalize.Supported(lang) {
+			slog.Warn("number normalization not supported for language, ignoring", "language", lang)
+			continue
+		}
+		norm[lang] = true
+	}
+	for name, target := range opts.Targets {
+		if _, ok := d.sender(target.Protocol); !ok {
+			slog.Warn("no transport for configured target's protocol, commands for it will be skipped",
+				"target", name, "protocol", target.Protocol)
+		}
+	}
+	d.pipeline.Store(&pipeline{
 		interpreter: interp,
-		transports:  tm,
+		named:       opts.Interpreters,
 		synthesizer: synthesizer,
-	}
+		prompts:     opts.Prompts,
+		vocab:       opts.TranscriptionPrompts,
+		minConf:     opts.MinConfidence,
+		normalize:   norm,
+		targets:     opts.Targets,
+		routes:      opts.Routes,
+		defaults:    Route{ResponseFormat: opts.DefaultResponseFormat, Prompt: opts.DefaultPrompt},
+		response:    opts.Response,
+		transforms:  opts.Transformers,
+		actions:     actionFilter{allow: opts.AllowedActions, deny: opts.DeniedActions},
+		schemas:     opts.CommandSchemas,
+	})
 }
 
 // Handle processes a single message through the full pipeline.
 // This function is passed as the transport.Handler to each transport.
 func (d *Dispatcher) Handle(ctx context.Context, msg *message.Message) (*message.DispatchResult, error) {
+	return d.HandleStream(ctx, msg, nil)
+}
+
+// HandleStream is Handle with progress reporting: emit is called with the
+// partial result as each pipeline stage completes. A nil emit is allowed.
+// This function is passed as the transport.StreamHandler to streaming transports.
+func (d *Dispatcher) HandleStream(ctx context.Context, msg *message.Message, emit transport.StageFunc) (*message.DispatchResult, error) {
+	if !d.drain.begin() {
+		return nil, transport.ErrShuttingDown
+	}
+	defer d.drain.end()
+	return d.handleStream(ctx, msg, emit)
+}
+
+// handleStream answers duplicates from the dedup cache and runs the rest.
+func (d *Dispatcher) handleStream(ctx context.Context, msg *message.Message, emit transport.StageFunc) (*message.DispatchResult, error) {
+	if d.dedup == nil || msg.ID == "" {
+		return d.run(ctx, msg, emit)
+	}
+
+	entry, first := d.dedup.claim(dedupKey(msg))
+	if !first {
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !remember(entry.result) {
+			// The earlier attempt failed or was a dry run and was not
+			// remembered; run again.
+			return d.handleStream(ctx, msg, emit)
+		}
+		slog.Info("duplicate message, returning earlier result", "message_id", msg.ID, "source", msg.Source, "trace_id", msg.TraceID)
+		result := *entry.result
+		return &result, nil
+	}
+
+	result, err := d.run(ctx, msg, emit)
+	if err != nil {
+		d.dedup.finish(entry, nil)
+	} else {
+		d.dedup.finish(entry, result)
+	}
+	return result, err
+}
+
+// run dispatches msg, counts and records it in the audit trail and notifies its
+// callback URL, if any, with the result.
+func (d *Dispatcher) run(ctx context.Context, msg *message.Message, emit transport.StageFunc) (*message.DispatchResult, error) {
 	start := time.Now()
+	received := msg.Instruction
+	result, err := d.dispatch(ctx, msg, emit)
+	if err == nil {
+		errorText(result, msg.Instruction)
+	}
+	if d.debugEcho && err == nil && result.Error != "" {
+		result.Debug = debugEcho(msg, received)
+	}
+	d.stats.record(err != nil || result.Error != "")
+	d.audit(ctx, msg, result, err, start)
+	if d.callbacks != nil && msg.Instruction.CallbackURL != "" {
+		if err != nil {
+			d.callbacks.Notify(msg, &message.DispatchResult{MessageID: msg.ID, Error: err.Error()})
+		} else {
+			d.callbacks.Notify(msg, result)
+		}
+	}
+	return result, err
+}
+
+// audit writes the record of one dispatch to the audit file and sends it to
+// the audit target. Sending happens in the background with a context detached
+// from the request, so a slow audit service doesn't delay the response.
+func (d *Dispatcher) audit(ctx context.Context, msg *message.Message, result *message.DispatchResult, err error, start time.Time) {
+	if d.auditFile == nil && d.auditTo == "" {
+		return
+	}
+	rec := audit.NewRecord(msg, result, err, start)
+	if d.auditFile != nil {
+		if err := d.auditFile.Write(rec); err != nil {
+			slog.Error("writing audit record failed", "message_id", rec.MessageID, "error", err)
+		}
+	}
+	if d.auditTo == "" {
+		return
+	}
+	target, ok := d.pipeline.Load().targets[d.auditTo]
+	if !ok {
+		slog.Error("audit target is not configured", "target", d.auditTo)
+		return
+	}
+	sender, ok := d.sender(target.Protocol)
+	if !ok {
+		slog.Error("no transport for audit target protocol", "target", d.auditTo, "protocol", target.Protocol)
+		return
+	}
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		slog.Error("encoding audit record failed", "error", err)
+		return
+	}
+	go func() {
+		sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if err := sender.Send(sctx, target, payload); err != nil {
+			slog.Error("sending audit record failed", "target", d.auditTo, "message_id", rec.MessageID, "error", err)
+		}
+	}()
+}
+
+// InFlight returns the number of messages currently being dispatched.
+func (d *Dispatcher) InFlight() int {
+	return int(d.inFlight.Load())
+}
+
+// acquire takes a concurrency slot, waiting for one unless the dispatcher is
+// configured to reject when busy. The returned func releases the slot.
+func (d *Dispatcher) acquire(ctx context.Context) (func(), error) {
+	if d.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case d.slots <- struct{}{}:
+	default:
+		if d.rejectBusy {
+			return nil, transport.ErrBusy
+		}
+		select {
+		case d.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-d.slots }, nil
+}
+
+// dispatch runs msg through the pipeline.
+func (d *Dispatcher) dispatch(ctx context.Context, msg *message.Message, emit transport.StageFunc) (*message.DispatchResult, error) {
 	logger := slog.With("message_id", msg.ID, "source", msg.Source)
+	if msg.TraceID != "" {
+		logger = logger.With("trace_id", msg.TraceID)
+	}
+	if d.limiter != nil {
+		if ok, retry := d.limiter.Allow(msg); !ok {
+			logger.Warn("dispatch rate limited", "retry_after", retry.Round(time.Millisecond))
+			return nil, transport.ErrRateLimited
+		}
+	}
+	release, err := d.acquire(ctx)
+	if err != nil {
+		logger.Warn("dispatch not started", "error", err)
+		return nil, err
+	}
+	defer release()
+	d.inFlight.Add(1)
+	defer d.inFlight.Add(-1)
+
+	start := time.Now()
 	logger.Info("dispatch started")
 
+	p := d.pipeline.Load()
 	result := &message.DispatchResult{
 		MessageID: msg.ID,
 	}
+	notify := func(stage string) {
+		if emit != nil {
+			emit(stage, result)
+		}
+	}
+	p.applyRoute(&msg.Instruction, msg.Source)
+
+	interp, ok := p.interpreterFor(msg.Instruction.Interpreter)
+	if !ok {
+		result.Fail(message.ErrorStageInput, message.ErrorCodeNoInterpreter,
+			fmt.Sprintf("unknown interpreter %q", msg.Instruction.Interpreter))
+		logger.Warn("unknown interpreter", "interpreter", msg.Instruction.Interpreter)
+		return result, nil
+	}
+
+	// Step 0: Download audio passed by URL.
+	if !msg.HasAudio() && msg.AudioURL != "" {
+		if d.fetcher == nil {
+			result.Fail(message.ErrorStageInput, message.ErrorCodeAudioFetch, "audio_url is not enabled on this server")
+			return result, nil
+		}
+		data, contentType, err := d.fetcher.Fetch(ctx, msg.AudioURL)
+		if err != nil {
+			result.Fail(message.ErrorStageInput, message.ErrorCodeAudioFetch, fmt.Sprintf("fetching audio failed: %v", err))
+			logger.Error("fetching audio failed", "error", err)
+			return result, nil
+		}
+		msg.Audio = data
+		if msg.ContentType == "" {
+			msg.ContentType = contentType
+		}
+		logger.Debug("fetched audio", "content_type", msg.ContentType, "bytes", len(data))
+	}
+
+	// Clients often omit the content type, and backends would then assume WAV.
+	if msg.HasAudio() && message.IsGenericContentType(msg.ContentType) {
+		if sniffed := message.SniffAudioType(msg.Audio); sniffed != "" {
+			logger.Debug("detected audio content type", "declared", msg.ContentType, "detected", sniffed)
+			msg.ContentType = sniffed
+		}
+	}
+
+	if msg.HasAudio() {
+		if reason := checkWAV(msg); reason != "" {
+			result.Fail(message.ErrorStageInput, message.ErrorCodeInvalidAudio, reason)
+			logger.Warn("audio rejected", "reason", reason)
+			return result, nil
+		}
+		if reason := d.checkAudioLimits(msg); reason != "" {
+			result.Fail(message.ErrorStageInput, message.ErrorCodeAudioTooLarge, reason)
+			logger.Warn("audio rejected", "reason", reason)
+			return result, nil
+		}
+		if level, silent := d.isSilent(msg); silent {
+			result.Fail(message.ErrorStageInput, message.ErrorCodeNoSpeech, "no speech detected: audio is silent")
+			logger.Info("silent audio, not transcribing", "rms", level, "silence_threshold", d.silence)
+			return result, nil
+		}
+	}
 
 	// Step 1: Transcribe audio (if present).
 	var transcript string
 	var detectedLang string
 	if msg.HasAudio() {
 		logger.Debug("transcribing audio", "content_type", msg.ContentType, "bytes", len(msg.Audio))
-		res, err := d.interpreter.Transcribe(ctx, msg.Audio, msg.ContentType, interpreter.TranscribeOpts{
-			Prompt: msg.Instruction.Prompt,
+		res, err := interp.Transcribe(ctx, msg.Audio, msg.ContentType, interpreter.TranscribeOpts{
+			Language:  msg.Instruction.Language,
+			Prompt:    p.transcriptionPrompt(msg.Instruction.Language, msg.Instruction.Prompt),
+			Translate: msg.Instruction.Translate,
 		})
+		d.stats.transcriptions.done(err)
 		if err != nil {
-			result.Error = fmt.Sprintf("transcription failed: %v", err)
+			result.Fail(message.ErrorStageTranscription, backendErrorCode(err), fmt.Sprintf("transcription failed: %v", err))
 			logger.Error("transcription failed", "error", err)
 			return result, nil
 		}
@@ -67,62 +521,205 @@ func (d *Dispatcher) Handle(ctx context.Context, msg *message.Message) (*message
 		detectedLang = res.Language
 		result.Transcript = transcript
 		result.Language = detectedLang
-		logger.Info("transcription complete", "text_length", len(transcript), "language", detectedLang)
+		result.Segments = res.Segments
+		result.Confidence = res.AvgConfidence
+		logger.Info("transcription complete", "text_length", len(transcript), "language", detectedLang,
+			"confidence", res.AvgConfidence)
+		if res.AvgConfidence > 0 && res.AvgConfidence < p.minConf {
+			result.Fail(message.ErrorStageTranscription, message.ErrorCodeLowConfidence,
+				fmt.Sprintf("low confidence transcription (%.2f < %.2f)", res.AvgConfidence, p.minConf))
+			logger.Warn("low confidence transcription, not interpreting",
+				"confidence", res.AvgConfidence, "min_confidence", p.minConf)
+			return result, nil
+		}
 	} else if msg.Text != "" {
 		transcript = msg.Text
 		result.Transcript = transcript
 		logger.Debug("using text input directly")
 	} else {
-		result.Error = "message has no audio and no text"
+		result.Fail(message.ErrorStageInput, message.ErrorCodeNoInput, "message has no audio and no text")
+		return result, nil
+	}
+	// Text is reduced to its length in logs when logging.redact is set.
+	logger.Debug("transcript", "transcript", transcript)
+	notify(transport.StageTranscript)
+
+	if msg.Instruction.SkipInterpretation {
+		logger.Info("dispatch complete, interpretation skipped", "duration", time.Since(start))
+		return result, nil
+	}
+
+	if strings.TrimSpace(transcript) == "" {
+		stage := message.ErrorStageTranscription
+		if !msg.HasAudio() {
+			stage = message.ErrorStageInput
+		}
+		result.Fail(stage, message.ErrorCodeNoSpeech, "no speech detected: transcript is empty")
+		logger.Info("empty transcript, not interpreting")
 		return result, nil
 	}
 
 	// Step 2: Interpret transcript into commands.
-	interpResult, err := d.interpreter.Interpret(ctx, transcript, msg.Instruction)
+	lang := msg.Instruction.Language
+	if lang == "" {
+		lang = detectedLang
+	}
+	if msg.Instruction.Translate && msg.HasAudio() {
+		// The transcript is English regardless of what was spoken.
+		lang = "en"
+	}
+	if p.normalize[lang] {
+		transcript = normalize.Numbers(transcript, lang)
+		logger.Debug("normalized transcript numbers", "language", lang, "text_length", len(transcript))
+	}
+	var history []message.Turn
+	if d.sessions != nil && msg.SessionID != "" {
+		history = d.sessions.History(msg.SessionID)
+	}
+	interpResult, err := interp.Interpret(ctx, transcript, msg.Instruction, interpreter.InterpretOpts{
+		Language: lang,
+		Context:  p.promptFor(lang),
+		History:  history,
+	})
+	d.stats.interpretations.done(err)
 	if err != nil {
-		result.Error = fmt.Sprintf("interpretation failed: %v", err)
+		result.Fail(message.ErrorStageInterpretation, backendErrorCode(err), fmt.Sprintf("interpretation failed: %v", err))
 		logger.Error("interpretation failed", "error", err)
 		return result, nil
 	}
-	result.Commands = interpResult.Commands
-	result.ResponseText = interpResult.ResponseText
-	logger.Info("interpretation complete", "commands", len(interpResult.Commands))
+	d.stats.promptTokens.Add(int64(interpResult.Usage.PromptTokens))
+	d.stats.completionTokens.Add(int64(interpResult.Usage.CompletionTokens))
+	commands, err := p.transform(ctx, interpResult.Commands, logger)
+	if err != nil {
+		result.Commands = interpResult.Commands
+		result.ResponseText = interpResult.ResponseText
+		result.Fail(message.ErrorStageTransform, message.ErrorCodeTransformFailed, err.Error())
+		logger.Error("command transform failed", "error", err)
+		return result, nil
+	}
+	result.Commands = filterCommands(p.actions, commands, "", result)
+	result.Commands = validateCommands(p.schemas, msg.Instruction.ResponseFormat, result.Commands, result)
+	result.ResponseText = p.response.truncate(interpResult.ResponseText, msg.Instruction)
+	result.Usage = interpResult.Usage
+	for _, r := range result.Rejected {
+		logger.Warn("command rejected", "action", r.Command.Action, "reason", r.Reason)
+	}
+	if d.sessions != nil && msg.SessionID != "" && !msg.Instruction.DryRun {
+		d.sessions.Append(msg.SessionID, message.Turn{
+			Transcript:   transcript,
+			Commands:     result.Commands,
+			ResponseText: interpResult.ResponseText,
+			At:           time.Now(),
+		})
+	}
+	logger.Debug("interpreted", "commands", result.Commands, "response_text", result.ResponseText)
+	logger.Info("interpretation complete", "commands", len(result.Commands),
+		"total_tokens", interpResult.Usage.TotalTokens)
+	notify(transport.StageCommands)
+	if result.ResponseText != "" {
+		notify(transport.StageResponseText)
+	}
 
 	// Step 3: Synthesize a spoken response (if TTS is enabled and we have text).
-	if d.synthesizer != nil && result.ResponseText != "" {
-		lang := detectedLang
+	// A failure when audio was required is recorded after routing, so the
+	// commands still reach the targets without it.
+	mode := msg.Instruction.ResponseMode
+	var synthCode, synthErr string
+	if mode == message.ResponseModeAudio && p.synthesizer == nil && result.ResponseText != "" {
+		synthCode, synthErr = message.ErrorCodeTTSDisabled, "audio response requested but TTS is disabled"
+		logger.Warn("audio response requested but TTS is disabled")
+	}
+	if p.synthesizer != nil && result.ResponseText != "" && mode != message.ResponseModeText {
+		if fixed := msg.Instruction.FixedResponseLanguage(); fixed != "" {
+			lang = fixed
+		}
 		if lang == "" {
 			lang = "en"
 		}
 		logger.Debug("synthesizing response", "language", lang, "text_length", len(result.ResponseText))
-		synthResult, err := d.synthesizer.Synthesize(ctx, result.ResponseText, tts.SynthesizeOpts{
-			Language: lang,
-		})
+		synthOpts := tts.SynthesizeOpts{
+			Language:   lang,
+			Format:     msg.Instruction.AudioFormat,
+			SampleRate: msg.Instruction.SampleRate,
+		}
+		if speech := msg.Instruction.Speech; speech != nil {
+			synthOpts.Rate, synthOpts.Pitch, synthOpts.Volume = speech.Rate, speech.Pitch, speech.Volume
+		}
+		synthResult, err := p.synthesizer.Synthesize(ctx, result.ResponseText, synthOpts)
+		d.stats.syntheses.done(err)
 		if err != nil {
 			logger.Warn("TTS synthesis failed, continuing without audio", "error", err)
+			if mode == message.ResponseModeAudio {
+				synthCode, synthErr = message.ErrorCodeBackend, fmt.Sprintf("synthesis failed: %v", err)
+			}
 		} else {
 			result.ResponseAudio = synthResult.Audio
 			result.ResponseContentType = synthResult.ContentType
-			logger.Info("TTS synthesis complete", "audio_bytes", len(synthResult.Audio))
+			if d.audioStore != nil {
+				if key := d.audioStore.Put(synthResult.Audio, synthResult.ContentType); key != "" {
+					result.ResponseAudio = nil
+					result.ResponseAudioURL = d.audioBase + "/audio/" + key
+				} else {
+					logger.Warn("response audio too large for audio store, returning inline", "audio_bytes", len(synthResult.Audio))
+				}
+			}
+			result.ResponseAudioBytes = len(synthResult.Audio)
+			result.ResponseAudioDurationMs = audioDuration(synthResult).Milliseconds()
+			logger.Info("TTS synthesis complete", "audio_bytes", len(synthResult.Audio),
+				"duration_ms", result.ResponseAudioDurationMs)
+			notify(transport.StageResponseAudio)
 		}
 	}
 
 	// Step 4: Route commands to target services.
 	payload, err := json.Marshal(result)
 	if err != nil {
-		result.Error = fmt.Sprintf("marshalling result: %v", err)
+		result.Fail(message.ErrorStageRouting, message.ErrorCodeEncodingFailed, fmt.Sprintf("marshalling result: %v", err))
 		return result, nil
 	}
 
+	sent := *result // as encoded in payload, before routing updates result
+	result.DryRun = msg.Instruction.DryRun
 	for _, target := range msg.Instruction.Targets {
-		t, ok := d.transports[target.Protocol]
+		target = p.resolveTarget(target, logger)
+		t, ok := d.sender(target.Protocol)
 		if !ok {
 			logger.Warn("no transport for target protocol", "protocol", target.Protocol, "target", target.ServiceName)
+			result.Skipped = append(result.Skipped, message.SkippedTarget{
+				Target:   target.ServiceName,
+				Protocol: target.Protocol,
+				Reason:   "no transport for protocol: " + target.Protocol,
+			})
 			continue
 		}
 
-		if err := t.Send(ctx, target, payload); err != nil {
+		body, view := payload, &sent
+		if len(target.AllowedActions) > 0 {
+			filtered, send := targetResult(target, result, logger)
+			if !send {
+				continue
+			}
+			if body, err = json.Marshal(filtered); err != nil {
+				result.Fail(message.ErrorStageRouting, message.ErrorCodeEncodingFailed,
+					fmt.Sprintf("marshalling result for %s: %v", target.ServiceName, err))
+				continue
+			}
+			view = filtered
+		}
+
+		if result.DryRun {
+			result.RoutedTo = append(result.RoutedTo, target.ServiceName)
+			logger.Info("dry run, not sending to target", "target", target.ServiceName)
+			continue
+		}
+		if msg.Instruction.Sequential && len(view.Commands) > 0 {
+			if !sendSequence(ctx, t, target, view, result, logger) {
+				continue
+			}
+		} else if err := t.Send(ctx, target, body); err != nil {
 			logger.Error("failed to send to target", "target", target.ServiceName, "error", err)
+			result.Fail(message.ErrorStageRouting, message.ErrorCodeSendFailed,
+				fmt.Sprintf("sending to %s failed: %v", target.ServiceName, err))
 			continue
 		}
 
@@ -130,8 +727,253 @@ func (d *Dispatcher) Handle(ctx context.Context, msg *message.Message) (*message
 		logger.Info("routed to target", "target", target.ServiceName)
 	}
 
+	if synthErr != "" {
+		result.Fail(message.ErrorStageSynthesis, synthCode, synthErr)
+	}
+
 	logger.Info("dispatch complete", "duration", time.Since(start), "routed_to", len(result.RoutedTo))
 
 	// The result is always returned to the sender via the transport that received the message.
 	return result, nil
 }
+
+// errorText gives a failed result that must be spoken (response mode
+// "audio") its error as response text when it has none, so a client that
+// gets no audio still has something to say or show.
+func errorText(result *message.DispatchResult, instr message.Instruction) {
+	if instr.ResponseMode == message.ResponseModeAudio && result.Error != "" && result.ResponseText == "" {
+		result.ResponseText = result.Error
+	}
+}
+
+// debugEcho describes msg for a failed result: as received, with the
+// instruction it arrived with, and with the instruction dispatch resolved.
+func debugEcho(msg *message.Message, received message.Instruction) *message.DebugEcho {
+	return &message.DebugEcho{
+		MessageID:           msg.ID,
+		Source:              msg.Source,
+		TraceID:             msg.TraceID,
+		SessionID:           msg.SessionID,
+		Text:                msg.Text,
+		AudioURL:            msg.AudioURL,
+		AudioBytes:          len(msg.Audio),
+		AudioSHA256:         msg.AudioSHA256(),
+		ContentType:         msg.ContentType,
+		Instruction:         received,
+		ResolvedInstruction: msg.Instruction,
+	}
+}
+
+// targetResult returns result with only the commands target accepts,
+// recording the others in result.Rejected. send is false if the target
+// accepts none of a non-empty command list, so there is nothing to send.
+func targetResult(target message.Target, result *message.DispatchResult, logger *slog.Logger) (filtered *message.DispatchResult, send bool) {
+	before := len(result.Rejected)
+	view := *result
+	view.Commands = filterCommands(actionFilter{allow: target.AllowedActions}, result.Commands, target.ServiceName, result)
+	for _, r := range result.Rejected[before:] {
+		logger.Warn("command rejected for target", "target", target.ServiceName, "action", r.Command.Action, "reason", r.Reason)
+	}
+	if len(view.Commands) == 0 && len(result.Commands) > 0 {
+		logger.Info("no commands accepted by target, not sending", "target", target.ServiceName)
+		return nil, false
+	}
+	view.Rejected = nil
+	return &view, true
+}
+
+// sendSequence sends view's commands to target one at a time, each in a
+// copy of view holding only that command, waiting for each send to succeed
+// before the next. The first failure ends the sequence; it is recorded in
+// result, and sendSequence reports false.
+func sendSequence(ctx context.Context, t transport.Sender, target message.Target, view, result *message.DispatchResult, logger *slog.Logger) bool {
+	step := *view
+	for idx, cmd := range view.Commands {
+		step.Commands = []message.Command{cmd}
+		body, err := json.Marshal(&step)
+		if err == nil {
+			err = t.Send(ctx, target, body)
+		}
+		if err == nil {
+			logger.Debug("sent command in sequence", "target", target.ServiceName, "index", idx, "action", cmd.Action)
+			continue
+		}
+		unsent := len(view.Commands) - idx - 1
+		logger.Error("sequence to target failed", "target", target.ServiceName,
+			"index", idx, "action", cmd.Action, "unsent", unsent, "error", err)
+		result.FailedCommands = append(result.FailedCommands, message.FailedCommand{
+			Target:  target.ServiceName,
+			Index:   idx,
+			Command: cmd,
+			Error:   err.Error(),
+			Unsent:  unsent,
+		})
+		result.Fail(message.ErrorStageRouting, message.ErrorCodeSendFailed,
+			fmt.Sprintf("sending command %d of %d (%s) to %s failed: %v", idx+1, len(view.Commands), cmd.Action, target.ServiceName, err))
+		return false
+	}
+	return true
+}
+
+// checkWAV returns why msg's audio, if it is declared or detected as WAV,
+// cannot be parsed, or "" if it can. WAV files with non-PCM samples pass,
+// since transcription backends may still accept them.
+func checkWAV(msg *message.Message) string {
+	if !message.IsWAVContentType(msg.ContentType) && message.SniffAudioType(msg.Audio) != "audio/wav" {
+		return ""
+	}
+	_, pcm, err := audio.ParseWAV(msg.Audio)
+	switch {
+	case errors.Is(err, audio.ErrUnsupportedEncoding):
+		return ""
+	case err != nil:
+		return "malformed wav audio: " + err.Error()
+	case len(pcm) == 0:
+		return "malformed wav audio: no samples"
+	}
+	return ""
+}
+
+// checkAudioLimits returns why msg's audio exceeds the configured size or
+// duration limit, or "" if it doesn't.
+func (d *Dispatcher) checkAudioLimits(msg *message.Message) string {
+	if d.maxAudio > 0 && len(msg.Audio) > d.maxAudio {
+		return fmt.Sprintf("audio is %d bytes, more than the allowed %d", len(msg.Audio), d.maxAudio)
+	}
+	if d.maxLength <= 0 {
+		return ""
+	}
+	format, pcm, err := audio.ParseWAV(msg.Audio)
+	if err != nil {
+		return "" // not PCM WAV: duration unknown
+	}
+	if length := format.Duration(pcm); length > d.maxLength {
+		return fmt.Sprintf("audio is %s long, more than the allowed %s", length.Round(time.Millisecond), d.maxLength)
+	}
+	return ""
+}
+
+// isSilent reports whether msg's audio is 16-bit PCM WAV below the silence
+// threshold, and its RMS level. Other audio is never considered silent.
+func (d *Dispatcher) isSilent(msg *message.Message) (float64, bool) {
+	if d.silence <= 0 {
+		return 0, false
+	}
+	format, pcm, err := audio.ParseWAV(msg.Audio)
+	if err != nil || format.BitsPerSample != 16 {
+		return 0, false
+	}
+	level := audio.RMS16(pcm)
+	return level, level < d.silence
+}
+
+// backendErrorCode classifies an interpreter error for DispatchResult.ErrorCode.
+func backendErrorCode(err error) string {
+	if errors.Is(err, interpreter.ErrTimeout) {
+		return message.ErrorCodeTimeout
+	}
+	return message.ErrorCodeBackend
+}
+
+// resolveTarget completes an instruction target from the configured target of
+// the same name: a missing endpoint, protocol or gRPC mode is filled in, and credentials
+// and headers are attached only when the endpoint is the configured one, so a client can't
+// redirect a token to a host of its choosing.
+func (p *pipeline) resolveTarget(target message.Target, logger *slog.Logger) message.Target {
+	conf, ok := p.targets[target.ServiceName]
+	if !ok {
+		return target
+	}
+	if target.Endpoint == "" {
+		target.Endpoint = conf.Endpoint
+	}
+	if target.Protocol == "" {
+		target.Protocol = conf.Protocol
+	}
+	if target.GRPCMode == "" && target.GRPCMethod == "" {
+		target.GRPCMode, target.GRPCMethod = conf.GRPCMode, conf.GRPCMethod
+	}
+	// The action allow-list and TLS apply whichever endpoint the client chose.
+	target.AllowedActions = conf.AllowedActions
+	target.TLS = target.TLS || conf.TLS
+	if target.Endpoint != conf.Endpoint {
+		if conf.Token != "" || len(conf.Headers) > 0 {
+			logger.Warn("target endpoint differs from configured endpoint, not sending token or headers", "target", target.ServiceName)
+		}
+		return target
+	}
+	target.Token = conf.Token
+	target.AuthHeader = conf.AuthHeader
+	target.AuthScheme = conf.AuthScheme
+	target.Headers = conf.Headers
+	return target
+}
+
+// interpreterFor returns the interpreter selected by name: the default one
+// for "" or "default", otherwise the named one if it exists.
+func (p *pipeline) interpreterFor(name string) (interpreter.Interpreter, bool) {
+	if name == "" || name == "default" {
+		return p.interpreter, true
+	}
+	interp, ok := p.named[name]
+	return interp, ok
+}
+
+// promptFor returns the configured prompt context for lang, falling back to
+// the "default" entry.
+func (p *pipeline) promptFor(lang string) string {
+	return byLanguage(p.prompts, lang)
+}
+
+// transcriptionPrompt combines the configured vocabulary hint for lang with
+// the caller's prompt. The caller's prompt goes last, where Whisper weighs
+// it most. Without a language hint only the "default" entry applies, since
+// the language is not known until transcription.
+func (p *pipeline) transcriptionPrompt(lang, callerPrompt string) string {
+	vocab := byLanguage(p.vocab, lang)
+	switch {
+	case vocab == "":
+		return callerPrompt
+	case callerPrompt == "":
+		return vocab
+	}
+	return vocab + " " + callerPrompt
+}
+
+// byLanguage returns m[lang], falling back to the entry for lang's base
+// language (e.g., "fr" for "fr-CA") and then to the "default" entry.
+func byLanguage(m map[string]string, lang string) string {
+	if lang == "" {
+		return m["default"]
+	}
+	if v, ok := m[lang]; ok {
+		return v
+	}
+	if base, _, ok := strings.Cut(lang, "-"); ok {
+		if v, ok := m[base]; ok {
+			return v
+		}
+	}
+	return m["default"]
+}
+
+// audioDuration computes the playback duration of synthesized audio from its
+// PCM length. WAV audio is measured by its own header; raw "audio/pcm" is
+// 16-bit at the result's sample rate and channel count. Compressed formats
+// report 0.
+func audioDuration(res *tts.SynthesizeResult) time.Duration {
+	if res == nil || len(res.Audio) == 0 {
+		return 0
+	}
+	switch res.ContentType {
+	case "audio/wav":
+		f, pcm, err := audio.ParseWAV(res.Audio)
+		if err != nil {
+			return 0
+		}
+		return f.Duration(pcm)
+	case "audio/pcm":
+		return audio.PCMDuration(len(res.Audio), res.SampleRate, max(res.Channels, 1), 2)
+	}
+	return 0
+}
@@ -0,0 +1,627 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+	"github.com/nadzzz/switchyard/internal/tts"
+)
+
+// fakeInterpreter returns canned results and records what it was asked.
+type fakeInterpreter struct {
+	transcript    *interpreter.TranscribeResult
+	transcribeErr error
+	result        *interpreter.InterpretResult
+	interpretErr  error
+
+	mu          sync.Mutex
+	transcribed []interpreter.TranscribeOpts
+	interpreted []string
+	opts        []interpreter.InterpretOpts
+}
+
+func (f *fakeInterpreter) Name() string { return "fake" }
+
+func (f *fakeInterpreter) Transcribe(_ context.Context, _ []byte, _ string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	f.mu.Lock()
+	f.transcribed = append(f.transcribed, opts)
+	f.mu.Unlock()
+	if f.transcribeErr != nil {
+		return nil, f.transcribeErr
+	}
+	if f.transcript == nil {
+		return &interpreter.TranscribeResult{Text: "turn on the light", Language: "en"}, nil
+	}
+	return f.transcript, nil
+}
+
+func (f *fakeInterpreter) Interpret(_ context.Context, text string, _ message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	f.mu.Lock()
+	f.interpreted = append(f.interpreted, text)
+	f.opts = append(f.opts, opts)
+	f.mu.Unlock()
+	if f.interpretErr != nil {
+		return nil, f.interpretErr
+	}
+	if f.result == nil {
+		return &interpreter.InterpretResult{
+			Commands:     []message.Command{{Action: "turn_on", Params: map[string]any{"entity": "light.kitchen"}}},
+			ResponseText: "Turning on the light.",
+		}, nil
+	}
+	return f.result, nil
+}
+
+func (f *fakeInterpreter) Close() error { return nil }
+
+// fakeSynthesizer returns a short WAV, or err, and records its calls.
+type fakeSynthesizer struct {
+	err error
+
+	mu    sync.Mutex
+	texts []string
+	opts  []tts.SynthesizeOpts
+}
+
+func (f *fakeSynthesizer) Synthesize(_ context.Context, text string, opts tts.SynthesizeOpts) (*tts.SynthesizeResult, error) {
+	f.mu.Lock()
+	f.texts = append(f.texts, text)
+	f.opts = append(f.opts, opts)
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &tts.SynthesizeResult{Audio: testWAV(make([]byte, 3200)), ContentType: "audio/wav", SampleRate: 16000, Channels: 1}, nil
+}
+
+func (f *fakeSynthesizer) Close() error { return nil }
+
+// sent is one payload delivered by a fakeTransport.
+type sent struct {
+	target  message.Target
+	payload []byte
+}
+
+// fakeTransport records every payload sent through it and fails sends to
+// the targets named in fail.
+type fakeTransport struct {
+	name string
+	fail map[string]error
+
+	mu   sync.Mutex
+	sent []sent
+}
+
+func (f *fakeTransport) Name() string { return f.name }
+
+func (f *fakeTransport) Listen(ctx context.Context, _ transport.Handler) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeTransport) Send(_ context.Context, target message.Target, payload []byte) error {
+	if err := f.fail[target.ServiceName]; err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, sent{target: target, payload: payload})
+	return nil
+}
+
+func (f *fakeTransport) Close() error { return nil }
+
+// payloads decodes the results sent through f.
+func (f *fakeTransport) payloads(t *testing.T) []message.DispatchResult {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make([]message.DispatchResult, len(f.sent))
+	for i, s := range f.sent {
+		if err := json.Unmarshal(s.payload, &out[i]); err != nil {
+			t.Fatalf("decoding payload %d: %v", i, err)
+		}
+	}
+	return out
+}
+
+// testWAV wraps 16 kHz mono 16-bit pcm in a canonical WAV header.
+func testWAV(pcm []byte) []byte {
+	const rate, channels, bits = 16000, 1, 16
+	h := make([]byte, 0, 44+len(pcm))
+	le32 := func(v int) []byte { return []byte{byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24)} }
+	le16 := func(v int) []byte { return []byte{byte(v), byte(v >> 8)} }
+	h = append(h, "RIFF"...)
+	h = append(h, le32(36+len(pcm))...)
+	h = append(h, "WAVEfmt "...)
+	h = append(h, le32(16)...)
+	h = append(h, le16(1)...)
+	h = append(h, le16(channels)...)
+	h = append(h, le32(rate)...)
+	h = append(h, le32(rate*channels*bits/8)...)
+	h = append(h, le16(channels*bits/8)...)
+	h = append(h, le16(bits)...)
+	h = append(h, "data"...)
+	h = append(h, le32(len(pcm))...)
+	return append(h, pcm...)
+}
+
+// loudPCM returns n bytes of a full-scale square wave.
+func loudPCM(n int) []byte {
+	pcm := make([]byte, n)
+	for i := 0; i+1 < n; i += 2 {
+		if i%64 < 32 {
+			pcm[i], pcm[i+1] = 0xFF, 0x7F
+		} else {
+			pcm[i], pcm[i+1] = 0x01, 0x80
+		}
+	}
+	return pcm
+}
+
+// handle dispatches msg and fails the test on a transport-level error.
+func handle(t *testing.T, d *Dispatcher, msg *message.Message) *message.DispatchResult {
+	t.Helper()
+	result, err := d.Handle(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	return result
+}
+
+// textMessage returns a text message routed to an http target named "home".
+func textMessage(text string) *message.Message {
+	return &message.Message{
+		ID:   "m1",
+		Text: text,
+		Instruction: message.Instruction{
+			Targets: []message.Target{{ServiceName: "home", Protocol: "http", Endpoint: "http://home.local/cmd"}},
+		},
+	}
+}
+
+func TestErrorStages(t *testing.T) {
+	timeoutErr := fmt.Errorf("transcription %w after 1s", interpreter.ErrTimeout)
+	tests := []struct {
+		name   string
+		interp *fakeInterpreter
+		fail   map[string]error
+		msg    *message.Message
+		stage  string
+		code   string
+		routed bool
+	}{
+		{
+			name:   "no input",
+			interp: &fakeInterpreter{},
+			msg:    &message.Message{ID: "m1"},
+			stage:  message.ErrorStageInput, code: message.ErrorCodeNoInput,
+		},
+		{
+			name:   "unknown interpreter",
+			interp: &fakeInterpreter{},
+			msg:    &message.Message{ID: "m1", Text: "hi", Instruction: message.Instruction{Interpreter: "nope"}},
+			stage:  message.ErrorStageInput, code: message.ErrorCodeNoInterpreter,
+		},
+		{
+			name:   "malformed wav",
+			interp: &fakeInterpreter{},
+			msg:    &message.Message{ID: "m1", Audio: []byte("RIFF\x00\x00\x00\x00WAVEjunk"), ContentType: "audio/wav"},
+			stage:  message.ErrorStageInput, code: message.ErrorCodeInvalidAudio,
+		},
+		{
+			name:   "transcription backend error",
+			interp: &fakeInterpreter{transcribeErr: errors.New("boom")},
+			msg:    &message.Message{ID: "m1", Audio: testWAV(loudPCM(3200)), ContentType: "audio/wav"},
+			stage:  message.ErrorStageTranscription, code: message.ErrorCodeBackend,
+		},
+		{
+			name:   "transcription timeout",
+			interp: &fakeInterpreter{transcribeErr: timeoutErr},
+			msg:    &message.Message{ID: "m1", Audio: testWAV(loudPCM(3200)), ContentType: "audio/wav"},
+			stage:  message.ErrorStageTranscription, code: message.ErrorCodeTimeout,
+		},
+		{
+			name:   "empty transcript",
+			interp: &fakeInterpreter{transcript: &interpreter.TranscribeResult{Text: "  "}},
+			msg:    &message.Message{ID: "m1", Audio: testWAV(loudPCM(3200)), ContentType: "audio/wav"},
+			stage:  message.ErrorStageTranscription, code: message.ErrorCodeNoSpeech,
+		},
+		{
+			name:   "interpretation error",
+			interp: &fakeInterpreter{interpretErr: errors.New("bad json")},
+			msg:    textMessage("turn on the light"),
+			stage:  message.ErrorStageInterpretation, code: message.ErrorCodeBackend,
+		},
+		{
+			name:   "send failure",
+			interp: &fakeInterpreter{},
+			fail:   map[string]error{"home": errors.New("connection refused")},
+			msg:    textMessage("turn on the light"),
+			stage:  message.ErrorStageRouting, code: message.ErrorCodeSendFailed,
+		},
+		{
+			name:   "success",
+			interp: &fakeInterpreter{},
+			msg:    textMessage("turn on the light"),
+			routed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &fakeTransport{name: "http", fail: tt.fail}
+			d := New(tt.interp, []transport.Transport{tr}, nil, Options{})
+			result := handle(t, d, tt.msg)
+			if result.ErrorStage != tt.stage || result.ErrorCode != tt.code {
+				t.Errorf("error_stage/error_code = %q/%q, want %q/%q (error %q)",
+					result.ErrorStage, result.ErrorCode, tt.stage, tt.code, result.Error)
+			}
+			if (tt.stage == "") != (result.Error == "") {
+				t.Errorf("error = %q with stage %q", result.Error, result.ErrorStage)
+			}
+			if got := len(result.RoutedTo) > 0; got != tt.routed {
+				t.Errorf("routed_to = %v, want routed %v", result.RoutedTo, tt.routed)
+			}
+		})
+	}
+}
+
+func TestFailAccumulatesRoutingErrors(t *testing.T) {
+	tr := &fakeTransport{name: "http", fail: map[string]error{
+		"a": errors.New("refused"),
+		"b": errors.New("timeout"),
+	}}
+	d := New(&fakeInterpreter{}, []transport.Transport{tr}, nil, Options{})
+	msg := textMessage("turn on the light")
+	msg.Instruction.Targets = []message.Target{
+		{ServiceName: "a", Protocol: "http", Endpoint: "http://a"},
+		{ServiceName: "b", Protocol: "http", Endpoint: "http://b"},
+	}
+	result := handle(t, d, msg)
+	if result.ErrorCode != message.ErrorCodeSendFailed {
+		t.Fatalf("error_code = %q", result.ErrorCode)
+	}
+	for _, name := range []string{"sending to a", "sending to b"} {
+		if !strings.Contains(result.Error, name) {
+			t.Errorf("error %q does not mention %q", result.Error, name)
+		}
+	}
+}
+
+func TestAudioModeErrorText(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		wantText bool
+	}{
+		{"audio mode carries the error", message.ResponseModeAudio, true},
+		{"default mode leaves text empty", "", false},
+		{"text mode leaves text empty", message.ResponseModeText, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := &fakeInterpreter{transcribeErr: errors.New("boom")}
+			d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, &fakeSynthesizer{}, Options{})
+			msg := &message.Message{
+				ID:          "m1",
+				Audio:       testWAV(loudPCM(3200)),
+				ContentType: "audio/wav",
+				Instruction: message.Instruction{ResponseMode: tt.mode},
+			}
+			result := handle(t, d, msg)
+			if result.Error == "" {
+				t.Fatal("dispatch did not fail")
+			}
+			if tt.wantText && result.ResponseText != result.Error {
+				t.Errorf("response_text = %q, want the error %q", result.ResponseText, result.Error)
+			}
+			if !tt.wantText && result.ResponseText != "" {
+				t.Errorf("response_text = %q, want empty", result.ResponseText)
+			}
+		})
+	}
+}
+
+func TestAudioModeSynthesisFailure(t *testing.T) {
+	tr := &fakeTransport{name: "http"}
+	d := New(&fakeInterpreter{}, []transport.Transport{tr}, &fakeSynthesizer{err: errors.New("piper down")}, Options{})
+	msg := textMessage("turn on the light")
+	msg.Instruction.ResponseMode = message.ResponseModeAudio
+	result := handle(t, d, msg)
+	if result.ErrorStage != message.ErrorStageSynthesis || result.ErrorCode != message.ErrorCodeBackend {
+		t.Errorf("error_stage/error_code = %q/%q, want synthesis/backend_error", result.ErrorStage, result.ErrorCode)
+	}
+	if result.ResponseText != "Turning on the light." {
+		t.Errorf("response_text = %q, want the interpreter's", result.ResponseText)
+	}
+	if len(result.RoutedTo) != 1 {
+		t.Fatalf("routed_to = %v, want the target", result.RoutedTo)
+	}
+	for _, p := range tr.payloads(t) {
+		if p.Error != "" {
+			t.Errorf("routed payload carries error %q", p.Error)
+		}
+	}
+}
+
+func TestTextModeSkipsSynthesis(t *testing.T) {
+	synth := &fakeSynthesizer{}
+	d := New(&fakeInterpreter{}, []transport.Transport{&fakeTransport{name: "http"}}, synth, Options{})
+	msg := textMessage("turn on the light")
+	msg.Instruction.ResponseMode = message.ResponseModeText
+	result := handle(t, d, msg)
+	if len(synth.texts) != 0 || len(result.ResponseAudio) != 0 {
+		t.Errorf("synthesized %q, want nothing in text mode", synth.texts)
+	}
+	if result.ResponseText != "Turning on the light." {
+		t.Errorf("response_text = %q, want the interpreter's", result.ResponseText)
+	}
+}
+
+func TestUnknownProtocolSkipped(t *testing.T) {
+	tr := &fakeTransport{name: "http"}
+	d := New(&fakeInterpreter{}, []transport.Transport{tr}, nil, Options{})
+	msg := textMessage("turn on the light")
+	msg.Instruction.Targets = append(msg.Instruction.Targets,
+		message.Target{ServiceName: "rpc", Protocol: "grpc", Endpoint: "rpc.local:50051"})
+	result := handle(t, d, msg)
+	if result.Error != "" {
+		t.Errorf("error = %q (%s/%s), want none", result.Error, result.ErrorStage, result.ErrorCode)
+	}
+	if len(result.Skipped) != 1 || result.Skipped[0].Target != "rpc" || result.Skipped[0].Protocol != "grpc" {
+		t.Errorf("skipped = %+v, want the grpc target", result.Skipped)
+	}
+	if len(result.RoutedTo) != 1 || result.RoutedTo[0] != "home" {
+		t.Errorf("routed_to = %v, want [home]", result.RoutedTo)
+	}
+}
+
+func TestSilentAudio(t *testing.T) {
+	tests := []struct {
+		name       string
+		pcm        []byte
+		threshold  float64
+		transcribe bool
+	}{
+		{"silent", make([]byte, 3200), 0.01, false},
+		{"loud", loudPCM(3200), 0.01, true},
+		{"silent with check disabled", make([]byte, 3200), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := &fakeInterpreter{}
+			d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, nil, Options{SilenceThreshold: tt.threshold})
+			result := handle(t, d, &message.Message{ID: "m1", Audio: testWAV(tt.pcm), ContentType: "audio/wav"})
+			if got := len(interp.transcribed) > 0; got != tt.transcribe {
+				t.Fatalf("transcribed = %v, want %v", got, tt.transcribe)
+			}
+			if !tt.transcribe && (result.ErrorStage != message.ErrorStageInput || result.ErrorCode != message.ErrorCodeNoSpeech) {
+				t.Errorf("error_stage/error_code = %q/%q, want input/no_speech", result.ErrorStage, result.ErrorCode)
+			}
+			if tt.transcribe && result.Error != "" {
+				t.Errorf("error = %q, want none", result.Error)
+			}
+		})
+	}
+}
+
+func TestEmptyTranscriptNotInterpreted(t *testing.T) {
+	interp := &fakeInterpreter{transcript: &interpreter.TranscribeResult{Text: " "}}
+	d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, nil, Options{})
+	handle(t, d, &message.Message{ID: "m1", Audio: testWAV(loudPCM(3200)), ContentType: "audio/wav"})
+	if len(interp.interpreted) != 0 {
+		t.Errorf("interpreted %q, want nothing", interp.interpreted)
+	}
+}
+
+func TestResolveTargetCredentials(t *testing.T) {
+	conf := message.Target{
+		ServiceName: "ha",
+		Protocol:    "http",
+		Endpoint:    "http://ha.local/api",
+		Token:       "s3cret",
+		Headers:     map[string]string{"X-Tenant": "home"},
+	}
+	p := &pipeline{targets: map[string]message.Target{"ha": conf}}
+	tests := []struct {
+		name     string
+		target   message.Target
+		endpoint string
+		creds    bool
+	}{
+		{"configured endpoint filled in", message.Target{ServiceName: "ha"}, conf.Endpoint, true},
+		{"configured endpoint repeated", message.Target{ServiceName: "ha", Endpoint: conf.Endpoint}, conf.Endpoint, true},
+		{"other endpoint", message.Target{ServiceName: "ha", Endpoint: "http://evil.example/"}, "http://evil.example/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.resolveTarget(tt.target, slog.Default())
+			if got.Endpoint != tt.endpoint || got.Protocol != "http" {
+				t.Errorf("endpoint/protocol = %q/%q, want %q/http", got.Endpoint, got.Protocol, tt.endpoint)
+			}
+			if has := got.Token != "" || len(got.Headers) > 0; has != tt.creds {
+				t.Errorf("token %q, headers %v: want credentials %v", got.Token, got.Headers, tt.creds)
+			}
+		})
+	}
+}
+
+func TestDebugEchoOmitsAudio(t *testing.T) {
+	interp := &fakeInterpreter{transcribeErr: errors.New("boom")}
+	d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, nil, Options{
+		DebugEcho: true,
+		Targets: map[string]message.Target{
+			"home": {ServiceName: "home", Protocol: "http", Endpoint: "http://home.local/cmd", Token: "s3cret"},
+		},
+	})
+	wav := testWAV(loudPCM(3200))
+	msg := &message.Message{
+		ID:          "m1",
+		Audio:       wav,
+		ContentType: "audio/wav",
+		Instruction: message.Instruction{Targets: []message.Target{{ServiceName: "home"}}},
+	}
+	result := handle(t, d, msg)
+	if result.Debug == nil {
+		t.Fatal("failed result has no debug echo")
+	}
+	if result.Debug.AudioBytes != len(wav) || result.Debug.AudioSHA256 != msg.AudioSHA256() {
+		t.Errorf("audio_bytes/audio_sha256 = %d/%q, want %d/%q",
+			result.Debug.AudioBytes, result.Debug.AudioSHA256, len(wav), msg.AudioSHA256())
+	}
+	body, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), base64.StdEncoding.EncodeToString(wav[:63])) {
+		t.Error("debug echo carries the audio")
+	}
+	if strings.Contains(string(body), "s3cret") {
+		t.Error("debug echo carries the target token")
+	}
+
+	if result := handle(t, d, textMessage("turn on the light")); result.Debug != nil {
+		t.Errorf("successful result has debug echo %+v", result.Debug)
+	}
+}
+
+func TestPromptSelection(t *testing.T) {
+	prompts := map[string]string{
+		"default": "Rooms: kitchen, living room.",
+		"en":      "The lounge is light.living_room.",
+		"fr":      "Le salon s'appelle light.salon.",
+	}
+	tests := []struct {
+		name     string
+		language string // instruction language
+		detected string // transcription language of audio input; "" sends text
+		want     string
+	}{
+		{name: "exact match", language: "fr", want: prompts["fr"]},
+		{name: "region falls back to base", language: "fr-CA", want: prompts["fr"]},
+		{name: "unknown language", language: "de", want: prompts["default"]},
+		{name: "no language", want: prompts["default"]},
+		{name: "detected language", detected: "en", want: prompts["en"]},
+		{name: "instruction overrides detection", language: "fr", detected: "en", want: prompts["fr"]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := &fakeInterpreter{transcript: &interpreter.TranscribeResult{Text: "turn on the light", Language: tt.detected}}
+			d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, nil, Options{Prompts: prompts})
+			msg := textMessage("turn on the light")
+			if tt.detected != "" {
+				msg.Text = ""
+				msg.Audio, msg.ContentType = testWAV(loudPCM(3200)), "audio/wav"
+			}
+			msg.Instruction.Language = tt.language
+			if result := handle(t, d, msg); result.Error != "" {
+				t.Fatalf("error %q", result.Error)
+			}
+			if len(interp.opts) != 1 {
+				t.Fatalf("interpreted %d times", len(interp.opts))
+			}
+			if got := interp.opts[0].Context; got != tt.want {
+				t.Errorf("context = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioDuration(t *testing.T) {
+	second16k := make([]byte, 32000) // one second of 16 kHz mono 16-bit PCM
+	tests := []struct {
+		name string
+		res  *tts.SynthesizeResult
+		want time.Duration
+	}{
+		{name: "nil", want: 0},
+		{name: "empty", res: &tts.SynthesizeResult{ContentType: "audio/wav", SampleRate: 16000, Channels: 1}, want: 0},
+		{name: "pcm 16 kHz mono", res: &tts.SynthesizeResult{Audio: second16k, ContentType: "audio/pcm", SampleRate: 16000, Channels: 1}, want: time.Second},
+		{name: "pcm 16 kHz stereo", res: &tts.SynthesizeResult{Audio: second16k, ContentType: "audio/pcm", SampleRate: 16000, Channels: 2}, want: 500 * time.Millisecond},
+		{name: "pcm 22.05 kHz mono", res: &tts.SynthesizeResult{Audio: make([]byte, 22050), ContentType: "audio/pcm", SampleRate: 22050, Channels: 1}, want: 500 * time.Millisecond},
+		{name: "pcm 22.05 kHz stereo", res: &tts.SynthesizeResult{Audio: make([]byte, 88200), ContentType: "audio/pcm", SampleRate: 22050, Channels: 2}, want: time.Second},
+		{name: "pcm channels unset", res: &tts.SynthesizeResult{Audio: second16k, ContentType: "audio/pcm", SampleRate: 16000}, want: time.Second},
+		// The header is not counted: 44 bytes would add 1.375 ms.
+		{name: "wav", res: &tts.SynthesizeResult{Audio: testWAV(second16k), ContentType: "audio/wav", SampleRate: 16000, Channels: 1}, want: time.Second},
+		{name: "wav header only", res: &tts.SynthesizeResult{Audio: testWAV(nil), ContentType: "audio/wav", SampleRate: 16000, Channels: 1}, want: 0},
+		{name: "compressed", res: &tts.SynthesizeResult{Audio: second16k, ContentType: "audio/mpeg", SampleRate: 24000, Channels: 1}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audioDuration(tt.res); got != tt.want {
+				t.Errorf("audioDuration = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipInterpretation(t *testing.T) {
+	audioMessage := func() *message.Message {
+		msg := textMessage("")
+		msg.Audio, msg.ContentType = testWAV(loudPCM(3200)), "audio/wav"
+		return msg
+	}
+	tests := []struct {
+		name        string
+		msg         func() *message.Message
+		skip        bool
+		mode        string
+		transcribed int
+		interpreted bool
+	}{
+		{name: "audio, skipped", msg: audioMessage, skip: true, transcribed: 1},
+		{name: "audio, skipped in audio mode", msg: audioMessage, skip: true, mode: message.ResponseModeAudio, transcribed: 1},
+		{name: "text, skipped", msg: func() *message.Message { return textMessage("turn on the light") }, skip: true},
+		// Sending text is the interpret-only path: nothing is transcribed.
+		{name: "text only", msg: func() *message.Message { return textMessage("turn on the light") }, interpreted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := &fakeInterpreter{}
+			synth := &fakeSynthesizer{}
+			tr := &fakeTransport{name: "http"}
+			d := New(interp, []transport.Transport{tr}, synth, Options{})
+			msg := tt.msg()
+			msg.Instruction.SkipInterpretation = tt.skip
+			msg.Instruction.ResponseMode = tt.mode
+
+			result := handle(t, d, msg)
+			if result.Error != "" {
+				t.Fatalf("error %q", result.Error)
+			}
+			if result.Transcript != "turn on the light" {
+				t.Errorf("transcript = %q", result.Transcript)
+			}
+			if len(interp.transcribed) != tt.transcribed {
+				t.Errorf("transcribed %d times, want %d", len(interp.transcribed), tt.transcribed)
+			}
+			if got := len(interp.interpreted) > 0; got != tt.interpreted {
+				t.Errorf("interpreted = %v, want %v", got, tt.interpreted)
+			}
+			if tt.interpreted {
+				return
+			}
+			// Skipping interpretation leaves nothing to speak or route.
+			if len(result.Commands) > 0 || result.ResponseText != "" || len(result.ResponseAudio) > 0 {
+				t.Errorf("commands %v, response text %q, %d audio bytes", result.Commands, result.ResponseText, len(result.ResponseAudio))
+			}
+			if len(synth.texts) > 0 {
+				t.Errorf("synthesized %q", synth.texts)
+			}
+			if len(result.RoutedTo) > 0 || len(tr.payloads(t)) > 0 {
+				t.Errorf("routed to %v", result.RoutedTo)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package dispatch
+
+import (
+	"context"
+	"sync"
+)
+
+// drainState tracks calls into the dispatcher so shutdown can wait for them.
+type drainState struct {
+	mu       sync.Mutex
+	active   int
+	draining bool
+	idle     chan struct{} // closed once draining and no calls are active
+}
+
+// begin registers a call. It returns false once draining has started.
+func (s *drainState) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return false
+	}
+	s.active++
+	return true
+}
+
+// end unregisters a call started with begin.
+func (s *drainState) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if s.draining && s.active == 0 {
+		close(s.idle)
+	}
+}
+
+// drain stops new calls and returns a channel closed when the active ones
+// have finished.
+func (s *drainState) drain() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.draining {
+		s.draining = true
+		s.idle = make(chan struct{})
+		if s.active == 0 {
+			close(s.idle)
+		}
+	}
+	return s.idle
+}
+
+// Wait stops the dispatcher from accepting messages and waits until the
+// messages already being handled are finished or ctx is done. Messages that
+// arrive afterwards fail with transport.ErrShuttingDown. Call it on shutdown,
+// before closing transports and backends.
+func (d *Dispatcher) Wait(ctx context.Context) error {
+	select {
+	case <-d.drain.drain():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Pending returns the number of messages being handled, including those
+// waiting for a concurrency slot or for a duplicate's result.
+func (d *Dispatcher) Pending() int {
+	d.drain.mu.Lock()
+	defer d.drain.mu.Unlock()
+	return d.drain.active
+}
@@ -0,0 +1,64 @@
+package dispatch
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/nadzzz/switchyard/internal/cmdschema"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// actionFilter decides which interpreted actions may be routed. Patterns use
+// path.Match syntax and are validated when the config is loaded.
+type actionFilter struct {
+	allow []string // empty = every action not denied
+	deny  []string
+}
+
+// reject returns why action may not be routed, or "" if it may.
+func (f actionFilter) reject(action string) string {
+	if matchAny(f.deny, action) {
+		return fmt.Sprintf("action %q is denied", action)
+	}
+	if len(f.allow) > 0 && !matchAny(f.allow, action) {
+		return fmt.Sprintf("action %q is not allowed", action)
+	}
+	return ""
+}
+
+// filterCommands returns the commands f lets through and records the rest in
+// result.Rejected under target (empty for the server-wide filter).
+func filterCommands(f actionFilter, commands []message.Command, target string, result *message.DispatchResult) []message.Command {
+	kept := make([]message.Command, 0, len(commands))
+	for _, c := range commands {
+		if reason := f.reject(c.Action); reason != "" {
+			result.Rejected = append(result.Rejected, message.RejectedCommand{Command: c, Target: target, Reason: reason})
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// validateCommands returns the commands whose params match their action's
+// schema for format and records the rest in result.Rejected.
+func validateCommands(v *cmdschema.Validator, format string, commands []message.Command, result *message.DispatchResult) []message.Command {
+	kept := make([]message.Command, 0, len(commands))
+	for _, c := range commands {
+		if err := v.Validate(format, c); err != nil {
+			result.Rejected = append(result.Rejected, message.RejectedCommand{Command: c, Reason: err.Error()})
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+func matchAny(patterns []string, action string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, action); ok {
+			return true
+		}
+	}
+	return false
+}
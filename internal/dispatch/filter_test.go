@@ -0,0 +1,79 @@
+package dispatch
+
+import (
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+func TestActionFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter actionFilter
+		action string
+		allow  bool
+	}{
+		{"no patterns", actionFilter{}, "unlock", true},
+		{"allowed", actionFilter{allow: []string{"turn_*"}}, "turn_on", true},
+		{"not allowed", actionFilter{allow: []string{"turn_*"}}, "unlock", false},
+		{"denied", actionFilter{deny: []string{"unlock*"}}, "unlock_door", false},
+		{"deny wins over allow", actionFilter{allow: []string{"*"}, deny: []string{"unlock"}}, "unlock", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.reject(tt.action) == ""; got != tt.allow {
+				t.Errorf("reject(%q) = %q, want allowed %v", tt.action, tt.filter.reject(tt.action), tt.allow)
+			}
+		})
+	}
+}
+
+func TestDispatchFiltersActions(t *testing.T) {
+	interp := &fakeInterpreter{result: &interpreter.InterpretResult{Commands: []message.Command{
+		{Action: "turn_on"},
+		{Action: "unlock"},
+	}}}
+	tr := &fakeTransport{name: "http"}
+	d := New(interp, []transport.Transport{tr}, nil, Options{DeniedActions: []string{"unlock"}})
+
+	result := handle(t, d, textMessage("turn on the light and unlock the door"))
+	if len(result.Commands) != 1 || result.Commands[0].Action != "turn_on" {
+		t.Errorf("commands = %+v, want only turn_on", result.Commands)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Command.Action != "unlock" {
+		t.Errorf("rejected = %+v, want unlock", result.Rejected)
+	}
+	sent := tr.payloads(t)
+	if len(sent) != 1 || len(sent[0].Commands) != 1 || sent[0].Commands[0].Action != "turn_on" {
+		t.Errorf("target received %+v, want only turn_on", sent)
+	}
+}
+
+func TestDispatchFiltersActionsPerTarget(t *testing.T) {
+	interp := &fakeInterpreter{result: &interpreter.InterpretResult{Commands: []message.Command{
+		{Action: "turn_on"},
+		{Action: "unlock"},
+	}}}
+	tr := &fakeTransport{name: "http"}
+	d := New(interp, []transport.Transport{tr}, nil, Options{
+		Targets: map[string]message.Target{
+			"lights": {ServiceName: "lights", Protocol: "http", Endpoint: "http://lights", AllowedActions: []string{"turn_*"}},
+		},
+	})
+	msg := textMessage("turn on the light and unlock the door")
+	msg.Instruction.Targets = []message.Target{{ServiceName: "lights"}}
+
+	result := handle(t, d, msg)
+	if len(result.Commands) != 2 {
+		t.Errorf("commands = %+v, want both kept in the result", result.Commands)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Target != "lights" {
+		t.Errorf("rejected = %+v, want unlock rejected for lights", result.Rejected)
+	}
+	sent := tr.payloads(t)
+	if len(sent) != 1 || len(sent[0].Commands) != 1 || sent[0].Commands[0].Action != "turn_on" {
+		t.Errorf("lights received %+v, want only turn_on", sent)
+	}
+}
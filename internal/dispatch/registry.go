@@ -0,0 +1,49 @@
+package dispatch
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+var (
+	sendersMu sync.RWMutex
+	senders   = map[string]transport.Sender{}
+)
+
+// RegisterSender makes sender deliver to targets whose protocol is protocol,
+// for protocols that no listening transport provides. It is meant to be
+// called from an init function or before the dispatcher starts routing, and
+// panics if sender is nil or protocol is already registered.
+//
+// Enabled transports take precedence: a sender registered for "http" is only
+// used while the HTTP transport is disabled.
+func RegisterSender(protocol string, sender transport.Sender) {
+	sendersMu.Lock()
+	defer sendersMu.Unlock()
+	if sender == nil {
+		panic("dispatch: RegisterSender sender is nil")
+	}
+	if _, dup := senders[protocol]; dup {
+		panic(fmt.Sprintf("dispatch: RegisterSender called twice for protocol %q", protocol))
+	}
+	senders[protocol] = sender
+}
+
+// registeredSender returns the sender registered for protocol, if any.
+func registeredSender(protocol string) (transport.Sender, bool) {
+	sendersMu.RLock()
+	defer sendersMu.RUnlock()
+	s, ok := senders[protocol]
+	return s, ok
+}
+
+// sender returns what delivers to targets of protocol: the listening
+// transport of that name, or else a registered sender.
+func (d *Dispatcher) sender(protocol string) (transport.Sender, bool) {
+	if t, ok := d.transports[protocol]; ok {
+		return t, true
+	}
+	return registeredSender(protocol)
+}
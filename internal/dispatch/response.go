@@ -0,0 +1,50 @@
+package dispatch
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// ResponseConstraints limit the length and set the language and style of
+// response texts. Instructions that set their own override them.
+type ResponseConstraints struct {
+	MaxWords int // 0 = no limit
+	MaxChars int // 0 = no limit
+	Style    string
+	Language string // Instruction.ResponseLanguage value: "match", "auto" or an ISO-639-1 code
+
+	// Truncate cuts response texts the model wrote too long to the
+	// instruction's limits, so TTS never speaks a paragraph.
+	Truncate bool
+}
+
+// truncate returns text cut to the word and character limits of instr if
+// truncation is enabled. It cuts after the last sentence that fits, or else
+// at the last word boundary.
+func (c ResponseConstraints) truncate(text string, instr message.Instruction) string {
+	if !c.Truncate {
+		return text
+	}
+	cut := text
+	if n := instr.ResponseMaxWords; n > 0 {
+		if words := strings.Fields(cut); len(words) > n {
+			cut = strings.Join(words[:n], " ")
+		}
+	}
+	if runes := []rune(cut); instr.ResponseMaxChars > 0 && len(runes) > instr.ResponseMaxChars {
+		cut = string(runes[:instr.ResponseMaxChars])
+		// Drop a word cut in half.
+		if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > 0 && !unicode.IsSpace(runes[instr.ResponseMaxChars]) {
+			cut = cut[:i]
+		}
+	}
+	if cut == text {
+		return text
+	}
+	if i := strings.LastIndexAny(cut, ".!?"); i > 0 {
+		return cut[:i+1]
+	}
+	return strings.TrimRightFunc(cut, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsPunct(r) })
+}
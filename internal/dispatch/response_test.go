@@ -0,0 +1,130 @@
+package dispatch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+func TestSynthesisLanguage(t *testing.T) {
+	tests := []struct {
+		name             string
+		detected         string
+		instruction      string // Instruction.Language
+		responseLanguage string
+		want             string
+	}{
+		{"detected language", "fr", "", "", "fr"},
+		{"requested language", "", "es", "", "es"},
+		{"response_language overrides detected", "fr", "", "de", "de"},
+		{"response_language overrides requested", "", "es", "en", "en"},
+		{"match keeps detected", "fr", "", message.ResponseLanguageMatch, "fr"},
+		{"auto keeps detected", "fr", "", message.ResponseLanguageAuto, "fr"},
+		{"nothing known", "", "", "", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interp := &fakeInterpreter{transcript: &interpreter.TranscribeResult{Text: "allume la lumière", Language: tt.detected}}
+			synth := &fakeSynthesizer{}
+			d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, synth, Options{})
+			msg := &message.Message{
+				ID:          "m1",
+				Audio:       testWAV(loudPCM(3200)),
+				ContentType: "audio/wav",
+				Instruction: message.Instruction{Language: tt.instruction, ResponseLanguage: tt.responseLanguage},
+			}
+			handle(t, d, msg)
+			if len(synth.opts) != 1 {
+				t.Fatalf("synthesized %d times, want 1", len(synth.opts))
+			}
+			if got := synth.opts[0].Language; got != tt.want {
+				t.Errorf("synthesis language = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioModeWithoutTTS(t *testing.T) {
+	tr := &fakeTransport{name: "http", fail: map[string]error{"b": errors.New("refused")}}
+	d := New(&fakeInterpreter{}, []transport.Transport{tr}, nil, Options{})
+	msg := textMessage("turn on the light")
+	msg.Instruction.ResponseMode = message.ResponseModeAudio
+	msg.Instruction.Targets = append(msg.Instruction.Targets,
+		message.Target{ServiceName: "b", Protocol: "http", Endpoint: "http://b"})
+	result := handle(t, d, msg)
+
+	// The routing failure came first and keeps the stage; the missing audio
+	// is still reported.
+	if result.ErrorStage != message.ErrorStageRouting || result.ErrorCode != message.ErrorCodeSendFailed {
+		t.Errorf("error_stage/error_code = %q/%q, want routing/send_failed", result.ErrorStage, result.ErrorCode)
+	}
+	if !strings.Contains(result.Error, "TTS is disabled") {
+		t.Errorf("error %q does not mention the disabled TTS", result.Error)
+	}
+	payloads := tr.payloads(t)
+	if len(payloads) != 1 {
+		t.Fatalf("sent %d payloads, want 1", len(payloads))
+	}
+	if payloads[0].Error != "" {
+		t.Errorf("routed payload carries error %q", payloads[0].Error)
+	}
+
+	msg = textMessage("turn on the light")
+	msg.Instruction.ResponseMode = message.ResponseModeAudio
+	result = handle(t, d, msg)
+	if result.ErrorStage != message.ErrorStageSynthesis || result.ErrorCode != message.ErrorCodeTTSDisabled {
+		t.Errorf("error_stage/error_code = %q/%q, want synthesis/tts_disabled", result.ErrorStage, result.ErrorCode)
+	}
+	if result.ResponseText != "Turning on the light." || len(result.RoutedTo) != 1 {
+		t.Errorf("response_text %q, routed_to %v: want the interpreter's text, routed", result.ResponseText, result.RoutedTo)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	c := ResponseConstraints{Truncate: true}
+	tests := []struct {
+		name  string
+		text  string
+		words int
+		chars int
+		want  string
+	}{
+		{"within limits", "Done.", 5, 40, "Done."},
+		{"last whole sentence", "The light is on. The kitchen is bright now.", 6, 0, "The light is on."},
+		{"word boundary without a sentence", "Turning on every light in the house", 4, 0, "Turning on every light"},
+		{"chars drop a cut word", "Turning on the kitchen light", 0, 17, "Turning on the"},
+		{"trailing punctuation dropped", "Okay, turning it on, then off", 0, 20, "Okay, turning it on"},
+		{"no limits", "The light is on. The kitchen is bright now.", 0, 0, "The light is on. The kitchen is bright now."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instr := message.Instruction{ResponseMaxWords: tt.words, ResponseMaxChars: tt.chars}
+			if got := c.truncate(tt.text, instr); got != tt.want {
+				t.Errorf("truncate = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	long := "The light is on. The kitchen is bright now."
+	if got := (ResponseConstraints{}).truncate(long, message.Instruction{ResponseMaxWords: 2}); got != long {
+		t.Errorf("truncate with Truncate off = %q, want the text unchanged", got)
+	}
+}
+
+func TestDispatchTruncatesResponse(t *testing.T) {
+	interp := &fakeInterpreter{result: &interpreter.InterpretResult{
+		Commands:     []message.Command{{Action: "turn_on"}},
+		ResponseText: "The light is on. The kitchen is bright now.",
+	}}
+	d := New(interp, []transport.Transport{&fakeTransport{name: "http"}}, nil, Options{
+		Response: ResponseConstraints{MaxWords: 6, Truncate: true},
+	})
+	result := handle(t, d, textMessage("turn on the light"))
+	if result.ResponseText != "The light is on." {
+		t.Errorf("response_text = %q, want it cut to the server's word limit", result.ResponseText)
+	}
+}
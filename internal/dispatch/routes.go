@@ -0,0 +1,45 @@
+package dispatch
+
+import (
+	"cmp"
+	"strings"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Route holds server-side instruction defaults for messages from one source.
+type Route struct {
+	// Targets are used when the instruction lists none. Targets naming a
+	// configured target are completed from it like instruction targets.
+	Targets []message.Target
+
+	// ResponseFormat and Prompt are used when the instruction leaves them empty.
+	ResponseFormat string
+	Prompt         string
+}
+
+// routeFor returns the route for source, falling back to the "default"
+// entry. Sources are matched case-insensitively.
+func (p *pipeline) routeFor(source string) (Route, bool) {
+	if r, ok := p.routes[strings.ToLower(source)]; ok && source != "" {
+		return r, true
+	}
+	r, ok := p.routes["default"]
+	return r, ok
+}
+
+// applyRoute fills in the parts of instr the caller left empty from the
+// route for source, then from the server-wide defaults, including the
+// response constraints. Caller-provided values always take precedence.
+func (p *pipeline) applyRoute(instr *message.Instruction, source string) {
+	r, _ := p.routeFor(source)
+	if len(instr.Targets) == 0 && len(r.Targets) > 0 {
+		instr.Targets = append([]message.Target(nil), r.Targets...)
+	}
+	instr.ResponseFormat = cmp.Or(instr.ResponseFormat, r.ResponseFormat, p.defaults.ResponseFormat)
+	instr.Prompt = cmp.Or(instr.Prompt, r.Prompt, p.defaults.Prompt)
+	instr.ResponseMaxWords = cmp.Or(instr.ResponseMaxWords, p.response.MaxWords)
+	instr.ResponseMaxChars = cmp.Or(instr.ResponseMaxChars, p.response.MaxChars)
+	instr.ResponseStyle = cmp.Or(instr.ResponseStyle, p.response.Style)
+	instr.ResponseLanguage = cmp.Or(instr.ResponseLanguage, p.response.Language)
+}
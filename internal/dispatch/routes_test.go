@@ -0,0 +1,131 @@
+package dispatch
+
+import (
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+func testRoutes() map[string]Route {
+	return map[string]Route{
+		"kitchen": {
+			Targets:        []message.Target{{ServiceName: "lights"}},
+			ResponseFormat: "home_assistant",
+			Prompt:         "kitchen devices",
+		},
+		"default": {
+			Targets:        []message.Target{{ServiceName: "log"}},
+			ResponseFormat: "generic",
+		},
+	}
+}
+
+func TestApplyRoute(t *testing.T) {
+	p := &pipeline{routes: testRoutes()}
+	tests := []struct {
+		name   string
+		source string
+		instr  message.Instruction
+		target string
+		format string
+		prompt string
+	}{
+		{"source route", "kitchen", message.Instruction{}, "lights", "home_assistant", "kitchen devices"},
+		{"source matched case-insensitively", "Kitchen", message.Instruction{}, "lights", "home_assistant", "kitchen devices"},
+		{"default route", "garage", message.Instruction{}, "log", "generic", ""},
+		{"no source uses default", "", message.Instruction{}, "log", "generic", ""},
+		{
+			"caller values win", "kitchen",
+			message.Instruction{
+				Targets:        []message.Target{{ServiceName: "robot"}},
+				ResponseFormat: "ros2",
+				Prompt:         "mine",
+			},
+			"robot", "ros2", "mine",
+		},
+		{
+			"caller fills some fields", "kitchen",
+			message.Instruction{Prompt: "mine"},
+			"lights", "home_assistant", "mine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instr := tt.instr
+			p.applyRoute(&instr, tt.source)
+			if len(instr.Targets) != 1 || instr.Targets[0].ServiceName != tt.target {
+				t.Errorf("targets = %+v, want %s", instr.Targets, tt.target)
+			}
+			if instr.ResponseFormat != tt.format || instr.Prompt != tt.prompt {
+				t.Errorf("format/prompt = %q/%q, want %q/%q", instr.ResponseFormat, instr.Prompt, tt.format, tt.prompt)
+			}
+		})
+	}
+}
+
+func TestApplyRouteDoesNotShareTargets(t *testing.T) {
+	p := &pipeline{routes: testRoutes()}
+	var instr message.Instruction
+	p.applyRoute(&instr, "kitchen")
+	instr.Targets[0].Endpoint = "http://changed"
+	if got := p.routes["kitchen"].Targets[0].Endpoint; got != "" {
+		t.Errorf("route target changed to %q through the instruction", got)
+	}
+}
+
+func TestDispatchRoutesBySource(t *testing.T) {
+	tr := &fakeTransport{name: "http"}
+	d := New(&fakeInterpreter{}, []transport.Transport{tr}, nil, Options{
+		Routes: testRoutes(),
+		Targets: map[string]message.Target{
+			"lights": {ServiceName: "lights", Protocol: "http", Endpoint: "http://lights"},
+			"log":    {ServiceName: "log", Protocol: "http", Endpoint: "http://log"},
+		},
+	})
+	result := handle(t, d, &message.Message{ID: "m1", Source: "kitchen", Text: "turn on the light"})
+	if len(result.RoutedTo) != 1 || result.RoutedTo[0] != "lights" {
+		t.Errorf("routed_to = %v, want lights", result.RoutedTo)
+	}
+	if len(tr.sent) != 1 || tr.sent[0].target.Endpoint != "http://lights" {
+		t.Errorf("sent = %+v, want the configured lights endpoint", tr.sent)
+	}
+}
+
+func TestApplyRouteServerDefaults(t *testing.T) {
+	p := &pipeline{
+		routes:   testRoutes(),
+		defaults: Route{ResponseFormat: "server_format", Prompt: "server prompt"},
+	}
+	tests := []struct {
+		name   string
+		source string
+		instr  message.Instruction
+		format string
+		prompt string
+	}{
+		{"route beats server defaults", "kitchen", message.Instruction{}, "home_assistant", "kitchen devices"},
+		{"server defaults fill what the route leaves", "garage", message.Instruction{}, "generic", "server prompt"},
+		{
+			"caller beats route and server defaults", "kitchen",
+			message.Instruction{ResponseFormat: "ros2", Prompt: "mine"},
+			"ros2", "mine",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instr := tt.instr
+			p.applyRoute(&instr, tt.source)
+			if instr.ResponseFormat != tt.format || instr.Prompt != tt.prompt {
+				t.Errorf("format/prompt = %q/%q, want %q/%q", instr.ResponseFormat, instr.Prompt, tt.format, tt.prompt)
+			}
+		})
+	}
+
+	p.routes = nil
+	var instr message.Instruction
+	p.applyRoute(&instr, "kitchen")
+	if instr.ResponseFormat != "server_format" || instr.Prompt != "server prompt" {
+		t.Errorf("without routes: format/prompt = %q/%q, want the server defaults", instr.ResponseFormat, instr.Prompt)
+	}
+}
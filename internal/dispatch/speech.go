@@ -0,0 +1,56 @@
+package dispatch
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/transport"
+	"github.com/nadzzz/switchyard/internal/tts"
+)
+
+// Speak synthesizes req.Text with the configured TTS backend and writes the
+// audio to w. WAV at the voice's native rate is streamed as it is produced
+// when the backend supports it; anything else is synthesized in full first.
+// This function is passed as the transport.SpeechHandler to speaking transports.
+func (d *Dispatcher) Speak(ctx context.Context, req transport.SpeechRequest, w io.Writer) error {
+	if !d.drain.begin() {
+		return transport.ErrShuttingDown
+	}
+	defer d.drain.end()
+
+	synthesizer := d.pipeline.Load().synthesizer
+	if synthesizer == nil {
+		return transport.ErrTTSDisabled
+	}
+	opts := tts.SynthesizeOpts{
+		Language:   req.Language,
+		Voice:      req.Voice,
+		Format:     req.AudioFormat,
+		SampleRate: req.SampleRate,
+	}
+	if opts.Language == "" {
+		opts.Language = "en"
+	}
+	if speech := req.Speech; speech != nil {
+		opts.Rate, opts.Pitch, opts.Volume = speech.Rate, speech.Pitch, speech.Volume
+	}
+
+	if s, ok := synthesizer.(tts.StreamSynthesizer); ok &&
+		(opts.Format == "" || opts.Format == audio.FormatWAV) && opts.SampleRate == 0 {
+		slog.Debug("streaming speech", "language", opts.Language, "text_length", len(req.Text))
+		err := s.SynthesizeStream(ctx, req.Text, opts, w)
+		d.stats.syntheses.done(err)
+		return err
+	}
+
+	slog.Debug("synthesizing speech", "language", opts.Language, "text_length", len(req.Text))
+	res, err := synthesizer.Synthesize(ctx, req.Text, opts)
+	d.stats.syntheses.done(err)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(res.Audio)
+	return err
+}
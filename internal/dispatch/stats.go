@@ -0,0 +1,108 @@
+package dispatch
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/health"
+)
+
+// recentWindow is the number of latest dispatches the recent error rate is
+// computed over.
+const recentWindow = 100
+
+// stats counts dispatch outcomes for the health server's /status page.
+type stats struct {
+	mu          sync.Mutex
+	total       int64
+	failed      int64
+	lastSuccess time.Time
+	lastFailure time.Time
+	recent      [recentWindow]bool // true = failed; a ring indexed by total
+
+	transcriptions, interpretations, syntheses callCounter
+	promptTokens, completionTokens             atomic.Int64
+}
+
+// callCounter counts calls to one kind of backend.
+type callCounter struct {
+	total, failed atomic.Int64
+}
+
+// done counts one finished call.
+func (c *callCounter) done(err error) {
+	c.total.Add(1)
+	if err != nil {
+		c.failed.Add(1)
+	}
+}
+
+func (c *callCounter) load() health.CallStats {
+	return health.CallStats{Total: c.total.Load(), Failed: c.failed.Load()}
+}
+
+// cacheStatser is implemented by synthesizers that cache, e.g. the TTS cache.
+type cacheStatser interface {
+	CacheStats() health.CacheStats
+}
+
+// record counts one finished dispatch.
+func (s *stats) record(failed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent[s.total%recentWindow] = failed
+	s.total++
+	if failed {
+		s.failed++
+		s.lastFailure = time.Now()
+	} else {
+		s.lastSuccess = time.Now()
+	}
+}
+
+// DispatchStats returns counters of dispatched messages, including those
+// rejected as busy or rate limited. Duplicates answered from the dedup cache
+// are not counted.
+func (d *Dispatcher) DispatchStats() health.DispatchStats {
+	s := &d.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := health.DispatchStats{
+		Total:       s.total,
+		Failed:      s.failed,
+		InFlight:    d.InFlight(),
+		LastSuccess: s.lastSuccess,
+		LastFailure: s.lastFailure,
+	}
+	n := min(s.total, recentWindow)
+	if n > 0 {
+		var failed int
+		for _, f := range s.recent[:n] {
+			if f {
+				failed++
+			}
+		}
+		out.RecentErrorRate = float64(failed) / float64(n)
+		out.RecentWindow = int(n)
+	}
+	return out
+}
+
+// BackendStats returns counters of interpreter and TTS calls. TTS cache
+// counters restart when a reload replaces the cache.
+func (d *Dispatcher) BackendStats() health.BackendStats {
+	s := &d.stats
+	out := health.BackendStats{
+		Transcriptions:   s.transcriptions.load(),
+		Interpretations:  s.interpretations.load(),
+		Syntheses:        s.syntheses.load(),
+		PromptTokens:     s.promptTokens.Load(),
+		CompletionTokens: s.completionTokens.Load(),
+	}
+	if c, ok := d.pipeline.Load().synthesizer.(cacheStatser); ok {
+		st := c.CacheStats()
+		out.TTSCache = &st
+	}
+	return out
+}
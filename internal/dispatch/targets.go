@@ -0,0 +1,84 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// probeTimeout bounds one target check.
+const probeTimeout = 10 * time.Second
+
+// Targets returns the configured targets, without credentials, sorted by
+// name.
+func (d *Dispatcher) Targets() []transport.TargetInfo {
+	targets := d.pipeline.Load().targets
+	out := make([]transport.TargetInfo, 0, len(targets))
+	for name, t := range targets {
+		info := transport.TargetInfo{
+			Name:           name,
+			Endpoint:       t.Endpoint,
+			Protocol:       t.Protocol,
+			HasToken:       t.Token != "",
+			AllowedActions: t.AllowedActions,
+		}
+		if info.HasToken {
+			info.AuthHeader = t.AuthHeader
+			if info.AuthHeader == "" {
+				info.AuthHeader = "Authorization"
+			}
+		}
+		for key := range t.Headers {
+			info.Headers = append(info.Headers, key)
+		}
+		slices.Sort(info.Headers)
+		out = append(out, info)
+	}
+	slices.SortFunc(out, func(a, b transport.TargetInfo) int { return strings.Compare(a.Name, b.Name) })
+	return out
+}
+
+// CheckTarget probes the named configured target with the sender of its
+// protocol, with the target's credentials and headers, without delivering
+// a payload.
+func (d *Dispatcher) CheckTarget(ctx context.Context, name string) (transport.TargetCheck, error) {
+	target, ok := d.pipeline.Load().targets[name]
+	if !ok {
+		return transport.TargetCheck{}, fmt.Errorf("%w %q", transport.ErrUnknownTarget, name)
+	}
+	check := transport.TargetCheck{Name: name, Protocol: target.Protocol, Endpoint: target.Endpoint}
+
+	sender, ok := d.sender(target.Protocol)
+	if !ok {
+		check.Status = transport.TargetError
+		check.Error = fmt.Sprintf("no transport for protocol %q", target.Protocol)
+		return check, nil
+	}
+	prober, ok := sender.(transport.Prober)
+	if !ok {
+		check.Status = transport.TargetUnsupported
+		check.Detail = fmt.Sprintf("the %s sender cannot probe targets", target.Protocol)
+		return check, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	start := time.Now()
+	detail, err := prober.Probe(ctx, target)
+	check.LatencyMs = time.Since(start).Milliseconds()
+	check.Detail = detail
+	if err != nil {
+		check.Status = transport.TargetError
+		check.Error = err.Error()
+		slog.Warn("target check failed", "target", name, "protocol", target.Protocol, "error", err)
+		return check, nil
+	}
+	check.Status = transport.TargetOK
+	slog.Info("target check passed", "target", name, "protocol", target.Protocol, "latency_ms", check.LatencyMs)
+	return check, nil
+}
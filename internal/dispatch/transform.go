@@ -0,0 +1,48 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// CommandTransformer rewrites the interpreted commands of one dispatch, e.g.
+// to map entity aliases or add default params. It may modify cmds in place.
+type CommandTransformer func(ctx context.Context, cmds []message.Command) ([]message.Command, error)
+
+// Transformer is a named CommandTransformer; the name appears in logs and
+// errors.
+type Transformer struct {
+	Name      string
+	Transform CommandTransformer
+}
+
+// transform runs the configured transformers over cmds in order. Commands
+// are re-encoded afterwards so their Raw JSON matches what is routed.
+func (p *pipeline) transform(ctx context.Context, cmds []message.Command, logger *slog.Logger) ([]message.Command, error) {
+	if len(p.transforms) == 0 {
+		return cmds, nil
+	}
+	for _, t := range p.transforms {
+		before := len(cmds)
+		out, err := t.Transform(ctx, cmds)
+		if err != nil {
+			return nil, fmt.Errorf("transformer %s: %w", t.Name, err)
+		}
+		cmds = out
+		logger.Debug("commands transformed", "transformer", t.Name, "commands_before", before, "commands_after", len(cmds))
+	}
+	for i := range cmds {
+		cmds[i].Raw = nil
+		raw, err := json.Marshal(cmds[i])
+		if err != nil {
+			return nil, fmt.Errorf("encoding transformed command %q: %w", cmds[i].Action, err)
+		}
+		cmds[i].Raw = raw
+	}
+	logger.Debug("transformed commands", "commands", cmds)
+	return cmds, nil
+}
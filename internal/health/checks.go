@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"time"
+)
+
+// Checker is implemented by components that can cheaply verify that the
+// services they depend on are reachable (e.g., by dialing them).
+type Checker interface {
+	// HealthCheck returns an error if a dependency is unreachable.
+	HealthCheck(ctx context.Context) error
+}
+
+// check is a registered dependency probe and its latest result.
+type check struct {
+	checker  Checker
+	critical bool
+	status   ComponentStatus
+}
+
+// ComponentStatus is the latest probe result for one dependency.
+type ComponentStatus struct {
+	Status    string    `json:"status"` // "ok", "down" or "unknown" (not yet probed)
+	Critical  bool      `json:"critical"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+}
+
+// readyResponse is the /readyz response body.
+type readyResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// SetCheck registers (or replaces) the probe for a named dependency. While a
+// critical dependency is down, /readyz reports not ready. Non-critical ones
+// are reported but don't affect readiness. A nil checker removes the probe.
+func (s *Server) SetCheck(name string, c Checker, critical bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c == nil {
+		delete(s.checks, name)
+		return
+	}
+	s.checks[name] = &check{
+		checker:  c,
+		critical: critical,
+		status:   ComponentStatus{Status: "unknown", Critical: critical},
+	}
+}
+
+// RunChecks probes every registered dependency each interval until ctx is
+// cancelled. Each probe gets at most timeout.
+func (s *Server) RunChecks(ctx context.Context, interval, timeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.probe(ctx, timeout)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe runs all checks once, concurrently.
+func (s *Server) probe(ctx context.Context, timeout time.Duration) {
+	s.mu.RLock()
+	checks := make(map[string]*check, len(s.checks))
+	for name, c := range s.checks {
+		checks[name] = c
+	}
+	s.mu.RUnlock()
+
+	type outcome struct {
+		name  string
+		c     *check
+		err   error
+		start time.Time
+	}
+	results := make(chan outcome, len(checks))
+	for name, c := range checks {
+		go func() {
+			pctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			results <- outcome{name: name, c: c, err: c.checker.HealthCheck(pctx), start: start}
+		}()
+	}
+
+	for range checks {
+		r := <-results
+		status := ComponentStatus{Status: "ok", Critical: r.c.critical, CheckedAt: r.start}
+		if r.err != nil {
+			status.Status = "down"
+			status.Error = r.err.Error()
+		}
+
+		s.mu.Lock()
+		// Skip results for probes replaced while this round was running.
+		if s.checks[r.name] == r.c {
+			if r.c.status.Status != status.Status {
+				slog.Info("dependency status changed", "component", r.name, "status", status.Status, "error", status.Error)
+			}
+			r.c.status = status
+		}
+		s.mu.Unlock()
+	}
+}
+
+// readiness reports whether the daemon is ready and the status of every
+// probed dependency.
+func (s *Server) readiness() (bool, map[string]ComponentStatus) {
+	ready := s.ready.Load()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.checks) == 0 {
+		return ready, nil
+	}
+	components := make(map[string]ComponentStatus, len(s.checks))
+	for name, c := range s.checks {
+		components[name] = c.status
+		if c.critical && c.status.Status == "down" {
+			ready = false
+		}
+	}
+	return ready, components
+}
+
+// DialURL checks that the host of an http(s) URL accepts TCP connections.
+func DialURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", rawURL, err)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return Dial(ctx, net.JoinHostPort(u.Hostname(), port))
+}
+
+// Dial checks that addr (host:port) accepts TCP connections.
+func Dial(ctx context.Context, addr string) error {
+	if addr == "" {
+		return errors.New("no address configured")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -11,20 +11,35 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
 // Server is a lightweight HTTP server that exposes /healthz.
 type Server struct {
-	port   int
-	ready  atomic.Bool
-	server *http.Server
+	port    int
+	ready   atomic.Bool
+	server  *http.Server
+	started time.Time
+	stats   StatsProvider // nil if no dispatch stats are reported
+
+	mu       sync.RWMutex
+	checks   map[string]*check
+	backends Backends
+}
+
+// New creates a new health check server. stats, if not nil, supplies the
+// dispatch counters shown on /status.
+func New(port int, stats StatsProvider) *Server {
+	return &Server{port: port, stats: stats, started: time.Now(), checks: make(map[string]*check)}
 }
 
-// New creates a new health check server.
-func New(port int) *Server {
-	return &Server{port: port}
+// SetBackends records the configured backends shown on /status.
+func (s *Server) SetBackends(b Backends) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backends = b
 }
 
 // SetReady marks the daemon as ready to accept traffic.
@@ -58,19 +73,35 @@ func (s *Server) ListenAndServe(ctx context.Context) error {
 	// readyz godoc
 	// @Summary     Readiness probe
 	// @Description Returns 200 when the daemon is ready to accept traffic, 503 otherwise.
+	// @Description Includes the status of each probed dependency when health checks are enabled.
 	// @Tags        health
 	// @Produce     json
-	// @Success     200  {object}  map[string]string  "status: ok"
-	// @Failure     503  {object}  map[string]string  "status: not_ready"
+	// @Success     200  {object}  readyResponse  "status: ok"
+	// @Failure     503  {object}  readyResponse  "status: not_ready (startup incomplete or a critical dependency is down)"
 	// @Router      /readyz [get]
 	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
-		if !s.ready.Load() {
+		ready, components := s.readiness()
+		status := "ok"
+		if !ready {
+			status = "not_ready"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
 			w.WriteHeader(http.StatusServiceUnavailable)
-			_ = json.NewEncoder(w).Encode(map[string]string{"status": "not_ready"})
-			return
 		}
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		_ = json.NewEncoder(w).Encode(readyResponse{Status: status, Components: components})
+	})
+
+	// status godoc
+	// @Summary     Status overview
+	// @Description Returns uptime, dispatch counters, the recent error rate and the configured backends.
+	// @Tags        health
+	// @Produce     json
+	// @Success     200  {object}  StatusResponse
+	// @Router      /status [get]
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.status())
 	})
 
 	s.server = &http.Server{
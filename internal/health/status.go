@@ -0,0 +1,85 @@
+package health
+
+import "time"
+
+// StatsProvider supplies dispatch and backend counters, e.g. the dispatcher.
+type StatsProvider interface {
+	DispatchStats() DispatchStats
+	BackendStats() BackendStats
+}
+
+// DispatchStats counts dispatched messages since startup.
+type DispatchStats struct {
+	Total           int64     `json:"total"`
+	Failed          int64     `json:"failed"` // Dispatches that returned an error or a result with an error
+	InFlight        int       `json:"in_flight"`
+	RecentErrorRate float64   `json:"recent_error_rate"` // Share of the last RecentWindow dispatches that failed
+	RecentWindow    int       `json:"recent_window"`
+	LastSuccess     time.Time `json:"last_success,omitzero"`
+	LastFailure     time.Time `json:"last_failure,omitzero"`
+}
+
+// CallStats counts calls to one kind of backend since startup.
+type CallStats struct {
+	Total  int64 `json:"total"`
+	Failed int64 `json:"failed"`
+}
+
+// CacheStats reports how effective a cache is.
+type CacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"` // Hits / (Hits + Misses); 0 before the first lookup
+	Entries int     `json:"entries"`
+	Bytes   int     `json:"bytes"`
+}
+
+// BackendStats counts the interpreter and TTS calls made by dispatches and
+// speech requests.
+type BackendStats struct {
+	Transcriptions   CallStats   `json:"transcriptions"`
+	Interpretations  CallStats   `json:"interpretations"`
+	Syntheses        CallStats   `json:"syntheses"` // Including those answered from the TTS cache
+	PromptTokens     int64       `json:"prompt_tokens"`
+	CompletionTokens int64       `json:"completion_tokens"`
+	TTSCache         *CacheStats `json:"tts_cache,omitempty"` // nil when the TTS cache is disabled
+}
+
+// Backends names the configured interpreter and TTS backends.
+type Backends struct {
+	Interpreter string `json:"interpreter"`
+	TTS         string `json:"tts,omitempty"` // empty when TTS is disabled
+}
+
+// StatusResponse is the /status response body.
+type StatusResponse struct {
+	Status        string         `json:"status"` // "ok" or "not_ready", as on /readyz
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	Backends      Backends       `json:"backends"`
+	Dispatches    *DispatchStats `json:"dispatches,omitempty"`
+	BackendCalls  *BackendStats  `json:"backend_calls,omitempty"`
+}
+
+// status assembles the /status response from in-memory state only.
+func (s *Server) status() StatusResponse {
+	ready, _ := s.readiness()
+	resp := StatusResponse{
+		Status:        "ok",
+		StartedAt:     s.started,
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+	}
+	if !ready {
+		resp.Status = "not_ready"
+	}
+	s.mu.RLock()
+	resp.Backends = s.backends
+	s.mu.RUnlock()
+	if s.stats != nil {
+		st := s.stats.DispatchStats()
+		resp.Dispatches = &st
+		bs := s.stats.BackendStats()
+		resp.BackendCalls = &bs
+	}
+	return resp
+}
@@ -0,0 +1,49 @@
+// Package hostlist matches URLs against a host allow-list.
+//
+// Features that make switchyard issue requests to client-supplied URLs
+// (async callbacks, audio fetched by URL) check them against a List so
+// clients cannot reach arbitrary internal services (SSRF).
+package hostlist
+
+import (
+	"net"
+	"net/url"
+	"strings"
+)
+
+// List is a set of allowed hosts. Entries have the form "host",
+// "host:port" or "*.domain" (any subdomain of domain). An empty List
+// allows nothing.
+type List []string
+
+// New normalizes patterns into a List.
+func New(patterns []string) List {
+	l := make(List, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			l = append(l, p)
+		}
+	}
+	return l
+}
+
+// Allows reports whether the host of u matches an entry.
+func (l List) Allows(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	hostPort := strings.ToLower(u.Host)
+	for _, allowed := range l {
+		switch {
+		case strings.HasPrefix(allowed, "*."):
+			if strings.HasSuffix(host, allowed[1:]) {
+				return true
+			}
+		case strings.Contains(allowed, ":") && net.ParseIP(allowed) == nil:
+			if hostPort == allowed {
+				return true
+			}
+		case host == strings.Trim(allowed, "[]"):
+			return true
+		}
+	}
+	return false
+}
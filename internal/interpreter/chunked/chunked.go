@@ -0,0 +1,194 @@
+// Package chunked implements an Interpreter that transcribes long WAV audio
+// in overlapping windows, for recordings that exceed a backend's upload limit
+// (OpenAI accepts at most 25 MB per request).
+//
+// Audio that fits in one window is passed through unchanged. Longer audio is
+// split on frame boundaries, each window is transcribed in turn, and the
+// transcripts are joined with the words repeated in the overlaps removed.
+// Only PCM WAV can be split; other formats are always sent whole.
+package chunked
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// maxOverlapWords bounds the search for words repeated across a window boundary.
+const maxOverlapWords = 40
+
+// Interpreter wraps another Interpreter, splitting long audio for Transcribe.
+type Interpreter struct {
+	next     interpreter.Interpreter
+	maxBytes int           // largest WAV sent in one request
+	window   time.Duration // longest audio sent in one request
+	overlap  time.Duration // audio shared by consecutive windows
+}
+
+// New wraps next. Zero config values fall back to defaults (24 MB, 10
+// minutes, 5 seconds of overlap).
+func New(next interpreter.Interpreter, cfg config.ChunkingConfig) *Interpreter {
+	i := &Interpreter{
+		next:     next,
+		maxBytes: cfg.MaxBytes,
+		window:   cfg.Window,
+		overlap:  cfg.Overlap,
+	}
+	if i.maxBytes <= 0 {
+		i.maxBytes = 24 << 20
+	}
+	if i.window <= 0 {
+		i.window = 10 * time.Minute
+	}
+	if i.overlap < 0 || i.overlap >= i.window/2 {
+		i.overlap = min(5*time.Second, i.window/4)
+	}
+	return i
+}
+
+// Name returns the wrapped backend's name.
+func (i *Interpreter) Name() string { return i.next.Name() }
+
+// Transcribe sends short or non-WAV audio straight to the wrapped backend and
+// transcribes long WAV audio window by window. The language detected in the
+// first window is reported for the whole recording.
+func (i *Interpreter) Transcribe(ctx context.Context, data []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	format, pcm, err := audio.ParseWAV(data)
+	if err != nil {
+		return i.next.Transcribe(ctx, data, contentType, opts)
+	}
+
+	frameBytes := format.BlockAlign()
+	bytesPerSecond := format.SampleRate * frameBytes
+	windowFrames := min(
+		int(i.window.Seconds()*float64(format.SampleRate)),
+		(i.maxBytes-44)/frameBytes,
+	)
+	overlapFrames := int(i.overlap.Seconds() * float64(format.SampleRate))
+	if overlapFrames >= windowFrames/2 {
+		overlapFrames = windowFrames / 4
+	}
+	if len(pcm) <= windowFrames*frameBytes {
+		return i.next.Transcribe(ctx, data, contentType, opts)
+	}
+
+	windowBytes := windowFrames * frameBytes
+	stepBytes := (windowFrames - overlapFrames) * frameBytes
+	slog.Debug("transcribing audio in windows", "pcm_bytes", len(pcm),
+		"window", audio.PCMDuration(windowBytes, format.SampleRate, format.Channels, format.BitsPerSample/8),
+		"windows", (len(pcm)-windowBytes+stepBytes-1)/stepBytes+1)
+
+	merged := &interpreter.TranscribeResult{}
+	var lastEnd float64 // end of the previous window's last segment, in seconds
+	var confidence, seconds float64
+	scored := true // every window reported a confidence
+	for start := 0; ; start += stepBytes {
+		end := min(start+windowBytes, len(pcm))
+		wav := audio.EncodeWAV(pcm[start:end], format.SampleRate, format.Channels, format.BitsPerSample/8)
+
+		res, err := i.next.Transcribe(ctx, wav, "audio/wav", opts)
+		if err != nil {
+			return nil, fmt.Errorf("transcribing audio window at %s: %w",
+				audio.PCMDuration(start, format.SampleRate, format.Channels, format.BitsPerSample/8), err)
+		}
+
+		if start == 0 {
+			merged.Language = res.Language
+		}
+		merged.Text = joinTranscripts(merged.Text, res.Text)
+
+		offset := float64(start) / float64(bytesPerSecond)
+		for _, seg := range res.Segments {
+			seg.Start += offset
+			seg.End += offset
+			if seg.End <= lastEnd {
+				continue // already covered by the previous window
+			}
+			merged.Segments = append(merged.Segments, seg)
+		}
+		if n := len(merged.Segments); n > 0 {
+			lastEnd = merged.Segments[n-1].End
+		}
+
+		// Confidence is averaged over windows, weighted by their length.
+		d := float64(end-start) / float64(bytesPerSecond)
+		confidence += res.AvgConfidence * d
+		seconds += d
+		scored = scored && res.AvgConfidence > 0
+
+		if end == len(pcm) {
+			break
+		}
+	}
+	if scored && seconds > 0 {
+		merged.AvgConfidence = confidence / seconds
+	}
+	return merged, nil
+}
+
+// Interpret delegates to the wrapped backend.
+func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	return i.next.Interpret(ctx, text, instruction, opts)
+}
+
+// Close closes the wrapped backend.
+func (i *Interpreter) Close() error { return i.next.Close() }
+
+// HealthCheck delegates to the wrapped backend, if it supports checks.
+func (i *Interpreter) HealthCheck(ctx context.Context) error {
+	if c, ok := i.next.(health.Checker); ok {
+		return c.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// joinTranscripts appends next to prev, dropping the longest run of words at
+// the start of next that repeats the end of prev (the overlapping audio).
+func joinTranscripts(prev, next string) string {
+	next = strings.TrimSpace(next)
+	if prev == "" {
+		return next
+	}
+	if next == "" {
+		return prev
+	}
+
+	prevWords := strings.Fields(prev)
+	nextWords := strings.Fields(next)
+	longest := 0
+	for k := min(len(prevWords), len(nextWords), maxOverlapWords); k > 0; k-- {
+		if sameWords(prevWords[len(prevWords)-k:], nextWords[:k]) {
+			longest = k
+			break
+		}
+	}
+	if longest == len(nextWords) {
+		return prev
+	}
+	return prev + " " + strings.Join(nextWords[longest:], " ")
+}
+
+// sameWords compares words ignoring case and punctuation.
+func sameWords(a, b []string) bool {
+	for i := range a {
+		if normalizeWord(a[i]) != normalizeWord(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeWord(w string) string {
+	return strings.ToLower(strings.TrimFunc(w, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}))
+}
@@ -0,0 +1,68 @@
+// Package composite implements an Interpreter that splits work across two
+// backends: one for transcription and one for interpretation.
+//
+// This allows, for example, a local whisper.cpp server for speech-to-text
+// combined with OpenAI for command generation.
+package composite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Interpreter delegates Transcribe and Interpret to separate backends.
+type Interpreter struct {
+	transcriber interpreter.Interpreter
+	interpreter interpreter.Interpreter
+}
+
+// New creates a composite interpreter that transcribes with transcriber and
+// interprets with interp.
+func New(transcriber, interp interpreter.Interpreter) *Interpreter {
+	return &Interpreter{
+		transcriber: transcriber,
+		interpreter: interp,
+	}
+}
+
+// Name returns the backend identifier, e.g. "local+openai".
+func (i *Interpreter) Name() string {
+	return i.transcriber.Name() + "+" + i.interpreter.Name()
+}
+
+// Transcribe delegates to the transcription backend.
+func (i *Interpreter) Transcribe(ctx context.Context, audio []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	return i.transcriber.Transcribe(ctx, audio, contentType, opts)
+}
+
+// Interpret delegates to the interpretation backend.
+func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	return i.interpreter.Interpret(ctx, text, instruction, opts)
+}
+
+// HealthCheck probes both backends that support it.
+func (i *Interpreter) HealthCheck(ctx context.Context) error {
+	var errs []error
+	if c, ok := i.transcriber.(health.Checker); ok {
+		if err := c.HealthCheck(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("transcription backend: %w", err))
+		}
+	}
+	if c, ok := i.interpreter.(health.Checker); ok {
+		if err := c.HealthCheck(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("completion backend: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes both backends and returns any errors joined. Each backend is
+// closed exactly once, even if the other's Close fails.
+func (i *Interpreter) Close() error {
+	return errors.Join(i.transcriber.Close(), i.interpreter.Close())
+}
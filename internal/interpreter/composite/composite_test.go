@@ -0,0 +1,69 @@
+package composite
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+type fakeBackend struct {
+	name     string
+	closeErr error
+	closed   int
+}
+
+func (f *fakeBackend) Name() string { return f.name }
+
+func (f *fakeBackend) Transcribe(context.Context, []byte, string, interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	return &interpreter.TranscribeResult{Text: f.name}, nil
+}
+
+func (f *fakeBackend) Interpret(context.Context, string, message.Instruction, interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	return &interpreter.InterpretResult{ResponseText: f.name}, nil
+}
+
+func (f *fakeBackend) Close() error {
+	f.closed++
+	return f.closeErr
+}
+
+func TestCloseClosesBothBackends(t *testing.T) {
+	errTranscriber := errors.New("transcriber close failed")
+	transcriber := &fakeBackend{name: "local", closeErr: errTranscriber}
+	interp := &fakeBackend{name: "openai"}
+
+	err := New(transcriber, interp).Close()
+	if !errors.Is(err, errTranscriber) {
+		t.Fatalf("Close() = %v, want it to wrap %v", err, errTranscriber)
+	}
+	if transcriber.closed != 1 || interp.closed != 1 {
+		t.Errorf("closed transcriber %d times and interpreter %d times, want 1 each", transcriber.closed, interp.closed)
+	}
+}
+
+func TestCloseJoinsBothErrors(t *testing.T) {
+	errA := errors.New("a")
+	errB := errors.New("b")
+	err := New(&fakeBackend{closeErr: errA}, &fakeBackend{closeErr: errB}).Close()
+	if !errors.Is(err, errA) || !errors.Is(err, errB) {
+		t.Errorf("Close() = %v, want both errors", err)
+	}
+}
+
+func TestDelegation(t *testing.T) {
+	i := New(&fakeBackend{name: "local"}, &fakeBackend{name: "openai"})
+	if got := i.Name(); got != "local+openai" {
+		t.Errorf("Name() = %q", got)
+	}
+	tr, _ := i.Transcribe(context.Background(), nil, "audio/wav", interpreter.TranscribeOpts{})
+	if tr.Text != "local" {
+		t.Errorf("Transcribe went to %q, want local", tr.Text)
+	}
+	ir, _ := i.Interpret(context.Background(), "", message.Instruction{}, interpreter.InterpretOpts{})
+	if ir.ResponseText != "openai" {
+		t.Errorf("Interpret went to %q, want openai", ir.ResponseText)
+	}
+}
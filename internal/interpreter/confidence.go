@@ -0,0 +1,44 @@
+package interpreter
+
+import (
+	"math"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// WhisperSegment is a segment of a Whisper verbose_json response, as returned
+// by OpenAI and by whisper.cpp and faster-whisper servers.
+type WhisperSegment struct {
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Text       string   `json:"text"`
+	AvgLogprob *float64 `json:"avg_logprob"`
+}
+
+// WhisperSegments converts Whisper segments and derives the transcript's
+// confidence: the mean of each segment's exp(avg_logprob), weighted by its
+// duration. Confidence is 0 if any segment lacks avg_logprob.
+func WhisperSegments(raw []WhisperSegment) ([]message.Segment, float64) {
+	if len(raw) == 0 {
+		return nil, 0
+	}
+	segments := make([]message.Segment, len(raw))
+	var weighted, total float64
+	scored := true
+	for n, s := range raw {
+		segments[n] = message.Segment{Start: s.Start, End: s.End, Text: s.Text}
+		if s.AvgLogprob == nil {
+			scored = false
+			continue
+		}
+		// Zero-length segments still count, so a transcript of only those
+		// gets a plain mean.
+		d := max(s.End-s.Start, 1e-3)
+		weighted += math.Exp(*s.AvgLogprob) * d
+		total += d
+	}
+	if !scored || total == 0 {
+		return segments, 0
+	}
+	return segments, min(weighted/total, 1)
+}
@@ -0,0 +1,189 @@
+// Package deepgram implements transcription with Deepgram's pre-recorded
+// speech-to-text API (POST /v1/listen).
+//
+// Deepgram only transcribes, so this backend is meant to be paired with
+// another one for interpretation through interpreter.transcription_backend.
+package deepgram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// ErrTranscriptionOnly is returned by Interpret: Deepgram has no text model.
+var ErrTranscriptionOnly = errors.New("deepgram only transcribes; set interpreter.completion_backend to another backend")
+
+// Interpreter transcribes audio with Deepgram.
+type Interpreter struct {
+	apiKey               string
+	endpoint             string
+	model                string
+	transcriptionTimeout time.Duration
+	retry                interpreter.RetryPolicy
+	client               *http.Client
+}
+
+// New creates a Deepgram transcriber from config.
+func New(cfg config.DeepgramConfig) *Interpreter {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.deepgram.com/v1/listen"
+	}
+	model := cfg.Model
+	if model == "" {
+		model = "nova-3"
+	}
+	timeout := cfg.TranscriptionTimeout
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	return &Interpreter{
+		apiKey:               cfg.APIKey,
+		endpoint:             endpoint,
+		model:                model,
+		transcriptionTimeout: timeout,
+		retry:                interpreter.DefaultRetryPolicy(cfg.MaxAttempts),
+		client:               &http.Client{},
+	}
+}
+
+// Name returns the backend identifier.
+func (i *Interpreter) Name() string { return "deepgram" }
+
+// Transcribe sends audio to Deepgram. Without a requested language, Deepgram
+// detects it. A vocabulary prompt is split on commas into key terms.
+func (i *Interpreter) Transcribe(ctx context.Context, audio []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	if opts.Translate {
+		return nil, errors.New("deepgram does not support translation")
+	}
+	model := i.model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, i.transcriptionTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.listenURL(model, opts), bytes.NewReader(audio))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+i.apiKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := i.retry.Do(i.client, req)
+	if err != nil {
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("deepgram request: %w", err), "transcription", i.transcriptionTimeout)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("deepgram transcription failed (status %d): %s", resp.StatusCode, respBody)
+	}
+
+	var result listenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("decoding transcription: %w", err), "transcription", i.transcriptionTimeout)
+	}
+	if len(result.Results.Channels) == 0 || len(result.Results.Channels[0].Alternatives) == 0 {
+		return nil, errors.New("deepgram returned no transcript")
+	}
+	channel := result.Results.Channels[0]
+	best := channel.Alternatives[0]
+
+	lang := opts.Language
+	if channel.DetectedLanguage != "" {
+		// Deepgram may report a regional variant ("en-US"); keep ISO-639-1.
+		lang, _, _ = strings.Cut(strings.ToLower(channel.DetectedLanguage), "-")
+	}
+
+	var segments []message.Segment
+	for _, u := range result.Results.Utterances {
+		segments = append(segments, message.Segment{Start: u.Start, End: u.End, Text: u.Transcript})
+	}
+
+	slog.Debug("deepgram transcription complete", "text_length", len(best.Transcript),
+		"language", lang, "confidence", best.Confidence)
+	return &interpreter.TranscribeResult{
+		Text:          best.Transcript,
+		Language:      lang,
+		Segments:      segments,
+		AvgConfidence: best.Confidence,
+	}, nil
+}
+
+// listenURL builds the request URL with its query options.
+func (i *Interpreter) listenURL(model string, opts interpreter.TranscribeOpts) string {
+	q := url.Values{}
+	q.Set("model", model)
+	q.Set("smart_format", "true")
+	q.Set("utterances", "true")
+	if opts.Language != "" {
+		q.Set("language", opts.Language)
+	} else {
+		q.Set("detect_language", "true")
+	}
+	// Nova-3 takes key terms; older models take keywords.
+	param := "keywords"
+	if strings.HasPrefix(model, "nova-3") {
+		param = "keyterm"
+	}
+	for _, term := range strings.Split(opts.Prompt, ",") {
+		if term = strings.TrimSpace(term); term != "" {
+			q.Add(param, term)
+		}
+	}
+	sep := "?"
+	if strings.Contains(i.endpoint, "?") {
+		sep = "&"
+	}
+	return i.endpoint + sep + q.Encode()
+}
+
+// listenResponse is the part of Deepgram's /v1/listen response we use.
+type listenResponse struct {
+	Results struct {
+		Channels []struct {
+			DetectedLanguage string `json:"detected_language"`
+			Alternatives     []struct {
+				Transcript string  `json:"transcript"`
+				Confidence float64 `json:"confidence"`
+			} `json:"alternatives"`
+		} `json:"channels"`
+		Utterances []struct {
+			Start      float64 `json:"start"`
+			End        float64 `json:"end"`
+			Transcript string  `json:"transcript"`
+		} `json:"utterances"`
+	} `json:"results"`
+}
+
+// Interpret always fails: Deepgram only transcribes.
+func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	return nil, ErrTranscriptionOnly
+}
+
+// HealthCheck verifies that the Deepgram API accepts connections.
+func (i *Interpreter) HealthCheck(ctx context.Context) error {
+	return health.DialURL(ctx, i.endpoint)
+}
+
+// Close is a no-op for the Deepgram interpreter.
+func (i *Interpreter) Close() error { return nil }
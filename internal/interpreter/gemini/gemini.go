@@ -0,0 +1,478 @@
+// Package gemini implements the Interpreter interface using the Google
+// Gemini API (POST /v1beta/models/{model}:generateContent).
+//
+// Gemini is multimodal, so one model both transcribes and interprets:
+// audio is sent inline as base64 next to a transcription prompt, and
+// commands are requested as structured output with a response schema.
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Interpreter uses Gemini for transcription and command generation.
+type Interpreter struct {
+	apiKey               string
+	endpoint             string // API base URL, without a trailing slash
+	model                string
+	temperature          float64
+	transcriptionTimeout time.Duration
+	completionTimeout    time.Duration
+	retry                interpreter.RetryPolicy
+	client               *http.Client
+}
+
+// New creates a new Gemini interpreter from config.
+func New(cfg config.GeminiConfig) *Interpreter {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://generativelanguage.googleapis.com"
+	}
+	model := cfg.Model
+	if model == "" {
+		model = "gemini-2.5-flash"
+	}
+	transcriptionTimeout := cfg.TranscriptionTimeout
+	if transcriptionTimeout <= 0 {
+		transcriptionTimeout = 60 * time.Second
+	}
+	completionTimeout := cfg.CompletionTimeout
+	if completionTimeout <= 0 {
+		completionTimeout = 30 * time.Second
+	}
+	return &Interpreter{
+		apiKey:               cfg.APIKey,
+		endpoint:             endpoint,
+		model:                model,
+		temperature:          cfg.Temperature,
+		transcriptionTimeout: transcriptionTimeout,
+		completionTimeout:    completionTimeout,
+		retry:                interpreter.DefaultRetryPolicy(cfg.MaxAttempts),
+		client:               &http.Client{},
+	}
+}
+
+// Name returns the backend identifier.
+func (i *Interpreter) Name() string { return "gemini" }
+
+// --- Request and response types ---
+
+type generateRequest struct {
+	SystemInstruction *content         `json:"systemInstruction,omitempty"`
+	Contents          []content        `json:"contents"`
+	GenerationConfig  generationConfig `json:"generationConfig"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *inlineData `json:"inlineData,omitempty"`
+}
+
+type inlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
+type generationConfig struct {
+	Temperature      *float64       `json:"temperature,omitempty"`
+	ResponseMimeType string         `json:"responseMimeType"`
+	ResponseSchema   map[string]any `json:"responseSchema"`
+}
+
+type generateResponse struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// blockedFinishReasons are the finish reasons of candidates withheld by
+// Gemini's safety and policy filters.
+var blockedFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// BlockedError is returned when Gemini refuses a request or withholds its
+// reply under its safety or policy filters.
+type BlockedError struct {
+	Reason string // blockReason of the prompt, or finishReason of the candidate
+}
+
+func (e *BlockedError) Error() string {
+	return "request blocked by safety filters (reason " + e.Reason + ")"
+}
+
+// --- Transcription ---
+
+// Transcribe sends the audio inline with a prompt asking for a verbatim
+// transcript and its language. With opts.Translate the model is asked for
+// an English translation instead.
+//
+// Gemini reports no confidence, so transcripts from it are never gated by
+// min_confidence.
+func (i *Interpreter) Transcribe(ctx context.Context, data []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	model := i.model
+	if opts.Model != "" {
+		model = opts.Model
+	}
+
+	var prompt strings.Builder
+	if opts.Translate {
+		prompt.WriteString("Translate the speech in this audio into English. Return the English text and the ISO-639-1 code of the spoken language.")
+	} else {
+		prompt.WriteString("Transcribe the speech in this audio verbatim, in the language spoken. Return the transcript and the ISO-639-1 code of its language.")
+	}
+	if opts.Language != "" {
+		prompt.WriteString(" The speaker uses language " + opts.Language + " (ISO-639-1).")
+	}
+	if opts.Prompt != "" {
+		prompt.WriteString(" Vocabulary that may occur: " + opts.Prompt + ".")
+	}
+	prompt.WriteString(" Return an empty transcript if there is no speech.")
+
+	zero := 0.0
+	reqBody := generateRequest{
+		Contents: []content{{
+			Role: "user",
+			Parts: []part{
+				{Text: prompt.String()},
+				{InlineData: &inlineData{MimeType: mimeType(contentType), Data: base64.StdEncoding.EncodeToString(data)}},
+			},
+		}},
+		GenerationConfig: generationConfig{
+			Temperature:      &zero,
+			ResponseMimeType: "application/json",
+			ResponseSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"text":     map[string]any{"type": "string"},
+					"language": map[string]any{"type": "string"},
+				},
+				"required": []string{"text", "language"},
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, i.transcriptionTimeout)
+	defer cancel()
+	reply, _, err := i.generate(ctx, model, reqBody)
+	if err != nil {
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("gemini transcription: %w", err), "transcription", i.transcriptionTimeout)
+	}
+
+	var result struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	if err := json.Unmarshal([]byte(reply), &result); err != nil {
+		return nil, fmt.Errorf("decoding gemini transcript: %w: %.200s", err, reply)
+	}
+	language := result.Language
+	if opts.Language != "" {
+		language = opts.Language
+	}
+
+	slog.Debug("gemini transcription complete", "text_length", len(result.Text), "language", language)
+	return &interpreter.TranscribeResult{
+		Text:     strings.TrimSpace(result.Text),
+		Language: language,
+	}, nil
+}
+
+// mimeType maps the message content type to one Gemini accepts for audio.
+func mimeType(contentType string) string {
+	ct, _, _ := strings.Cut(contentType, ";")
+	ct = strings.TrimSpace(ct)
+	switch {
+	case ct == "", ct == "application/octet-stream", strings.Contains(ct, "wav"):
+		return "audio/wav"
+	case strings.Contains(ct, "mpeg"), strings.Contains(ct, "mp3"):
+		return "audio/mp3"
+	}
+	return ct
+}
+
+// --- Interpretation ---
+
+// Interpret asks Gemini for commands as structured output matching the
+// {"commands": [...], "response": "..."} shape. Session history is replayed
+// as earlier user and model turns. A reply that does not parse as commands
+// gets one repair request before the call fails.
+func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	model := i.model
+	if instruction.CompletionModel != "" {
+		model = instruction.CompletionModel
+	}
+	temperature := i.temperature
+	if instruction.Temperature != nil {
+		temperature = *instruction.Temperature
+	}
+
+	contents := make([]content, 0, 2*len(opts.History)+1)
+	for _, turn := range opts.History {
+		contents = append(contents,
+			content{Role: "user", Parts: []part{{Text: turn.Transcript}}},
+			content{Role: "model", Parts: []part{{Text: interpreter.TurnReply(turn)}}})
+	}
+	contents = append(contents, content{Role: "user", Parts: []part{{Text: text}}})
+
+	reqBody := generateRequest{
+		SystemInstruction: &content{Parts: []part{{Text: buildSystemPrompt(instruction, opts)}}},
+		Contents:          contents,
+		GenerationConfig: generationConfig{
+			Temperature:      &temperature,
+			ResponseMimeType: "application/json",
+			ResponseSchema:   commandsSchema(instruction),
+		},
+	}
+
+	reply, usage, err := i.complete(ctx, model, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	commands, responseText, err := parseCommands(reply)
+	if err != nil {
+		// One repair attempt: show the model its reply and the parse error.
+		slog.Warn("unparseable commands from Gemini, asking for a repair", "model", model, "error", err)
+		reqBody.Contents = append(contents,
+			content{Role: "model", Parts: []part{{Text: reply}}},
+			content{Role: "user", Parts: []part{{Text: interpreter.RepairPrompt(err)}}})
+		var repairUsage message.Usage
+		if reply, repairUsage, err = i.complete(ctx, model, reqBody); err != nil {
+			return nil, fmt.Errorf("repair request: %w", err)
+		}
+		usage.PromptTokens += repairUsage.PromptTokens
+		usage.CompletionTokens += repairUsage.CompletionTokens
+		usage.TotalTokens += repairUsage.TotalTokens
+		if commands, responseText, err = parseCommands(reply); err != nil {
+			return nil, fmt.Errorf("parsing commands after repair: %w", err)
+		}
+	}
+
+	slog.Debug("gemini interpretation complete", "commands", len(commands), "has_response", responseText != "")
+	return &interpreter.InterpretResult{
+		Commands:     commands,
+		ResponseText: responseText,
+		Usage:        usage,
+	}, nil
+}
+
+// complete makes one interpretation call under the completion timeout.
+func (i *Interpreter) complete(ctx context.Context, model string, reqBody generateRequest) (string, message.Usage, error) {
+	ctx, cancel := context.WithTimeout(ctx, i.completionTimeout)
+	defer cancel()
+	reply, usage, err := i.generate(ctx, model, reqBody)
+	if err != nil {
+		return "", usage, interpreter.TimeoutError(ctx, fmt.Errorf("gemini completion: %w", err), "completion", i.completionTimeout)
+	}
+	return reply, usage, nil
+}
+
+// generate calls generateContent and returns the text of the first
+// candidate. Blocked prompts and withheld candidates fail with a
+// *BlockedError rather than an empty reply.
+func (i *Interpreter) generate(ctx context.Context, model string, reqBody generateRequest) (string, message.Usage, error) {
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", message.Usage{}, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	u := i.endpoint + "/v1beta/models/" + url.PathEscape(model) + ":generateContent"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return "", message.Usage{}, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", i.apiKey)
+
+	resp, err := i.retry.Do(i.client, req)
+	if err != nil {
+		return "", message.Usage{}, fmt.Errorf("request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return "", message.Usage{}, fmt.Errorf("request failed (status %d): %s", resp.StatusCode, respBody)
+	}
+
+	var result generateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", message.Usage{}, fmt.Errorf("decoding response: %w", err)
+	}
+	usage := message.Usage{
+		PromptTokens:     result.UsageMetadata.PromptTokenCount,
+		CompletionTokens: result.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      result.UsageMetadata.TotalTokenCount,
+	}
+
+	if reason := result.PromptFeedback.BlockReason; reason != "" {
+		return "", usage, &BlockedError{Reason: reason}
+	}
+	if len(result.Candidates) == 0 {
+		return "", usage, fmt.Errorf("no candidates in response")
+	}
+	candidate := result.Candidates[0]
+	if blockedFinishReasons[candidate.FinishReason] {
+		return "", usage, &BlockedError{Reason: candidate.FinishReason}
+	}
+	var sb strings.Builder
+	for _, p := range candidate.Content.Parts {
+		sb.WriteString(p.Text)
+	}
+	if sb.Len() == 0 {
+		return "", usage, fmt.Errorf("empty response (finish reason %s)", candidate.FinishReason)
+	}
+	return sb.String(), usage, nil
+}
+
+// commandsSchema is the response schema of interpretation replies.
+// instr.Actions becomes an enum on the action field. Gemini's schema subset
+// has no free-form objects, so params are returned as a JSON string that
+// parseCommands decodes.
+func commandsSchema(instr message.Instruction) map[string]any {
+	action := map[string]any{
+		"type":        "string",
+		"description": "Command verb, e.g. turn_on, move_to, set_temperature",
+	}
+	if len(instr.Actions) > 0 {
+		action["enum"] = instr.Actions
+	}
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"commands": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"action": action,
+						"params": map[string]any{
+							"type":        "string",
+							"description": "Action-specific parameters as a JSON object, e.g. {\"brightness\": 50}",
+						},
+					},
+					"required": []string{"action"},
+				},
+			},
+			"response": map[string]any{"type": "string"},
+		},
+		"required": []string{"commands", "response"},
+	}
+}
+
+// Close is a no-op — requests are independent.
+func (i *Interpreter) Close() error { return nil }
+
+// HealthCheck verifies that the API endpoint accepts connections.
+func (i *Interpreter) HealthCheck(ctx context.Context) error {
+	return health.DialURL(ctx, i.endpoint)
+}
+
+// --- Internal helpers ---
+
+func buildSystemPrompt(instr message.Instruction, opts interpreter.InterpretOpts) string {
+	var sb strings.Builder
+	sb.WriteString("You are a voice command interpreter. ")
+	sb.WriteString("Turn the user's request into structured commands.\n\n")
+
+	if instr.ResponseFormat != "" {
+		sb.WriteString("Output format: " + instr.ResponseFormat + "\n")
+	}
+	if len(instr.Actions) > 0 {
+		sb.WriteString("Allowed actions: " + strings.Join(instr.Actions, ", ") + "\n")
+	}
+	if instr.Prompt != "" {
+		sb.WriteString("Context: " + instr.Prompt + "\n")
+	}
+	if opts.Context != "" {
+		sb.WriteString("Context: " + opts.Context + "\n")
+	}
+
+	responseLang := "the user's language"
+	if fixed := instr.FixedResponseLanguage(); fixed != "" {
+		responseLang = "language " + fixed + " (ISO-639-1)"
+	} else if strings.EqualFold(instr.ResponseLanguage, message.ResponseLanguageAuto) {
+		responseLang = "whichever language suits the user best"
+	}
+	if g := interpreter.ResponseGuidance(instr); g != "" {
+		sb.WriteString(g + "\n")
+	}
+	sb.WriteString("\nGive each command's params as a JSON object encoded in a string. ")
+	sb.WriteString("Set response to a short confirmation in " + responseLang + ".\n")
+	return sb.String()
+}
+
+// parseCommands decodes a structured-output reply. Params arrive as a JSON
+// string (see commandsSchema); an object is accepted too. An empty commands
+// array is a valid answer: the user asked for nothing to be done.
+func parseCommands(reply string) ([]message.Command, string, error) {
+	var wrapper struct {
+		Commands *[]struct {
+			Action string          `json:"action"`
+			Params json.RawMessage `json:"params"`
+		} `json:"commands"`
+		Response string `json:"response"`
+	}
+	if err := json.Unmarshal([]byte(reply), &wrapper); err != nil {
+		return nil, "", fmt.Errorf("could not parse Gemini response: %w: %.200s", err, reply)
+	}
+	if wrapper.Commands == nil {
+		return nil, "", fmt.Errorf("no commands in Gemini response: %.200s", reply)
+	}
+
+	commands := make([]message.Command, 0, len(*wrapper.Commands))
+	for _, c := range *wrapper.Commands {
+		if c.Action == "" {
+			return nil, "", fmt.Errorf("command without action in Gemini response: %.200s", reply)
+		}
+		cmd := message.Command{Action: c.Action}
+		params := []byte(c.Params)
+		var encoded string
+		if json.Unmarshal(params, &encoded) == nil {
+			params = []byte(encoded)
+		}
+		if len(bytes.TrimSpace(params)) > 0 {
+			if err := json.Unmarshal(params, &cmd.Params); err != nil {
+				return nil, "", fmt.Errorf("params of %q are not a JSON object: %w", c.Action, err)
+			}
+		}
+		raw, _ := json.Marshal(cmd)
+		cmd.Raw = raw
+		commands = append(commands, cmd)
+	}
+	return commands, wrapper.Response, nil
+}
@@ -0,0 +1,62 @@
+package gemini
+
+import "testing"
+
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    string
+		actions  []string
+		response string
+		wantErr  bool
+	}{
+		{
+			name:     "params as string",
+			reply:    `{"commands":[{"action":"turn_on","params":"{\"entity\":\"light.kitchen\"}"}],"response":"Done."}`,
+			actions:  []string{"turn_on"},
+			response: "Done.",
+		},
+		{
+			name:     "params as object",
+			reply:    `{"commands":[{"action":"turn_on","params":{"entity":"light.kitchen"}}],"response":"Done."}`,
+			actions:  []string{"turn_on"},
+			response: "Done.",
+		},
+		{
+			name:     "empty commands",
+			reply:    `{"commands":[],"response":"There is nothing to do."}`,
+			response: "There is nothing to do.",
+		},
+		{name: "missing commands", reply: `{"response":"Done."}`, wantErr: true},
+		{name: "command without action", reply: `{"commands":[{"params":"{}"}]}`, wantErr: true},
+		{name: "not JSON", reply: `turn on the light`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, response, err := parseCommands(tt.reply)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseCommands succeeded with %+v", commands)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCommands: %v", err)
+			}
+			if commands == nil {
+				t.Error("commands is nil, want a list")
+			}
+			if len(commands) != len(tt.actions) {
+				t.Fatalf("commands = %+v, want actions %v", commands, tt.actions)
+			}
+			for i, c := range commands {
+				if c.Action != tt.actions[i] || c.Params["entity"] != "light.kitchen" {
+					t.Errorf("command %d = %+v", i, c)
+				}
+			}
+			if response != tt.response {
+				t.Errorf("response = %q, want %q", response, tt.response)
+			}
+		})
+	}
+}
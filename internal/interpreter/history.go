@@ -0,0 +1,38 @@
+package interpreter
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// TurnReply renders a prior turn's result as the JSON object the model is
+// asked to return, for replaying it as an assistant message.
+func TurnReply(turn message.Turn) string {
+	type command struct {
+		Action string         `json:"action"`
+		Params map[string]any `json:"params,omitempty"`
+	}
+	reply := struct {
+		Commands []command `json:"commands"`
+		Response string    `json:"response,omitempty"`
+	}{Commands: []command{}, Response: turn.ResponseText}
+	for _, c := range turn.Commands {
+		reply.Commands = append(reply.Commands, command{Action: c.Action, Params: c.Params})
+	}
+	b, _ := json.Marshal(reply)
+	return string(b)
+}
+
+// FormatHistory renders prior turns as plain text, for backends that take a
+// single prompt instead of a message list.
+func FormatHistory(turns []message.Turn) string {
+	var sb strings.Builder
+	sb.WriteString("Previous turns in this conversation, oldest first:\n")
+	for _, t := range turns {
+		sb.WriteString("User: " + t.Transcript + "\n")
+		sb.WriteString("Assistant: " + TurnReply(t) + "\n")
+	}
+	return sb.String()
+}
@@ -7,10 +7,27 @@ package interpreter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/nadzzz/switchyard/internal/message"
 )
 
+// ErrTimeout is wrapped by errors returned when a backend call exceeds its
+// configured timeout.
+var ErrTimeout = errors.New("timed out")
+
+// TimeoutError returns a descriptive ErrTimeout-wrapping error if ctx expired
+// because of its deadline, and err unchanged otherwise. Backends call it with
+// the per-request context they derived from their configured timeout.
+func TimeoutError(ctx context.Context, err error, stage string, timeout time.Duration) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s %w after %s", stage, ErrTimeout, timeout)
+	}
+	return err
+}
+
 // TranscribeOpts controls transcription behavior.
 type TranscribeOpts struct {
 	// Language is the ISO-639-1 code (e.g., "en", "fr") to guide transcription.
@@ -21,6 +38,11 @@ type TranscribeOpts struct {
 
 	// Model overrides the default transcription model.
 	Model string
+
+	// Translate asks for an English translation of the speech instead of a
+	// transcript in the spoken language. Language, if set, names the source
+	// language.
+	Translate bool
 }
 
 // TranscribeResult holds the output of audio transcription.
@@ -29,8 +51,30 @@ type TranscribeResult struct {
 	Text string
 
 	// Language is the ISO-639-1 code detected by the transcription model (e.g., "en", "fr", "es").
+	// It is the source language even when the text was translated.
 	// Empty if the model does not report language or a fixed language was requested.
 	Language string
+
+	// Segments are timed spans of Text. Empty if the backend or model does
+	// not report them.
+	Segments []message.Segment
+
+	// AvgConfidence is the backend's confidence in Text, from 0 to 1.
+	// It is 0 if the backend or model does not report one.
+	AvgConfidence float64
+}
+
+// InterpretOpts controls interpretation behavior.
+type InterpretOpts struct {
+	// Language is the ISO-639-1 code of the transcript (detected or requested).
+	Language string
+
+	// Context is extra prompt context selected for Language by the dispatcher.
+	Context string
+
+	// History holds the session's previous turns, oldest first. Backends
+	// replay them ahead of the current transcript.
+	History []message.Turn
 }
 
 // InterpretResult holds the output of command interpretation.
@@ -40,6 +84,9 @@ type InterpretResult struct {
 
 	// ResponseText is an optional natural-language confirmation to speak back to the user.
 	ResponseText string
+
+	// Usage is the token usage reported by the backend (zero if not reported).
+	Usage message.Usage
 }
 
 // Interpreter is the interface for audio transcription and command generation.
@@ -51,7 +98,7 @@ type Interpreter interface {
 	Transcribe(ctx context.Context, audio []byte, contentType string, opts TranscribeOpts) (*TranscribeResult, error)
 
 	// Interpret takes transcribed text and an instruction, then produces commands.
-	Interpret(ctx context.Context, text string, instruction message.Instruction) (*InterpretResult, error)
+	Interpret(ctx context.Context, text string, instruction message.Instruction, opts InterpretOpts) (*InterpretResult, error)
 
 	// Close releases any resources held by the interpreter.
 	Close() error
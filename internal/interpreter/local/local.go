@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -16,21 +17,30 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/nadzzz/switchyard/internal/audio"
 	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
 	"github.com/nadzzz/switchyard/internal/interpreter"
 	"github.com/nadzzz/switchyard/internal/message"
 )
 
 // Interpreter uses self-hosted models for transcription and command generation.
 type Interpreter struct {
-	whisperEndpoint string
-	whisperType     string // "openai" or "asr"
-	llmEndpoint     string
-	llmModel        string
-	vadFilter       bool
-	defaultLanguage string
-	client          *http.Client
+	whisperEndpoint      string
+	whisperType          string // "openai" or "asr"
+	llmEndpoint          string
+	llmModel             string
+	vadFilter            bool
+	defaultLanguage      string
+	transcode            bool
+	ffmpegPath           string
+	temperature          float64
+	transcriptionTimeout time.Duration
+	completionTimeout    time.Duration
+	retry                interpreter.RetryPolicy
+	client               *http.Client
 }
 
 // New creates a new local interpreter from config.
@@ -43,14 +53,28 @@ func New(cfg config.LocalConfig) *Interpreter {
 	if model == "" {
 		model = "llama3"
 	}
+	transcriptionTimeout := cfg.TranscriptionTimeout
+	if transcriptionTimeout <= 0 {
+		transcriptionTimeout = 60 * time.Second
+	}
+	completionTimeout := cfg.CompletionTimeout
+	if completionTimeout <= 0 {
+		completionTimeout = 30 * time.Second
+	}
 	return &Interpreter{
-		whisperEndpoint: cfg.WhisperEndpoint,
-		whisperType:     wt,
-		llmEndpoint:     cfg.LLMEndpoint,
-		llmModel:        model,
-		vadFilter:       cfg.VADFilter,
-		defaultLanguage: cfg.Language,
-		client:          &http.Client{},
+		whisperEndpoint:      cfg.WhisperEndpoint,
+		whisperType:          wt,
+		llmEndpoint:          cfg.LLMEndpoint,
+		llmModel:             model,
+		vadFilter:            cfg.VADFilter,
+		defaultLanguage:      cfg.Language,
+		transcode:            cfg.Transcode,
+		ffmpegPath:           cfg.FFmpegPath,
+		temperature:          cfg.Temperature,
+		transcriptionTimeout: transcriptionTimeout,
+		completionTimeout:    completionTimeout,
+		retry:                interpreter.DefaultRetryPolicy(cfg.MaxAttempts),
+		client:               &http.Client{},
 	}
 }
 
@@ -61,17 +85,38 @@ func (i *Interpreter) Name() string { return "local" }
 // Supports two flavors:
 //   - "openai": OpenAI-compatible API (whisper.cpp server, faster-whisper)
 //   - "asr":    ahmetoner/whisper-asr-webservice (POST /asr with query params)
-func (i *Interpreter) Transcribe(ctx context.Context, audio []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+//
+// With transcoding enabled, non-WAV input is converted to 16 kHz mono WAV
+// first, for servers that only accept WAV.
+func (i *Interpreter) Transcribe(ctx context.Context, data []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, i.transcriptionTimeout)
+	defer cancel()
+
+	if i.transcode && !isWAV(data, contentType) {
+		wav, err := audio.DecodeWithFFmpeg(ctx, i.ffmpegPath, data, 16000)
+		if err != nil {
+			return nil, interpreter.TimeoutError(ctx, fmt.Errorf("transcoding %s: %w", contentType, err), "transcription", i.transcriptionTimeout)
+		}
+		slog.Debug("transcoded audio to wav", "content_type", contentType, "bytes", len(data), "wav_bytes", len(wav))
+		data, contentType = wav, "audio/wav"
+	}
+
 	switch i.whisperType {
 	case "asr":
-		return i.transcribeASR(ctx, audio, contentType, opts)
+		return i.transcribeASR(ctx, data, contentType, opts)
 	default:
-		return i.transcribeOpenAI(ctx, audio, contentType, opts)
+		return i.transcribeOpenAI(ctx, data, contentType, opts)
 	}
 }
 
+// isWAV reports whether data is WAV audio, by its content type or header.
+func isWAV(data []byte, contentType string) bool {
+	return strings.Contains(contentType, "wav") || bytes.HasPrefix(data, []byte("RIFF"))
+}
+
 // transcribeASR handles the ahmetoner/whisper-asr-webservice format.
 // API: POST /asr?task=transcribe&language=en&output=json&vad_filter=true
+// (task=translate when opts.Translate is set)
 // Body: multipart/form-data with field "audio_file"
 func (i *Interpreter) transcribeASR(ctx context.Context, audio []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
 	body := &bytes.Buffer{}
@@ -90,7 +135,11 @@ func (i *Interpreter) transcribeASR(ctx context.Context, audio []byte, contentTy
 	// Build URL with query parameters.
 	endpoint := i.whisperEndpoint
 	q := make(url.Values)
-	q.Set("task", "transcribe")
+	task := "transcribe"
+	if opts.Translate {
+		task = "translate"
+	}
+	q.Set("task", task)
 	q.Set("output", "verbose_json")
 	q.Set("encode", "true")
 
@@ -117,9 +166,9 @@ func (i *Interpreter) transcribeASR(ctx context.Context, audio []byte, contentTy
 
 	slog.Debug("whisper-asr request", "url", reqURL)
 
-	resp, err := i.client.Do(req)
+	resp, err := i.retry.Do(i.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("asr transcription request: %w", err)
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("asr transcription request: %w", err), "transcription", i.transcriptionTimeout)
 	}
 	defer resp.Body.Close()
 
@@ -130,17 +179,21 @@ func (i *Interpreter) transcribeASR(ctx context.Context, audio []byte, contentTy
 
 	// The ASR service returns {"text": "...", "language": "..."} when output=verbose_json.
 	var result struct {
-		Text     string `json:"text"`
-		Language string `json:"language"`
+		Text     string                       `json:"text"`
+		Language string                       `json:"language"`
+		Segments []interpreter.WhisperSegment `json:"segments"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding asr response: %w", err)
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("decoding asr response: %w", err), "transcription", i.transcriptionTimeout)
 	}
 
-	slog.Debug("asr transcription complete", "text_length", len(result.Text), "language", result.Language)
+	segments, confidence := interpreter.WhisperSegments(result.Segments)
+	slog.Debug("asr transcription complete", "text_length", len(result.Text), "language", result.Language, "confidence", confidence)
 	return &interpreter.TranscribeResult{
-		Text:     result.Text,
-		Language: result.Language,
+		Text:          result.Text,
+		Language:      result.Language,
+		Segments:      segments,
+		AvgConfidence: confidence,
 	}, nil
 }
 
@@ -169,17 +222,27 @@ func (i *Interpreter) transcribeOpenAI(ctx context.Context, audio []byte, conten
 		_ = writer.WriteField("language", lang)
 	}
 	_ = writer.WriteField("response_format", "verbose_json")
+	endpoint := i.whisperEndpoint
+	if opts.Translate {
+		// OpenAI-compatible servers expose translation as a sibling of the
+		// transcriptions endpoint; whisper.cpp's server takes a form flag.
+		if base, ok := strings.CutSuffix(endpoint, "/transcriptions"); ok {
+			endpoint = base + "/translations"
+		} else {
+			_ = writer.WriteField("translate", "true")
+		}
+	}
 	writer.Close()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.whisperEndpoint, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, body)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := i.client.Do(req)
+	resp, err := i.retry.Do(i.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("local transcription request: %w", err)
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("local transcription request: %w", err), "transcription", i.transcriptionTimeout)
 	}
 	defer resp.Body.Close()
 
@@ -189,100 +252,180 @@ func (i *Interpreter) transcribeOpenAI(ctx context.Context, audio []byte, conten
 	}
 
 	var result struct {
-		Text     string `json:"text"`
-		Language string `json:"language"`
+		Text     string                       `json:"text"`
+		Language string                       `json:"language"`
+		Segments []interpreter.WhisperSegment `json:"segments"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding transcription: %w", err)
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("decoding transcription: %w", err), "transcription", i.transcriptionTimeout)
 	}
 
-	slog.Debug("local transcription complete", "text_length", len(result.Text), "language", result.Language)
+	segments, confidence := interpreter.WhisperSegments(result.Segments)
+	slog.Debug("local transcription complete", "text_length", len(result.Text), "language", result.Language, "confidence", confidence)
 	return &interpreter.TranscribeResult{
-		Text:     result.Text,
-		Language: result.Language,
+		Text:          result.Text,
+		Language:      result.Language,
+		Segments:      segments,
+		AvgConfidence: confidence,
 	}, nil
 }
 
 // Interpret sends the transcribed text to the local LLM endpoint.
-// Supports Ollama's /api/generate and OpenAI-compatible /v1/chat/completions.
-func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction) (*interpreter.InterpretResult, error) {
-	systemPrompt := buildSystemPrompt(instruction)
+// Supports Ollama's /api/chat and /api/generate, and OpenAI-compatible
+// /v1/chat/completions. A reply that does not parse as commands gets one
+// repair request before the call fails.
+func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	systemPrompt := buildSystemPrompt(instruction, opts)
+	model := i.llmModel
+	if instruction.CompletionModel != "" {
+		model = instruction.CompletionModel
+	}
+	temperature := i.temperature
+	if instruction.Temperature != nil {
+		temperature = *instruction.Temperature
+	}
 
 	// Try OpenAI-compatible chat completions format first (works with Ollama, vLLM, llama.cpp).
+	messages := []map[string]string{{"role": "system", "content": systemPrompt}}
+	for _, turn := range opts.History {
+		messages = append(messages,
+			map[string]string{"role": "user", "content": turn.Transcript},
+			map[string]string{"role": "assistant", "content": interpreter.TurnReply(turn)})
+	}
+	messages = append(messages, map[string]string{"role": "user", "content": text})
+
 	reqBody := map[string]any{
-		"model": i.llmModel,
-		"messages": []map[string]string{
-			{"role": "system", "content": systemPrompt},
-			{"role": "user", "content": text},
-		},
-		"temperature": 0.2,
+		"model":       model,
+		"messages":    messages,
+		"temperature": temperature,
 		"stream":      false,
 	}
 
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("marshalling request: %w", err)
+	// Determine endpoint — Ollama's native /api/chat and /api/generate take
+	// their own request shapes.
+	generate := strings.HasSuffix(i.llmEndpoint, "/api/generate")
+	if strings.HasSuffix(i.llmEndpoint, "/api/chat") {
+		reqBody = map[string]any{
+			"model":    model,
+			"messages": messages,
+			"stream":   false,
+			"format":   "json",
+		}
+		reqBody["options"] = map[string]any{"temperature": temperature}
 	}
-
-	// Determine endpoint — if it ends with /api/generate, use Ollama format.
-	endpoint := i.llmEndpoint
-	if strings.HasSuffix(endpoint, "/api/generate") {
+	prompt := text
+	if generate {
+		if len(opts.History) > 0 {
+			prompt = interpreter.FormatHistory(opts.History) + "\nCurrent request:\n" + text
+		}
 		reqBody = map[string]any{
-			"model":  i.llmModel,
+			"model":  model,
 			"system": systemPrompt,
-			"prompt": text,
+			"prompt": prompt,
 			"stream": false,
 			"format": "json",
 		}
-		bodyBytes, _ = json.Marshal(reqBody)
+		reqBody["options"] = map[string]any{"temperature": temperature}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
+	content, err := i.complete(ctx, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
+	}
+	commands, responseText, err := parseCommands(content)
+	if err != nil {
+		// One repair attempt: show the model its reply and the parse error.
+		slog.Warn("unparseable commands from local LLM, asking for a repair", "model", model, "error", err)
+		if generate {
+			reqBody["prompt"] = prompt + "\n\nYour reply:\n" + content + "\n\n" + interpreter.RepairPrompt(err)
+		} else {
+			reqBody["messages"] = append(messages,
+				map[string]string{"role": "assistant", "content": content},
+				map[string]string{"role": "user", "content": interpreter.RepairPrompt(err)})
+		}
+		if content, err = i.complete(ctx, reqBody); err != nil {
+			return nil, fmt.Errorf("repair request: %w", err)
+		}
+		if commands, responseText, err = parseCommands(content); err != nil {
+			return nil, fmt.Errorf("parsing commands after repair: %w", err)
+		}
+	}
+
+	slog.Debug("local interpretation complete", "commands", len(commands), "has_response", responseText != "")
+	return &interpreter.InterpretResult{
+		Commands:     commands,
+		ResponseText: responseText,
+	}, nil
+}
+
+// complete makes one LLM call and returns the reply content.
+func (i *Interpreter) complete(ctx context.Context, reqBody map[string]any) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, i.completionTimeout)
+	defer cancel()
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", fmt.Errorf("marshalling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.llmEndpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := i.client.Do(req)
+	resp, err := i.retry.Do(i.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("local LLM request: %w", err)
+		return "", interpreter.TimeoutError(ctx, fmt.Errorf("local LLM request: %w", err), "completion", i.completionTimeout)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-		return nil, fmt.Errorf("local LLM failed (status %d): %s", resp.StatusCode, respBody)
+		return "", fmt.Errorf("local LLM failed (status %d): %s", resp.StatusCode, respBody)
 	}
 
 	respData, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("reading LLM response: %w", err)
+		return "", interpreter.TimeoutError(ctx, fmt.Errorf("reading LLM response: %w", err), "completion", i.completionTimeout)
 	}
 
 	// Extract the content from the response.
 	content := extractContent(respData)
 	if content == "" {
-		return nil, fmt.Errorf("empty response from local LLM")
-	}
-
-	commands, responseText, err := parseCommands(content)
-	if err != nil {
-		return nil, fmt.Errorf("parsing commands: %w", err)
+		return "", fmt.Errorf("empty response from local LLM")
 	}
-
-	slog.Debug("local interpretation complete", "commands", len(commands), "has_response", responseText != "")
-	return &interpreter.InterpretResult{
-		Commands:     commands,
-		ResponseText: responseText,
-	}, nil
+	return content, nil
 }
 
 // Close is a no-op for the local interpreter.
 func (i *Interpreter) Close() error { return nil }
 
+// HealthCheck verifies that the whisper and LLM servers accept connections.
+func (i *Interpreter) HealthCheck(ctx context.Context) error {
+	var errs []error
+	if err := health.DialURL(ctx, i.whisperEndpoint); err != nil {
+		errs = append(errs, fmt.Errorf("whisper: %w", err))
+	}
+	if err := health.DialURL(ctx, i.llmEndpoint); err != nil {
+		errs = append(errs, fmt.Errorf("llm: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
 // --- Internal helpers ---
 
+// extractContent returns the generated text of an OpenAI-compatible or
+// Ollama response, streamed or not, or the body itself if it has neither.
 func extractContent(data []byte) string {
+	// A streamed body (stream: true, or a streaming proxy) is a sequence of
+	// JSON objects rather than one.
+	if !json.Valid(data) {
+		if content, ok := extractStreamed(data); ok {
+			return content
+		}
+	}
+
 	// Try OpenAI-compatible format: {"choices": [{"message": {"content": "..."}}]}
 	var chatResp struct {
 		Choices []struct {
@@ -295,6 +438,16 @@ func extractContent(data []byte) string {
 		return chatResp.Choices[0].Message.Content
 	}
 
+	// Try Ollama chat format: {"message": {"content": "..."}}
+	var ollamaChat struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(data, &ollamaChat); err == nil && ollamaChat.Message.Content != "" {
+		return ollamaChat.Message.Content
+	}
+
 	// Try Ollama format: {"response": "..."}
 	var ollamaResp struct {
 		Response string `json:"response"`
@@ -306,7 +459,43 @@ func extractContent(data []byte) string {
 	return string(data)
 }
 
-func buildSystemPrompt(instr message.Instruction) string {
+// extractStreamed concatenates the text fragments of a streamed response:
+// Ollama's newline-delimited JSON objects, or OpenAI-style "data:" lines
+// with choice deltas. It reports false if a line is not a JSON object.
+func extractStreamed(data []byte) (string, bool) {
+	var sb strings.Builder
+	for line := range bytes.Lines(data) {
+		line = bytes.TrimSpace(line)
+		if rest, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			line = bytes.TrimSpace(rest)
+		}
+		if len(line) == 0 || string(line) == "[DONE]" {
+			continue
+		}
+		var chunk struct {
+			Response string `json:"response"`
+			Message  struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", false
+		}
+		sb.WriteString(chunk.Response)
+		sb.WriteString(chunk.Message.Content)
+		if len(chunk.Choices) > 0 {
+			sb.WriteString(chunk.Choices[0].Delta.Content)
+		}
+	}
+	return sb.String(), true
+}
+
+func buildSystemPrompt(instr message.Instruction, opts interpreter.InterpretOpts) string {
 	var sb strings.Builder
 	sb.WriteString("You are a voice command interpreter. ")
 	sb.WriteString("Return structured commands as JSON.\n\n")
@@ -314,11 +503,26 @@ func buildSystemPrompt(instr message.Instruction) string {
 	if instr.ResponseFormat != "" {
 		sb.WriteString("Output format: " + instr.ResponseFormat + "\n")
 	}
+	if len(instr.Actions) > 0 {
+		sb.WriteString("Allowed actions: " + strings.Join(instr.Actions, ", ") + "\n")
+	}
 	if instr.Prompt != "" {
 		sb.WriteString("Context: " + instr.Prompt + "\n")
 	}
+	if opts.Context != "" {
+		sb.WriteString("Context: " + opts.Context + "\n")
+	}
 
-	sb.WriteString("\nReturn: {\"commands\": [{\"action\": \"...\", \"params\": {...}}], \"response\": \"short confirmation in the user's language\"}\n")
+	responseLang := "the user's language"
+	if fixed := instr.FixedResponseLanguage(); fixed != "" {
+		responseLang = "language " + fixed + " (ISO-639-1)"
+	} else if strings.EqualFold(instr.ResponseLanguage, message.ResponseLanguageAuto) {
+		responseLang = "whichever language suits the user best"
+	}
+	if g := interpreter.ResponseGuidance(instr); g != "" {
+		sb.WriteString(g + "\n")
+	}
+	sb.WriteString("\nReturn: {\"commands\": [{\"action\": \"...\", \"params\": {...}}], \"response\": \"short confirmation in " + responseLang + "\"}\n")
 	return sb.String()
 }
 
@@ -0,0 +1,179 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+const commandsJSON = `{"commands": [{"action": "turn_on", "params": {"entity": "light.kitchen"}}], "response": "Done."}`
+
+// llmServer serves path with a handler that records each decoded request
+// body and answers with reply.
+func llmServer(t *testing.T, path, reply string) (*httptest.Server, *[]map[string]any) {
+	t.Helper()
+	var reqs []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]any
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reqs = append(reqs, req)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, reply)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func TestInterpretEndpointStyles(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		reply string
+		check func(t *testing.T, req map[string]any)
+	}{
+		{
+			name:  "openai compatible",
+			path:  "/v1/chat/completions",
+			reply: `{"choices": [{"message": {"role": "assistant", "content": ` + quote(commandsJSON) + `}}]}`,
+			check: func(t *testing.T, req map[string]any) {
+				if _, ok := req["messages"].([]any); !ok {
+					t.Error("request has no messages")
+				}
+				if req["temperature"] == nil {
+					t.Error("temperature not sent at the top level")
+				}
+			},
+		},
+		{
+			name:  "ollama chat",
+			path:  "/api/chat",
+			reply: `{"model": "llama3", "message": {"role": "assistant", "content": ` + quote(commandsJSON) + `}, "done": true}`,
+			check: func(t *testing.T, req map[string]any) {
+				if _, ok := req["messages"].([]any); !ok {
+					t.Error("request has no messages")
+				}
+				if req["format"] != "json" {
+					t.Errorf("format = %v, want json", req["format"])
+				}
+				if opts, _ := req["options"].(map[string]any); opts["temperature"] == nil {
+					t.Error("temperature not sent in options")
+				}
+			},
+		},
+		{
+			name:  "ollama generate",
+			path:  "/api/generate",
+			reply: `{"model": "llama3", "response": ` + quote(commandsJSON) + `, "done": true}`,
+			check: func(t *testing.T, req map[string]any) {
+				if req["prompt"] != "turn on the kitchen light" {
+					t.Errorf("prompt = %v", req["prompt"])
+				}
+				if s, _ := req["system"].(string); s == "" {
+					t.Error("system prompt not sent")
+				}
+				if req["format"] != "json" {
+					t.Errorf("format = %v, want json", req["format"])
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, reqs := llmServer(t, tt.path, tt.reply)
+			i := New(config.LocalConfig{LLMEndpoint: srv.URL + tt.path, LLMModel: "llama3", MaxAttempts: 1})
+
+			res, err := i.Interpret(context.Background(), "turn on the kitchen light", message.Instruction{}, interpreter.InterpretOpts{})
+			if err != nil {
+				t.Fatalf("Interpret: %v", err)
+			}
+			if len(res.Commands) != 1 || res.Commands[0].Action != "turn_on" || res.ResponseText != "Done." {
+				t.Errorf("result = %+v", res)
+			}
+			if len(*reqs) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*reqs))
+			}
+			req := (*reqs)[0]
+			if req["model"] != "llama3" || req["stream"] != false {
+				t.Errorf("model/stream = %v/%v", req["model"], req["stream"])
+			}
+			tt.check(t, req)
+		})
+	}
+}
+
+func TestExtractContent(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"openai", `{"choices":[{"message":{"content":"hi"}}]}`, "hi"},
+		{"ollama chat", `{"message":{"content":"hi"}}`, "hi"},
+		{"ollama generate", `{"response":"hi"}`, "hi"},
+		{"plain text", "hi there", "hi there"},
+		{
+			"ollama ndjson",
+			`{"message":{"content":"{\"com"},"done":false}` + "\n" +
+				`{"message":{"content":"mands\":[]}"},"done":false}` + "\n" +
+				`{"message":{"content":""},"done":true}` + "\n",
+			`{"commands":[]}`,
+		},
+		{
+			"ollama generate ndjson",
+			`{"response":"hel","done":false}` + "\n" + `{"response":"lo","done":true}`,
+			"hello",
+		},
+		{
+			"openai sse",
+			`data: {"choices":[{"delta":{"role":"assistant"}}]}` + "\n\n" +
+				`data: {"choices":[{"delta":{"content":"hel"}}]}` + "\n\n" +
+				`data: {"choices":[{"delta":{"content":"lo"}}]}` + "\n\n" +
+				"data: [DONE]\n\n",
+			"hello",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractContent([]byte(tt.body)); got != tt.want {
+				t.Errorf("extractContent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemPromptResponseLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", "confirmation in the user's language"},
+		{message.ResponseLanguageMatch, "confirmation in the user's language"},
+		{message.ResponseLanguageAuto, "confirmation in whichever language suits the user best"},
+		{"de", "confirmation in language de (ISO-639-1)"},
+	}
+	for _, tt := range tests {
+		prompt := buildSystemPrompt(message.Instruction{ResponseLanguage: tt.language}, interpreter.InterpretOpts{})
+		if !strings.Contains(prompt, tt.want) {
+			t.Errorf("response_language %q: prompt lacks %q:\n%s", tt.language, tt.want, prompt)
+		}
+	}
+}
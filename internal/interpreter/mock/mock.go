@@ -0,0 +1,124 @@
+// Package mock implements an interpreter that needs no model server, for
+// CI, integration tests of transports and routing, and demos.
+//
+// Audio always transcribes to a fixed transcript. Text is interpreted with
+// keyword rules: each rule whose keyword appears in the text contributes a
+// command, so "turn on the lights" yields a turn_on command.
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// defaultRules are used when no rules are configured.
+var defaultRules = []config.MockRule{
+	{Keywords: []string{"off"}, Action: "turn_off", ResponseText: "Turning off."},
+	{Keywords: []string{"on"}, Action: "turn_on", ResponseText: "Turning on."},
+}
+
+// rule is a MockRule with its keywords split into lowercase words.
+type rule struct {
+	keywords [][]string
+	config.MockRule
+}
+
+// Interpreter answers from configuration instead of a model.
+type Interpreter struct {
+	transcript string
+	language   string
+	rules      []rule
+}
+
+// New creates a mock interpreter from config.
+func New(cfg config.MockConfig) *Interpreter {
+	rules := cfg.Rules
+	if len(rules) == 0 {
+		rules = defaultRules
+	}
+	i := &Interpreter{transcript: cfg.Transcript, language: cfg.Language}
+	for _, r := range rules {
+		compiled := rule{MockRule: r}
+		for _, k := range r.Keywords {
+			if words := words(k); len(words) > 0 {
+				compiled.keywords = append(compiled.keywords, words)
+			}
+		}
+		i.rules = append(i.rules, compiled)
+	}
+	return i
+}
+
+// Name returns the backend identifier.
+func (i *Interpreter) Name() string { return "mock" }
+
+// Transcribe returns the configured transcript for any audio, in the
+// requested language or else the configured one.
+func (i *Interpreter) Transcribe(_ context.Context, _ []byte, _ string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	lang := opts.Language
+	if lang == "" {
+		lang = i.language
+	}
+	return &interpreter.TranscribeResult{Text: i.transcript, Language: lang}, nil
+}
+
+// Interpret returns a command for every rule with a keyword in text, in
+// rule order, and their response texts joined. Text matching no rule gives
+// no commands.
+func (i *Interpreter) Interpret(_ context.Context, text string, _ message.Instruction, _ interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	textWords := words(text)
+	result := &interpreter.InterpretResult{}
+	var responses []string
+	for _, r := range i.rules {
+		if !r.matches(textWords) {
+			continue
+		}
+		// A JSON round trip gives each command its own params, decoded
+		// like a model's reply.
+		raw, err := json.Marshal(message.Command{Action: r.Action, Params: r.Params})
+		if err != nil {
+			return nil, fmt.Errorf("encoding mock command: %w", err)
+		}
+		var cmd message.Command
+		if err := json.Unmarshal(raw, &cmd); err != nil {
+			return nil, fmt.Errorf("decoding mock command: %w", err)
+		}
+		cmd.Raw = raw
+		result.Commands = append(result.Commands, cmd)
+		if r.ResponseText != "" {
+			responses = append(responses, r.ResponseText)
+		}
+	}
+	result.ResponseText = strings.Join(responses, " ")
+	return result, nil
+}
+
+// Close is a no-op for the mock interpreter.
+func (i *Interpreter) Close() error { return nil }
+
+// matches reports whether any keyword occurs in text as whole words.
+func (r rule) matches(text []string) bool {
+	for _, k := range r.keywords {
+		for start := 0; start+len(k) <= len(text); start++ {
+			if slices.Equal(text[start:start+len(k)], k) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// words splits s into lowercase words of letters and digits.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
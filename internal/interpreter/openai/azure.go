@@ -0,0 +1,56 @@
+package openai
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nadzzz/switchyard/internal/config"
+)
+
+// defaultAzureAPIVersion is used when azure.api_version is not set.
+const defaultAzureAPIVersion = "2024-10-21"
+
+// azure holds Azure OpenAI settings. Azure serves each model from a named
+// deployment under the resource endpoint, takes the key in an "api-key"
+// header, and requires an api-version query parameter. Model names in the
+// config (and per-message overrides) are used as deployment names.
+type azure struct {
+	endpoint   string // https://{resource}.openai.azure.com
+	apiVersion string
+}
+
+func newAzure(cfg config.AzureOpenAIConfig) *azure {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	version := cfg.APIVersion
+	if version == "" {
+		version = defaultAzureAPIVersion
+	}
+	return &azure{endpoint: strings.TrimSuffix(cfg.Endpoint, "/"), apiVersion: version}
+}
+
+// url builds the URL of operation (e.g., "chat/completions") on deployment.
+func (a *azure) url(deployment, operation string) string {
+	return a.endpoint + "/openai/deployments/" + url.PathEscape(deployment) + "/" + operation +
+		"?api-version=" + url.QueryEscape(a.apiVersion)
+}
+
+// endpointURL returns the URL for operation with model: {base}/v1/{operation}
+// for OpenAI and compatible gateways, or the model's deployment on Azure.
+func (i *Interpreter) endpointURL(operation, model string) string {
+	if i.azure == nil {
+		return i.baseURL + "/v1/" + operation
+	}
+	return i.azure.url(model, operation)
+}
+
+// setAuth sets the API key header expected by OpenAI or Azure.
+func (i *Interpreter) setAuth(req *http.Request) {
+	if i.azure != nil {
+		req.Header.Set("api-key", i.apiKey)
+		return
+	}
+	req.Header.Set("Authorization", "Bearer "+i.apiKey)
+}
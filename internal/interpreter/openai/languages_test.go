@@ -0,0 +1,38 @@
+package openai
+
+import "testing"
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"english", "en"},
+		{"French", "fr"},
+		{" spanish ", "es"},
+		{"castilian", "es"},
+		{"flemish", "nl"},
+		{"haitian creole", "ht"},
+		{"cantonese", "yue"},
+		{"hawaiian", "haw"},
+		{"jw", "jv"},
+		{"de", "de"},
+		{"klingon", "klingon"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeLanguage(tt.in); got != tt.want {
+			t.Errorf("normalizeLanguage(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageCodes(t *testing.T) {
+	for name, code := range languageCodes {
+		if normalizeLanguage(name) != code {
+			t.Errorf("languageCodes[%q] = %q is not reachable through normalizeLanguage", name, code)
+		}
+		if len(code) < 2 || len(code) > 3 {
+			t.Errorf("languageCodes[%q] = %q is not a language code", name, code)
+		}
+	}
+}
@@ -15,40 +15,179 @@ import (
 	"mime/multipart"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
 	"github.com/nadzzz/switchyard/internal/interpreter"
 	"github.com/nadzzz/switchyard/internal/message"
 )
 
 const (
-	transcriptionURL = "https://api.openai.com/v1/audio/transcriptions"
-	chatURL          = "https://api.openai.com/v1/chat/completions"
+	// defaultBaseURL is used when base_url is not set. Endpoints are
+	// {base}/v1/{operation}.
+	defaultBaseURL = "https://api.openai.com"
+
+	// groqBaseURL is the default base URL with provider "groq".
+	groqBaseURL = "https://api.groq.com/openai"
+
+	// translationModel is the only model the translations endpoint accepts.
+	translationModel = "whisper-1"
+
+	// groqTranslationModel is the Groq model that translates; the turbo
+	// variant only transcribes.
+	groqTranslationModel = "whisper-large-v3"
 )
 
 // Interpreter uses OpenAI APIs for transcription and command generation.
 type Interpreter struct {
-	apiKey             string
-	transcriptionModel string
-	completionModel    string
-	client             *http.Client
+	apiKey               string
+	transcriptionModel   string
+	completionModel      string
+	transcriptionTimeout time.Duration
+	completionTimeout    time.Duration
+	retry                interpreter.RetryPolicy
+	limits               limiters // per-model request pacing
+	toolCalling          string   // "auto", "on" or "off"
+	temperature          float64
+	seed                 *int   // nil = not sent
+	baseURL              string // e.g., https://api.openai.com, without trailing slash
+	azure                *azure // nil for OpenAI-compatible APIs
+	groq                 bool
+	client               *http.Client
+
+	// jsonOnly records the transcription models that rejected verbose_json,
+	// so later requests go straight to the json format.
+	jsonOnly sync.Map
 }
 
 // New creates a new OpenAI interpreter from config.
 func New(cfg config.OpenAIConfig) *Interpreter {
+	transcriptionTimeout := cfg.TranscriptionTimeout
+	if transcriptionTimeout <= 0 {
+		transcriptionTimeout = 60 * time.Second
+	}
+	completionTimeout := cfg.CompletionTimeout
+	if completionTimeout <= 0 {
+		completionTimeout = 30 * time.Second
+	}
+	groq := cfg.Provider == "groq"
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	switch {
+	case baseURL != "":
+	case groq:
+		baseURL = groqBaseURL
+	default:
+		baseURL = defaultBaseURL
+	}
 	return &Interpreter{
-		apiKey:             cfg.APIKey,
-		transcriptionModel: cfg.TranscriptionModel,
-		completionModel:    cfg.CompletionModel,
-		client:             &http.Client{},
+		apiKey:               cfg.APIKey,
+		transcriptionModel:   cfg.TranscriptionModel,
+		completionModel:      cfg.CompletionModel,
+		transcriptionTimeout: transcriptionTimeout,
+		completionTimeout:    completionTimeout,
+		retry:                interpreter.DefaultRetryPolicy(cfg.MaxAttempts),
+		limits:               newLimiters(cfg.RateLimits),
+		toolCalling:          cfg.ToolCalling,
+		temperature:          cfg.Temperature,
+		seed:                 cfg.Seed,
+		baseURL:              baseURL,
+		azure:                newAzure(cfg.Azure),
+		groq:                 groq,
+		client:               &http.Client{},
 	}
 }
 
 // Name returns the backend identifier.
 func (i *Interpreter) Name() string { return "openai" }
 
-// Transcribe sends audio to the OpenAI Transcription API.
+// Transcribe sends audio to the OpenAI Transcription API, or to the
+// Translation API (always whisper-1) when opts.Translate is set. On Azure the
+// transcription deployment serves both, so it must be a Whisper deployment
+// for translation to work.
+//
+// Responses are requested as verbose_json for segment confidences. Models
+// that reject it (some Groq and gateway models) are asked again for plain
+// json, which has no segments or language.
 func (i *Interpreter) Transcribe(ctx context.Context, audio []byte, contentType string, opts interpreter.TranscribeOpts) (*interpreter.TranscribeResult, error) {
+	// Time spent queued for the rate limit doesn't count against the timeout.
+	model, operation := i.transcriptionModel, "audio/transcriptions"
+	if opts.Translate {
+		operation = "audio/translations"
+		switch {
+		case i.groq:
+			model = groqTranslationModel
+		case i.azure == nil:
+			model = translationModel
+		}
+	}
+	if opts.Model != "" {
+		model = opts.Model
+	}
+	if err := i.limits.wait(ctx, model); err != nil {
+		return nil, fmt.Errorf("waiting for rate limit: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, i.transcriptionTimeout)
+	defer cancel()
+
+	format := "verbose_json"
+	if _, ok := i.jsonOnly.Load(model); ok {
+		format = "json"
+	}
+	resp, err := i.transcribe(ctx, operation, model, format, audio, contentType, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusBadRequest && format == "verbose_json" {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		if !bytes.Contains(respBody, []byte("response_format")) {
+			return nil, fmt.Errorf("transcription failed (status %d): %s", resp.StatusCode, respBody)
+		}
+		slog.Info("transcription model rejected verbose_json, using json", "model", model)
+		i.jsonOnly.Store(model, true)
+		if resp, err = i.transcribe(ctx, operation, model, "json", audio, contentType, opts); err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("transcription failed (status %d): %s", resp.StatusCode, respBody)
+	}
+
+	var result struct {
+		Text     string                       `json:"text"`
+		Language string                       `json:"language"`
+		Segments []interpreter.WhisperSegment `json:"segments"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("decoding transcription: %w", err), "transcription", i.transcriptionTimeout)
+	}
+
+	// OpenAI returns full language names ("english"); normalise to ISO-639-1.
+	// json responses, and some compatible APIs, omit it: the requested
+	// language is then the best answer.
+	lang := normalizeLanguage(result.Language)
+	if lang == "" && !opts.Translate {
+		lang = opts.Language
+	}
+
+	segments, confidence := interpreter.WhisperSegments(result.Segments)
+	slog.Debug("transcription complete", "text_length", len(result.Text), "language", lang, "confidence", confidence)
+	return &interpreter.TranscribeResult{
+		Text:          result.Text,
+		Language:      lang,
+		Segments:      segments,
+		AvgConfidence: confidence,
+	}, nil
+}
+
+// transcribe posts audio to operation with model, asking for format. The
+// caller closes the response body.
+func (i *Interpreter) transcribe(ctx context.Context, operation, model, format string, audio []byte, contentType string, opts interpreter.TranscribeOpts) (*http.Response, error) {
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
@@ -62,133 +201,215 @@ func (i *Interpreter) Transcribe(ctx context.Context, audio []byte, contentType
 		return nil, fmt.Errorf("writing audio: %w", err)
 	}
 
-	model := i.transcriptionModel
-	if opts.Model != "" {
-		model = opts.Model
-	}
 	_ = writer.WriteField("model", model)
 
-	if opts.Language != "" {
+	// The translations endpoint detects the source language itself.
+	if opts.Language != "" && !opts.Translate {
 		_ = writer.WriteField("language", opts.Language)
 	}
 	if opts.Prompt != "" {
 		_ = writer.WriteField("prompt", opts.Prompt)
 	}
-	_ = writer.WriteField("response_format", "verbose_json")
+	_ = writer.WriteField("response_format", format)
 	writer.Close()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, transcriptionURL, body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpointURL(operation, model), body)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+i.apiKey)
+	i.setAuth(req)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	resp, err := i.client.Do(req)
+	resp, err := i.retry.Do(i.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("transcription request: %w", err)
+		return nil, interpreter.TimeoutError(ctx, fmt.Errorf("transcription request: %w", err), "transcription", i.transcriptionTimeout)
 	}
-	defer resp.Body.Close()
+	return resp, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-		return nil, fmt.Errorf("transcription failed (status %d): %s", resp.StatusCode, respBody)
+// Interpret sends the transcribed text + instruction to the Chat Completions API
+// and returns structured commands. A reply that does not parse as commands
+// gets one repair request before the call fails.
+func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction, opts interpreter.InterpretOpts) (*interpreter.InterpretResult, error) {
+	model := i.completionModel
+	if instruction.CompletionModel != "" {
+		model = instruction.CompletionModel
+	}
+	temperature := i.temperature
+	if instruction.Temperature != nil {
+		temperature = *instruction.Temperature
+	}
+	tools := i.useTools(model)
+	systemPrompt := buildSystemPrompt(instruction, opts, tools)
+
+	messages := []chatMessage{{Role: "system", Content: systemPrompt}}
+	for _, turn := range opts.History {
+		messages = append(messages,
+			chatMessage{Role: "user", Content: turn.Transcript},
+			chatMessage{Role: "assistant", Content: interpreter.TurnReply(turn)})
 	}
+	messages = append(messages, chatMessage{Role: "user", Content: text})
 
-	var result struct {
-		Text     string `json:"text"`
-		Language string `json:"language"`
+	reqBody := chatRequest{
+		Model:       model,
+		Messages:    messages,
+		Temperature: temperature,
+		Seed:        i.seed,
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decoding transcription: %w", err)
+	if tools {
+		reqBody.Tools = []tool{commandsToolDef(instruction)}
+		reqBody.ToolChoice = forceTool(commandsTool)
+	} else {
+		reqBody.ResponseFormat = &responseFormat{Type: "json_object"}
 	}
 
-	// OpenAI returns full language names ("english"); normalise to ISO-639-1.
-	lang := normalizeLanguage(result.Language)
+	reply, usage, err := i.chat(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	commands, responseText, err := parseCommands(reply.commandsContent())
+	if err != nil {
+		// One repair attempt: show the model its reply and the parse error.
+		slog.Warn("unparseable commands from model, asking for a repair", "model", model, "error", err)
+		reqBody.Messages = append(reqBody.Messages, repairMessages(reply, err)...)
+		var repairUsage message.Usage
+		reply, repairUsage, err = i.chat(ctx, reqBody)
+		if err != nil {
+			return nil, fmt.Errorf("repair request: %w", err)
+		}
+		usage.PromptTokens += repairUsage.PromptTokens
+		usage.CompletionTokens += repairUsage.CompletionTokens
+		usage.TotalTokens += repairUsage.TotalTokens
+		if commands, responseText, err = parseCommands(reply.commandsContent()); err != nil {
+			return nil, fmt.Errorf("parsing commands after repair: %w", err)
+		}
+	}
 
-	slog.Debug("transcription complete", "text_length", len(result.Text), "language", lang)
-	return &interpreter.TranscribeResult{
-		Text:     result.Text,
-		Language: lang,
+	slog.Debug("interpretation complete", "commands", len(commands), "has_response", responseText != "",
+		"total_tokens", usage.TotalTokens)
+	return &interpreter.InterpretResult{
+		Commands:     commands,
+		ResponseText: responseText,
+		Usage:        usage,
 	}, nil
 }
 
-// Interpret sends the transcribed text + instruction to the Chat Completions API
-// and returns structured commands.
-func (i *Interpreter) Interpret(ctx context.Context, text string, instruction message.Instruction) (*interpreter.InterpretResult, error) {
-	systemPrompt := buildSystemPrompt(instruction)
+// repairMessages returns the messages that replay reply and ask the model to
+// fix it. A dispatch_commands call is answered with tool messages, as the API
+// requires for every tool call; a plain reply gets a user message.
+func repairMessages(reply chatMessage, parseErr error) []chatMessage {
+	prompt := interpreter.RepairPrompt(parseErr)
+	if len(reply.ToolCalls) == 0 {
+		return []chatMessage{
+			{Role: "assistant", Content: reply.Content},
+			{Role: "user", Content: prompt},
+		}
+	}
+	msgs := []chatMessage{reply}
+	for _, call := range reply.ToolCalls {
+		msgs = append(msgs, chatMessage{Role: "tool", ToolCallID: call.ID, Content: prompt})
+	}
+	return msgs
+}
 
-	reqBody := chatRequest{
-		Model: i.completionModel,
-		Messages: []chatMessage{
-			{Role: "system", Content: systemPrompt},
-			{Role: "user", Content: text},
-		},
-		ResponseFormat: &responseFormat{Type: "json_object"},
-		Temperature:    0.2,
+// chat makes one Chat Completions call and returns the assistant's reply and
+// token usage.
+func (i *Interpreter) chat(ctx context.Context, reqBody chatRequest) (chatMessage, message.Usage, error) {
+	// Time spent queued for the rate limit doesn't count against the timeout.
+	if err := i.limits.wait(ctx, reqBody.Model); err != nil {
+		return chatMessage{}, message.Usage{}, fmt.Errorf("waiting for rate limit: %w", err)
 	}
+	ctx, cancel := context.WithTimeout(ctx, i.completionTimeout)
+	defer cancel()
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("marshalling chat request: %w", err)
+		return chatMessage{}, message.Usage{}, fmt.Errorf("marshalling chat request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, chatURL, bytes.NewReader(bodyBytes))
+	endpoint := i.endpointURL("chat/completions", reqBody.Model)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
 	if err != nil {
-		return nil, fmt.Errorf("creating chat request: %w", err)
+		return chatMessage{}, message.Usage{}, fmt.Errorf("creating chat request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+i.apiKey)
+	i.setAuth(req)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := i.client.Do(req)
+	resp, err := i.retry.Do(i.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("chat request: %w", err)
+		return chatMessage{}, message.Usage{}, interpreter.TimeoutError(ctx, fmt.Errorf("chat request: %w", err), "completion", i.completionTimeout)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-		return nil, fmt.Errorf("chat failed (status %d): %s", resp.StatusCode, respBody)
+		return chatMessage{}, message.Usage{}, fmt.Errorf("chat failed (status %d): %s", resp.StatusCode, respBody)
 	}
 
 	var chatResp chatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return nil, fmt.Errorf("decoding chat response: %w", err)
+		return chatMessage{}, message.Usage{}, interpreter.TimeoutError(ctx, fmt.Errorf("decoding chat response: %w", err), "completion", i.completionTimeout)
 	}
 
 	if len(chatResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices returned from chat API")
+		return chatMessage{}, chatResp.Usage, fmt.Errorf("no choices returned from chat API")
 	}
 
-	// Parse the JSON response into commands.
-	content := chatResp.Choices[0].Message.Content
-	commands, responseText, err := parseCommands(content)
-	if err != nil {
-		return nil, fmt.Errorf("parsing commands: %w", err)
-	}
+	reply := chatResp.Choices[0].Message
+	reply.Role = "assistant"
+	return reply, chatResp.Usage, nil
+}
 
-	slog.Debug("interpretation complete", "commands", len(commands), "has_response", responseText != "")
-	return &interpreter.InterpretResult{
-		Commands:     commands,
-		ResponseText: responseText,
-	}, nil
+// commandsContent returns the text to parse commands from: the
+// dispatch_commands arguments when the model called the tool, otherwise the
+// reply content.
+func (m chatMessage) commandsContent() string {
+	for _, call := range m.ToolCalls {
+		if call.Function.Name == commandsTool {
+			return call.Function.Arguments
+		}
+	}
+	return m.Content
 }
 
 // Close is a no-op for the OpenAI interpreter.
 func (i *Interpreter) Close() error { return nil }
 
+// HealthCheck verifies that the OpenAI (or Azure, or gateway) API accepts
+// connections.
+func (i *Interpreter) HealthCheck(ctx context.Context) error {
+	if i.azure != nil {
+		return health.DialURL(ctx, i.azure.endpoint)
+	}
+	return health.DialURL(ctx, i.baseURL)
+}
+
 // --- Internal types and helpers ---
 
 type chatRequest struct {
 	Model          string          `json:"model"`
 	Messages       []chatMessage   `json:"messages"`
 	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Tools          []tool          `json:"tools,omitempty"`
+	ToolChoice     *toolChoice     `json:"tool_choice,omitempty"`
 	Temperature    float64         `json:"temperature"`
+	Seed           *int            `json:"seed,omitempty"`
 }
 
 type chatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"` // set on "tool" messages
+}
+
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type responseFormat struct {
@@ -197,45 +418,75 @@ type responseFormat struct {
 
 type chatResponse struct {
 	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
+		Message chatMessage `json:"message"`
 	} `json:"choices"`
+	Usage message.Usage `json:"usage"`
 }
 
-func buildSystemPrompt(instr message.Instruction) string {
+// buildSystemPrompt builds the interpretation prompt. With tools, the output
+// shape comes from the tool schema and is not spelled out.
+func buildSystemPrompt(instr message.Instruction, opts interpreter.InterpretOpts, tools bool) string {
 	var sb strings.Builder
 	sb.WriteString("You are a voice command interpreter for a home automation and robotics system.\n")
-	sb.WriteString("Interpret the user's transcribed speech and return structured commands as JSON.\n\n")
+	if tools {
+		sb.WriteString("Interpret the user's transcribed speech and call " + commandsTool + " with the structured commands.\n\n")
+	} else {
+		sb.WriteString("Interpret the user's transcribed speech and return structured commands as JSON.\n\n")
+	}
 
 	if instr.ResponseFormat != "" {
 		sb.WriteString("Output format: " + instr.ResponseFormat + "\n")
 	}
+	if len(instr.Actions) > 0 {
+		sb.WriteString("Allowed actions: " + strings.Join(instr.Actions, ", ") + "\n")
+	}
 	if instr.Prompt != "" {
 		sb.WriteString("Additional context: " + instr.Prompt + "\n")
 	}
+	if opts.Context != "" {
+		sb.WriteString("Additional context: " + opts.Context + "\n")
+	}
+	fixed := instr.FixedResponseLanguage()
+	if fixed != "" {
+		sb.WriteString("Write the response in the language with ISO-639-1 code \"" + fixed + "\", whatever language the user spoke.\n")
+	}
+	if g := interpreter.ResponseGuidance(instr); g != "" {
+		sb.WriteString(g + "\n")
+	}
 
+	if tools {
+		return sb.String()
+	}
 	sb.WriteString("\nReturn a JSON object with:\n")
 	sb.WriteString("- \"commands\": array of commands, each with \"action\" and \"params\"\n")
-	sb.WriteString("- \"response\": a short confirmation sentence in the SAME language the user spoke\n")
+	switch {
+	case fixed != "":
+		sb.WriteString("- \"response\": a short confirmation sentence in the response language\n")
+	case strings.EqualFold(instr.ResponseLanguage, message.ResponseLanguageAuto):
+		sb.WriteString("- \"response\": a short confirmation sentence, in whichever language suits the user best\n")
+	default:
+		sb.WriteString("- \"response\": a short confirmation sentence in the SAME language the user spoke\n")
+	}
 	sb.WriteString("\nExample: {\"commands\": [{\"action\": \"turn_on\", \"params\": {\"entity\": \"light.living_room\"}}], \"response\": \"Turning on the living room light\"}\n")
 
 	return sb.String()
 }
 
 func parseCommands(content string) ([]message.Command, string, error) {
-	// Try parsing as {"commands": [...], "response": "..."}
+	// Try parsing as {"commands": [...], "response": "..."}. An empty array
+	// is a valid answer: the user asked for nothing to be done.
 	var wrapper struct {
-		Commands []message.Command `json:"commands"`
-		Response string            `json:"response"`
+		Commands *[]message.Command `json:"commands"`
+		Response string             `json:"response"`
 	}
-	if err := json.Unmarshal([]byte(content), &wrapper); err == nil && len(wrapper.Commands) > 0 {
+	if err := json.Unmarshal([]byte(content), &wrapper); err == nil && wrapper.Commands != nil {
+		commands := *wrapper.Commands
 		// Preserve the raw JSON for each command.
-		for idx := range wrapper.Commands {
-			raw, _ := json.Marshal(wrapper.Commands[idx])
-			wrapper.Commands[idx].Raw = raw
+		for idx := range commands {
+			raw, _ := json.Marshal(commands[idx])
+			commands[idx].Raw = raw
 		}
-		return wrapper.Commands, wrapper.Response, nil
+		return commands, wrapper.Response, nil
 	}
 
 	// Try parsing as a single command.
@@ -270,28 +521,15 @@ func extFromContentType(ct string) string {
 
 // normalizeLanguage converts full language names (as returned by OpenAI) to ISO-639-1 codes.
 func normalizeLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "jw" { // Whisper's code for Javanese
+		return "jv"
+	}
 	if len(lang) == 2 {
-		return strings.ToLower(lang)
-	}
-	known := map[string]string{
-		"english":    "en",
-		"french":     "fr",
-		"spanish":    "es",
-		"german":     "de",
-		"italian":    "it",
-		"portuguese": "pt",
-		"dutch":      "nl",
-		"polish":     "pl",
-		"russian":    "ru",
-		"japanese":   "ja",
-		"korean":     "ko",
-		"chinese":    "zh",
-		"arabic":     "ar",
-		"hindi":      "hi",
-		"turkish":    "tr",
-	}
-	if code, ok := known[strings.ToLower(lang)]; ok {
+		return lang
+	}
+	if code, ok := languageCodes[lang]; ok {
 		return code
 	}
-	return strings.ToLower(lang)
+	return lang
 }
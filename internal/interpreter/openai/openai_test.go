@@ -0,0 +1,235 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// chatServer answers successive chat completion requests with replies, in
+// order, and records the requests it received.
+func chatServer(t *testing.T, replies ...chatMessage) (*httptest.Server, *[]chatRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []chatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		n := len(reqs)
+		reqs = append(reqs, req)
+		mu.Unlock()
+		if n >= len(replies) {
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		var resp chatResponse
+		resp.Choices = append(resp.Choices, struct {
+			Message chatMessage `json:"message"`
+		}{replies[n]})
+		resp.Usage = message.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+// toolReply is an assistant reply calling dispatch_commands with args.
+func toolReply(id, args string) chatMessage {
+	call := toolCall{ID: id, Type: "function"}
+	call.Function.Name = commandsTool
+	call.Function.Arguments = args
+	return chatMessage{Role: "assistant", ToolCalls: []toolCall{call}}
+}
+
+func newTestInterpreter(baseURL, toolCalling string) *Interpreter {
+	return New(config.OpenAIConfig{
+		APIKey:          "sk-test",
+		BaseURL:         baseURL,
+		CompletionModel: "gpt-4o",
+		ToolCalling:     toolCalling,
+		MaxAttempts:     1,
+	})
+}
+
+const validReply = `{"commands": [{"action": "turn_on", "params": {"entity": "light.kitchen"}}], "response": "Done."}`
+
+func TestInterpretRepairsJSONReply(t *testing.T) {
+	srv, reqs := chatServer(t,
+		chatMessage{Role: "assistant", Content: "Sure! I'll turn on the kitchen light."},
+		chatMessage{Role: "assistant", Content: validReply},
+	)
+	i := newTestInterpreter(srv.URL, "off")
+
+	res, err := i.Interpret(context.Background(), "turn on the kitchen light", message.Instruction{}, interpreter.InterpretOpts{})
+	if err != nil {
+		t.Fatalf("Interpret: %v", err)
+	}
+	if len(res.Commands) != 1 || res.Commands[0].Action != "turn_on" || res.ResponseText != "Done." {
+		t.Errorf("result = %+v", res)
+	}
+	if res.Usage.TotalTokens != 30 {
+		t.Errorf("usage = %+v, want both calls counted", res.Usage)
+	}
+	if len(*reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(*reqs))
+	}
+	msgs := (*reqs)[1].Messages
+	last, prev := msgs[len(msgs)-1], msgs[len(msgs)-2]
+	if prev.Role != "assistant" || prev.Content != "Sure! I'll turn on the kitchen light." {
+		t.Errorf("repair replays %+v, want the assistant's reply", prev)
+	}
+	if last.Role != "user" || last.Content == "" {
+		t.Errorf("repair prompt = %+v, want a user message", last)
+	}
+}
+
+func TestInterpretRepairsToolCall(t *testing.T) {
+	srv, reqs := chatServer(t,
+		toolReply("call_1", `{"commands": "turn on"}`),
+		toolReply("call_2", validReply),
+	)
+	i := newTestInterpreter(srv.URL, "on")
+
+	res, err := i.Interpret(context.Background(), "turn on the kitchen light", message.Instruction{}, interpreter.InterpretOpts{})
+	if err != nil {
+		t.Fatalf("Interpret: %v", err)
+	}
+	if len(res.Commands) != 1 || res.Commands[0].Action != "turn_on" {
+		t.Errorf("commands = %+v", res.Commands)
+	}
+	if len(*reqs) != 2 {
+		t.Fatalf("got %d requests, want 2", len(*reqs))
+	}
+	msgs := (*reqs)[1].Messages
+	last, prev := msgs[len(msgs)-1], msgs[len(msgs)-2]
+	if prev.Role != "assistant" || len(prev.ToolCalls) != 1 || prev.ToolCalls[0].ID != "call_1" {
+		t.Errorf("repair replays %+v, want the assistant's tool call", prev)
+	}
+	if last.Role != "tool" || last.ToolCallID != "call_1" || last.Content == "" {
+		t.Errorf("repair prompt = %+v, want a tool message answering call_1", last)
+	}
+}
+
+func TestInterpretEmptyCommandsNeedNoRepair(t *testing.T) {
+	srv, reqs := chatServer(t,
+		chatMessage{Role: "assistant", Content: `{"commands": [], "response": "Nothing to do."}`},
+	)
+	i := newTestInterpreter(srv.URL, "off")
+
+	res, err := i.Interpret(context.Background(), "thanks", message.Instruction{}, interpreter.InterpretOpts{})
+	if err != nil {
+		t.Fatalf("Interpret: %v", err)
+	}
+	if len(res.Commands) != 0 || res.ResponseText != "Nothing to do." {
+		t.Errorf("result = %+v", res)
+	}
+	if len(*reqs) != 1 {
+		t.Errorf("got %d requests, want no repair", len(*reqs))
+	}
+}
+
+func TestInterpretFailsAfterRepair(t *testing.T) {
+	srv, _ := chatServer(t,
+		chatMessage{Role: "assistant", Content: "no"},
+		chatMessage{Role: "assistant", Content: "still no"},
+	)
+	i := newTestInterpreter(srv.URL, "off")
+	if _, err := i.Interpret(context.Background(), "hi", message.Instruction{}, interpreter.InterpretOpts{}); err == nil {
+		t.Fatal("Interpret succeeded on two unparseable replies")
+	}
+}
+
+func TestParseCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		commands int
+		response string
+		wantErr  bool
+	}{
+		{"wrapper", validReply, 1, "Done.", false},
+		{"empty commands", `{"commands": [], "response": "Okay."}`, 0, "Okay.", false},
+		{"single command", `{"action": "stop"}`, 1, "", false},
+		{"prose", "I can't do that", 0, "", true},
+		{"no commands key", `{"response": "Okay."}`, 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commands, response, err := parseCommands(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(commands) != tt.commands || response != tt.response {
+				t.Errorf("got %d commands and %q, want %d and %q", len(commands), response, tt.commands, tt.response)
+			}
+		})
+	}
+}
+
+func TestSystemPromptResponseLanguage(t *testing.T) {
+	for _, tools := range []bool{false, true} {
+		prompt := buildSystemPrompt(message.Instruction{ResponseLanguage: "de"}, interpreter.InterpretOpts{Language: "fr"}, tools)
+		if !strings.Contains(prompt, `ISO-639-1 code "de"`) {
+			t.Errorf("tools=%v: prompt does not force German:\n%s", tools, prompt)
+		}
+	}
+	def := commandsToolDef(message.Instruction{ResponseLanguage: "de"})
+	props := def.Function.Parameters["properties"].(map[string]any)
+	if desc := props["response"].(map[string]any)["description"].(string); !strings.Contains(desc, "code de") {
+		t.Errorf("tool response description = %q, want it to name de", desc)
+	}
+}
+
+func TestSystemPromptResponseConstraints(t *testing.T) {
+	instr := message.Instruction{ResponseMaxWords: 12, ResponseStyle: "terse"}
+	for _, tools := range []bool{false, true} {
+		prompt := buildSystemPrompt(instr, interpreter.InterpretOpts{}, tools)
+		if !strings.Contains(prompt, interpreter.ResponseGuidance(instr)) {
+			t.Errorf("tools=%v: prompt lacks the response constraints:\n%s", tools, prompt)
+		}
+	}
+}
+
+func TestSystemPromptResponseLanguageModes(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"", "SAME language the user spoke"},
+		{message.ResponseLanguageMatch, "SAME language the user spoke"},
+		{"Match", "SAME language the user spoke"},
+		{message.ResponseLanguageAuto, "whichever language suits the user best"},
+	}
+	for _, tt := range tests {
+		instr := message.Instruction{ResponseLanguage: tt.language}
+		prompt := buildSystemPrompt(instr, interpreter.InterpretOpts{Language: "fr"}, false)
+		if !strings.Contains(prompt, tt.want) {
+			t.Errorf("response_language %q: prompt lacks %q:\n%s", tt.language, tt.want, prompt)
+		}
+		if strings.Contains(prompt, "ISO-639-1 code") {
+			t.Errorf("response_language %q: prompt forces a language:\n%s", tt.language, prompt)
+		}
+	}
+
+	def := commandsToolDef(message.Instruction{ResponseLanguage: message.ResponseLanguageAuto})
+	props := def.Function.Parameters["properties"].(map[string]any)
+	if desc := props["response"].(map[string]any)["description"].(string); !strings.Contains(desc, "whichever language") {
+		t.Errorf("tool response description = %q, want it to leave the language to the model", desc)
+	}
+}
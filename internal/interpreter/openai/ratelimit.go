@@ -0,0 +1,86 @@
+package openai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that paces requests to a fixed number per
+// minute. Callers queue in Wait instead of being rejected, so bursts are
+// spread out rather than hitting the provider's 429s.
+type rateLimiter struct {
+	interval time.Duration // time to refill one token
+	burst    float64
+
+	mu     sync.Mutex
+	tokens float64 // may go negative: each queued caller holds a reservation
+	last   time.Time
+}
+
+// newRateLimiter allows rpm requests per minute, with bursts of up to one
+// second's worth of requests (at least one).
+func newRateLimiter(rpm int) *rateLimiter {
+	burst := max(float64(rpm)/60, 1)
+	return &rateLimiter{
+		interval: time.Minute / time.Duration(rpm),
+		burst:    burst,
+		tokens:   burst,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a request may be sent or ctx is done. On cancellation the
+// reservation is returned to the bucket.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+float64(now.Sub(l.last))/float64(l.interval))
+	l.last = now
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens * float64(l.interval))
+	}
+	l.mu.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// limiters holds one rateLimiter per model.
+type limiters map[string]*rateLimiter
+
+// newLimiters builds limiters from a model -> requests-per-minute map,
+// skipping non-positive limits.
+func newLimiters(rpm map[string]int) limiters {
+	ls := make(limiters, len(rpm))
+	for model, n := range rpm {
+		if n > 0 {
+			ls[model] = newRateLimiter(n)
+		}
+	}
+	return ls
+}
+
+// wait paces a request to model; models without a limit pass straight through.
+func (ls limiters) wait(ctx context.Context, model string) error {
+	l, ok := ls[model]
+	if !ok {
+		return nil
+	}
+	return l.Wait(ctx)
+}
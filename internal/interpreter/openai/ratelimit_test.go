@@ -0,0 +1,66 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterThrottlesAfterBurst(t *testing.T) {
+	// 1200 rpm: a burst of 20, then one request every 50ms.
+	l := newRateLimiter(1200)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 20 {
+		if err := l.Wait(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Fatalf("burst took %s, want no waiting", elapsed)
+	}
+
+	start = time.Now()
+	if err := l.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("request after the burst waited %s, want about 50ms", elapsed)
+	}
+}
+
+func TestRateLimiterCancelReturnsReservation(t *testing.T) {
+	// 60 rpm: a burst of 1, then one request per second.
+	l := newRateLimiter(60)
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() = %v, want context.DeadlineExceeded", err)
+	}
+
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < -0.5 {
+		t.Errorf("tokens = %.2f after cancellation, want the reservation returned", tokens)
+	}
+}
+
+func TestLimitersSkipUnlimitedModels(t *testing.T) {
+	ls := newLimiters(map[string]int{"gpt-4o": 60, "whisper-1": 0})
+	if _, ok := ls["whisper-1"]; ok {
+		t.Error("non-positive limit should not create a limiter")
+	}
+	ctx := context.Background()
+	for range 5 {
+		if err := ls.wait(ctx, "other-model"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
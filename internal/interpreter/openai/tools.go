@@ -0,0 +1,136 @@
+package openai
+
+import (
+	"strings"
+
+	"github.com/nadzzz/switchyard/internal/interpreter"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// commandsTool is the name of the function the model is made to call.
+const commandsTool = "dispatch_commands"
+
+// toolsUnsupported lists model prefixes, among those matched by
+// toolModelPrefixes, that reject the tools parameter.
+var toolsUnsupported = []string{"o1-mini", "o1-preview"}
+
+// toolModelPrefixes lists the chat model families that accept tools,
+// including the Groq-hosted ones.
+var toolModelPrefixes = []string{
+	"gpt-3.5-turbo", "gpt-4", "gpt-5", "o1", "o3", "o4",
+	"llama-3.3-70b-versatile", "llama-3.1-8b-instant", "openai/gpt-oss",
+}
+
+// supportsTools reports whether model accepts function tools. Unknown models
+// are assumed not to, so they keep the json_object path.
+func supportsTools(model string) bool {
+	model = strings.TrimPrefix(model, "ft:") // fine-tuned models keep their base's abilities
+	for _, p := range toolsUnsupported {
+		if strings.HasPrefix(model, p) {
+			return false
+		}
+	}
+	for _, p := range toolModelPrefixes {
+		if strings.HasPrefix(model, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// useTools decides between tool calling and json_object output for model
+// according to the tool_calling setting.
+func (i *Interpreter) useTools(model string) bool {
+	switch i.toolCalling {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	return supportsTools(model)
+}
+
+type tool struct {
+	Type     string       `json:"type"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type toolChoice struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+// commandsToolDef builds the dispatch_commands tool for instr. Its arguments
+// decode directly into the {"commands": [...], "response": "..."} shape that
+// parseCommands reads. instr.Actions becomes an enum on the action field.
+func commandsToolDef(instr message.Instruction) tool {
+	action := map[string]any{
+		"type":        "string",
+		"description": "Command verb, e.g. turn_on, move_to, set_temperature",
+	}
+	if len(instr.Actions) > 0 {
+		action["enum"] = instr.Actions
+	}
+
+	response := "Short confirmation sentence in the same language the user spoke"
+	if fixed := instr.FixedResponseLanguage(); fixed != "" {
+		response = "Short confirmation sentence in the language with ISO-639-1 code " + fixed
+	} else if strings.EqualFold(instr.ResponseLanguage, message.ResponseLanguageAuto) {
+		response = "Short confirmation sentence, in whichever language suits the user best"
+	}
+	if g := interpreter.ResponseGuidance(instr); g != "" {
+		response += ". " + g
+	}
+
+	desc := "Send the commands interpreted from the user's speech, with a short spoken confirmation."
+	if instr.ResponseFormat != "" {
+		desc += " Commands must suit the " + instr.ResponseFormat + " format."
+	}
+
+	return tool{
+		Type: "function",
+		Function: toolFunction{
+			Name:        commandsTool,
+			Description: desc,
+			Parameters: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"commands": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"action": action,
+								"params": map[string]any{
+									"type":        "object",
+									"description": "Action-specific parameters",
+								},
+							},
+							"required": []string{"action"},
+						},
+					},
+					"response": map[string]any{
+						"type":        "string",
+						"description": response,
+					},
+				},
+				"required": []string{"commands", "response"},
+			},
+		},
+	}
+}
+
+// forceTool returns a tool_choice that makes the model call name.
+func forceTool(name string) *toolChoice {
+	c := &toolChoice{Type: "function"}
+	c.Function.Name = name
+	return c
+}
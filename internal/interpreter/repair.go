@@ -0,0 +1,12 @@
+package interpreter
+
+import "fmt"
+
+// RepairPrompt is the follow-up message sent once when a model's reply could
+// not be parsed as commands. It quotes the parse error and restates the
+// expected shape; the bad reply itself is replayed as the assistant turn.
+func RepairPrompt(parseErr error) string {
+	return fmt.Sprintf("Your previous reply could not be parsed (%v). "+
+		"Reply again with only a valid JSON object, no prose or code fences, of the form "+
+		`{"commands": [{"action": "...", "params": {...}}], "response": "..."}.`, parseErr)
+}
@@ -0,0 +1,39 @@
+package interpreter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// responseStyles describes the named response styles to the model. Other
+// styles are passed on as given.
+var responseStyles = map[string]string{
+	"terse":    "Be terse: state what was done in as few words as possible, with no pleasantries.",
+	"friendly": "Be warm and friendly, but still brief.",
+	"formal":   "Be polite and formal.",
+}
+
+// ResponseGuidance returns prompt text constraining the length and style of
+// the confirmation as set in instr, or "" if it sets none. Backends add it
+// to their system prompt.
+func ResponseGuidance(instr message.Instruction) string {
+	var parts []string
+	switch words, chars := instr.ResponseMaxWords, instr.ResponseMaxChars; {
+	case words > 0 && chars > 0:
+		parts = append(parts, fmt.Sprintf("Keep the response under %d words and %d characters; it is spoken aloud.", words, chars))
+	case words > 0:
+		parts = append(parts, fmt.Sprintf("Keep the response under %d words; it is spoken aloud.", words))
+	case chars > 0:
+		parts = append(parts, fmt.Sprintf("Keep the response under %d characters; it is spoken aloud.", chars))
+	}
+	if style := strings.TrimSpace(instr.ResponseStyle); style != "" {
+		if desc, ok := responseStyles[strings.ToLower(style)]; ok {
+			parts = append(parts, desc)
+		} else {
+			parts = append(parts, "Response style: "+style+".")
+		}
+	}
+	return strings.Join(parts, " ")
+}
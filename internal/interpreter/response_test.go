@@ -0,0 +1,36 @@
+package interpreter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+func TestResponseGuidance(t *testing.T) {
+	tests := []struct {
+		name  string
+		instr message.Instruction
+		want  []string
+	}{
+		{"none", message.Instruction{}, nil},
+		{"words", message.Instruction{ResponseMaxWords: 12}, []string{"under 12 words"}},
+		{"chars", message.Instruction{ResponseMaxChars: 80}, []string{"under 80 characters"}},
+		{"both", message.Instruction{ResponseMaxWords: 12, ResponseMaxChars: 80}, []string{"12 words and 80 characters"}},
+		{"named style", message.Instruction{ResponseStyle: "Terse"}, []string{"Be terse"}},
+		{"free-form style", message.Instruction{ResponseStyle: "pirate"}, []string{"Response style: pirate."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ResponseGuidance(tt.instr)
+			if len(tt.want) == 0 && got != "" {
+				t.Errorf("guidance = %q, want none", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("guidance %q does not contain %q", got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries HTTP calls that fail transiently: 429 and 5xx
+// responses, and connection errors while dialing.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts (1 disables retries).
+	MaxAttempts int
+
+	// BaseDelay is the backoff before the first retry; it doubles each attempt.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the backoff between attempts.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy returns a policy with the given attempt count and
+// sensible delays.
+func DefaultRetryPolicy(maxAttempts int) RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+	}
+}
+
+// Do sends req with client, retrying transient failures with exponential
+// backoff and jitter. A Retry-After header on 429/503 responses takes
+// precedence over the computed backoff. Waiting between attempts stops as soon
+// as the request context is done.
+//
+// The request body must be replayable via req.GetBody, which
+// http.NewRequestWithContext sets for bytes.Buffer and bytes.Reader bodies.
+// When all attempts fail with a retryable status, the last response is
+// returned for the caller to handle like any other non-2xx response.
+func (p RetryPolicy) Do(client *http.Client, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	attempts := max(p.MaxAttempts, 1)
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("rewinding request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := client.Do(req)
+		last := attempt >= attempts
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if last || !isDialError(err) || ctx.Err() != nil {
+				return nil, err
+			}
+			wait = p.backoff(attempt)
+			slog.Debug("retrying after connection error", "url", req.URL.Redacted(), "attempt", attempt, "error", err)
+
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			if last {
+				return resp, nil
+			}
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+			if wait <= 0 {
+				wait = p.backoff(attempt)
+			}
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+			slog.Debug("retrying after transient status", "url", req.URL.Redacted(), "attempt", attempt, "status", resp.StatusCode, "wait", wait)
+
+		default:
+			return resp, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry following attempt, with jitter
+// in [d/2, d).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = 500 * time.Millisecond
+	}
+	d := base << (attempt - 1)
+	if p.MaxDelay > 0 && (d > p.MaxDelay || d <= 0) {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int64N(int64(half)+1))
+}
+
+// retryAfter parses a Retry-After header (seconds or HTTP date).
+func retryAfter(h string) time.Duration {
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// isDialError reports whether err happened while establishing a connection,
+// e.g. connection refused during a model server restart.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && !errors.Is(err, context.Canceled)
+}
@@ -2,7 +2,10 @@
 package message
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -14,15 +17,30 @@ type Message struct {
 	// Source identifies the sender (e.g., "robot-arm-01", "phone-alice").
 	Source string `json:"source"`
 
+	// TraceID correlates the message with the caller's logs. Transports set
+	// it from an inbound request ID (X-Request-ID or W3C traceparent), and
+	// every log line of the dispatch carries it.
+	TraceID string `json:"trace_id,omitempty"`
+
 	// Audio is the raw audio payload. Nil if the message is text-only.
 	Audio []byte `json:"audio,omitempty"`
 
+	// AudioURL points to the audio when it is not sent inline (e.g., a
+	// pre-signed object storage link). Switchyard downloads it before
+	// transcription. Ignored if Audio is set.
+	AudioURL string `json:"audio_url,omitempty"`
+
 	// ContentType is the MIME type of the audio (e.g., "audio/wav", "audio/ogg").
 	ContentType string `json:"content_type,omitempty"`
 
 	// Text is an optional pre-transcribed text input (bypasses transcription).
 	Text string `json:"text,omitempty"`
 
+	// SessionID groups messages into a conversation. When set (and sessions
+	// are enabled), the session's recent turns are given to the interpreter
+	// so follow-ups like "make it dimmer" can refer back to them.
+	SessionID string `json:"session_id,omitempty"`
+
 	// Instruction tells switchyard how to interpret and route the response.
 	Instruction Instruction `json:"instruction"`
 
@@ -35,6 +53,16 @@ func (m *Message) HasAudio() bool {
 	return len(m.Audio) > 0
 }
 
+// AudioSHA256 returns the hex SHA-256 of the audio, or "" if there is none,
+// for matching a recording against logs without keeping it.
+func (m *Message) AudioSHA256() string {
+	if !m.HasAudio() {
+		return ""
+	}
+	sum := sha256.Sum256(m.Audio)
+	return hex.EncodeToString(sum[:])
+}
+
 // Instruction describes how to process and route a message.
 type Instruction struct {
 	// Targets lists the services that should receive the interpreted commands.
@@ -44,8 +72,107 @@ type Instruction struct {
 	// ResponseFormat specifies the desired output format (e.g., "homeassistant", "json", "ros2").
 	ResponseFormat string `json:"response_format"`
 
+	// Actions, if set, restricts commands to these action names. Backends
+	// that support tool calling enforce it in the schema; others are told
+	// in the prompt.
+	Actions []string `json:"actions,omitempty"`
+
 	// Prompt is additional context for the LLM interpreter (e.g., "return motor commands").
 	Prompt string `json:"prompt,omitempty"`
+
+	// Interpreter selects one of the server's named interpreters
+	// (interpreter.named) for this message. Empty uses the default one.
+	Interpreter string `json:"interpreter,omitempty"`
+
+	// CompletionModel overrides the backend's configured interpretation
+	// model for this message (e.g., to compare prompts across models).
+	CompletionModel string `json:"completion_model,omitempty"`
+
+	// Temperature overrides the sampling temperature used for
+	// interpretation (default 0.2).
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// Language is an optional ISO-639-1 hint (e.g., "fr"). It guides transcription
+	// and selects the language-specific prompt context.
+	Language string `json:"language,omitempty"`
+
+	// ResponseLanguage sets the language of the confirmation text: an
+	// ISO-639-1 code forces it and the TTS voice, e.g. when detection
+	// mislabels a short utterance; "match" (the default) answers in the
+	// language the user spoke; "auto" lets the model choose. Transcription
+	// and DispatchResult.Language are unaffected.
+	ResponseLanguage string `json:"response_language,omitempty"`
+
+	// ResponseMaxWords and ResponseMaxChars ask the interpreter to keep the
+	// confirmation text within these limits (0 = no limit), so it stays
+	// short enough to speak.
+	ResponseMaxWords int `json:"response_max_words,omitempty"`
+	ResponseMaxChars int `json:"response_max_chars,omitempty"`
+
+	// ResponseStyle is a style hint for the confirmation text, such as
+	// "terse" or "friendly".
+	ResponseStyle string `json:"response_style,omitempty"`
+
+	// Translate transcribes non-English speech directly into English, so
+	// prompts and targets only ever see English text. The result's Language
+	// still reports the spoken language.
+	Translate bool `json:"translate,omitempty"`
+
+	// SkipInterpretation returns the transcript without interpreting it:
+	// no commands, response text or response audio are produced and
+	// nothing is routed (e.g., for captioning).
+	SkipInterpretation bool `json:"skip_interpretation,omitempty"`
+
+	// DryRun runs transcription and interpretation but sends nothing to the
+	// targets. RoutedTo then lists the targets that would have received the
+	// commands, so prompts can be tried safely against a production config.
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// Sequential sends the commands to each target one at a time, in order,
+	// each in a payload of its own. A command is only sent once the previous
+	// one was accepted; the first failure ends the sequence for that target
+	// and is reported in DispatchResult.FailedCommands. By default all
+	// commands go to a target bundled in one payload, with no ordering
+	// contract.
+	Sequential bool `json:"sequential,omitempty"`
+
+	// ResponseMode is "audio" to require a spoken response or "text" to skip
+	// it. Empty speaks the response when TTS is enabled. Requiring audio
+	// from a server without TTS fails the dispatch at the "synthesis" stage
+	// with ErrorCodeTTSDisabled, and a failed synthesis with
+	// ErrorCodeBackend; commands are still routed. A failed dispatch in
+	// "audio" mode carries its error as ResponseText when it has no other.
+	ResponseMode string `json:"response_mode,omitempty"`
+
+	// AudioFormat selects the encoding of the spoken response:
+	// "wav" (default), "mp3" or "opus".
+	AudioFormat string `json:"audio_format,omitempty"`
+
+	// SampleRate asks for response audio at this rate in Hz (8000-48000),
+	// e.g. 16000 for devices that cannot play the voice's native rate.
+	// WAV output is resampled; 0 keeps the native rate.
+	SampleRate int `json:"sample_rate,omitempty"`
+
+	// Speech adjusts how the spoken response sounds (e.g., slower speech
+	// for accessibility). Backends apply what they support.
+	Speech *Prosody `json:"speech,omitempty"`
+
+	// CallbackURL requests asynchronous dispatch: the HTTP transport accepts
+	// the message immediately and POSTs the DispatchResult here when done.
+	CallbackURL string `json:"callback_url,omitempty"`
+}
+
+// Prosody holds speech adjustments as multipliers of the voice's defaults.
+// 1.0 (or omitted) leaves a property unchanged.
+type Prosody struct {
+	// Rate is the speaking speed (e.g., 0.8 for slower speech).
+	Rate float64 `json:"rate,omitempty"`
+
+	// Pitch is the voice pitch.
+	Pitch float64 `json:"pitch,omitempty"`
+
+	// Volume is the loudness (e.g., 1.5 for louder output).
+	Volume float64 `json:"volume,omitempty"`
 }
 
 // Target defines a downstream service that should receive commands.
@@ -56,11 +183,59 @@ type Target struct {
 	// Endpoint is the address to reach this target (e.g., "http://ha.local:8123/api/services").
 	Endpoint string `json:"endpoint"`
 
-	// Protocol is the protocol to use ("http", "grpc", "mqtt").
+	// Protocol is the protocol to use ("http", "grpc", "mqtt", "ws").
 	Protocol string `json:"protocol"`
 
 	// FormatTemplate is an optional Go template to transform commands before sending.
 	FormatTemplate string `json:"format_template,omitempty"`
+
+	// GRPCMode selects how gRPC targets are called: "native" (default) uses
+	// SwitchyardTarget/Receive, "bytes" and "metadata" call GRPCMethod instead.
+	GRPCMode string `json:"grpc_mode,omitempty"`
+
+	// GRPCMethod is the full method name (e.g., "/robot.v1.Robot/Push") for
+	// the "bytes" and "metadata" gRPC modes.
+	GRPCMethod string `json:"grpc_method,omitempty"`
+
+	// TLS dials gRPC targets over TLS, verified against the system roots or
+	// transports.grpc.client_tls.ca_file, instead of plaintext.
+	TLS bool `json:"tls,omitempty"`
+
+	// Token authenticates switchyard to the target. It comes only from the
+	// server's configured targets and is never read from or written to JSON.
+	Token string `json:"-"`
+
+	// AuthHeader is the header that carries Token (default "Authorization").
+	AuthHeader string `json:"-"`
+
+	// AuthScheme prefixes Token in AuthHeader (default "Bearer" for the
+	// Authorization header, none for custom headers).
+	AuthScheme string `json:"-"`
+
+	// Headers are extra request headers for HTTP targets, and handshake
+	// headers for WebSocket targets (e.g., an API version or tenant ID). Like Token, they come only from the server's
+	// configured targets.
+	Headers map[string]string `json:"-"`
+
+	// AllowedActions, if set, limits the commands sent to this target. It
+	// comes only from the server's configured targets.
+	AllowedActions []string `json:"-"`
+}
+
+// Authorization returns the header name and value carrying the target's
+// Token.
+func (t Target) Authorization() (header, value string) {
+	header, scheme := t.AuthHeader, t.AuthScheme
+	if header == "" {
+		header = "Authorization"
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+	}
+	if scheme == "" {
+		return header, t.Token
+	}
+	return header, scheme + " " + t.Token
 }
 
 // Command is a single structured command produced by the interpreter.
@@ -75,6 +250,72 @@ type Command struct {
 	Raw json.RawMessage `json:"raw,omitempty"`
 }
 
+// RejectedCommand is a command the server refused to route.
+type RejectedCommand struct {
+	// Command is the command as interpreted.
+	Command Command `json:"command"`
+
+	// Target is the target that refused it; empty if it went to no target.
+	Target string `json:"target,omitempty"`
+
+	// Reason explains the rejection (e.g., "action \"launch\" is denied").
+	Reason string `json:"reason"`
+}
+
+// DebugEcho is a failed message as it was received and as the server
+// resolved its instruction, so the failure can be reproduced. Audio is
+// never echoed; its size and SHA-256 are.
+type DebugEcho struct {
+	MessageID   string `json:"message_id"`
+	Source      string `json:"source,omitempty"`
+	TraceID     string `json:"trace_id,omitempty"`
+	SessionID   string `json:"session_id,omitempty"`
+	Text        string `json:"text,omitempty"`
+	AudioURL    string `json:"audio_url,omitempty"`
+	AudioBytes  int    `json:"audio_bytes,omitempty"`
+	AudioSHA256 string `json:"audio_sha256,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+
+	// Instruction is the instruction as received.
+	Instruction Instruction `json:"instruction"`
+
+	// ResolvedInstruction is the instruction after routes and server
+	// defaults were applied. Target credentials are never included.
+	ResolvedInstruction Instruction `json:"resolved_instruction"`
+}
+
+// SkippedTarget is a target the commands could not be routed to.
+type SkippedTarget struct {
+	// Target is the target's service name.
+	Target string `json:"target"`
+
+	// Protocol is the target's protocol.
+	Protocol string `json:"protocol"`
+
+	// Reason explains the skip (e.g., "no transport for protocol: grpc").
+	Reason string `json:"reason"`
+}
+
+// FailedCommand describes the command that ended a sequential send to a
+// target (see Instruction.Sequential).
+type FailedCommand struct {
+	// Target is the target's service name.
+	Target string `json:"target"`
+
+	// Index is the command's position in the sequence sent to the target,
+	// from 0. The commands before it were delivered.
+	Index int `json:"index"`
+
+	// Command is the command that failed.
+	Command Command `json:"command"`
+
+	// Error is the send error.
+	Error string `json:"error"`
+
+	// Unsent is the number of commands after it that were not sent.
+	Unsent int `json:"unsent"`
+}
+
 // DispatchResult is the outcome of processing a message through the pipeline.
 type DispatchResult struct {
 	// MessageID is the original message ID.
@@ -86,12 +327,37 @@ type DispatchResult struct {
 	// Language is the ISO-639-1 code detected during transcription (e.g., "en", "fr", "es").
 	Language string `json:"language,omitempty"`
 
+	// Segments are the timed pieces of Transcript, for backends that report them.
+	Segments []Segment `json:"segments,omitempty"`
+
+	// Confidence is the transcription backend's confidence in Transcript,
+	// from 0 to 1 (omitted if the backend does not report one).
+	Confidence float64 `json:"confidence,omitempty"`
+
 	// Commands is the list of interpreted commands.
 	Commands []Command `json:"commands"`
 
-	// RoutedTo lists the targets that received the commands.
+	// Rejected lists commands dropped by the server's action filters instead
+	// of being routed.
+	Rejected []RejectedCommand `json:"rejected,omitempty"`
+
+	// RoutedTo lists the targets that received the commands, or that would
+	// have received them when DryRun is set.
 	RoutedTo []string `json:"routed_to"`
 
+	// Skipped lists targets that were not sent the commands because no
+	// enabled transport or registered sender handles their protocol. A
+	// skipped target does not fail the dispatch.
+	Skipped []SkippedTarget `json:"skipped,omitempty"`
+
+	// FailedCommands lists, per target, the command that ended a sequential
+	// send.
+	FailedCommands []FailedCommand `json:"failed_commands,omitempty"`
+
+	// DryRun reports that the instruction requested a dry run and nothing
+	// was sent to the targets.
+	DryRun bool `json:"dry_run,omitempty"`
+
 	// ResponseText is a natural-language confirmation (in the detected language).
 	ResponseText string `json:"response_text,omitempty"`
 
@@ -101,6 +367,113 @@ type DispatchResult struct {
 	// ResponseContentType is the MIME type of ResponseAudio (e.g., "audio/wav").
 	ResponseContentType string `json:"response_content_type,omitempty"`
 
+	// ResponseAudioDurationMs is the playback duration of ResponseAudio in milliseconds.
+	ResponseAudioDurationMs int64 `json:"response_audio_duration_ms,omitempty"`
+
+	// ResponseAudioBytes is the size of ResponseAudio in bytes.
+	ResponseAudioBytes int `json:"response_audio_bytes,omitempty"`
+
+	// ResponseAudioURL is where to fetch the response audio when TTS delivery
+	// is "url". ResponseAudio is omitted in that case.
+	ResponseAudioURL string `json:"response_audio_url,omitempty"`
+
+	// Usage reports the LLM tokens consumed by interpretation.
+	// Zero-valued for backends that do not report usage.
+	Usage Usage `json:"usage"`
+
+	// Debug echoes the message that failed, when the server runs with
+	// server.debug_echo.
+	Debug *DebugEcho `json:"debug,omitempty"`
+
 	// Error is set if processing failed at any stage.
 	Error string `json:"error,omitempty"`
+
+	// ErrorStage is the pipeline stage that failed (one of the ErrorStage
+	// constants), so clients can decide what to retry.
+	ErrorStage string `json:"error_stage,omitempty"`
+
+	// ErrorCode is a machine-readable reason (one of the ErrorCode constants).
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// Values of Instruction.ResponseLanguage other than ISO-639-1 codes.
+const (
+	ResponseLanguageMatch = "match" // Answer in the language the user spoke
+	ResponseLanguageAuto  = "auto"  // Let the model choose
+)
+
+// FixedResponseLanguage returns the language code ResponseLanguage forces,
+// or "" if it is unset, "match" or "auto".
+func (i Instruction) FixedResponseLanguage() string {
+	switch strings.ToLower(i.ResponseLanguage) {
+	case "", ResponseLanguageMatch, ResponseLanguageAuto:
+		return ""
+	}
+	return i.ResponseLanguage
+}
+
+// Values of Instruction.ResponseMode.
+const (
+	ResponseModeAudio = "audio"
+	ResponseModeText  = "text"
+)
+
+// Pipeline stages reported in DispatchResult.ErrorStage.
+const (
+	ErrorStageInput          = "input"          // The message had no usable audio or text
+	ErrorStageTranscription  = "transcription"  // Speech-to-text failed
+	ErrorStageInterpretation = "interpretation" // The LLM call or its response failed
+	ErrorStageTransform      = "transform"      // A command transformer failed
+	ErrorStageSynthesis      = "synthesis"      // Spoken response audio was required but not produced
+	ErrorStageRouting        = "routing"        // Commands were interpreted but not all targets received them
+)
+
+// Reasons reported in DispatchResult.ErrorCode.
+const (
+	ErrorCodeNoInput         = "no_input"            // Neither audio, audio_url nor text was given
+	ErrorCodeNoInterpreter   = "unknown_interpreter" // Instruction.Interpreter names no configured interpreter
+	ErrorCodeAudioFetch      = "audio_fetch_failed"  // Audio passed by URL could not be downloaded
+	ErrorCodeAudioTooLarge   = "audio_too_large"     // Audio exceeded the configured size or duration limit
+	ErrorCodeInvalidAudio    = "invalid_audio"       // Audio declared or detected as WAV is malformed
+	ErrorCodeTimeout         = "timeout"             // The backend call exceeded its timeout
+	ErrorCodeBackend         = "backend_error"       // The backend call failed
+	ErrorCodeLowConfidence   = "low_confidence"      // The transcript's confidence was below the configured minimum
+	ErrorCodeNoSpeech        = "no_speech"           // The audio was silent or transcribed to nothing
+	ErrorCodeSendFailed      = "send_failed"         // A transport failed to deliver to a target
+	ErrorCodeEncodingFailed  = "encoding_failed"     // The result could not be encoded for targets
+	ErrorCodeTransformFailed = "transform_failed"    // A command transformer returned an error
+	ErrorCodeTTSDisabled     = "tts_disabled"        // Audio was required but TTS is disabled
+)
+
+// Fail records a failure. The first failure keeps its stage and code; later
+// ones, such as one per missed target, append their text so Error lists
+// them all.
+func (r *DispatchResult) Fail(stage, code, text string) {
+	if r.Error != "" {
+		r.Error += "; " + text
+		return
+	}
+	r.Error, r.ErrorStage, r.ErrorCode = text, stage, code
+}
+
+// Turn is one completed exchange of a conversation session.
+type Turn struct {
+	Transcript   string    `json:"transcript"`
+	Commands     []Command `json:"commands"`
+	ResponseText string    `json:"response_text,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// Segment is a timed span of a transcript.
+type Segment struct {
+	Start float64 `json:"start"` // Offset from the start of the audio, in seconds
+	End   float64 `json:"end"`   // Offset from the start of the audio, in seconds
+	Text  string  `json:"text"`
+}
+
+// Usage counts the tokens consumed by an LLM call.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
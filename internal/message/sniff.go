@@ -0,0 +1,53 @@
+package message
+
+import (
+	"bytes"
+	"mime"
+)
+
+// SniffAudioType detects the audio container from the leading bytes of data
+// and returns its MIME type, or "" if the format is not recognized.
+// Recognized: WAV (RIFF/WAVE), Ogg, MP3 (ID3 tag or MPEG frame sync), FLAC
+// and WebM (EBML).
+func SniffAudioType(data []byte) string {
+	switch {
+	case len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE")):
+		return "audio/wav"
+	case bytes.HasPrefix(data, []byte("OggS")):
+		return "audio/ogg"
+	case bytes.HasPrefix(data, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(data, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return "audio/webm"
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return "audio/mpeg"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0 && data[1]&0x06 != 0:
+		// MPEG audio frame sync with a non-zero layer (layer 0 is AAC ADTS).
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// IsGenericContentType reports whether ct says nothing about the audio
+// format: empty, application/octet-stream, or unparseable.
+func IsGenericContentType(ct string) bool {
+	if ct == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(ct)
+	return err != nil || mediaType == "application/octet-stream"
+}
+
+// IsWAVContentType reports whether ct names a WAV file (audio/wav or one of
+// its aliases).
+func IsWAVContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package message
+
+import "testing"
+
+func TestSniffAudioType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"wav", []byte("RIFF\x24\x08\x00\x00WAVEfmt "), "audio/wav"},
+		{"riff but not wave", []byte("RIFF\x24\x08\x00\x00AVI LIST"), ""},
+		{"ogg", []byte("OggS\x00\x02\x00\x00"), "audio/ogg"},
+		{"flac", []byte("fLaC\x00\x00\x00\x22"), "audio/flac"},
+		{"webm", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81}, "audio/webm"},
+		{"mp3 with id3 tag", []byte("ID3\x04\x00\x00\x00\x00\x00\x00"), "audio/mpeg"},
+		{"mp3 frame sync", []byte{0xFF, 0xFB, 0x90, 0x64}, "audio/mpeg"},
+		{"aac adts is not mp3", []byte{0xFF, 0xF1, 0x50, 0x80}, ""},
+		{"text", []byte(`{"text":"hi"}`), ""},
+		{"short", []byte("RIF"), ""},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffAudioType(tt.data); got != tt.want {
+				t.Errorf("SniffAudioType = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsGenericContentType(t *testing.T) {
+	for ct, want := range map[string]bool{
+		"":                         true,
+		"application/octet-stream": true,
+		"not a; media type":        true,
+		"audio/wav":                false,
+		"audio/ogg; codecs=opus":   false,
+	} {
+		if got := IsGenericContentType(ct); got != want {
+			t.Errorf("IsGenericContentType(%q) = %v, want %v", ct, got, want)
+		}
+	}
+}
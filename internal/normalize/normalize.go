@@ -0,0 +1,318 @@
+// Package normalize rewrites transcripts into a form that is easier for the
+// interpreter and downstream targets to parse.
+//
+// Numbers normalizes spelled-out numbers ("twenty three") to digits ("23")
+// and common units that follow them ("degrees", "percent") to symbols.
+package normalize
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// numberWord describes a single spelled-out number token.
+type numberWord struct {
+	value int
+	mult  bool // multiplies the running value (hundred, thousand)
+}
+
+// language holds the word tables for one language.
+type language struct {
+	words      map[string]numberWord
+	connectors map[string]bool   // joins parts of one number ("and", "et", "y")
+	decimal    string            // decimal separator word ("point", "virgule")
+	units      map[string]string // unit word -> symbol
+	phrases    map[string]string // two-word unit phrase -> symbol
+	articles   map[string]bool   // words that are articles when standalone ("un", "une")
+	frenchTens bool              // allow 60/80 + teens (soixante-dix, quatre-vingt-onze)
+}
+
+var languages = map[string]*language{
+	"en": {
+		words: map[string]numberWord{
+			"zero": {0, false}, "one": {1, false}, "two": {2, false}, "three": {3, false},
+			"four": {4, false}, "five": {5, false}, "six": {6, false}, "seven": {7, false},
+			"eight": {8, false}, "nine": {9, false}, "ten": {10, false}, "eleven": {11, false},
+			"twelve": {12, false}, "thirteen": {13, false}, "fourteen": {14, false},
+			"fifteen": {15, false}, "sixteen": {16, false}, "seventeen": {17, false},
+			"eighteen": {18, false}, "nineteen": {19, false}, "twenty": {20, false},
+			"thirty": {30, false}, "forty": {40, false}, "fifty": {50, false},
+			"sixty": {60, false}, "seventy": {70, false}, "eighty": {80, false},
+			"ninety": {90, false}, "hundred": {100, true}, "thousand": {1000, true},
+		},
+		connectors: map[string]bool{"and": true},
+		decimal:    "point",
+		units: map[string]string{
+			"degree": "°", "degrees": "°", "percent": "%",
+		},
+		phrases: map[string]string{"per cent": "%"},
+	},
+	"fr": {
+		words: map[string]numberWord{
+			"zéro": {0, false}, "un": {1, false}, "une": {1, false}, "deux": {2, false},
+			"trois": {3, false}, "quatre": {4, false}, "cinq": {5, false}, "six": {6, false},
+			"sept": {7, false}, "huit": {8, false}, "neuf": {9, false}, "dix": {10, false},
+			"onze": {11, false}, "douze": {12, false}, "treize": {13, false},
+			"quatorze": {14, false}, "quinze": {15, false}, "seize": {16, false},
+			"vingt": {20, false}, "vingts": {20, false}, "trente": {30, false},
+			"quarante": {40, false}, "cinquante": {50, false}, "soixante": {60, false},
+			"cent": {100, true}, "cents": {100, true}, "mille": {1000, true},
+		},
+		connectors: map[string]bool{"et": true},
+		decimal:    "virgule",
+		units: map[string]string{
+			"degré": "°", "degrés": "°", "pourcent": "%", "pourcents": "%",
+		},
+		phrases:    map[string]string{"pour cent": "%"},
+		articles:   map[string]bool{"un": true, "une": true},
+		frenchTens: true,
+	},
+	"es": {
+		words: map[string]numberWord{
+			"cero": {0, false}, "uno": {1, false}, "una": {1, false}, "un": {1, false},
+			"dos": {2, false}, "tres": {3, false}, "cuatro": {4, false}, "cinco": {5, false},
+			"seis": {6, false}, "siete": {7, false}, "ocho": {8, false}, "nueve": {9, false},
+			"diez": {10, false}, "once": {11, false}, "doce": {12, false}, "trece": {13, false},
+			"catorce": {14, false}, "quince": {15, false}, "dieciséis": {16, false},
+			"diecisiete": {17, false}, "dieciocho": {18, false}, "diecinueve": {19, false},
+			"veinte": {20, false}, "veintiuno": {21, false}, "veintidós": {22, false},
+			"veintitrés": {23, false}, "veinticuatro": {24, false}, "veinticinco": {25, false},
+			"veintiséis": {26, false}, "veintisiete": {27, false}, "veintiocho": {28, false},
+			"veintinueve": {29, false}, "treinta": {30, false}, "cuarenta": {40, false},
+			"cincuenta": {50, false}, "sesenta": {60, false}, "setenta": {70, false},
+			"ochenta": {80, false}, "noventa": {90, false}, "cien": {100, false},
+			"ciento": {100, false}, "doscientos": {200, false}, "trescientos": {300, false},
+			"cuatrocientos": {400, false}, "quinientos": {500, false}, "seiscientos": {600, false},
+			"setecientos": {700, false}, "ochocientos": {800, false}, "novecientos": {900, false},
+			"mil": {1000, true},
+		},
+		connectors: map[string]bool{"y": true},
+		decimal:    "coma",
+		units: map[string]string{
+			"grado": "°", "grados": "°",
+		},
+		phrases:  map[string]string{"por ciento": "%"},
+		articles: map[string]bool{"un": true, "una": true},
+	},
+}
+
+// Supported reports whether Numbers has word tables for lang.
+func Supported(lang string) bool {
+	_, ok := languages[lang]
+	return ok
+}
+
+// Numbers rewrites spelled-out numbers in text as digits, along with any unit
+// word that directly follows them. Text in unsupported languages is returned
+// unchanged.
+func Numbers(text, lang string) string {
+	l, ok := languages[lang]
+	if !ok {
+		return text
+	}
+
+	toks := tokenize(text, l)
+	out := make([]string, 0, len(toks))
+
+	for i := 0; i < len(toks); {
+		n, consumed := l.parseNumber(toks[i:])
+		if consumed == 0 {
+			out = append(out, toks[i].raw)
+			i++
+			continue
+		}
+		lead := toks[i].lead
+		i += consumed
+		trail := toks[i-1].trail
+
+		// Attach a directly following unit, keeping its trailing punctuation.
+		if trail == "" {
+			if sym, used := l.unitAt(toks[i:]); used > 0 {
+				n += sym
+				i += used
+				trail = toks[i-1].trail
+			}
+		}
+		out = append(out, lead+n+trail)
+	}
+	return strings.Join(out, " ")
+}
+
+// token is one whitespace-separated word split into its parts.
+type token struct {
+	raw   string // original text
+	word  string // lowercased word without surrounding punctuation
+	lead  string // leading punctuation
+	trail string // trailing punctuation
+}
+
+// tokenize splits text on whitespace and breaks hyphenated number words
+// ("twenty-three", "dix-sept") into their parts.
+func tokenize(text string, l *language) []token {
+	var toks []token
+	for _, raw := range strings.Fields(text) {
+		start := strings.IndexFunc(raw, isWordRune)
+		if start < 0 {
+			toks = append(toks, token{raw: raw})
+			continue
+		}
+		end := strings.LastIndexFunc(raw, isWordRune) + 1
+		lead, core, trail := raw[:start], raw[start:end], raw[end:]
+
+		parts := strings.Split(core, "-")
+		if len(parts) > 1 && allNumberWords(parts, l) {
+			for j, p := range parts {
+				t := token{raw: p, word: strings.ToLower(p)}
+				if j == 0 {
+					t.lead = lead
+				}
+				if j == len(parts)-1 {
+					t.trail = trail
+				}
+				toks = append(toks, t)
+			}
+			continue
+		}
+		toks = append(toks, token{raw: raw, word: strings.ToLower(core), lead: lead, trail: trail})
+	}
+	return toks
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-'
+}
+
+func allNumberWords(parts []string, l *language) bool {
+	for _, p := range parts {
+		w := strings.ToLower(p)
+		if _, ok := l.words[w]; !ok && !l.connectors[w] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNumber consumes the longest run of number words at the start of toks.
+// It returns the formatted number and the number of tokens consumed (0 if
+// toks does not start with a number).
+func (l *language) parseNumber(toks []token) (string, int) {
+	var (
+		total, current int
+		consumed       int
+		started        bool
+	)
+
+	for consumed < len(toks) {
+		t := toks[consumed]
+
+		// A connector only counts if the next word continues this number.
+		if started && l.connectors[t.word] && consumed+1 < len(toks) {
+			next, ok := l.words[toks[consumed+1].word]
+			if ok && l.canAdd(current, next) && t.trail == "" {
+				consumed++
+				continue
+			}
+			break
+		}
+
+		w, ok := l.words[t.word]
+		if !ok {
+			break
+		}
+		if started && !w.mult && !l.canAdd(current, w) {
+			break
+		}
+
+		switch {
+		case w.mult && w.value >= 1000:
+			total += max(current, 1) * w.value
+			current = 0
+		case w.mult:
+			current = max(current, 1) * w.value
+		case l.frenchTens && w.value == 20 && current%100 == 4:
+			current = current - 4 + 80 // quatre-vingt(s)
+		default:
+			current += w.value
+		}
+		started = true
+		consumed++
+
+		// Stop at punctuation so "one, two" stays two numbers.
+		if t.trail != "" {
+			break
+		}
+	}
+
+	if consumed == 0 {
+		return "", 0
+	}
+
+	// A lone article ("un", "une") is not a number.
+	if consumed == 1 && l.articles[toks[0].word] {
+		return "", 0
+	}
+
+	n := strconv.Itoa(total + current)
+
+	// Decimal part: "<number> point five", one digit word at a time.
+	if l.decimal != "" && consumed < len(toks) && toks[consumed-1].trail == "" && toks[consumed].word == l.decimal {
+		var digits strings.Builder
+		j := consumed + 1
+		for ; j < len(toks); j++ {
+			w, ok := l.words[toks[j].word]
+			if !ok || w.mult || w.value > 9 {
+				break
+			}
+			digits.WriteString(strconv.Itoa(w.value))
+			if toks[j].trail != "" {
+				j++
+				break
+			}
+		}
+		if digits.Len() > 0 {
+			n += "." + digits.String()
+			consumed = j
+		}
+	}
+
+	return n, consumed
+}
+
+// canAdd reports whether w can be added to current as part of the same number.
+// This keeps digit sequences like "one two three" as separate numbers.
+func (l *language) canAdd(current int, w numberWord) bool {
+	if w.mult {
+		return true
+	}
+	r := current % 100
+	switch {
+	case w.value >= 100:
+		return current%1000 == 0
+	case r == 0:
+		return true
+	case w.value < 10 && r >= 20 && r%10 == 0:
+		return true
+	case l.frenchTens && (r == 60 || r == 80) && w.value < 20:
+		return true
+	case l.frenchTens && r == 4 && w.value == 20:
+		return true
+	}
+	return false
+}
+
+// unitAt returns the unit symbol at the start of toks, if any, and the number
+// of tokens it spans.
+func (l *language) unitAt(toks []token) (string, int) {
+	if len(toks) >= 2 {
+		if sym, ok := l.phrases[toks[0].word+" "+toks[1].word]; ok {
+			return sym, 2
+		}
+	}
+	if len(toks) >= 1 {
+		if sym, ok := l.units[toks[0].word]; ok {
+			return sym, 1
+		}
+	}
+	return "", 0
+}
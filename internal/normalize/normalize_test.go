@@ -0,0 +1,42 @@
+package normalize
+
+import "testing"
+
+func TestNumbers(t *testing.T) {
+	tests := []struct {
+		lang, in, want string
+	}{
+		{"en", "set the thermostat to twenty three degrees", "set the thermostat to 23°"},
+		{"en", "dim the lights to forty percent", "dim the lights to 40%"},
+		{"en", "forty per cent", "40%"},
+		{"en", "one hundred and five", "105"},
+		{"en", "two thousand three hundred", "2300"},
+		{"en", "nineteen point five degrees", "19.5°"},
+		{"en", "turn on the light", "turn on the light"},
+		{"fr", "règle le chauffage à vingt et un degrés", "règle le chauffage à 21°"},
+		{"fr", "soixante-dix pour cent", "70%"},
+		{"fr", "quatre-vingt-onze", "91"},
+		{"fr", "allume une lampe", "allume une lampe"},
+		{"es", "pon la temperatura a veintidós grados", "pon la temperatura a 22°"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang+"/"+tt.in, func(t *testing.T) {
+			if got := Numbers(tt.in, tt.lang); got != tt.want {
+				t.Errorf("Numbers(%q, %q) = %q, want %q", tt.in, tt.lang, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumbersUnsupportedLanguage(t *testing.T) {
+	const in = "zwanzig Grad"
+	if got := Numbers(in, "de"); got != in {
+		t.Errorf("Numbers(%q, \"de\") = %q, want it unchanged", in, got)
+	}
+	if Supported("de") {
+		t.Error("Supported(\"de\") = true")
+	}
+	if !Supported("en") || !Supported("fr") {
+		t.Error("en and fr should be supported")
+	}
+}
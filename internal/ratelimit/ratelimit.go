@@ -0,0 +1,89 @@
+// Package ratelimit throttles incoming messages with a token bucket per key,
+// by default the message's source, so one client cannot starve the others.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// KeyFunc picks the bucket a message is counted against. Messages with the
+// same key share a rate.
+type KeyFunc func(msg *message.Message) string
+
+// BySource keys messages by Message.Source. Messages without a source share
+// one bucket.
+func BySource(msg *message.Message) string { return msg.Source }
+
+// sweepInterval is how often buckets that have refilled are dropped.
+const sweepInterval = time.Minute
+
+// Limiter allows each key a steady rate of messages with bursts up to a
+// fixed size. It is safe for concurrent use.
+type Limiter struct {
+	rate  float64 // tokens per second
+	burst float64
+	key   KeyFunc
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New creates a limiter that allows rps messages per second per key, with
+// bursts of up to burst messages (at least one). A nil key uses BySource.
+func New(rps float64, burst int, key KeyFunc) *Limiter {
+	if key == nil {
+		key = BySource
+	}
+	return &Limiter{
+		rate:    rps,
+		burst:   float64(max(burst, 1)),
+		key:     key,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from msg's bucket. If the bucket is empty it returns
+// false and how long until a token is available; nothing is taken then.
+func (l *Limiter) Allow(msg *message.Message) (bool, time.Duration) {
+	key := l.key(msg)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that would be full by now; a new bucket starts full,
+// so forgetting them changes nothing.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+func TestAllowBurstThenReject(t *testing.T) {
+	l := New(1, 3, nil)
+	kitchen := &message.Message{Source: "kitchen"}
+	for i := range 3 {
+		if ok, _ := l.Allow(kitchen); !ok {
+			t.Fatalf("message %d of the burst rejected", i+1)
+		}
+	}
+	ok, retry := l.Allow(kitchen)
+	if ok {
+		t.Fatal("message beyond the burst allowed")
+	}
+	if retry <= 0 || retry > time.Second {
+		t.Errorf("retry after %s, want up to 1s at 1 rps", retry)
+	}
+}
+
+func TestAllowPerSource(t *testing.T) {
+	l := New(1, 1, nil)
+	if ok, _ := l.Allow(&message.Message{Source: "kitchen"}); !ok {
+		t.Fatal("kitchen rejected")
+	}
+	if ok, _ := l.Allow(&message.Message{Source: "kitchen"}); ok {
+		t.Fatal("second kitchen message allowed")
+	}
+	if ok, _ := l.Allow(&message.Message{Source: "bedroom"}); !ok {
+		t.Error("bedroom rejected because of kitchen's rate")
+	}
+}
+
+func TestAllowRefills(t *testing.T) {
+	l := New(100, 1, nil) // one token every 10ms
+	msg := &message.Message{Source: "kitchen"}
+	if ok, _ := l.Allow(msg); !ok {
+		t.Fatal("first message rejected")
+	}
+	if ok, _ := l.Allow(msg); ok {
+		t.Fatal("second message allowed before refill")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := l.Allow(msg); !ok {
+		t.Error("message rejected after the bucket refilled")
+	}
+}
+
+func TestCustomKey(t *testing.T) {
+	l := New(1, 1, func(msg *message.Message) string { return msg.SessionID })
+	if ok, _ := l.Allow(&message.Message{Source: "a", SessionID: "s1"}); !ok {
+		t.Fatal("first message rejected")
+	}
+	if ok, _ := l.Allow(&message.Message{Source: "b", SessionID: "s1"}); ok {
+		t.Error("same key from another source allowed past the burst")
+	}
+}
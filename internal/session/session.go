@@ -0,0 +1,101 @@
+// Package session remembers recent turns of a conversation so follow-up
+// utterances ("make it dimmer") can be interpreted against what came before.
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Store keeps the recent turns of each session.
+type Store interface {
+	// History returns the retained turns for id, oldest first.
+	History(id string) []message.Turn
+
+	// Append records a completed turn for id.
+	Append(id string, turn message.Turn)
+}
+
+// Memory is an in-memory Store. Each session keeps its last maxTurns turns
+// and is forgotten after ttl without activity. It is safe for concurrent use.
+type Memory struct {
+	maxTurns int
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	sessions  map[string]*entry
+	lastSweep time.Time
+}
+
+type entry struct {
+	turns   []message.Turn
+	expires time.Time
+}
+
+// NewMemory creates an in-memory store. Zero values fall back to defaults
+// (5 turns, 10 minutes).
+func NewMemory(maxTurns int, ttl time.Duration) *Memory {
+	if maxTurns <= 0 {
+		maxTurns = 5
+	}
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &Memory{
+		maxTurns:  maxTurns,
+		ttl:       ttl,
+		sessions:  make(map[string]*entry),
+		lastSweep: time.Now(),
+	}
+}
+
+// History returns a copy of the retained turns for id.
+func (m *Memory) History(id string) []message.Turn {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(e.expires) {
+		delete(m.sessions, id)
+		return nil
+	}
+	return append([]message.Turn(nil), e.turns...)
+}
+
+// Append records turn for id, dropping the oldest turn beyond the limit.
+func (m *Memory) Append(id string, turn message.Turn) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweepLocked(now)
+
+	e, ok := m.sessions[id]
+	if !ok || now.After(e.expires) {
+		e = &entry{}
+		m.sessions[id] = e
+	}
+	e.turns = append(e.turns, turn)
+	if len(e.turns) > m.maxTurns {
+		e.turns = append(e.turns[:0], e.turns[len(e.turns)-m.maxTurns:]...)
+	}
+	e.expires = now.Add(m.ttl)
+}
+
+// sweepLocked drops expired sessions, at most once per ttl.
+func (m *Memory) sweepLocked(now time.Time) {
+	if now.Sub(m.lastSweep) < m.ttl {
+		return
+	}
+	m.lastSweep = now
+	for id, e := range m.sessions {
+		if now.After(e.expires) {
+			delete(m.sessions, id)
+		}
+	}
+}
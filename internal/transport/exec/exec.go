@@ -0,0 +1,113 @@
+// Package exec implements a sink-only transport that runs a local command
+// with the routed payload on stdin.
+//
+// Running programs on the host is dangerous, so this transport is disabled by
+// default and only executes commands from an explicit allow-list in config.
+// A target selects a command by name via its endpoint; the argv comes verbatim
+// from config and is never passed through a shell or templated with payload
+// data.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	osexec "os/exec"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// maxOutput caps how much stdout/stderr is captured from a command.
+const maxOutput = 4 << 10
+
+// Transport implements transport.Transport by executing allow-listed commands.
+type Transport struct {
+	commands map[string][]string
+	timeout  time.Duration
+}
+
+// New creates a new exec transport from config.
+func New(cfg config.ExecConfig) *Transport {
+	commands := make(map[string][]string, len(cfg.Commands))
+	for name, argv := range cfg.Commands {
+		if len(argv) == 0 {
+			slog.Warn("exec command has empty argv, ignoring", "name", name)
+			continue
+		}
+		commands[name] = argv
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Transport{commands: commands, timeout: timeout}
+}
+
+// Name returns the transport identifier.
+func (t *Transport) Name() string { return "exec" }
+
+// Listen blocks until the context is cancelled. The exec transport never
+// receives messages.
+func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error {
+	slog.Info("exec transport ready", "commands", len(t.commands))
+	<-ctx.Done()
+	return nil
+}
+
+// Send runs the allow-listed command named by target.Endpoint, writing the
+// payload to its stdin. A non-zero exit status is returned as an error.
+func (t *Transport) Send(ctx context.Context, target message.Target, payload []byte) error {
+	argv, ok := t.commands[target.Endpoint]
+	if !ok {
+		return fmt.Errorf("exec send: command %q is not in the allow-list", target.Endpoint)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	cmd := osexec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &limitedWriter{w: &output, n: maxOutput}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("exec send: %s timed out after %s", target.Endpoint, t.timeout)
+		}
+		return fmt.Errorf("exec send: %s: %w: %s", target.Endpoint, err, output.Bytes())
+	}
+
+	slog.Debug("exec send success", "target", target.ServiceName, "command", target.Endpoint, "bytes", len(payload))
+	return nil
+}
+
+// Close is a no-op — commands are run per send.
+func (t *Transport) Close() error { return nil }
+
+// limitedWriter discards everything past the first n bytes.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	total := len(p)
+	if l.n <= 0 {
+		return total, nil
+	}
+	if len(p) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.w.Write(p)
+	l.n -= n
+	if err != nil {
+		return n, err
+	}
+	return total, nil
+}
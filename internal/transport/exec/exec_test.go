@@ -0,0 +1,114 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+func TestSend(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	out := filepath.Join(t.TempDir(), "stdin")
+	tr := New(config.ExecConfig{
+		Commands: map[string][]string{
+			"save":  {"/bin/sh", "-c", `cat > "$0"`, out},
+			"fail":  {"/bin/sh", "-c", "echo bad payload >&2; exit 3"},
+			"slow":  {"/bin/sh", "-c", "exec sleep 5"},
+			"empty": {},
+		},
+		Timeout: 200 * time.Millisecond,
+	})
+
+	tests := []struct {
+		name    string
+		command string
+		wantErr string
+	}{
+		{name: "payload on stdin", command: "save"},
+		{name: "not allow-listed", command: "rm", wantErr: `command "rm" is not in the allow-list`},
+		{name: "empty argv ignored", command: "empty", wantErr: "not in the allow-list"},
+		{name: "exit status with output", command: "fail", wantErr: "exit status 3: bad payload"},
+		{name: "timeout", command: "slow", wantErr: "slow timed out after 200ms"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			target := message.Target{ServiceName: "script", Protocol: "exec", Endpoint: tt.command}
+			err := tr.Send(context.Background(), target, []byte(`{"commands":[]}`))
+			if time.Since(start) > 2*time.Second {
+				t.Errorf("Send took %s, want it killed at the timeout", time.Since(start))
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != `{"commands":[]}` {
+		t.Errorf("stdin = %q", got)
+	}
+}
+
+func TestSendTruncatesOutput(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("no /bin/sh")
+	}
+	tr := New(config.ExecConfig{Commands: map[string][]string{
+		"noisy": {"/bin/sh", "-c", "head -c 10000 /dev/zero | tr '\\0' z; exit 1"},
+	}})
+	err := tr.Send(context.Background(), message.Target{Endpoint: "noisy"}, nil)
+	if err == nil {
+		t.Fatal("Send succeeded, want exit status error")
+	}
+	if got := strings.Count(err.Error(), "z"); got != maxOutput {
+		t.Errorf("error carries %d bytes of output, want %d", got, maxOutput)
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int
+		writes []string
+		want   string
+	}{
+		{name: "under limit", limit: 8, writes: []string{"abc", "de"}, want: "abcde"},
+		{name: "split write", limit: 4, writes: []string{"abc", "def"}, want: "abcd"},
+		{name: "after limit", limit: 3, writes: []string{"abc", "def", "g"}, want: "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &limitedWriter{w: &buf, n: tt.limit}
+			for _, s := range tt.writes {
+				// The full length is reported so the command never sees a
+				// short write.
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if buf.String() != tt.want {
+				t.Errorf("output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
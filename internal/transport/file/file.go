@@ -0,0 +1,71 @@
+// Package file implements a send-only sink that appends routed payloads to
+// files, for capturing golden outputs while developing prompts.
+//
+// Targets with protocol "file" name a file relative to the configured
+// directory in their endpoint; each dispatch appends one line of JSON to it.
+// An empty endpoint or "-" writes to stdout instead. Endpoints can come from
+// clients, so paths that are absolute or climb out of the directory are
+// rejected.
+package file
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// Sender implements transport.Sender. Register it with dispatch.RegisterSender.
+type Sender struct {
+	dir string
+	mu  sync.Mutex // serializes writes so lines from concurrent dispatches don't interleave
+}
+
+// New creates a file sender from config.
+func New(cfg config.FileConfig) *Sender {
+	return &Sender{dir: cfg.Dir}
+}
+
+// Send appends the payload and a newline to the target's file.
+func (s *Sender) Send(ctx context.Context, target message.Target, payload []byte) error {
+	line := make([]byte, 0, len(payload)+1)
+	line = append(line, payload...)
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if target.Endpoint == "" || target.Endpoint == "-" {
+		if _, err := os.Stdout.Write(line); err != nil {
+			return fmt.Errorf("file send to stdout: %w", err)
+		}
+		return nil
+	}
+
+	if !filepath.IsLocal(target.Endpoint) {
+		return fmt.Errorf("file target %q must be a relative path inside the sink directory", target.Endpoint)
+	}
+	path := filepath.Join(s.dir, target.Endpoint)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating file sink directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening file sink %s: %w", path, err)
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return fmt.Errorf("file send: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing file sink %s: %w", path, err)
+	}
+
+	slog.Debug("file send", "target", target.ServiceName, "path", path, "bytes", len(payload))
+	return nil
+}
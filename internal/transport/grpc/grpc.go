@@ -1,30 +1,75 @@
 // Package grpc implements the gRPC transport for switchyard.
 //
 // This transport exposes a gRPC server that accepts DispatchRequest messages
-// containing audio payloads and instructions. It is the preferred transport
-// for low-latency, strongly-typed communication with robots and edge devices.
+// containing audio payloads and instructions, or audio streamed in chunks via
+// StreamDispatch. It is the preferred transport for low-latency,
+// strongly-typed communication with robots and edge devices.
 package grpc
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log/slog"
 	"net"
+	"sync"
 
+	"github.com/nadzzz/switchyard/internal/config"
 	"github.com/nadzzz/switchyard/internal/message"
 	"github.com/nadzzz/switchyard/internal/transport"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// Send modes for gRPC targets (message.Target.GRPCMode).
+const (
+	// SendModeNative calls SwitchyardTarget/Receive with a DispatchResponse.
+	SendModeNative = "native"
+
+	// SendModeBytes calls Target.GRPCMethod with the JSON payload wrapped in a
+	// google.protobuf.BytesValue.
+	SendModeBytes = "bytes"
+
+	// SendModeMetadata calls Target.GRPCMethod with an empty request and the
+	// base64-encoded JSON payload in the "x-switchyard-payload" metadata key.
+	SendModeMetadata = "metadata"
+)
+
+// nativeReceiveMethod is the full method name of the switchyard-native push RPC.
+const nativeReceiveMethod = "/switchyard.v1.SwitchyardTarget/Receive"
+
 // Transport implements transport.Transport over gRPC.
 type Transport struct {
-	port   int
-	server *grpc.Server
+	port          int
+	maxAudioBytes int
+	tls           config.GRPCTLSConfig
+	clientTLS     config.GRPCClientTLSConfig
+	server        *grpc.Server
+
+	mu    sync.Mutex
+	creds credentials.TransportCredentials // for TLS targets, loaded on first use
+	conns map[connKey]*grpc.ClientConn     // client connections for Send
 }
 
-// New creates a new gRPC transport on the given port.
-func New(port int) *Transport {
-	return &Transport{port: port}
+// connKey identifies a client connection: the same endpoint may be dialed
+// both in plaintext and over TLS.
+type connKey struct {
+	endpoint string
+	tls      bool
+}
+
+// New creates a new gRPC transport from config.
+func New(cfg config.GRPCConfig) *Transport {
+	maxAudio := cfg.MaxAudioBytes
+	if maxAudio <= 0 {
+		maxAudio = 25 << 20
+	}
+	return &Transport{port: cfg.Port, maxAudioBytes: maxAudio, tls: cfg.TLS, clientTLS: cfg.ClientTLS}
 }
 
 // Name returns the transport identifier.
@@ -32,17 +77,28 @@ func (t *Transport) Name() string { return "grpc" }
 
 // Listen starts the gRPC server and routes incoming requests to the handler.
 func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error {
+	creds, err := serverCredentials(t.tls)
+	if err != nil {
+		return err
+	}
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(rawCodec{}),
+		// A unary request carries the whole clip; leave room for the other fields.
+		grpc.MaxRecvMsgSize(t.maxAudioBytes + 64<<10),
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", t.port))
 	if err != nil {
 		return fmt.Errorf("grpc listen: %w", err)
 	}
 
-	t.server = grpc.NewServer()
+	t.server = grpc.NewServer(opts...)
+	t.server.RegisterService(&serviceDesc, &service{handler: handler, maxAudioBytes: t.maxAudioBytes})
 
-	// TODO: Register the generated SwitchyardService server here once proto is compiled.
-	// pb.RegisterSwitchyardServiceServer(t.server, &serviceServer{handler: handler})
-
-	slog.Info("grpc transport listening", "port", t.port)
+	slog.Info("grpc transport listening", "port", t.port, "tls", creds != nil, "mtls", t.tls.ClientCAFile != "")
 
 	go func() {
 		<-ctx.Done()
@@ -54,16 +110,120 @@ func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error
 }
 
 // Send delivers a payload to a gRPC target.
+//
+// Targets that do not implement switchyard's own Receive RPC can select a
+// generic mode via Target.GRPCMode; see the SendMode constants.
 func (t *Transport) Send(ctx context.Context, target message.Target, payload []byte) error {
-	// TODO: Implement gRPC client send to target endpoint.
-	slog.Debug("grpc send", "target", target.Endpoint, "bytes", len(payload))
+	conn, err := t.conn(target)
+	if err != nil {
+		return fmt.Errorf("grpc send: %w", err)
+	}
+
+	mode := target.GRPCMode
+	if mode == "" {
+		mode = SendModeNative
+	}
+
+	var (
+		method string
+		req    []byte
+	)
+	switch mode {
+	case SendModeNative:
+		method = nativeReceiveMethod
+		req, err = encodeDispatchResponse(payload)
+		if err != nil {
+			return fmt.Errorf("grpc send: %w", err)
+		}
+	case SendModeBytes:
+		if target.GRPCMethod == "" {
+			return fmt.Errorf("grpc send: mode %q requires grpc_method", mode)
+		}
+		method = target.GRPCMethod
+		req = encodeBytesValue(payload)
+	case SendModeMetadata:
+		if target.GRPCMethod == "" {
+			return fmt.Errorf("grpc send: mode %q requires grpc_method", mode)
+		}
+		method = target.GRPCMethod
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-switchyard-payload", base64.StdEncoding.EncodeToString(payload))
+	default:
+		return fmt.Errorf("grpc send: unknown mode %q", mode)
+	}
+
+	var resp []byte
+	if err := conn.Invoke(ctx, method, req, &resp, grpc.ForceCodec(rawCodec{})); err != nil {
+		return fmt.Errorf("grpc send %s: %w", method, err)
+	}
+
+	slog.Debug("grpc send success", "target", target.Endpoint, "method", method, "mode", mode, "bytes", len(payload))
 	return nil
 }
 
-// Close gracefully stops the gRPC server.
+// Probe checks that a gRPC target is reachable with the standard health
+// service. Servers without it still pass if they answer Unimplemented, since
+// that shows the connection works.
+func (t *Transport) Probe(ctx context.Context, target message.Target) (string, error) {
+	conn, err := t.conn(target)
+	if err != nil {
+		return "", fmt.Errorf("grpc probe: %w", err)
+	}
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return "connected; no health service", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("grpc probe: %w", err)
+	}
+	if s := resp.GetStatus(); s != healthpb.HealthCheckResponse_SERVING {
+		return "health " + s.String(), fmt.Errorf("grpc probe: target reports %s", s)
+	}
+	return "health SERVING", nil
+}
+
+// conn returns a cached client connection to target's endpoint, over TLS if
+// target sets it, creating it if needed.
+func (t *Transport) conn(target message.Target) (*grpc.ClientConn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := connKey{endpoint: target.Endpoint, tls: target.TLS}
+	if c, ok := t.conns[key]; ok {
+		return c, nil
+	}
+	creds := insecure.NewCredentials()
+	if target.TLS {
+		if t.creds == nil {
+			tc, err := clientCredentials(t.clientTLS)
+			if err != nil {
+				return nil, err
+			}
+			t.creds = tc
+		}
+		creds = t.creds
+	}
+	c, err := grpc.NewClient(target.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", target.Endpoint, err)
+	}
+	if t.conns == nil {
+		t.conns = make(map[connKey]*grpc.ClientConn)
+	}
+	t.conns[key] = c
+	return c, nil
+}
+
+// Close gracefully stops the gRPC server and closes client connections.
 func (t *Transport) Close() error {
 	if t.server != nil {
 		t.server.GracefulStop()
 	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, c := range t.conns {
+		_ = c.Close()
+		delete(t.conns, key)
+	}
 	return nil
 }
@@ -0,0 +1,236 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// fakeTarget serves switchyard.v1.SwitchyardTarget and records the requests
+// it receives.
+type fakeTarget struct {
+	received chan []byte
+}
+
+var fakeTargetDesc = grpc.ServiceDesc{
+	ServiceName: "switchyard.v1.SwitchyardTarget",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Receive",
+		Handler: func(srv any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+			var req []byte
+			if err := dec(&req); err != nil {
+				return nil, err
+			}
+			srv.(*fakeTarget).received <- req
+			return []byte{}, nil // ReceiveResponse
+		},
+	}},
+}
+
+// startFakeTarget starts a fake target, over TLS if creds is set, and
+// returns it with its address.
+func startFakeTarget(t *testing.T, creds credentials.TransportCredentials) (*fakeTarget, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := []grpc.ServerOption{grpc.ForceServerCodec(rawCodec{})}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	ft := &fakeTarget{received: make(chan []byte, 1)}
+	srv.RegisterService(&fakeTargetDesc, ft)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return ft, lis.Addr().String()
+}
+
+// decodedResponse holds the fields of a DispatchResponse checked by tests.
+type decodedResponse struct {
+	messageID    string
+	actions      []string
+	routedTo     []string
+	responseText string
+}
+
+func decodeResponse(t *testing.T, b []byte) decodedResponse {
+	t.Helper()
+	var r decodedResponse
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			r.messageID = string(v)
+		case 3:
+			return walkFields(v, func(num protowire.Number, v []byte) error {
+				if num == 1 {
+					r.actions = append(r.actions, string(v))
+				}
+				return nil
+			})
+		case 4:
+			r.routedTo = append(r.routedTo, string(v))
+		case 7:
+			r.responseText = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("decoding DispatchResponse: %v", err)
+	}
+	return r
+}
+
+func sendAndReceive(t *testing.T, tr *Transport, ft *fakeTarget, target message.Target) decodedResponse {
+	t.Helper()
+	payload, err := json.Marshal(message.DispatchResult{
+		MessageID:    "msg-1",
+		Commands:     []message.Command{{Action: "turn_on", Params: map[string]any{"entity_id": "light.kitchen"}}},
+		RoutedTo:     []string{"robot"},
+		ResponseText: "Done.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tr.Send(ctx, target, payload); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	select {
+	case req := <-ft.received:
+		return decodeResponse(t, req)
+	default:
+		t.Fatal("target received nothing")
+	}
+	return decodedResponse{}
+}
+
+func TestSendNative(t *testing.T) {
+	ft, addr := startFakeTarget(t, nil)
+	tr := New(config.GRPCConfig{})
+	defer tr.Close()
+
+	got := sendAndReceive(t, tr, ft, message.Target{ServiceName: "robot", Endpoint: addr, Protocol: "grpc"})
+	if got.messageID != "msg-1" || len(got.actions) != 1 || got.actions[0] != "turn_on" ||
+		len(got.routedTo) != 1 || got.routedTo[0] != "robot" || got.responseText != "Done." {
+		t.Errorf("target received %+v", got)
+	}
+}
+
+func TestSendNativeTLS(t *testing.T) {
+	cert, caFile := selfSignedCert(t)
+	ft, addr := startFakeTarget(t, credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}}))
+	tr := New(config.GRPCConfig{ClientTLS: config.GRPCClientTLSConfig{CAFile: caFile}})
+	defer tr.Close()
+
+	got := sendAndReceive(t, tr, ft, message.Target{ServiceName: "robot", Endpoint: addr, Protocol: "grpc", TLS: true})
+	if got.messageID != "msg-1" {
+		t.Errorf("target received %+v", got)
+	}
+}
+
+func TestDecodeInstruction(t *testing.T) {
+	var b []byte
+	b = appendString(b, 2, "homeassistant")
+	b = appendString(b, 4, "turn_on")
+	b = appendString(b, 4, "turn_off")
+	b = appendString(b, 5, "fast")
+	b = protowire.AppendTag(b, 7, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, 0x3fe0000000000000) // 0.5
+	b = protowire.AppendTag(b, 10, protowire.VarintType)
+	b = protowire.AppendVarint(b, 12)
+	b = protowire.AppendTag(b, 15, protowire.VarintType)
+	b = protowire.AppendVarint(b, 1)
+	b = appendString(b, 17, "audio")
+	var speech []byte
+	speech = protowire.AppendTag(speech, 1, protowire.Fixed64Type)
+	speech = protowire.AppendFixed64(speech, 0x3fe8000000000000) // 0.75
+	b = protowire.AppendTag(b, 20, protowire.BytesType)
+	b = protowire.AppendBytes(b, speech)
+	b = appendString(b, 21, "https://example.com/cb")
+	var target []byte
+	target = appendString(target, 1, "robot")
+	target = protowire.AppendTag(target, 7, protowire.VarintType)
+	target = protowire.AppendVarint(target, 1)
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendBytes(b, target)
+
+	instr, err := decodeInstruction(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case instr.ResponseFormat != "homeassistant":
+		t.Errorf("ResponseFormat = %q", instr.ResponseFormat)
+	case len(instr.Actions) != 2 || instr.Actions[1] != "turn_off":
+		t.Errorf("Actions = %v", instr.Actions)
+	case instr.Interpreter != "fast":
+		t.Errorf("Interpreter = %q", instr.Interpreter)
+	case instr.Temperature == nil || *instr.Temperature != 0.5:
+		t.Errorf("Temperature = %v", instr.Temperature)
+	case instr.ResponseMaxWords != 12:
+		t.Errorf("ResponseMaxWords = %d", instr.ResponseMaxWords)
+	case !instr.DryRun:
+		t.Error("DryRun not set")
+	case instr.ResponseMode != "audio":
+		t.Errorf("ResponseMode = %q", instr.ResponseMode)
+	case instr.Speech == nil || instr.Speech.Rate != 0.75:
+		t.Errorf("Speech = %+v", instr.Speech)
+	case instr.CallbackURL != "https://example.com/cb":
+		t.Errorf("CallbackURL = %q", instr.CallbackURL)
+	case len(instr.Targets) != 1 || instr.Targets[0].ServiceName != "robot" || !instr.Targets[0].TLS:
+		t.Errorf("Targets = %+v", instr.Targets)
+	}
+}
+
+// selfSignedCert returns a certificate for 127.0.0.1 and the path of a PEM
+// file holding it, to use as the CA.
+func selfSignedCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake target"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
@@ -0,0 +1,156 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// serviceDesc describes switchyard.v1.SwitchyardService. Requests are decoded
+// by hand (see wire.go), so no generated code is needed.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "switchyard.v1.SwitchyardService",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Dispatch", Handler: dispatchHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "StreamDispatch", Handler: streamDispatchHandler, ClientStreams: true},
+	},
+	Metadata: "switchyard.proto",
+}
+
+// service serves SwitchyardService with the dispatcher's handler.
+type service struct {
+	handler       transport.Handler
+	maxAudioBytes int
+}
+
+// dispatchHandler serves the unary Dispatch RPC.
+func dispatchHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	var req []byte
+	if err := dec(&req); err != nil {
+		return nil, err
+	}
+	s := srv.(*service)
+	if interceptor == nil {
+		return s.handleDispatch(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/switchyard.v1.SwitchyardService/Dispatch"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return s.handleDispatch(ctx, req.([]byte))
+	})
+}
+
+// handleDispatch decodes and dispatches a DispatchRequest.
+func (s *service) handleDispatch(ctx context.Context, req []byte) (any, error) {
+	msg, err := decodeDispatchRequest(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(msg.Audio) > s.maxAudioBytes {
+		return nil, status.Errorf(codes.ResourceExhausted, "audio exceeds %d bytes", s.maxAudioBytes)
+	}
+	return s.dispatch(ctx, msg)
+}
+
+// streamDispatchHandler serves the client-streaming StreamDispatch RPC. The
+// first chunk carries the source, content type and instruction; audio from
+// every chunk is assembled and dispatched once the client sends a final chunk
+// or closes its side of the stream.
+func streamDispatchHandler(srv any, stream grpc.ServerStream) error {
+	s := srv.(*service)
+	msg := &message.Message{}
+	for first := true; ; first = false {
+		var raw []byte
+		err := stream.RecvMsg(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// Includes the client cancelling mid-stream.
+			slog.Debug("grpc audio stream aborted", "source", msg.Source, "bytes", len(msg.Audio), "error", err)
+			return err
+		}
+
+		chunk, err := decodeAudioChunk(raw)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if first {
+			msg.Source = chunk.source
+			msg.ContentType = chunk.contentType
+			msg.SessionID = chunk.sessionID
+			if chunk.instruction != nil {
+				msg.Instruction = *chunk.instruction
+			}
+		}
+		if len(msg.Audio)+len(chunk.data) > s.maxAudioBytes {
+			return status.Errorf(codes.ResourceExhausted, "audio stream exceeds %d bytes", s.maxAudioBytes)
+		}
+		msg.Audio = append(msg.Audio, chunk.data...)
+		if chunk.final {
+			break
+		}
+	}
+	if len(msg.Audio) == 0 {
+		return status.Error(codes.InvalidArgument, "audio stream carried no audio")
+	}
+
+	resp, err := s.dispatch(stream.Context(), msg)
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(resp)
+}
+
+// firstValue returns the first of values, or "".
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// dispatch runs msg through the handler and encodes the DispatchResponse.
+func (s *service) dispatch(ctx context.Context, msg *message.Message) ([]byte, error) {
+	msg.Timestamp = time.Now()
+	if msg.Source == "" {
+		msg.Source = peerIdentity(ctx)
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		msg.TraceID = transport.TraceID(firstValue(md.Get("x-request-id")), firstValue(md.Get("traceparent")))
+	}
+	if msg.TraceID != "" {
+		// Echoed like the HTTP transport's X-Request-ID header.
+		_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", msg.TraceID))
+	}
+	result, err := s.handler(ctx, msg)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if errors.Is(err, transport.ErrBusy) || errors.Is(err, transport.ErrRateLimited) {
+			return nil, status.Error(codes.ResourceExhausted, err.Error())
+		}
+		if errors.Is(err, transport.ErrShuttingDown) {
+			return nil, status.Error(codes.Unavailable, err.Error())
+		}
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp, err := encodeDispatchResult(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return resp, nil
+}
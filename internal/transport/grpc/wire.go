@@ -0,0 +1,338 @@
+package grpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// rawCodec passes pre-encoded protobuf bytes through unchanged. It lets Send
+// call arbitrary methods without generated message types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("raw codec: cannot marshal %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("raw codec: cannot unmarshal into %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name reports "proto" so requests carry the standard application/grpc content type.
+func (rawCodec) Name() string { return "proto" }
+
+// encodeDispatchResponse converts a JSON-encoded message.DispatchResult into
+// the protobuf wire format of switchyard.v1.DispatchResponse.
+func encodeDispatchResponse(payload []byte) ([]byte, error) {
+	var result message.DispatchResult
+	if err := json.Unmarshal(payload, &result); err != nil {
+		return nil, fmt.Errorf("decoding dispatch result: %w", err)
+	}
+	return encodeDispatchResult(&result)
+}
+
+// encodeDispatchResult encodes result as a switchyard.v1.DispatchResponse.
+func encodeDispatchResult(result *message.DispatchResult) ([]byte, error) {
+	var b []byte
+	b = appendString(b, 1, result.MessageID)
+	b = appendString(b, 2, result.Transcript)
+	for _, cmd := range result.Commands {
+		var c []byte
+		c = appendString(c, 1, cmd.Action)
+		if len(cmd.Params) > 0 {
+			params, err := json.Marshal(cmd.Params)
+			if err != nil {
+				return nil, fmt.Errorf("encoding params for %q: %w", cmd.Action, err)
+			}
+			c = appendString(c, 2, string(params))
+		}
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, c)
+	}
+	for _, name := range result.RoutedTo {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendString(b, name)
+	}
+	b = appendString(b, 5, result.Error)
+	b = appendString(b, 6, result.Language)
+	b = appendString(b, 7, result.ResponseText)
+	if len(result.ResponseAudio) > 0 {
+		b = protowire.AppendTag(b, 8, protowire.BytesType)
+		b = protowire.AppendBytes(b, result.ResponseAudio)
+	}
+	b = appendString(b, 9, result.ResponseContentType)
+	b = appendString(b, 10, result.ResponseAudioURL)
+	b = appendString(b, 11, result.ErrorStage)
+	b = appendString(b, 12, result.ErrorCode)
+	if result.DryRun {
+		b = protowire.AppendTag(b, 13, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	return b, nil
+}
+
+// encodeBytesValue wraps payload in a google.protobuf.BytesValue.
+func encodeBytesValue(payload []byte) []byte {
+	b := protowire.AppendTag(nil, 1, protowire.BytesType)
+	return protowire.AppendBytes(b, payload)
+}
+
+// appendString appends a proto3 string field, omitting it when empty.
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+// audioChunk is a decoded switchyard.v1.AudioChunk.
+type audioChunk struct {
+	data        []byte
+	contentType string
+	source      string
+	sessionID   string
+	instruction *message.Instruction // nil if the chunk carries none
+	final       bool
+}
+
+// decodeDispatchRequest decodes a switchyard.v1.DispatchRequest.
+func decodeDispatchRequest(b []byte) (*message.Message, error) {
+	msg := &message.Message{}
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			msg.ID = string(v)
+		case 2:
+			msg.Source = string(v)
+		case 3:
+			msg.Audio = append([]byte(nil), v...)
+		case 4:
+			msg.ContentType = string(v)
+		case 5:
+			msg.Text = string(v)
+		case 6:
+			instr, err := decodeInstruction(v)
+			if err != nil {
+				return err
+			}
+			msg.Instruction = *instr
+		case 7:
+			msg.AudioURL = string(v)
+		case 8:
+			msg.SessionID = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding DispatchRequest: %w", err)
+	}
+	return msg, nil
+}
+
+// decodeAudioChunk decodes a switchyard.v1.AudioChunk. The sequence number is
+// not needed: gRPC delivers stream messages in order.
+func decodeAudioChunk(b []byte) (*audioChunk, error) {
+	c := &audioChunk{}
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 2:
+			c.data = v
+		case 3:
+			c.contentType = string(v)
+		case 4:
+			c.source = string(v)
+		case 5:
+			instr, err := decodeInstruction(v)
+			if err != nil {
+				return err
+			}
+			c.instruction = instr
+		case 6:
+			c.final = varintValue(v) != 0
+		case 7:
+			c.sessionID = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("decoding AudioChunk: %w", err)
+	}
+	return c, nil
+}
+
+// decodeInstruction decodes a switchyard.v1.Instruction.
+func decodeInstruction(b []byte) (*message.Instruction, error) {
+	instr := &message.Instruction{}
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			t, err := decodeTarget(v)
+			if err != nil {
+				return err
+			}
+			instr.Targets = append(instr.Targets, t)
+		case 2:
+			instr.ResponseFormat = string(v)
+		case 3:
+			instr.Prompt = string(v)
+		case 4:
+			instr.Actions = append(instr.Actions, string(v))
+		case 5:
+			instr.Interpreter = string(v)
+		case 6:
+			instr.CompletionModel = string(v)
+		case 7:
+			t := doubleValue(v)
+			instr.Temperature = &t
+		case 8:
+			instr.Language = string(v)
+		case 9:
+			instr.ResponseLanguage = string(v)
+		case 10:
+			instr.ResponseMaxWords = int(int32(varintValue(v)))
+		case 11:
+			instr.ResponseMaxChars = int(int32(varintValue(v)))
+		case 12:
+			instr.ResponseStyle = string(v)
+		case 13:
+			instr.Translate = varintValue(v) != 0
+		case 14:
+			instr.SkipInterpretation = varintValue(v) != 0
+		case 15:
+			instr.DryRun = varintValue(v) != 0
+		case 16:
+			instr.Sequential = varintValue(v) != 0
+		case 17:
+			instr.ResponseMode = string(v)
+		case 18:
+			instr.AudioFormat = string(v)
+		case 19:
+			instr.SampleRate = int(int32(varintValue(v)))
+		case 20:
+			speech, err := decodeProsody(v)
+			if err != nil {
+				return err
+			}
+			instr.Speech = speech
+		case 21:
+			instr.CallbackURL = string(v)
+		}
+		return nil
+	})
+	return instr, err
+}
+
+// decodeProsody decodes a switchyard.v1.Prosody.
+func decodeProsody(b []byte) (*message.Prosody, error) {
+	p := &message.Prosody{}
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			p.Rate = doubleValue(v)
+		case 2:
+			p.Pitch = doubleValue(v)
+		case 3:
+			p.Volume = doubleValue(v)
+		}
+		return nil
+	})
+	return p, err
+}
+
+// decodeTarget decodes a switchyard.v1.Target.
+func decodeTarget(b []byte) (message.Target, error) {
+	var t message.Target
+	err := walkFields(b, func(num protowire.Number, v []byte) error {
+		switch num {
+		case 1:
+			t.ServiceName = string(v)
+		case 2:
+			t.Endpoint = string(v)
+		case 3:
+			t.Protocol = string(v)
+		case 4:
+			t.FormatTemplate = string(v)
+		case 5:
+			t.GRPCMode = string(v)
+		case 6:
+			t.GRPCMethod = string(v)
+		case 7:
+			t.TLS = varintValue(v) != 0
+		}
+		return nil
+	})
+	return t, err
+}
+
+// walkFields calls fn for each field in b. Length-delimited values are passed
+// as their contents, varints in their wire encoding (see varintValue) and
+// 64-bit values as their 8 bytes (see doubleValue). Other types are skipped.
+func walkFields(b []byte, fn func(num protowire.Number, v []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		case protowire.VarintType:
+			_, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			_, n = protowire.ConsumeFixed64(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if typ == protowire.VarintType || typ == protowire.Fixed64Type {
+			v = b[:n]
+		}
+		b = b[n:]
+
+		if v == nil {
+			continue
+		}
+		if err := fn(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// varintValue decodes a varint value passed by walkFields.
+func varintValue(v []byte) uint64 {
+	x, n := protowire.ConsumeVarint(v)
+	if n != len(v) {
+		return 0
+	}
+	return x
+}
+
+// doubleValue decodes a double value passed by walkFields, or returns 0 if
+// v is not 8 bytes long.
+func doubleValue(v []byte) float64 {
+	if len(v) != 8 {
+		return 0
+	}
+	x, _ := protowire.ConsumeFixed64(v)
+	return math.Float64frombits(x)
+}
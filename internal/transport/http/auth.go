@@ -0,0 +1,66 @@
+package http
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// tokenAuth checks "Authorization: Bearer" headers against a fixed set of
+// accepted tokens.
+type tokenAuth struct {
+	// hashes holds SHA-256 digests of the accepted tokens so comparisons run
+	// over equal-length inputs and don't leak token length.
+	hashes [][sha256.Size]byte
+}
+
+// newTokenAuth returns nil when no tokens are configured (auth disabled).
+func newTokenAuth(tokens []string) *tokenAuth {
+	if len(tokens) == 0 {
+		return nil
+	}
+	a := &tokenAuth{hashes: make([][sha256.Size]byte, len(tokens))}
+	for i, tok := range tokens {
+		a.hashes[i] = sha256.Sum256([]byte(tok))
+	}
+	return a
+}
+
+// valid reports whether token matches any accepted token. Every candidate is
+// compared so the time taken doesn't depend on which one matched.
+func (a *tokenAuth) valid(token string) bool {
+	h := sha256.Sum256([]byte(token))
+	match := 0
+	for _, want := range a.hashes {
+		match |= subtle.ConstantTimeCompare(h[:], want[:])
+	}
+	return match == 1
+}
+
+// wrap returns next guarded by the token check. A nil tokenAuth passes
+// requests through unchanged.
+func (a *tokenAuth) wrap(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok || !a.valid(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="switchyard"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" value.
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/audiostore"
+	"github.com/nadzzz/switchyard/internal/config"
+)
+
+func TestTokenAuthWrap(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name   string
+		tokens []string
+		header string
+		want   int
+	}{
+		{name: "missing token", tokens: []string{"secret"}, want: http.StatusUnauthorized},
+		{name: "wrong token", tokens: []string{"secret"}, header: "Bearer guess", want: http.StatusUnauthorized},
+		{name: "empty token", tokens: []string{"secret"}, header: "Bearer ", want: http.StatusUnauthorized},
+		{name: "other scheme", tokens: []string{"secret"}, header: "Basic secret", want: http.StatusUnauthorized},
+		{name: "right token", tokens: []string{"secret"}, header: "Bearer secret", want: http.StatusOK},
+		{name: "scheme is case-insensitive", tokens: []string{"secret"}, header: "bearer secret", want: http.StatusOK},
+		{name: "any accepted token", tokens: []string{"secret", "kitchen"}, header: "Bearer kitchen", want: http.StatusOK},
+		{name: "auth disabled", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/dispatch", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			newTokenAuth(tt.tokens).wrap(next).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			challenged := w.Header().Get("WWW-Authenticate") != ""
+			if challenged != (tt.want == http.StatusUnauthorized) {
+				t.Errorf("WWW-Authenticate = %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAuthExemptions(t *testing.T) {
+	store := audiostore.New(0, 0, 0)
+	id := store.Put([]byte("RIFF"), "audio/wav")
+	tr := New(config.HTTPConfig{Auth: config.HTTPAuthConfig{Token: "secret"}}, Options{AudioStore: store})
+	routes := tr.routes(okHandler)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "dispatch is guarded", method: http.MethodPost, path: "/dispatch", want: http.StatusUnauthorized},
+		{name: "ws is guarded", method: http.MethodGet, path: "/ws", want: http.StatusUnauthorized},
+		// Media players fetch result audio by its unguessable ID.
+		{name: "audio is open", method: http.MethodGet, path: "/audio/" + id, want: http.StatusOK},
+		{name: "unknown audio is not found", method: http.MethodGet, path: "/audio/nope", want: http.StatusNotFound},
+		// /healthz is served by the health server on its own port, so
+		// probes never reach the token check here.
+		{name: "healthz is not routed", method: http.MethodGet, path: "/healthz", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"text":"hi"}`))
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package http
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	for _, ct := range []string{"application/json", "application/json; charset=utf-8", "Application/JSON"} {
+		t.Run(ct, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/dispatch", strings.NewReader(`{"source":"kitchen","text":"turn on the light"}`))
+			r.Header.Set("Content-Type", ct)
+			msg, err := decodeMessage(r)
+			if err != nil {
+				t.Fatalf("decodeMessage: %v", err)
+			}
+			if msg.Text != "turn on the light" || msg.Source != "kitchen" || msg.HasAudio() {
+				t.Errorf("message = %+v, want the decoded JSON", msg)
+			}
+		})
+	}
+}
+
+func TestDecodeMultipart(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="audio"; filename="clip.ogg"`)
+	h.Set("Content-Type", "audio/ogg")
+	part, _ := w.CreatePart(h)
+	_, _ = part.Write([]byte("OggS-audio"))
+	_ = w.WriteField("instruction", `{"language":"fr","prompt":"from json"}`)
+	_ = w.WriteField("prompt", "from field")
+	_ = w.WriteField("source", "kitchen")
+	_ = w.WriteField("dry_run", "true")
+	_ = w.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/dispatch", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	msg, err := decodeMessage(r)
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	if string(msg.Audio) != "OggS-audio" || msg.ContentType != "audio/ogg" {
+		t.Errorf("audio = %q (%s)", msg.Audio, msg.ContentType)
+	}
+	in := msg.Instruction
+	if in.Language != "fr" || in.Prompt != "from field" || !in.DryRun || msg.Source != "kitchen" {
+		t.Errorf("message = %+v, want fields applied over the instruction JSON", msg)
+	}
+}
+
+func TestDecodeMultipartBadBool(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	_ = w.WriteField("text", "hi")
+	_ = w.WriteField("dry_run", "maybe")
+	_ = w.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/dispatch", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+	if _, err := decodeMessage(r); err == nil || !strings.Contains(err.Error(), "dry_run") {
+		t.Errorf("decodeMessage() = %v, want an error naming dry_run", err)
+	}
+}
+
+func TestDecodeRawAudioQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost,
+		"/dispatch?command_format=home_assistant&prompt=kitchen&language=fr&response_mode=text&source=query&dry_run=1",
+		strings.NewReader("RIFF-audio"))
+	r.Header.Set("Content-Type", "audio/wav")
+	msg, err := decodeMessage(r)
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	in := msg.Instruction
+	if in.ResponseFormat != "home_assistant" || in.Prompt != "kitchen" || in.Language != "fr" ||
+		in.ResponseMode != "text" || !in.DryRun || msg.Source != "query" {
+		t.Errorf("message = %+v, want the query parameters applied", msg)
+	}
+	if string(msg.Audio) != "RIFF-audio" || msg.ContentType != "audio/wav" {
+		t.Errorf("audio = %q (%s)", msg.Audio, msg.ContentType)
+	}
+}
+
+func TestDecodeRawAudioHeadersOverrideQuery(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/dispatch?prompt=from+query&language=fr&source=query", strings.NewReader("RIFF-audio"))
+	r.Header.Set("Content-Type", "audio/wav")
+	r.Header.Set("X-Switchyard-Instruction", `{"prompt":"from header"}`)
+	r.Header.Set("X-Switchyard-Source", "header")
+	msg, err := decodeMessage(r)
+	if err != nil {
+		t.Fatalf("decodeMessage: %v", err)
+	}
+	in := msg.Instruction
+	if in.Prompt != "from header" || msg.Source != "header" {
+		t.Errorf("prompt/source = %q/%q, want the headers' values", in.Prompt, msg.Source)
+	}
+	if in.Language != "fr" {
+		t.Errorf("language = %q, want the query value kept", in.Language)
+	}
+}
+
+func TestDecodeRawAudioBadQueryBool(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/dispatch?dry_run=maybe", strings.NewReader("RIFF-audio"))
+	r.Header.Set("Content-Type", "audio/wav")
+	if _, err := decodeMessage(r); err == nil || !strings.Contains(err.Error(), "query parameter") {
+		t.Errorf("decodeMessage error = %v, want a query parameter error", err)
+	}
+}
@@ -6,14 +6,21 @@
 package http
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"mime"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/nadzzz/switchyard/internal/async"
+	"github.com/nadzzz/switchyard/internal/audiostore"
+	"github.com/nadzzz/switchyard/internal/config"
 	"github.com/nadzzz/switchyard/internal/message"
 	"github.com/nadzzz/switchyard/internal/transport"
 
@@ -22,45 +29,65 @@ import (
 
 // Transport implements transport.Transport over HTTP and WebSocket.
 type Transport struct {
-	port   int
-	server *http.Server
+	port       int
+	maxBody    int64
+	ws         config.WebSocketConfig
+	audioStore *audiostore.Store // nil unless TTS audio is delivered by URL
+	async      *async.Queue      // nil unless async dispatch is enabled
+	auth       *tokenAuth        // nil when auth is disabled
+	stream     transport.StreamHandler
+	speech     transport.SpeechHandler
+	targets    transport.TargetAdmin
+	server     *http.Server
 }
 
-// New creates a new HTTP transport on the given port.
-func New(port int) *Transport {
-	return &Transport{port: port}
+// Options holds optional dependencies of the HTTP transport.
+type Options struct {
+	// AudioStore, if set, is served at GET /audio/{id}.
+	AudioStore *audiostore.Store
+
+	// Async, if set, accepts messages with a callback URL for background
+	// dispatch. The transport runs the queue while listening.
+	Async *async.Queue
 }
 
-// Name returns the transport identifier.
-func (t *Transport) Name() string { return "http" }
+// New creates a new HTTP transport from config.
+func New(cfg config.HTTPConfig, opts Options) *Transport {
+	return &Transport{
+		port:       cfg.Port,
+		maxBody:    cfg.MaxBodyBytes,
+		ws:         cfg.WebSocket,
+		audioStore: opts.AudioStore,
+		async:      opts.Async,
+		auth:       newTokenAuth(cfg.Auth.AcceptedTokens()),
+	}
+}
 
-// Listen starts the HTTP server and routes incoming requests to the handler.
-func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error {
-	mux := http.NewServeMux()
+// SetStreamHandler enables the /dispatch/stream endpoint.
+func (t *Transport) SetStreamHandler(handler transport.StreamHandler) { t.stream = handler }
 
-	// POST /dispatch — accepts audio or text, returns commands.
-	mux.HandleFunc("POST /dispatch", func(w http.ResponseWriter, r *http.Request) {
-		t.handleDispatch(w, r, handler)
-	})
+// SetSpeechHandler enables the /speech endpoint.
+func (t *Transport) SetSpeechHandler(handler transport.SpeechHandler) { t.speech = handler }
 
-	// GET /ws — WebSocket endpoint for streaming audio (future).
-	mux.HandleFunc("GET /ws", func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Implement WebSocket upgrade and streaming audio handling.
-		http.Error(w, "websocket not yet implemented", http.StatusNotImplemented)
-	})
+// SetTargetAdmin enables the /targets endpoints.
+func (t *Transport) SetTargetAdmin(admin transport.TargetAdmin) { t.targets = admin }
 
-	// Swagger UI — serves the generated OpenAPI docs.
-	mux.Handle("GET /swagger/", httpSwagger.Handler(
-		httpSwagger.URL("/swagger/doc.json"),
-	))
+// Name returns the transport identifier.
+func (t *Transport) Name() string { return "http" }
 
+// Listen starts the HTTP server and routes incoming requests to the handler.
+func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error {
 	t.server = &http.Server{
 		Addr:              fmt.Sprintf(":%d", t.port),
-		Handler:           mux,
+		Handler:           t.routes(handler),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	slog.Info("http transport listening", "port", t.port)
+	slog.Info("http transport listening", "port", t.port, "auth", t.auth != nil)
+
+	if t.async != nil {
+		go t.async.Run(ctx, handler)
+	}
 
 	go func() {
 		<-ctx.Done()
@@ -76,6 +103,52 @@ func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error
 	return nil
 }
 
+// routes builds the request multiplexer. Endpoints that act on messages or
+// targets are guarded by the token check; /audio/{id} and the API docs are not.
+func (t *Transport) routes(handler transport.Handler) http.Handler {
+	mux := http.NewServeMux()
+
+	// POST /dispatch — accepts audio or text, returns commands.
+	mux.Handle("POST /dispatch", t.auth.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.handleDispatch(w, r, handler)
+	})))
+
+	// GET|POST /dispatch/stream — like /dispatch, but streams each pipeline
+	// stage as a Server-Sent Event.
+	if t.stream != nil {
+		mux.Handle("GET /dispatch/stream", t.auth.wrap(http.HandlerFunc(t.handleDispatchStream)))
+		mux.Handle("POST /dispatch/stream", t.auth.wrap(http.HandlerFunc(t.handleDispatchStream)))
+	}
+
+	// POST /speech — synthesizes text directly, streaming WAV audio.
+	if t.speech != nil {
+		mux.Handle("POST /speech", t.auth.wrap(http.HandlerFunc(t.handleSpeech)))
+	}
+
+	// GET /targets, POST /targets/{name}/check — lists and probes the
+	// configured targets.
+	if t.targets != nil {
+		mux.Handle("GET /targets", t.auth.wrap(http.HandlerFunc(t.handleTargets)))
+		mux.Handle("POST /targets/{name}/check", t.auth.wrap(http.HandlerFunc(t.handleTargetCheck)))
+	}
+
+	// GET /ws — WebSocket endpoint for streaming audio.
+	mux.Handle("GET /ws", t.auth.wrap(t.websocketServer(handler)))
+
+	// GET /audio/{id} — fetches response audio stored for URL delivery.
+	// Not authenticated: IDs are random and only handed out in dispatch
+	// results, so media players can fetch them directly.
+	if t.audioStore != nil {
+		mux.HandleFunc("GET /audio/{id}", t.handleAudio)
+	}
+
+	// Swagger UI — serves the generated OpenAPI docs.
+	mux.Handle("GET /swagger/", httpSwagger.Handler(
+		httpSwagger.URL("/swagger/doc.json"),
+	))
+	return mux
+}
+
 // handleDispatch processes a POST /dispatch request.
 //
 // @Summary     Dispatch a voice or text command
@@ -84,69 +157,238 @@ func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error
 // @Description commands are routed to the configured target services.
 // @Tags        dispatch
 // @Accept      json
+// @Accept      multipart/form-data
 // @Accept      audio/wav
 // @Accept      audio/ogg
 // @Produce     json
-// @Param       message  body      message.Message  true  "Dispatch request (JSON). For raw audio, POST the bytes directly with the appropriate Content-Type."
+// @Param       message  body      message.Message  true  "Dispatch request (JSON). For raw audio, POST the bytes directly with the appropriate Content-Type, or as the \"audio\" file of a multipart form."
 // @Param       X-Switchyard-Source       header  string  false  "Sender identifier (used with raw audio uploads)"
 // @Param       X-Switchyard-Instruction  header  string  false  "JSON-encoded Instruction (used with raw audio uploads)"
-// @Success     200  {object}  message.DispatchResult  "Interpreted commands"
+// @Param       X-Switchyard-Callback-URL header  string  false  "Dispatch asynchronously and POST the result to this URL"
+// @Param       command_format            query   string  false  "Instruction response_format (raw audio uploads; X-Switchyard-Instruction takes precedence)"
+// @Param       prompt                    query   string  false  "Instruction prompt (raw audio uploads)"
+// @Param       language                  query   string  false  "Instruction language (raw audio uploads)"
+// @Param       response_mode             query   string  false  "Instruction response_mode: audio or text (raw audio uploads)"
+// @Param       source                    query   string  false  "Sender identifier (raw audio uploads; X-Switchyard-Source takes precedence)"
+// @Param       X-Request-ID              header  string  false  "Trace ID logged with the dispatch and echoed in the response (alternatively a W3C traceparent header)"
+// @Success     200  {object}  message.DispatchResult  "Interpreted commands (error_code tts_disabled if audio was required but TTS is disabled)"
+// @Success     202  {object}  map[string]string       "Accepted for async dispatch (message_id)"
 // @Failure     400  {string}  string  "Invalid request body or headers"
+// @Failure     413  {string}  string  "Request body exceeds transports.http.max_body_bytes"
+// @Failure     422  {object}  message.DispatchResult  "The message could not be processed (see error_code)"
+// @Failure     429  {string}  string  "Dispatcher at its concurrency limit (server.on_busy: reject) or sender over server.rate_limit"
 // @Failure     500  {string}  string  "Internal processing error"
+// @Failure     502  {object}  message.DispatchResult  "An interpreter backend or target failed (see error_stage and error_code)"
+// @Failure     503  {string}  string  "Async queue is full, or the server is shutting down"
 // @Router      /dispatch [post]
 func (t *Transport) handleDispatch(w http.ResponseWriter, r *http.Request, handler transport.Handler) {
+	r.Body = http.MaxBytesReader(w, r.Body, t.maxBody)
+	msg, err := decodeMessage(r)
+	if err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+	echoTraceID(w, msg)
+
+	if msg.Instruction.CallbackURL != "" {
+		t.handleAsync(w, msg)
+		return
+	}
+
+	result, err := handler(r.Context(), msg)
+	if errors.Is(err, transport.ErrBusy) || errors.Is(err, transport.ErrRateLimited) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if errors.Is(err, transport.ErrShuttingDown) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		slog.Error("dispatch failed", "message_id", msg.ID, "trace_id", msg.TraceID, "error", err)
+		http.Error(w, "dispatch error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resultStatus(result))
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// decodeErrorStatus returns 413 if err comes from a request body over the
+// size limit, and 400 for any other malformed request.
+func decodeErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// resultStatus maps a dispatch result to an HTTP status: 200 on success or
+// when only the required audio was missing because TTS is disabled, 422
+// when the message itself could not be processed (no usable input or
+// speech, malformed WAV, unknown interpreter, low-confidence audio), 502
+// when a backend or target failed, and 500 otherwise.
+func resultStatus(result *message.DispatchResult) int {
+	if result.Error == "" || result.ErrorCode == message.ErrorCodeTTSDisabled {
+		return http.StatusOK
+	}
+	switch result.ErrorCode {
+	case message.ErrorCodeNoInput, message.ErrorCodeNoInterpreter, message.ErrorCodeAudioFetch,
+		message.ErrorCodeAudioTooLarge, message.ErrorCodeInvalidAudio, message.ErrorCodeLowConfidence, message.ErrorCodeNoSpeech:
+		return http.StatusUnprocessableEntity
+	case message.ErrorCodeBackend, message.ErrorCodeTimeout, message.ErrorCodeSendFailed:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// handleAsync queues a message that carries a callback URL and answers
+// 202 Accepted with its message ID.
+func (t *Transport) handleAsync(w http.ResponseWriter, msg *message.Message) {
+	if t.async == nil {
+		http.Error(w, "async dispatch is disabled", http.StatusBadRequest)
+		return
+	}
+	if err := t.async.CheckCallback(msg.Instruction.CallbackURL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := t.async.Submit(msg); err != nil {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message_id": msg.ID})
+}
+
+// decodeMessage reads a dispatch request: a JSON message, a multipart form,
+// or raw audio with the source and instruction in query parameters or
+// headers.
+func decodeMessage(r *http.Request) (*message.Message, error) {
 	var msg message.Message
 
 	contentType := r.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
 	switch {
-	case contentType == "application/json":
+	case mediaType == "application/json":
 		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-			http.Error(w, "invalid json: "+err.Error(), http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("invalid json: %w", err)
 		}
+	case mediaType == "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, fmt.Errorf("invalid multipart body: %w", err)
+		}
+		form, err := decodeMultipart(mr)
+		if err != nil {
+			return nil, err
+		}
+		msg = *form
 	default:
-		// Treat body as raw audio; read instruction from headers.
-		audioData, err := io.ReadAll(io.LimitReader(r.Body, 25<<20)) // 25 MB limit
+		// Treat body as raw audio; read instruction from query parameters
+		// and headers, the headers taking precedence.
+		audioData, err := io.ReadAll(r.Body)
 		if err != nil {
-			http.Error(w, "reading audio: "+err.Error(), http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("reading audio: %w", err)
 		}
 		msg.Audio = audioData
 		msg.ContentType = contentType
-		msg.Source = r.Header.Get("X-Switchyard-Source")
+		if err := applyFields(&msg, queryFields(r.URL.Query()), "query parameter"); err != nil {
+			return nil, err
+		}
+		if source := r.Header.Get("X-Switchyard-Source"); source != "" {
+			msg.Source = source
+		}
 
-		// Instruction can be passed as a JSON header.
+		// Instruction can be passed as a JSON header. Its fields replace
+		// those set from the query; the others are kept.
 		if instrHeader := r.Header.Get("X-Switchyard-Instruction"); instrHeader != "" {
 			if err := json.Unmarshal([]byte(instrHeader), &msg.Instruction); err != nil {
-				http.Error(w, "invalid instruction header: "+err.Error(), http.StatusBadRequest)
-				return
+				return nil, fmt.Errorf("invalid instruction header: %w", err)
 			}
 		}
 	}
+	if cb := r.Header.Get("X-Switchyard-Callback-URL"); cb != "" {
+		msg.Instruction.CallbackURL = cb
+	}
+	if id := requestTraceID(r); id != "" {
+		msg.TraceID = id
+	}
+	return &msg, nil
+}
 
-	result, err := handler(r.Context(), &msg)
-	if err != nil {
-		slog.Error("dispatch failed", "error", err)
-		http.Error(w, "dispatch error: "+err.Error(), http.StatusInternalServerError)
+// queryFields returns the first value of each query parameter.
+func queryFields(query url.Values) map[string]string {
+	fields := make(map[string]string, len(query))
+	for name, values := range query {
+		if len(values) > 0 {
+			fields[name] = values[0]
+		}
+	}
+	return fields
+}
+
+// requestTraceID returns the trace ID from the X-Request-ID or traceparent
+// header of r, or "".
+func requestTraceID(r *http.Request) string {
+	return transport.TraceID(r.Header.Get("X-Request-ID"), r.Header.Get("traceparent"))
+}
+
+// echoTraceID returns msg's trace ID to the client in X-Request-ID.
+func echoTraceID(w http.ResponseWriter, msg *message.Message) {
+	if msg.TraceID != "" {
+		w.Header().Set("X-Request-ID", msg.TraceID)
+	}
+}
+
+// handleAudio serves a stored response audio clip.
+//
+// @Summary     Fetch synthesized response audio
+// @Description Returns the audio referenced by a dispatch result's response_audio_url.
+// @Description Clips expire after the configured TTL.
+// @Tags        dispatch
+// @Produce     audio/wav
+// @Param       id   path      string  true  "Audio ID from response_audio_url"
+// @Success     200  {file}    binary  "Audio clip"
+// @Success     206  {file}    binary  "Requested byte range of the clip"
+// @Failure     404  {string}  string  "Unknown or expired audio ID"
+// @Router      /audio/{id} [get]
+func (t *Transport) handleAudio(w http.ResponseWriter, r *http.Request) {
+	entry, ok := t.audioStore.Get(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "audio not found or expired", http.StatusNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(result)
+	// Clips never change, so clients may cache them until they expire.
+	// ServeContent sets Content-Length and answers Range requests, which
+	// browsers' audio elements use to seek and stream.
+	maxAge := int(time.Until(entry.Expires).Seconds())
+	w.Header().Set("Content-Type", entry.ContentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d, immutable", max(maxAge, 0)))
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(entry.Data))
 }
 
 // Send delivers a payload to an HTTP target via POST.
 func (t *Transport) Send(ctx context.Context, target message.Target, payload []byte) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Endpoint, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Endpoint, bytes.NewReader(payload))
 	if err != nil {
 		return fmt.Errorf("http send: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-
-	// Attach the payload.
-	req.Body = io.NopCloser(io.LimitReader(
-		io.NopCloser(jsonReader(payload)), 25<<20,
-	))
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+	if target.Token != "" {
+		header, value := target.Authorization()
+		req.Header.Set(header, value)
+	}
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -163,6 +405,45 @@ func (t *Transport) Send(ctx context.Context, target message.Target, payload []b
 	return nil
 }
 
+// Probe checks that an HTTP target answers and accepts its credentials with
+// a HEAD request, or OPTIONS if HEAD is not allowed. Authentication failures
+// (401, 403) and server errors fail the probe; other statuses show the
+// server is reachable, since targets often only handle POST.
+func (t *Transport) Probe(ctx context.Context, target message.Target) (string, error) {
+	var status int
+	for _, method := range []string{http.MethodHead, http.MethodOptions} {
+		req, err := http.NewRequestWithContext(ctx, method, target.Endpoint, nil)
+		if err != nil {
+			return "", fmt.Errorf("http probe: %w", err)
+		}
+		for key, value := range target.Headers {
+			req.Header.Set(key, value)
+		}
+		if target.Token != "" {
+			header, value := target.Authorization()
+			req.Header.Set(header, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("http probe: %w", err)
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		detail := fmt.Sprintf("%s %d", method, status)
+		switch {
+		case status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented:
+			continue
+		case status == http.StatusUnauthorized || status == http.StatusForbidden:
+			return detail, fmt.Errorf("http probe: credentials rejected (status %d)", status)
+		case status >= 500:
+			return detail, fmt.Errorf("http probe: server error (status %d)", status)
+		}
+		return detail, nil
+	}
+	// Neither method is allowed, but the server answered.
+	return fmt.Sprintf("OPTIONS %d", status), nil
+}
+
 // Close gracefully shuts down the HTTP server.
 func (t *Transport) Close() error {
 	if t.server != nil {
@@ -172,24 +453,3 @@ func (t *Transport) Close() error {
 	}
 	return nil
 }
-
-// jsonReader wraps a byte slice as an io.Reader.
-func jsonReader(data []byte) io.ReadCloser {
-	return io.NopCloser(io.LimitReader(
-		&byteReader{data: data}, int64(len(data)),
-	))
-}
-
-type byteReader struct {
-	data []byte
-	pos  int
-}
-
-func (r *byteReader) Read(p []byte) (int, error) {
-	if r.pos >= len(r.data) {
-		return 0, io.EOF
-	}
-	n := copy(p, r.data[r.pos:])
-	r.pos += n
-	return n, nil
-}
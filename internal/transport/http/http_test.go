@@ -0,0 +1,134 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/audiostore"
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// okHandler answers every message with an empty successful result.
+func okHandler(_ context.Context, msg *message.Message) (*message.DispatchResult, error) {
+	return &message.DispatchResult{MessageID: msg.ID}, nil
+}
+
+func multipartBody(audio []byte) (string, *bytes.Buffer) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, _ := w.CreateFormFile("audio", "clip.wav")
+	_, _ = part.Write(audio)
+	_ = w.Close()
+	return w.FormDataContentType(), &body
+}
+
+func TestDispatchBodyLimit(t *testing.T) {
+	const limit = 1024
+	tr := New(config.HTTPConfig{MaxBodyBytes: limit}, Options{})
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        func(size int) *bytes.Buffer
+	}{
+		{"json", "application/json", func(size int) *bytes.Buffer {
+			return bytes.NewBufferString(`{"text":"` + strings.Repeat("a", size) + `"}`)
+		}},
+		{"raw audio", "audio/wav", func(size int) *bytes.Buffer {
+			return bytes.NewBuffer(make([]byte, size))
+		}},
+	}
+	for _, tt := range tests {
+		for _, c := range []struct {
+			size int
+			want int
+		}{{limit / 2, http.StatusOK}, {limit * 2, http.StatusRequestEntityTooLarge}} {
+			r := httptest.NewRequest(http.MethodPost, "/dispatch", tt.body(c.size))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			tr.handleDispatch(w, r, okHandler)
+			if w.Code != c.want {
+				t.Errorf("%s body of %d bytes: status %d, want %d", tt.name, c.size, w.Code, c.want)
+			}
+		}
+	}
+
+	ct, body := multipartBody(make([]byte, limit*2))
+	r := httptest.NewRequest(http.MethodPost, "/dispatch", body)
+	r.Header.Set("Content-Type", ct)
+	w := httptest.NewRecorder()
+	tr.handleDispatch(w, r, okHandler)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("multipart body over the limit: status %d, want 413", w.Code)
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	tests := []struct {
+		code string
+		want int
+	}{
+		{"", http.StatusOK},
+		{message.ErrorCodeTTSDisabled, http.StatusOK},
+		{message.ErrorCodeNoSpeech, http.StatusUnprocessableEntity},
+		{message.ErrorCodeSendFailed, http.StatusBadGateway},
+		{message.ErrorCodeTransformFailed, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		result := &message.DispatchResult{}
+		if tt.code != "" {
+			result.Fail(message.ErrorStageInput, tt.code, "failed")
+		}
+		if got := resultStatus(result); got != tt.want {
+			t.Errorf("resultStatus(%q) = %d, want %d", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestHandleAudio(t *testing.T) {
+	store := audiostore.New(time.Minute, 0, 0)
+	wav := store.Put([]byte("RIFF....WAVE"), "audio/wav")
+	ogg := store.Put([]byte("OggS"), "audio/ogg")
+	tr := New(config.HTTPConfig{}, Options{AudioStore: store})
+	routes := tr.routes(okHandler)
+
+	tests := []struct {
+		name        string
+		id          string
+		status      int
+		contentType string
+		body        string
+	}{
+		{name: "wav", id: wav, status: http.StatusOK, contentType: "audio/wav", body: "RIFF....WAVE"},
+		{name: "ogg", id: ogg, status: http.StatusOK, contentType: "audio/ogg", body: "OggS"},
+		{name: "unknown", id: "0123456789abcdef", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audio/"+tt.id, nil))
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if !strings.HasPrefix(w.Header().Get("Cache-Control"), "private, max-age=") {
+				t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+			}
+			if w.Body.String() != tt.body {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// maxFieldBytes caps each non-file form field.
+const maxFieldBytes = 64 << 10
+
+// decodeMultipart reads a multipart/form-data dispatch request. The "audio"
+// file part holds the audio (alternatively, a "text" field holds text input).
+// An "instruction" field may carry the whole instruction as JSON; the
+// individual fields listed at applyFields are applied on top of it.
+func decodeMultipart(mr *multipart.Reader) (*message.Message, error) {
+	var msg message.Message
+	fields := map[string]string{}
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading multipart body: %w", err)
+		}
+		name := part.FormName()
+		if name == "audio" {
+			// The request body limit bounds the audio.
+			data, err := io.ReadAll(part)
+			if err != nil {
+				return nil, fmt.Errorf("reading audio: %w", err)
+			}
+			msg.Audio = data
+			msg.ContentType = part.Header.Get("Content-Type")
+			continue
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxFieldBytes+1))
+		if err != nil {
+			return nil, fmt.Errorf("reading form field %q: %w", name, err)
+		}
+		if len(value) > maxFieldBytes {
+			return nil, fmt.Errorf("form field %q exceeds %d bytes", name, maxFieldBytes)
+		}
+		fields[name] = string(value)
+	}
+
+	if raw := fields["instruction"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &msg.Instruction); err != nil {
+			return nil, fmt.Errorf("invalid instruction field: %w", err)
+		}
+	}
+	msg.Text = fields["text"]
+	if err := applyFields(&msg, fields, "form field"); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// applyFields sets the message and instruction fields named in fields,
+// leaving the others as they are. kind names the fields in errors (e.g.,
+// "form field"). The recognized names are:
+//
+//	source, session_id, response_format (or command_format), prompt,
+//	language, response_language, response_mode, interpreter,
+//	completion_model, callback_url, translate, skip_interpretation,
+//	dry_run, sequential
+func applyFields(msg *message.Message, fields map[string]string, kind string) error {
+	set := func(dst *string, names ...string) {
+		for _, n := range names {
+			if v, ok := fields[n]; ok {
+				*dst = v
+			}
+		}
+	}
+	set(&msg.Source, "source")
+	set(&msg.SessionID, "session_id")
+	set(&msg.Instruction.ResponseFormat, "response_format", "command_format")
+	set(&msg.Instruction.Prompt, "prompt")
+	set(&msg.Instruction.Language, "language")
+	set(&msg.Instruction.ResponseLanguage, "response_language")
+	set(&msg.Instruction.ResponseMode, "response_mode")
+	set(&msg.Instruction.Interpreter, "interpreter")
+	set(&msg.Instruction.CompletionModel, "completion_model")
+	set(&msg.Instruction.CallbackURL, "callback_url")
+	for name, dst := range map[string]*bool{
+		"translate":           &msg.Instruction.Translate,
+		"skip_interpretation": &msg.Instruction.SkipInterpretation,
+		"dry_run":             &msg.Instruction.DryRun,
+		"sequential":          &msg.Instruction.Sequential,
+	} {
+		if v, ok := fields[name]; ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s %q: %w", kind, name, err)
+			}
+			*dst = b
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// received is one request seen by a recording target.
+type received struct {
+	header        http.Header
+	body          []byte
+	contentLength int64
+}
+
+// recordingTarget serves handler behind a recorder of every request it gets.
+func recordingTarget(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *[]received) {
+	t.Helper()
+	var reqs []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		reqs = append(reqs, received{header: r.Header.Clone(), body: body, contentLength: r.ContentLength})
+		if handler != nil {
+			handler(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestSendBody(t *testing.T) {
+	srv, reqs := recordingTarget(t, nil)
+	payload := []byte(`{"commands":[{"action":"turn_on"}]}`)
+
+	tr := New(config.HTTPConfig{}, Options{})
+	if err := tr.Send(context.Background(), message.Target{Endpoint: srv.URL}, payload); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(*reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(*reqs))
+	}
+	got := (*reqs)[0]
+	if !bytes.Equal(got.body, payload) {
+		t.Errorf("body = %q, want %q", got.body, payload)
+	}
+	if got.contentLength != int64(len(payload)) {
+		t.Errorf("Content-Length = %d, want %d", got.contentLength, len(payload))
+	}
+	if ct := got.header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+}
+
+func TestSendReplaysBodyOnRedirect(t *testing.T) {
+	srv, reqs := recordingTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusTemporaryRedirect)
+		}
+	})
+	payload := []byte(`{"response_text":"ok"}`)
+
+	tr := New(config.HTTPConfig{}, Options{})
+	if err := tr.Send(context.Background(), message.Target{Endpoint: srv.URL + "/old"}, payload); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(*reqs) != 2 {
+		t.Fatalf("got %d requests, want the original and the redirected one", len(*reqs))
+	}
+	if got := (*reqs)[1].body; !bytes.Equal(got, payload) {
+		t.Errorf("redirected body = %q, want %q", got, payload)
+	}
+}
+
+func TestSendErrorStatus(t *testing.T) {
+	srv, _ := recordingTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	tr := New(config.HTTPConfig{}, Options{})
+	if err := tr.Send(context.Background(), message.Target{Endpoint: srv.URL}, []byte(`{}`)); err == nil {
+		t.Fatal("Send succeeded on a 500")
+	}
+}
+
+func TestSendAuthHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       message.Target
+		header, want string
+	}{
+		{"bearer by default", message.Target{Token: "s3cret"}, "Authorization", "Bearer s3cret"},
+		{"custom scheme", message.Target{Token: "s3cret", AuthScheme: "Token"}, "Authorization", "Token s3cret"},
+		{"custom header sends the bare token", message.Target{Token: "s3cret", AuthHeader: "X-Api-Key"}, "X-Api-Key", "s3cret"},
+		{"custom header and scheme", message.Target{Token: "s3cret", AuthHeader: "X-Auth", AuthScheme: "Key"}, "X-Auth", "Key s3cret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, reqs := recordingTarget(t, nil)
+			tt.target.Endpoint = srv.URL
+			tr := New(config.HTTPConfig{}, Options{})
+			if err := tr.Send(context.Background(), tt.target, []byte(`{}`)); err != nil {
+				t.Fatalf("Send: %v", err)
+			}
+			if got := (*reqs)[0].header.Get(tt.header); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendWithoutToken(t *testing.T) {
+	srv, reqs := recordingTarget(t, nil)
+	tr := New(config.HTTPConfig{}, Options{})
+	if err := tr.Send(context.Background(), message.Target{Endpoint: srv.URL}, []byte(`{}`)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := (*reqs)[0].header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization = %q, want none", got)
+	}
+}
+
+func TestSendTargetHeaders(t *testing.T) {
+	srv, reqs := recordingTarget(t, nil)
+	tr := New(config.HTTPConfig{}, Options{})
+	target := message.Target{
+		Endpoint: srv.URL,
+		Token:    "s3cret",
+		Headers:  map[string]string{"X-Tenant": "home", "Authorization": "overridden"},
+	}
+	if err := tr.Send(context.Background(), target, []byte(`{}`)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	h := (*reqs)[0].header
+	if got := h.Get("X-Tenant"); got != "home" {
+		t.Errorf("X-Tenant = %q, want home", got)
+	}
+	if got := h.Get("Authorization"); got != "Bearer s3cret" {
+		t.Errorf("Authorization = %q, want the token to win over headers", got)
+	}
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// handleSpeech synthesizes the text of a JSON speech request and returns the
+// audio. WAV at the voice's native rate is sent with chunked encoding as the
+// TTS backend produces it, behind a header whose lengths are left unknown;
+// other formats and sample rates are sent once synthesized.
+//
+// Errors before the first audio byte get a normal error status. Once audio
+// is flowing the status is already sent, so a failure ends the response early.
+//
+// @Summary     Synthesize speech
+// @Description Converts text to speech with the configured TTS backend, without interpretation or routing.
+// @Description WAV output at the voice's native rate is streamed as it is synthesized.
+// @Tags        speech
+// @Accept      json
+// @Produce     audio/wav
+// @Produce     audio/mpeg
+// @Produce     audio/ogg
+// @Param       request  body      transport.SpeechRequest  true  "Text and synthesis options"
+// @Success     200      {file}    binary  "Synthesized audio"
+// @Failure     400      {string}  string  "Invalid request body"
+// @Failure     413      {string}  string  "Request body exceeds transports.http.max_body_bytes"
+// @Failure     500      {string}  string  "Synthesis failed"
+// @Failure     501      {string}  string  "TTS is disabled"
+// @Failure     503      {string}  string  "The server is shutting down"
+// @Router      /speech [post]
+func (t *Transport) handleSpeech(w http.ResponseWriter, r *http.Request) {
+	var req transport.SpeechRequest
+	r.Body = http.MaxBytesReader(w, r.Body, t.maxBody)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body: "+err.Error(), decodeErrorStatus(err))
+		return
+	}
+	if req.Text == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	contentType := audio.ContentType(req.AudioFormat)
+	if contentType == "" {
+		http.Error(w, "unsupported audio_format "+req.AudioFormat, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	fw := &flushWriter{w: w, rc: http.NewResponseController(w)}
+	err := t.speech(r.Context(), req, fw)
+	switch {
+	case err == nil:
+	case fw.n > 0:
+		slog.Error("speech synthesis failed mid-stream", "bytes_sent", fw.n, "error", err)
+	case errors.Is(err, transport.ErrTTSDisabled):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+	case errors.Is(err, transport.ErrShuttingDown):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		slog.Error("speech synthesis failed", "error", err)
+		http.Error(w, "synthesis error: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// flushWriter flushes each write to the client and counts the bytes sent.
+type flushWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+	n  int
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.n += n
+	if err != nil {
+		return n, err
+	}
+	_ = f.rc.Flush()
+	return n, nil
+}
@@ -0,0 +1,139 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// handleDispatchStream processes a dispatch request and streams progress as
+// Server-Sent Events.
+//
+// POST accepts the same bodies as /dispatch. GET is for EventSource clients,
+// which cannot send a body: the message is read from the "text", "source" and
+// "instruction" (JSON) query parameters.
+//
+// Events are emitted as each pipeline stage completes: "transcript",
+// "commands", "response_text" and "response_audio" (when TTS is enabled),
+// followed by a final "result" event holding the full dispatch result, or an
+// "error" event if the dispatch could not run.
+//
+// @Summary     Dispatch a command and stream progress
+// @Description Same input as POST /dispatch. Responds with text/event-stream events for each pipeline stage:
+// @Description transcript, commands, response_text, response_audio, then result (or error).
+// @Tags        dispatch
+// @Accept      json
+// @Accept      audio/wav
+// @Produce     text/event-stream
+// @Param       text         query  string  false  "Text to interpret (GET only)"
+// @Param       source       query  string  false  "Sender identifier (GET only)"
+// @Param       instruction  query  string  false  "JSON-encoded Instruction (GET only)"
+// @Success     200  {string}  string  "Event stream"
+// @Failure     400  {string}  string  "Invalid request body, headers or query"
+// @Failure     413  {string}  string  "Request body exceeds transports.http.max_body_bytes"
+// @Router      /dispatch/stream [post]
+func (t *Transport) handleDispatchStream(w http.ResponseWriter, r *http.Request) {
+	var (
+		msg *message.Message
+		err error
+	)
+	if r.Method == http.MethodGet {
+		msg, err = decodeQueryMessage(r)
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, t.maxBody)
+		msg, err = decodeMessage(r)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), decodeErrorStatus(err))
+		return
+	}
+	echoTraceID(w, msg)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	send := func(event string, data any) {
+		if err := writeEvent(w, event, data); err != nil {
+			slog.Debug("sse write failed", "event", event, "error", err)
+			return
+		}
+		_ = rc.Flush()
+	}
+
+	audioSent := false
+	result, err := t.stream(r.Context(), msg, func(stage string, res *message.DispatchResult) {
+		if stage == transport.StageResponseAudio {
+			audioSent = true
+		}
+		send(stage, stageData(stage, res))
+	})
+	if err != nil {
+		slog.Error("dispatch failed", "message_id", msg.ID, "trace_id", msg.TraceID, "error", err)
+		send("error", map[string]string{"error": err.Error()})
+		return
+	}
+
+	// The audio was already streamed; don't send it twice.
+	final := *result
+	if audioSent {
+		final.ResponseAudio = nil
+	}
+	send("result", &final)
+}
+
+// decodeQueryMessage reads a text message from the query string.
+func decodeQueryMessage(r *http.Request) (*message.Message, error) {
+	q := r.URL.Query()
+	msg := &message.Message{
+		Source: q.Get("source"),
+		Text:   q.Get("text"),
+	}
+	msg.TraceID = requestTraceID(r)
+	if instr := q.Get("instruction"); instr != "" {
+		if err := json.Unmarshal([]byte(instr), &msg.Instruction); err != nil {
+			return nil, fmt.Errorf("invalid instruction parameter: %w", err)
+		}
+	}
+	return msg, nil
+}
+
+// stageData selects the fields of the partial result that a stage produced.
+func stageData(stage string, res *message.DispatchResult) any {
+	switch stage {
+	case transport.StageTranscript:
+		return map[string]any{"transcript": res.Transcript, "language": res.Language, "segments": res.Segments}
+	case transport.StageCommands:
+		return map[string]any{"commands": res.Commands}
+	case transport.StageResponseText:
+		return map[string]any{"response_text": res.ResponseText}
+	case transport.StageResponseAudio:
+		data := map[string]any{
+			"response_content_type":      res.ResponseContentType,
+			"response_audio_duration_ms": res.ResponseAudioDurationMs,
+		}
+		if res.ResponseAudioURL != "" {
+			data["response_audio_url"] = res.ResponseAudioURL
+		} else {
+			data["response_audio"] = res.ResponseAudio
+		}
+		return data
+	}
+	return res
+}
+
+// writeEvent writes one SSE event with a JSON data line.
+func writeEvent(w http.ResponseWriter, event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("encoding %s event: %w", event, err)
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b)
+	return err
+}
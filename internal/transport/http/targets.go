@@ -0,0 +1,55 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// handleTargets lists the configured targets without their credentials.
+//
+// @Summary     List targets
+// @Description Returns the server-configured targets. Tokens and header values are never shown.
+// @Tags        targets
+// @Produce     json
+// @Success     200  {array}   transport.TargetInfo  "Configured targets, sorted by name"
+// @Failure     401  {string}  string  "Missing or invalid bearer token"
+// @Router      /targets [get]
+func (t *Transport) handleTargets(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t.targets.Targets())
+}
+
+// handleTargetCheck probes one configured target without sending it a
+// command. A failed probe is a 502 with the check in the body, so scripts
+// can tell it apart from an unknown target.
+//
+// @Summary     Check a target
+// @Description Probes a configured target with its protocol's sender: HEAD (or OPTIONS) with the target's credentials for http,
+// @Description a gRPC health check for grpc, the broker connection for mqtt. Nothing is delivered.
+// @Tags        targets
+// @Produce     json
+// @Param       name  path      string  true  "Configured target name"
+// @Success     200   {object}  transport.TargetCheck  "Probe passed (status ok), or the protocol cannot be probed (status unsupported)"
+// @Failure     401   {string}  string  "Missing or invalid bearer token"
+// @Failure     404   {string}  string  "No configured target has this name"
+// @Failure     502   {object}  transport.TargetCheck  "Probe failed (status error)"
+// @Router      /targets/{name}/check [post]
+func (t *Transport) handleTargetCheck(w http.ResponseWriter, r *http.Request) {
+	check, err := t.targets.CheckTarget(r.Context(), r.PathValue("name"))
+	if errors.Is(err, transport.ErrUnknownTarget) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "target check error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if check.Status == transport.TargetError {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	_ = json.NewEncoder(w).Encode(check)
+}
@@ -0,0 +1,226 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// maxFrameBytes bounds the size of a single incoming WebSocket frame.
+const maxFrameBytes = 1 << 20
+
+// wsEvent is a JSON control frame exchanged over the /ws endpoint.
+//
+// Client → server:
+//
+//	{"type": "start", "source": "...", "instruction": {...}}  (optional, before audio)
+//	{"type": "end"}                                           (end of utterance)
+//
+// Server → client:
+//
+//	{"type": "result", "result": {...}}
+//	{"type": "error", "error": "..."}
+type wsEvent struct {
+	Type        string                  `json:"type"`
+	Source      string                  `json:"source,omitempty"`
+	Instruction *message.Instruction    `json:"instruction,omitempty"`
+	Result      *message.DispatchResult `json:"result,omitempty"`
+	Error       string                  `json:"error,omitempty"`
+}
+
+// wsFrame is a single frame received from the client.
+type wsFrame struct {
+	binary bool
+	data   []byte
+}
+
+// frameCodec receives frames while preserving whether they were text or binary.
+var frameCodec = websocket.Codec{
+	Marshal: func(v any) ([]byte, byte, error) {
+		data, err := json.Marshal(v)
+		return data, websocket.TextFrame, err
+	},
+	Unmarshal: func(data []byte, payloadType byte, v any) error {
+		f, ok := v.(*wsFrame)
+		if !ok {
+			return fmt.Errorf("unexpected frame target %T", v)
+		}
+		f.binary = payloadType == websocket.BinaryFrame
+		f.data = data
+		return nil
+	},
+}
+
+// errBufferFull is reported to the client when on_overflow is "error".
+var errBufferFull = errors.New("audio buffer limit exceeded")
+
+// websocketServer returns the handler for GET /ws.
+//
+// Binary frames carry 16-bit little-endian mono PCM at the configured sample
+// rate. Audio is buffered until the client sends an "end" frame, no audio
+// arrives for the silence timeout, or the buffer cap is reached. Each
+// utterance is wrapped in WAV, dispatched, and answered with a "result" frame;
+// the connection stays open for further utterances.
+func (t *Transport) websocketServer(handler transport.Handler) http.Handler {
+	return websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+			ws.MaxPayloadBytes = maxFrameBytes
+			t.serveWebSocket(ws.Request().Context(), ws, handler)
+		},
+	}
+}
+
+// bufferCap returns the most PCM bytes buffered per utterance, by whichever
+// of cfg's limits is tighter, or 0 if neither is set. The cap is a whole
+// number of 16-bit samples, and at least one, so an utterance finalized at
+// the cap never ends mid-sample.
+func bufferCap(cfg config.WebSocketConfig, sampleRate int) int {
+	maxBytes := cfg.MaxBufferBytes
+	if cfg.MaxDuration > 0 {
+		durBytes := int(cfg.MaxDuration.Seconds() * float64(sampleRate*2))
+		if maxBytes <= 0 || durBytes < maxBytes {
+			maxBytes = durBytes
+		}
+	}
+	if cfg.MaxBufferBytes <= 0 && cfg.MaxDuration <= 0 {
+		return 0
+	}
+	return max(maxBytes&^1, 2)
+}
+
+func (t *Transport) serveWebSocket(ctx context.Context, ws *websocket.Conn, handler transport.Handler) {
+	cfg := t.ws
+	sampleRate := cfg.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 16000
+	}
+	silence := cfg.SilenceTimeout
+	if silence <= 0 {
+		silence = time.Second
+	}
+
+	maxBytes := bufferCap(cfg, sampleRate)
+
+	frames := make(chan wsFrame)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			var f wsFrame
+			if err := frameCodec.Receive(ws, &f); err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case frames <- f:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	msg := &message.Message{
+		Source:  ws.Request().URL.Query().Get("source"),
+		TraceID: requestTraceID(ws.Request()), // shared by the connection's utterances
+	}
+	var pcm []byte
+
+	finalize := func(reason string) {
+		if len(pcm) == 0 {
+			return
+		}
+		slog.Debug("websocket utterance complete", "reason", reason, "pcm_bytes", len(pcm),
+			"duration", audio.PCMDuration(len(pcm), sampleRate, 1, 2))
+
+		m := *msg
+		m.Audio = audio.EncodeWAV(pcm, sampleRate, 1, 2)
+		m.ContentType = "audio/wav"
+		m.Timestamp = time.Now()
+		pcm = nil
+
+		result, err := handler(ctx, &m)
+		if err != nil {
+			slog.Error("websocket dispatch failed", "error", err)
+			_ = frameCodec.Send(ws, wsEvent{Type: "error", Error: "dispatch error: " + err.Error()})
+			return
+		}
+		_ = frameCodec.Send(ws, wsEvent{Type: "result", Result: result})
+	}
+
+	silenceTimer := time.NewTimer(silence)
+	silenceTimer.Stop()
+	defer silenceTimer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err := <-readErr:
+			if !errors.Is(err, io.EOF) {
+				slog.Debug("websocket read error", "error", err)
+			}
+			return
+
+		case <-silenceTimer.C:
+			finalize("silence")
+
+		case f := <-frames:
+			if !f.binary {
+				var evt wsEvent
+				if err := json.Unmarshal(f.data, &evt); err != nil {
+					_ = frameCodec.Send(ws, wsEvent{Type: "error", Error: "invalid control frame: " + err.Error()})
+					continue
+				}
+				switch evt.Type {
+				case "start":
+					if evt.Source != "" {
+						msg.Source = evt.Source
+					}
+					if evt.Instruction != nil {
+						msg.Instruction = *evt.Instruction
+					}
+				case "end":
+					silenceTimer.Stop()
+					finalize("end")
+				default:
+					_ = frameCodec.Send(ws, wsEvent{Type: "error", Error: "unknown control frame type: " + evt.Type})
+				}
+				continue
+			}
+
+			data := f.data
+			for len(data) > 0 {
+				room := len(data)
+				if maxBytes > 0 && len(pcm)+room > maxBytes {
+					room = maxBytes - len(pcm)
+				}
+				pcm = append(pcm, data[:room]...)
+				data = data[room:]
+
+				if maxBytes > 0 && len(pcm) >= maxBytes {
+					if cfg.OnOverflow == "error" {
+						slog.Warn("websocket audio buffer limit exceeded, closing", "max_bytes", maxBytes)
+						_ = frameCodec.Send(ws, wsEvent{Type: "error", Error: errBufferFull.Error()})
+						return
+					}
+					slog.Warn("websocket audio buffer limit reached, finalizing early", "max_bytes", maxBytes)
+					finalize("buffer_limit")
+				}
+			}
+			silenceTimer.Reset(silence)
+		}
+	}
+}
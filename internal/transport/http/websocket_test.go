@@ -0,0 +1,132 @@
+package http
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+func TestBufferCap(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.WebSocketConfig
+		want int
+	}{
+		{"no cap", config.WebSocketConfig{}, 0},
+		{"bytes", config.WebSocketConfig{MaxBufferBytes: 1000}, 1000},
+		{"odd bytes round down", config.WebSocketConfig{MaxBufferBytes: 1001}, 1000},
+		{"one byte is one sample", config.WebSocketConfig{MaxBufferBytes: 1}, 2},
+		{"duration", config.WebSocketConfig{MaxDuration: time.Second}, 32000},
+		{"tighter of both", config.WebSocketConfig{MaxBufferBytes: 64000, MaxDuration: time.Second}, 32000},
+		{"tiny duration is one sample", config.WebSocketConfig{MaxDuration: time.Nanosecond}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bufferCap(tt.cfg, 16000); got != tt.want {
+				t.Errorf("bufferCap = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// dialWebSocket serves /ws with cfg and handler and connects to it.
+func dialWebSocket(t *testing.T, cfg config.WebSocketConfig, handler func(context.Context, *message.Message) (*message.DispatchResult, error)) *websocket.Conn {
+	t.Helper()
+	tr := New(config.HTTPConfig{WebSocket: cfg}, Options{})
+	srv := httptest.NewServer(tr.websocketServer(handler))
+	t.Cleanup(srv.Close)
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", srv.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	_ = ws.SetDeadline(time.Now().Add(5 * time.Second))
+	return ws
+}
+
+func TestWebSocketFinalizesAtBufferCap(t *testing.T) {
+	var mu sync.Mutex
+	var utterances [][]byte
+	handler := func(ctx context.Context, msg *message.Message) (*message.DispatchResult, error) {
+		_, pcm, err := audio.ParseWAV(msg.Audio)
+		if err != nil {
+			t.Errorf("dispatched audio is not WAV: %v", err)
+		}
+		mu.Lock()
+		utterances = append(utterances, pcm)
+		mu.Unlock()
+		return &message.DispatchResult{MessageID: "m"}, nil
+	}
+	ws := dialWebSocket(t, config.WebSocketConfig{
+		SampleRate:     16000,
+		SilenceTimeout: time.Minute,
+		MaxBufferBytes: 1001,
+		OnOverflow:     "finalize",
+	}, handler)
+
+	// 2500 bytes past a 1000-byte cap: two full utterances, the rest
+	// dispatched at "end".
+	if err := websocket.Message.Send(ws, make([]byte, 2500)); err != nil {
+		t.Fatal(err)
+	}
+	if err := websocket.Message.Send(ws, `{"type":"end"}`); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		var evt wsEvent
+		if err := websocket.JSON.Receive(ws, &evt); err != nil {
+			t.Fatalf("receiving result %d: %v", i, err)
+		}
+		if evt.Type != "result" {
+			t.Fatalf("event %d = %+v, want a result", i, evt)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var sizes []int
+	for _, pcm := range utterances {
+		sizes = append(sizes, len(pcm))
+	}
+	if len(sizes) != 3 || sizes[0] != 1000 || sizes[1] != 1000 || sizes[2] != 500 {
+		t.Errorf("utterance sizes = %v, want [1000 1000 500]", sizes)
+	}
+}
+
+func TestWebSocketOverflowError(t *testing.T) {
+	handler := func(ctx context.Context, msg *message.Message) (*message.DispatchResult, error) {
+		t.Error("handler called for an overflowing utterance")
+		return &message.DispatchResult{}, nil
+	}
+	ws := dialWebSocket(t, config.WebSocketConfig{
+		SampleRate:     16000,
+		SilenceTimeout: time.Minute,
+		MaxDuration:    10 * time.Millisecond, // 320 bytes
+		OnOverflow:     "error",
+	}, handler)
+
+	if err := websocket.Message.Send(ws, make([]byte, 400)); err != nil {
+		t.Fatal(err)
+	}
+	var evt wsEvent
+	if err := websocket.JSON.Receive(ws, &evt); err != nil {
+		t.Fatal(err)
+	}
+	if evt.Type != "error" || evt.Error != errBufferFull.Error() {
+		t.Fatalf("event = %+v, want a buffer limit error", evt)
+	}
+	var next wsEvent
+	if err := websocket.JSON.Receive(ws, &next); err == nil {
+		t.Errorf("connection still open after overflow, got %+v", next)
+	}
+}
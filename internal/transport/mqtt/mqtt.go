@@ -7,44 +7,263 @@ package mqtt
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+
+	"github.com/nadzzz/switchyard/internal/config"
 	"github.com/nadzzz/switchyard/internal/message"
 	"github.com/nadzzz/switchyard/internal/transport"
 )
 
-// Transport implements transport.Transport over MQTT.
+// Reply modes.
+const (
+	// ReplyResponseTopic replies to the MQTT v5 Response Topic property of
+	// each request, falling back to the reply topic template without one.
+	ReplyResponseTopic = "response_topic"
+
+	// ReplyTemplate always replies to the reply topic template.
+	ReplyTemplate = "template"
+)
+
+// Transport implements transport.Transport over MQTT (v5).
 type Transport struct {
-	broker string
-	topic  string
+	broker   string
+	topic    string
+	clientID string
+	qos      byte
+	username string
+	password string
+	tls      config.MQTTTLSConfig
+	reply    config.MQTTReplyConfig
+
+	mu sync.Mutex
+	cm *autopaho.ConnectionManager // nil until Listen connects
 }
 
-// New creates a new MQTT transport.
-func New(broker, topic string) *Transport {
-	return &Transport{broker: broker, topic: topic}
+// New creates a new MQTT transport from config.
+func New(cfg config.MQTTConfig) *Transport {
+	return &Transport{
+		broker:   cfg.Broker,
+		topic:    cfg.Topic,
+		clientID: cfg.ClientID,
+		qos:      byte(cfg.QoS),
+		username: cfg.Username,
+		password: cfg.Password,
+		tls:      cfg.TLS,
+		reply:    cfg.Reply,
+	}
 }
 
 // Name returns the transport identifier.
 func (t *Transport) Name() string { return "mqtt" }
 
 // Listen connects to the MQTT broker and subscribes to the configured topic.
+// Each publish carries a JSON message; its result is published to the
+// sender's reply topic. The connection is re-established if it drops.
 func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error {
-	// TODO: Implement MQTT client connection, subscription, and message handling.
-	// Recommended library: github.com/eclipse/paho.mqtt.golang
-	slog.Info("mqtt transport listening", "broker", t.broker, "topic", t.topic)
+	u, err := url.Parse(t.broker)
+	if err != nil {
+		return fmt.Errorf("mqtt broker url: %w", err)
+	}
+	tlsCfg, err := clientTLS(t.tls)
+	if err != nil {
+		return err
+	}
+
+	cfg := autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{u},
+		TlsCfg:                        tlsCfg,
+		ConnectUsername:               t.username,
+		ConnectPassword:               []byte(t.password),
+		KeepAlive:                     30,
+		CleanStartOnInitialConnection: true,
+		OnConnectionUp: func(cm *autopaho.ConnectionManager, _ *paho.Connack) {
+			slog.Info("mqtt connected", "broker", t.broker)
+			if _, err := cm.Subscribe(ctx, &paho.Subscribe{
+				Subscriptions: []paho.SubscribeOptions{{Topic: t.topic, QoS: t.qos}},
+			}); err != nil {
+				slog.Error("mqtt subscribe failed", "topic", t.topic, "error", err)
+			}
+		},
+		OnConnectError: func(err error) {
+			// A refused login will not succeed on retry, unlike a network error.
+			var refused *autopaho.ConnackError
+			if errors.As(err, &refused) {
+				slog.Error("mqtt broker refused the connection", "broker", t.broker,
+					"reason_code", refused.ReasonCode, "reason", refused.Reason)
+				return
+			}
+			slog.Warn("mqtt connection failed", "broker", t.broker, "error", err)
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: t.clientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(pr paho.PublishReceived) (bool, error) {
+					go t.handle(ctx, pr.Packet, handler)
+					return true, nil
+				},
+			},
+			OnClientError: func(err error) {
+				slog.Warn("mqtt client error", "error", err)
+			},
+		},
+	}
+
+	cm, err := autopaho.NewConnection(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("mqtt connect: %w", err)
+	}
+	t.mu.Lock()
+	t.cm = cm
+	t.mu.Unlock()
+
+	slog.Info("mqtt transport listening", "broker", t.broker, "topic", t.topic, "reply_mode", t.reply.Mode,
+		"auth", t.username != "", "tls", tlsCfg != nil)
 	<-ctx.Done()
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = cm.Disconnect(disconnectCtx)
+	<-cm.Done()
 	return nil
 }
 
-// Send publishes a payload to an MQTT topic derived from the target.
+// handle dispatches one received publish and publishes the result to the
+// sender's reply topic, if it has one.
+func (t *Transport) handle(ctx context.Context, p *paho.Publish, handler transport.Handler) {
+	var msg message.Message
+	if err := json.Unmarshal(p.Payload, &msg); err != nil {
+		slog.Warn("mqtt: ignoring invalid message", "topic", p.Topic, "error", err)
+		return
+	}
+	// Replies published under the subscribed topic come back to us; they
+	// carry no input and must not be answered again.
+	if !msg.HasAudio() && msg.AudioURL == "" && msg.Text == "" {
+		slog.Debug("mqtt: ignoring publish without input", "topic", p.Topic)
+		return
+	}
+	if msg.Source == "" {
+		msg.Source = p.Topic
+	}
+	if msg.TraceID == "" && p.Properties != nil {
+		msg.TraceID = transport.TraceID(p.Properties.User.Get("x-request-id"), p.Properties.User.Get("traceparent"))
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+
+	result, err := handler(ctx, &msg)
+	if err != nil {
+		slog.Error("mqtt dispatch failed", "topic", p.Topic, "trace_id", msg.TraceID, "error", err)
+		result = &message.DispatchResult{MessageID: msg.ID, Error: err.Error()}
+	}
+
+	replyTopic := t.replyTopic(p, &msg)
+	if replyTopic == "" {
+		return
+	}
+	payload, err := json.Marshal(result)
+	if err != nil {
+		slog.Error("mqtt: encoding result failed", "error", err)
+		return
+	}
+	reply := &paho.Publish{
+		Topic:   replyTopic,
+		QoS:     t.qos,
+		Payload: payload,
+		Properties: &paho.PublishProperties{
+			ContentType: "application/json",
+		},
+	}
+	if p.Properties != nil {
+		reply.Properties.CorrelationData = p.Properties.CorrelationData
+	}
+	if msg.TraceID != "" {
+		reply.Properties.User = paho.UserProperties{{Key: "x-request-id", Value: msg.TraceID}}
+	}
+	if err := t.publish(ctx, reply); err != nil {
+		slog.Error("mqtt reply failed", "topic", replyTopic, "error", err)
+	}
+}
+
+// replyTopic returns the topic that receives the result of p, or "" if the
+// sender asked for none.
+func (t *Transport) replyTopic(p *paho.Publish, msg *message.Message) string {
+	if t.reply.Mode != ReplyTemplate && p.Properties != nil && p.Properties.ResponseTopic != "" {
+		return p.Properties.ResponseTopic
+	}
+	if t.reply.TopicTemplate == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{topic}", p.Topic,
+		"{source}", msg.Source,
+		"{message_id}", msg.ID,
+	).Replace(t.reply.TopicTemplate)
+}
+
+// Send publishes a payload to the MQTT topic named by the target's endpoint.
 func (t *Transport) Send(ctx context.Context, target message.Target, payload []byte) error {
-	// TODO: Implement MQTT publish to target endpoint (topic).
-	slog.Debug("mqtt send", "target", target.Endpoint, "bytes", len(payload))
+	err := t.publish(ctx, &paho.Publish{
+		Topic:   target.Endpoint,
+		QoS:     t.qos,
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("mqtt send: %w", err)
+	}
+	slog.Debug("mqtt send success", "topic", target.Endpoint, "bytes", len(payload))
 	return nil
 }
 
+// Probe checks that the broker connection used for publishing is up. MQTT
+// has no way to check a topic has subscribers, so only the broker is probed.
+func (t *Transport) Probe(ctx context.Context, target message.Target) (string, error) {
+	t.mu.Lock()
+	cm := t.cm
+	t.mu.Unlock()
+	if cm == nil {
+		return "", fmt.Errorf("mqtt probe: not connected")
+	}
+	if err := cm.AwaitConnection(ctx); err != nil {
+		return "", fmt.Errorf("mqtt probe: broker unreachable: %w", err)
+	}
+	return "broker connected", nil
+}
+
+// publish sends p once the broker connection is up.
+func (t *Transport) publish(ctx context.Context, p *paho.Publish) error {
+	t.mu.Lock()
+	cm := t.cm
+	t.mu.Unlock()
+	if cm == nil {
+		return fmt.Errorf("not connected")
+	}
+	if err := cm.AwaitConnection(ctx); err != nil {
+		return err
+	}
+	_, err := cm.Publish(ctx, p)
+	return err
+}
+
 // Close disconnects from the MQTT broker.
 func (t *Transport) Close() error {
-	// TODO: Disconnect MQTT client.
-	return nil
+	t.mu.Lock()
+	cm := t.cm
+	t.mu.Unlock()
+	if cm == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return cm.Disconnect(ctx)
 }
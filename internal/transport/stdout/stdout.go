@@ -0,0 +1,104 @@
+// Package stdout implements a sink-only transport that writes routed payloads
+// to stdout (or a configured file).
+//
+// It is meant for scripting and debugging: point a target at protocol "stdout"
+// and every dispatch that routes to it prints one line of JSON. The transport
+// does not accept incoming messages.
+package stdout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+	"github.com/nadzzz/switchyard/internal/transport"
+)
+
+// Transport implements transport.Transport as a write-only sink.
+type Transport struct {
+	path string
+
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File // non-nil when writing to Path
+}
+
+// New creates a new stdout transport from config.
+func New(cfg config.StdoutConfig) *Transport {
+	return &Transport{path: cfg.Path}
+}
+
+// Name returns the transport identifier.
+func (t *Transport) Name() string { return "stdout" }
+
+// Listen opens the output and blocks until the context is cancelled.
+// The stdout transport never receives messages.
+func (t *Transport) Listen(ctx context.Context, handler transport.Handler) error {
+	if err := t.open(); err != nil {
+		return err
+	}
+	slog.Info("stdout transport ready", "path", t.path)
+	<-ctx.Done()
+	return nil
+}
+
+// Send writes the payload followed by a newline.
+func (t *Transport) Send(ctx context.Context, target message.Target, payload []byte) error {
+	if err := t.open(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	line := make([]byte, 0, len(payload)+1)
+	line = append(line, payload...)
+	line = append(line, '\n')
+	if _, err := t.w.Write(line); err != nil {
+		return fmt.Errorf("stdout send: %w", err)
+	}
+
+	slog.Debug("stdout send", "target", target.ServiceName, "bytes", len(payload))
+	return nil
+}
+
+// Close closes the output file, if any.
+func (t *Transport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.file != nil {
+		err := t.file.Close()
+		t.file = nil
+		t.w = nil
+		return err
+	}
+	return nil
+}
+
+// open lazily selects the writer so Send works even if Listen has not run yet.
+func (t *Transport) open() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.w != nil {
+		return nil
+	}
+	if t.path == "" {
+		t.w = os.Stdout
+		return nil
+	}
+
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening stdout sink %s: %w", t.path, err)
+	}
+	t.file = f
+	t.w = f
+	return nil
+}
@@ -0,0 +1,88 @@
+package stdout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+func TestSend(t *testing.T) {
+	payloads := []string{`{"commands":[{"action":"turn_on"}]}`, `{"commands":[]}`}
+	tests := []struct {
+		name  string
+		path  bool   // write to a file instead of stdout
+		prior string // file content before the transport opens it
+	}{
+		{name: "stdout"},
+		{name: "new file", path: true},
+		{name: "appends to file", path: true, prior: "{}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				tr  *Transport
+				buf bytes.Buffer
+				out string
+			)
+			if tt.path {
+				out = filepath.Join(t.TempDir(), "results.jsonl")
+				if tt.prior != "" {
+					if err := os.WriteFile(out, []byte(tt.prior), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				tr = New(config.StdoutConfig{Path: out})
+			} else {
+				tr = New(config.StdoutConfig{})
+				tr.w = &buf // stands in for os.Stdout
+			}
+
+			for _, p := range payloads {
+				if err := tr.Send(context.Background(), message.Target{ServiceName: "log"}, []byte(p)); err != nil {
+					t.Fatalf("Send: %v", err)
+				}
+			}
+			if err := tr.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			got := buf.String()
+			if tt.path {
+				data, err := os.ReadFile(out)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.HasPrefix(string(data), tt.prior) {
+					t.Fatalf("file lost its earlier content: %q", data)
+				}
+				got = strings.TrimPrefix(string(data), tt.prior)
+			}
+
+			// One JSON result per line.
+			lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+			if len(lines) != len(payloads) || !strings.HasSuffix(got, "\n") {
+				t.Fatalf("output = %q, want %d newline-terminated lines", got, len(payloads))
+			}
+			for i, line := range lines {
+				if line != payloads[i] || !json.Valid([]byte(line)) {
+					t.Errorf("line %d = %q, want %q", i, line, payloads[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSendUnwritablePath(t *testing.T) {
+	tr := New(config.StdoutConfig{Path: filepath.Join(t.TempDir(), "missing", "results.jsonl")})
+	err := tr.Send(context.Background(), message.Target{ServiceName: "log"}, []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "opening stdout sink") {
+		t.Fatalf("err = %v, want open error", err)
+	}
+}
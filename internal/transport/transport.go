@@ -7,14 +7,180 @@ package transport
 
 import (
 	"context"
+	"errors"
+	"io"
+	"strings"
 
 	"github.com/nadzzz/switchyard/internal/message"
 )
 
+// ErrBusy is returned by a Handler that rejects a message because the
+// dispatcher is at its concurrency limit. Transports report it as a
+// retryable "busy" condition (e.g., HTTP 429).
+var ErrBusy = errors.New("dispatcher busy")
+
+// ErrRateLimited is returned by a Handler that rejects a message because its
+// sender exceeded the configured rate. Transports report it like ErrBusy.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// ErrShuttingDown is returned by a Handler that rejects a message because
+// the daemon is draining for shutdown. Transports report it as unavailable
+// (e.g., HTTP 503) so clients retry against another instance.
+var ErrShuttingDown = errors.New("shutting down")
+
+// ErrTTSDisabled is returned by a SpeechHandler when no TTS backend is
+// configured. Transports report it as not implemented (e.g., HTTP 501).
+var ErrTTSDisabled = errors.New("tts is disabled")
+
+// maxTraceIDLength caps inbound request IDs so clients can't bloat logs.
+const maxTraceIDLength = 128
+
+// TraceID returns the trace ID for an inbound request: its request ID
+// (e.g., the X-Request-ID header) if set, otherwise the trace-id field of a
+// W3C traceparent value ("00-<trace-id>-<parent-id>-<flags>"). It returns ""
+// if neither is usable.
+func TraceID(requestID, traceparent string) string {
+	if id := strings.TrimSpace(requestID); id != "" {
+		if len(id) > maxTraceIDLength {
+			id = id[:maxTraceIDLength]
+		}
+		return id
+	}
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return ""
+}
+
 // Handler is a function that processes an incoming message and returns a result.
 // The dispatcher provides this handler to each transport.
 type Handler func(ctx context.Context, msg *message.Message) (*message.DispatchResult, error)
 
+// Pipeline stages reported to a StageFunc, in the order they complete.
+const (
+	StageTranscript    = "transcript"     // Transcript (and Language) are set
+	StageCommands      = "commands"       // Commands are set
+	StageResponseText  = "response_text"  // ResponseText is set
+	StageResponseAudio = "response_audio" // ResponseAudio or ResponseAudioURL is set
+)
+
+// StageFunc observes the partial result after a pipeline stage completes.
+// It is called synchronously from the dispatch goroutine and must not retain
+// result after returning.
+type StageFunc func(stage string, result *message.DispatchResult)
+
+// StreamHandler is a Handler that also reports each completed pipeline stage
+// to emit before returning the final result.
+type StreamHandler func(ctx context.Context, msg *message.Message, emit StageFunc) (*message.DispatchResult, error)
+
+// Streamer is implemented by transports that can deliver intermediate
+// results to the sender (e.g., Server-Sent Events over HTTP).
+type Streamer interface {
+	// SetStreamHandler provides the handler used for streaming requests.
+	// It is called before Listen.
+	SetStreamHandler(handler StreamHandler)
+}
+
+// SpeechRequest asks for text to be synthesized directly, without dispatch.
+type SpeechRequest struct {
+	Text        string           `json:"text"`
+	Language    string           `json:"language,omitempty"`     // default "en"
+	Voice       string           `json:"voice,omitempty"`        // backend default if empty
+	AudioFormat string           `json:"audio_format,omitempty"` // "wav" (default), "mp3" or "opus"
+	SampleRate  int              `json:"sample_rate,omitempty"`  // voice's native rate if 0
+	Speech      *message.Prosody `json:"speech,omitempty"`
+}
+
+// SpeechHandler synthesizes req and writes the audio to w, as it is produced
+// when the backend supports streaming. Nothing is written if it fails early.
+type SpeechHandler func(ctx context.Context, req SpeechRequest, w io.Writer) error
+
+// Speaker is implemented by transports that serve direct speech synthesis.
+type Speaker interface {
+	// SetSpeechHandler provides the handler for speech requests. It is
+	// called before Listen.
+	SetSpeechHandler(handler SpeechHandler)
+}
+
+// ErrUnknownTarget is returned by a TargetAdmin for a name that is not a
+// configured target. Transports report it as not found (e.g., HTTP 404).
+var ErrUnknownTarget = errors.New("unknown target")
+
+// TargetInfo describes a configured target without its credentials.
+type TargetInfo struct {
+	Name           string   `json:"name"`
+	Endpoint       string   `json:"endpoint"`
+	Protocol       string   `json:"protocol"`
+	HasToken       bool     `json:"has_token"`
+	AuthHeader     string   `json:"auth_header,omitempty"` // Header carrying the token, when one is set
+	Headers        []string `json:"headers,omitempty"`     // Names of the extra request headers; values are not shown
+	AllowedActions []string `json:"allowed_actions,omitempty"`
+}
+
+// Outcomes of a target check (TargetCheck.Status).
+const (
+	TargetOK          = "ok"          // The probe succeeded
+	TargetError       = "error"       // The probe failed; see Error
+	TargetUnsupported = "unsupported" // The target's sender cannot probe without delivering
+)
+
+// TargetCheck is the outcome of probing one configured target.
+type TargetCheck struct {
+	Name      string `json:"name"`
+	Protocol  string `json:"protocol"`
+	Endpoint  string `json:"endpoint"`
+	Status    string `json:"status"`
+	Detail    string `json:"detail,omitempty"` // What was checked, e.g. "HEAD 200"
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+// TargetAdmin lists and probes the server-configured targets. The
+// dispatcher implements it.
+type TargetAdmin interface {
+	// Targets returns the configured targets sorted by name.
+	Targets() []TargetInfo
+
+	// CheckTarget probes the named target with its sender. It returns
+	// ErrUnknownTarget if no target has that name; a failed probe is
+	// reported in the TargetCheck, not as an error.
+	CheckTarget(ctx context.Context, name string) (TargetCheck, error)
+}
+
+// TargetAdminServer is implemented by transports that expose target
+// administration (e.g., GET /targets over HTTP).
+type TargetAdminServer interface {
+	// SetTargetAdmin provides the target list and probe. It is called
+	// before Listen.
+	SetTargetAdmin(admin TargetAdmin)
+}
+
+// Prober is implemented by senders that can check a target is reachable,
+// and accepts switchyard's credentials where the protocol carries them,
+// without delivering a payload.
+type Prober interface {
+	// Probe returns a short description of what was checked, or an error
+	// if the target is unreachable or rejected the request.
+	Probe(ctx context.Context, target message.Target) (string, error)
+}
+
+// Sender delivers routed payloads to targets of one protocol. It is the
+// outbound half of a Transport, for delivery mechanisms that don't receive
+// messages (e.g., a Kafka producer).
+type Sender interface {
+	// Send delivers a payload to a target address using this sender's protocol.
+	Send(ctx context.Context, target message.Target, payload []byte) error
+}
+
+// SenderFunc adapts a function to the Sender interface.
+type SenderFunc func(ctx context.Context, target message.Target, payload []byte) error
+
+// Send calls f.
+func (f SenderFunc) Send(ctx context.Context, target message.Target, payload []byte) error {
+	return f(ctx, target, payload)
+}
+
 // Transport is the interface that every transport adapter must implement.
 type Transport interface {
 	// Name returns the transport identifier (e.g., "grpc", "http", "mqtt").
@@ -24,8 +190,8 @@ type Transport interface {
 	// It blocks until the context is cancelled.
 	Listen(ctx context.Context, handler Handler) error
 
-	// Send delivers a payload to a target address using this transport's protocol.
-	Send(ctx context.Context, target message.Target, payload []byte) error
+	// Sender delivers payloads to targets using this transport's protocol.
+	Sender
 
 	// Close gracefully shuts down the transport, draining in-flight work.
 	Close() error
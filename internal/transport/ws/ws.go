@@ -0,0 +1,344 @@
+// Package ws implements a send-only sender that delivers routed payloads to
+// targets over persistent WebSocket connections, for services that only
+// accept commands on a socket they keep open.
+//
+// Targets with protocol "ws" name a ws:// or wss:// URL in their endpoint.
+// One connection is kept per endpoint and set of handshake credentials and
+// headers, and a single writer sends the queued payloads to it as text
+// frames, in order. A connection that drops is
+// redialed on the next send. Frames the target sends back (such as device
+// state) are read and discarded, which also answers the target's pings.
+package ws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// maxFrameBytes bounds the size of a frame read from a target.
+const maxFrameBytes = 1 << 20
+
+// errClosed is returned for sends after, or queued at, Close.
+var errClosed = errors.New("ws sender closed")
+
+// Sender implements transport.Sender. Register it with dispatch.RegisterSender.
+type Sender struct {
+	queueSize    int
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mu        sync.Mutex
+	endpoints map[string]*endpoint // by connKey
+	closed    bool
+}
+
+// New creates a WebSocket sender from config.
+func New(cfg config.WSConfig) *Sender {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 64
+	}
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = 10 * time.Second
+	}
+	writeTimeout := cfg.WriteTimeout
+	if writeTimeout <= 0 {
+		writeTimeout = 10 * time.Second
+	}
+	return &Sender{
+		queueSize:    queueSize,
+		dialTimeout:  dialTimeout,
+		writeTimeout: writeTimeout,
+		endpoints:    make(map[string]*endpoint),
+	}
+}
+
+// sendReq is a payload waiting for the writer of its endpoint.
+type sendReq struct {
+	ctx     context.Context
+	target  message.Target
+	payload []byte
+	done    chan error // buffered, so the writer never blocks on it
+}
+
+// Send queues the payload for the target's endpoint and waits until it is
+// written. It fails at once if the endpoint already has queue_size payloads
+// waiting, rather than holding up the dispatch behind a slow target.
+func (s *Sender) Send(ctx context.Context, target message.Target, payload []byte) error {
+	if err := checkEndpoint(target.Endpoint); err != nil {
+		return fmt.Errorf("ws send: %w", err)
+	}
+	e, err := s.endpoint(target)
+	if err != nil {
+		return fmt.Errorf("ws send to %s: %w", target.Endpoint, err)
+	}
+	req := &sendReq{ctx: ctx, target: target, payload: payload, done: make(chan error, 1)}
+	select {
+	case e.queue <- req:
+	default:
+		return fmt.Errorf("ws send to %s: queue full (%d payloads waiting)", target.Endpoint, cap(e.queue))
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("ws send to %s: %w", target.Endpoint, ctx.Err())
+	}
+}
+
+// Probe checks that a WebSocket target completes the handshake with its
+// credentials on a connection of its own. Nothing is sent.
+func (s *Sender) Probe(ctx context.Context, target message.Target) (string, error) {
+	if err := checkEndpoint(target.Endpoint); err != nil {
+		return "", fmt.Errorf("ws probe: %w", err)
+	}
+	conn, err := dial(ctx, target, s.dialTimeout)
+	if err != nil {
+		return "", fmt.Errorf("ws probe: %w", err)
+	}
+	_ = conn.Close()
+	return "handshake ok", nil
+}
+
+// Close closes every connection. Payloads still queued fail.
+func (s *Sender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	for _, e := range s.endpoints {
+		e.close()
+	}
+	return nil
+}
+
+// endpoint returns the endpoint for target, starting its writer on first
+// use.
+func (s *Sender) endpoint(target message.Target) (*endpoint, error) {
+	key := connKey(target)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, errClosed
+	}
+	e, ok := s.endpoints[key]
+	if !ok {
+		e = &endpoint{
+			url:    target.Endpoint,
+			sender: s,
+			queue:  make(chan *sendReq, s.queueSize),
+			stop:   make(chan struct{}),
+		}
+		s.endpoints[key] = e
+		go e.run()
+	}
+	return e, nil
+}
+
+// connKey identifies the connection for target: its endpoint plus a hash of
+// the credentials and headers sent on the handshake. Targets that share an
+// endpoint but authenticate differently get connections of their own.
+func connKey(target message.Target) string {
+	h := sha256.New()
+	if target.Token != "" {
+		header, value := target.Authorization()
+		fmt.Fprintf(h, "%s\x00%s\x00", header, value)
+	}
+	for _, key := range slices.Sorted(maps.Keys(target.Headers)) {
+		fmt.Fprintf(h, "%s\x00%s\x00", key, target.Headers[key])
+	}
+	return target.Endpoint + "#" + hex.EncodeToString(h.Sum(nil))
+}
+
+// endpoint owns the connection to one endpoint, with one set of
+// credentials, and the queue feeding it.
+type endpoint struct {
+	url    string
+	sender *Sender
+	queue  chan *sendReq
+	stop   chan struct{}
+
+	mu      sync.Mutex
+	conn    *websocket.Conn // nil while disconnected
+	stopped bool
+}
+
+// run writes queued payloads until the endpoint is closed.
+func (e *endpoint) run() {
+	for {
+		select {
+		case <-e.stop:
+			for {
+				select {
+				case req := <-e.queue:
+					req.done <- fmt.Errorf("ws send to %s: %w", e.url, errClosed)
+				default:
+					return
+				}
+			}
+		case req := <-e.queue:
+			if err := req.ctx.Err(); err != nil {
+				req.done <- fmt.Errorf("ws send to %s: %w", e.url, err)
+				continue
+			}
+			req.done <- e.write(req)
+		}
+	}
+}
+
+// write sends one payload, connecting first if needed. A connection found
+// dead by the write has dropped since the last send, so it is redialed once;
+// a failure on a fresh connection is returned.
+func (e *endpoint) write(req *sendReq) error {
+	for {
+		conn, fresh, err := e.connect(req.ctx, req.target)
+		if err != nil {
+			return fmt.Errorf("ws send to %s: %w", e.url, err)
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(e.sender.writeTimeout))
+		err = websocket.Message.Send(conn, string(req.payload))
+		if err == nil {
+			slog.Debug("ws send success", "target", req.target.ServiceName, "endpoint", e.url, "bytes", len(req.payload))
+			return nil
+		}
+		e.drop(conn)
+		if fresh {
+			return fmt.Errorf("ws send to %s: %w", e.url, err)
+		}
+		slog.Info("ws connection lost, reconnecting", "endpoint", e.url, "error", err)
+	}
+}
+
+// connect returns the open connection, dialing one if there is none. fresh
+// reports whether it was just dialed.
+func (e *endpoint) connect(ctx context.Context, target message.Target) (conn *websocket.Conn, fresh bool, err error) {
+	e.mu.Lock()
+	conn = e.conn
+	e.mu.Unlock()
+	if conn != nil {
+		return conn, false, nil
+	}
+
+	conn, err = dial(ctx, target, e.sender.dialTimeout)
+	if err != nil {
+		return nil, false, err
+	}
+	e.mu.Lock()
+	if e.stopped {
+		e.mu.Unlock()
+		_ = conn.Close()
+		return nil, false, errClosed
+	}
+	e.conn = conn
+	e.mu.Unlock()
+	slog.Info("ws connected", "endpoint", e.url)
+	go e.read(conn)
+	return conn, true, nil
+}
+
+// read discards frames from the target until the connection fails, then
+// drops it so the next send redials.
+func (e *endpoint) read(conn *websocket.Conn) {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			if e.drop(conn) {
+				slog.Warn("ws connection lost", "endpoint", e.url, "error", err)
+			}
+			return
+		}
+		slog.Debug("ws frame from target ignored", "endpoint", e.url, "bytes", len(data))
+	}
+}
+
+// drop closes conn and forgets it if it is still the current connection,
+// reporting whether it was.
+func (e *endpoint) drop(conn *websocket.Conn) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.conn != conn {
+		return false
+	}
+	e.conn = nil
+	_ = conn.Close()
+	return true
+}
+
+// close stops the writer and closes the connection.
+func (e *endpoint) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stopped = true
+	close(e.stop)
+	if e.conn != nil {
+		_ = e.conn.Close()
+		e.conn = nil
+	}
+}
+
+// dial opens a connection to the target's endpoint with its credentials and
+// headers on the handshake.
+func dial(ctx context.Context, target message.Target, timeout time.Duration) (*websocket.Conn, error) {
+	cfg, err := websocket.NewConfig(target.Endpoint, origin(target.Endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	for key, value := range target.Headers {
+		cfg.Header.Set(key, value)
+	}
+	if target.Token != "" {
+		header, value := target.Authorization()
+		cfg.Header.Set(header, value)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.MaxPayloadBytes = maxFrameBytes
+	return conn, nil
+}
+
+// checkEndpoint rejects endpoints that are not ws:// or wss:// URLs.
+func checkEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", endpoint, err)
+	}
+	if (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		return fmt.Errorf("endpoint %q must be a ws:// or wss:// URL", endpoint)
+	}
+	return nil
+}
+
+// origin returns the Origin sent on the handshake: the endpoint's host
+// with the matching http scheme.
+func origin(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "http://localhost"
+	}
+	scheme := "http"
+	if u.Scheme == "wss" {
+		scheme = "https"
+	}
+	return scheme + "://" + u.Host
+}
@@ -0,0 +1,89 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/message"
+)
+
+// handshakeServer accepts WebSocket connections, recording the
+// Authorization header of each handshake, and discards their frames.
+func handshakeServer(t *testing.T) (string, func() []string) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		auths []string
+	)
+	srv := httptest.NewServer(websocket.Server{
+		// Handshake runs before the client's dial returns.
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			mu.Lock()
+			auths = append(auths, r.Header.Get("Authorization"))
+			mu.Unlock()
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			for {
+				var data []byte
+				if err := websocket.Message.Receive(conn, &data); err != nil {
+					return
+				}
+			}
+		},
+	})
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), auths...)
+	}
+}
+
+func TestSendKeepsConnectionPerCredentials(t *testing.T) {
+	endpoint, auths := handshakeServer(t)
+	s := New(config.WSConfig{})
+	defer s.Close()
+
+	a := message.Target{ServiceName: "a", Protocol: "ws", Endpoint: endpoint, Token: "token-a"}
+	b := message.Target{ServiceName: "b", Protocol: "ws", Endpoint: endpoint, Token: "token-b"}
+	for _, target := range []message.Target{a, b, a, b} {
+		if err := s.Send(context.Background(), target, []byte(`{}`)); err != nil {
+			t.Fatalf("Send to %s: %v", target.ServiceName, err)
+		}
+	}
+
+	got := auths()
+	if len(got) != 2 || got[0] != "Bearer token-a" || got[1] != "Bearer token-b" {
+		t.Errorf("handshake authorizations = %q, want one per token", got)
+	}
+}
+
+func TestConnKey(t *testing.T) {
+	base := message.Target{Endpoint: "ws://hub.local/ws", Token: "t", Headers: map[string]string{"X-A": "1", "X-B": "2"}}
+	same := base
+	same.ServiceName = "other"
+	same.Headers = map[string]string{"X-B": "2", "X-A": "1"}
+	if connKey(base) != connKey(same) {
+		t.Error("targets with equal credentials have different keys")
+	}
+	for name, change := range map[string]func(*message.Target){
+		"token":       func(t *message.Target) { t.Token = "u" },
+		"auth header": func(t *message.Target) { t.AuthHeader = "X-Api-Key" },
+		"headers":     func(t *message.Target) { t.Headers = map[string]string{"X-A": "1"} },
+		"endpoint":    func(t *message.Target) { t.Endpoint = "ws://hub.local/other" },
+	} {
+		other := base
+		change(&other)
+		if connKey(base) == connKey(other) {
+			t.Errorf("targets differing in %s share a key", name)
+		}
+	}
+}
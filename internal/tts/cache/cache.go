@@ -0,0 +1,165 @@
+// Package cache provides an LRU cache in front of any tts.Synthesizer.
+//
+// Spoken confirmations are often identical ("Turning on the living room
+// light"), so caching synthesized audio saves backend CPU and latency.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/tts"
+)
+
+// Synthesizer wraps another synthesizer with a bounded LRU cache keyed by
+// text and synthesis options. It is safe for concurrent use.
+//
+// Cached results share their Audio slice between callers, which must treat
+// it as read-only.
+type Synthesizer struct {
+	next       tts.Synthesizer
+	maxEntries int
+	maxBytes   int
+
+	mu     sync.Mutex
+	lru    *list.List // most recently used at front; values are *entry
+	items  map[string]*list.Element
+	bytes  int
+	hits   int64
+	misses int64
+}
+
+type entry struct {
+	key    string
+	result tts.SynthesizeResult
+}
+
+// New wraps next with a cache holding at most maxEntries results and
+// maxBytes of audio. Zero limits fall back to 128 entries and 32 MB.
+func New(next tts.Synthesizer, maxEntries, maxBytes int) *Synthesizer {
+	if maxEntries <= 0 {
+		maxEntries = 128
+	}
+	if maxBytes <= 0 {
+		maxBytes = 32 << 20
+	}
+	return &Synthesizer{
+		next:       next,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// Synthesize returns cached audio for identical requests, calling the
+// wrapped synthesizer on a miss.
+func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.SynthesizeOpts) (*tts.SynthesizeResult, error) {
+	key := cacheKey(text, opts)
+
+	s.mu.Lock()
+	if el, ok := s.items[key]; ok {
+		s.lru.MoveToFront(el)
+		s.hits++
+		res := el.Value.(*entry).result
+		s.mu.Unlock()
+		return &res, nil
+	}
+	s.misses++
+	s.mu.Unlock()
+
+	res, err := s.next.Synthesize(ctx, text, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.add(key, *res)
+	return res, nil
+}
+
+// SynthesizeStream writes cached audio for identical requests. On a miss it
+// streams from the wrapped synthesizer if that supports streaming, without
+// caching the partial output; otherwise it synthesizes, caches and writes.
+func (s *Synthesizer) SynthesizeStream(ctx context.Context, text string, opts tts.SynthesizeOpts, w io.Writer) error {
+	s.mu.Lock()
+	if el, ok := s.items[cacheKey(text, opts)]; ok {
+		s.lru.MoveToFront(el)
+		s.hits++
+		data := el.Value.(*entry).result.Audio
+		s.mu.Unlock()
+		_, err := w.Write(data)
+		return err
+	}
+	s.mu.Unlock()
+
+	if next, ok := s.next.(tts.StreamSynthesizer); ok {
+		s.mu.Lock()
+		s.misses++
+		s.mu.Unlock()
+		return next.SynthesizeStream(ctx, text, opts, w)
+	}
+	res, err := s.Synthesize(ctx, text, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(res.Audio)
+	return err
+}
+
+// add stores a result, evicting least recently used entries to stay within
+// the limits. Results larger than maxBytes are not cached.
+func (s *Synthesizer) add(key string, res tts.SynthesizeResult) {
+	size := len(res.Audio)
+	if size > s.maxBytes {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.items[key]; ok {
+		return // stored by a concurrent miss
+	}
+	for s.lru.Len() >= s.maxEntries || s.bytes+size > s.maxBytes {
+		oldest := s.lru.Back()
+		e := oldest.Value.(*entry)
+		s.lru.Remove(oldest)
+		delete(s.items, e.key)
+		s.bytes -= len(e.result.Audio)
+	}
+	s.items[key] = s.lru.PushFront(&entry{key: key, result: res})
+	s.bytes += size
+}
+
+// CacheStats returns the hit and miss counts since the cache was created
+// and its current size.
+func (s *Synthesizer) CacheStats() health.CacheStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := health.CacheStats{Hits: s.hits, Misses: s.misses, Entries: s.lru.Len(), Bytes: s.bytes}
+	if n := s.hits + s.misses; n > 0 {
+		st.HitRate = float64(s.hits) / float64(n)
+	}
+	return st
+}
+
+// Close closes the wrapped synthesizer.
+func (s *Synthesizer) Close() error { return s.next.Close() }
+
+// HealthCheck probes the wrapped synthesizer if it supports health checks.
+func (s *Synthesizer) HealthCheck(ctx context.Context) error {
+	if c, ok := s.next.(health.Checker); ok {
+		return c.HealthCheck(ctx)
+	}
+	return nil
+}
+
+// cacheKey identifies a synthesis request. Every option that changes the
+// output is part of the key.
+func cacheKey(text string, opts tts.SynthesizeOpts) string {
+	return fmt.Sprintf("%s\x00%s\x00%s\x00%d\x00%g\x00%g\x00%g\x00%s",
+		opts.Language, opts.Voice, opts.Format, opts.SampleRate, opts.Rate, opts.Pitch, opts.Volume, text)
+}
@@ -0,0 +1,217 @@
+// Package elevenlabs implements the TTS Synthesizer using the ElevenLabs
+// text-to-speech API (POST /v1/text-to-speech/{voice_id}).
+//
+// ElevenLabs voices are picked by ID. The multilingual models speak every
+// supported language with any voice, so one default voice covers them all;
+// per-language voice IDs can pick a native speaker instead.
+package elevenlabs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/tts"
+)
+
+// defaultVoice is the premade "Rachel" voice, available on every account.
+const defaultVoice = "21m00Tcm4TlvDq8ikWAM"
+
+// Output formats requested from the API. WAV is built from raw PCM.
+const (
+	mp3OutputFormat = "mp3_44100_128"
+	pcmOutputFormat = "pcm_24000"
+	mp3SampleRate   = 44100
+	pcmSampleRate   = 24000
+)
+
+// Synthesizer implements tts.Synthesizer against the ElevenLabs API.
+type Synthesizer struct {
+	apiKey   string
+	endpoint string // API base URL, without a trailing slash
+	model    string
+	voice    string            // default voice ID
+	voices   map[string]string // language -> voice ID
+	format   string            // default output format
+	ffmpeg   string            // encoder for Opus output
+	client   *http.Client
+}
+
+// New creates a new ElevenLabs synthesizer from config.
+func New(cfg config.ElevenLabsConfig) *Synthesizer {
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = "https://api.elevenlabs.io"
+	}
+	model := cfg.Model
+	if model == "" {
+		model = "eleven_multilingual_v2"
+	}
+	voice := cfg.Voice
+	if voice == "" {
+		voice = defaultVoice
+	}
+	format := cfg.Format
+	if format == "" || audio.ContentType(format) == "" {
+		format = audio.FormatMP3
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Synthesizer{
+		apiKey:   cfg.APIKey,
+		endpoint: endpoint,
+		model:    model,
+		voice:    voice,
+		voices:   cfg.Voices,
+		format:   format,
+		ffmpeg:   cfg.FFmpegPath,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type speechRequest struct {
+	Text          string         `json:"text"`
+	ModelID       string         `json:"model_id"`
+	LanguageCode  string         `json:"language_code,omitempty"`
+	VoiceSettings *voiceSettings `json:"voice_settings,omitempty"`
+}
+
+type voiceSettings struct {
+	Speed float64 `json:"speed,omitempty"`
+}
+
+// Synthesize sends text to the API and returns MP3, WAV or Opus audio.
+//
+// MP3 comes from the API as is. For WAV, raw PCM is requested and wrapped
+// locally; Opus is encoded from that WAV with ffmpeg.
+//
+// Rate maps to the voice's speed setting. Volume and SampleRate are applied
+// locally to WAV and Opus output only; pitch is not supported.
+func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.SynthesizeOpts) (*tts.SynthesizeResult, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty text for synthesis")
+	}
+
+	// Select voice based on language or explicit override.
+	voice := opts.Voice
+	if voice == "" {
+		voice = s.voices[opts.Language]
+	}
+	if voice == "" {
+		voice = s.voice
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = s.format
+	}
+	if audio.ContentType(format) == "" {
+		return nil, fmt.Errorf("unsupported audio format %q", format)
+	}
+	outputFormat := pcmOutputFormat
+	if format == audio.FormatMP3 {
+		outputFormat = mp3OutputFormat
+	}
+
+	body := speechRequest{Text: text, ModelID: s.model}
+	if enforcesLanguage(s.model) {
+		body.LanguageCode = opts.Language
+	}
+	if sp := speed(opts.Rate); sp > 0 {
+		body.VoiceSettings = &voiceSettings{Speed: sp}
+	}
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	u := s.endpoint + "/v1/text-to-speech/" + url.PathEscape(voice) + "?output_format=" + outputFormat
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("xi-api-key", s.apiKey)
+
+	slog.Debug("elevenlabs synthesize", "text_length", len(text), "voice", voice, "language", opts.Language, "model", s.model)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("speech request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading speech response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("speech synthesis failed (status %d): %s", resp.StatusCode, data)
+	}
+
+	if format == audio.FormatMP3 {
+		return &tts.SynthesizeResult{
+			Audio:       data,
+			ContentType: audio.ContentType(audio.FormatMP3),
+			SampleRate:  mp3SampleRate,
+			Channels:    1,
+		}, nil
+	}
+	if opts.Volume > 0 {
+		audio.ScalePCM16(data, opts.Volume)
+	}
+	rate := pcmSampleRate
+	if opts.SampleRate > 0 {
+		if data, err = audio.ResamplePCM16(data, 1, pcmSampleRate, opts.SampleRate); err != nil {
+			return nil, err
+		}
+		rate = opts.SampleRate
+	}
+	out := audio.EncodeWAV(data, rate, 1, 2)
+	if format != audio.FormatWAV {
+		if out, err = audio.EncodeWithFFmpeg(ctx, s.ffmpeg, out, format); err != nil {
+			return nil, err
+		}
+	}
+	return &tts.SynthesizeResult{
+		Audio:       out,
+		ContentType: audio.ContentType(format),
+		SampleRate:  rate,
+		Channels:    1,
+	}, nil
+}
+
+// enforcesLanguage reports whether model accepts a language_code; the API
+// rejects it for the others.
+func enforcesLanguage(model string) bool {
+	return strings.HasSuffix(model, "_v2_5")
+}
+
+// speed maps a rate multiplier to the voice's speed setting, clamped to the
+// supported range. 0 leaves it unset.
+func speed(rate float64) float64 {
+	if rate <= 0 {
+		return 0
+	}
+	return max(min(rate, 1.2), 0.7)
+}
+
+// Close is a no-op — requests are independent.
+func (s *Synthesizer) Close() error { return nil }
+
+// HealthCheck verifies that the API endpoint accepts connections.
+func (s *Synthesizer) HealthCheck(ctx context.Context) error {
+	return health.DialURL(ctx, s.endpoint)
+}
@@ -0,0 +1,184 @@
+// Package openai implements the TTS Synthesizer using the OpenAI speech API
+// (POST /v1/audio/speech), or any server that is compatible with it.
+//
+// OpenAI voices are multilingual, so one default voice covers every language;
+// per-language overrides can pick a voice that suits a language better.
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/audio"
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
+	"github.com/nadzzz/switchyard/internal/tts"
+)
+
+// pcmSampleRate is the sample rate of the API's raw "pcm" output
+// (16-bit signed little-endian, mono).
+const pcmSampleRate = 24000
+
+// Synthesizer implements tts.Synthesizer against the OpenAI speech API.
+type Synthesizer struct {
+	apiKey   string
+	endpoint string
+	model    string
+	voice    string            // default voice
+	voices   map[string]string // language -> voice overrides
+	format   string            // default output format
+	client   *http.Client
+}
+
+// New creates a new OpenAI synthesizer from config.
+func New(cfg config.OpenAITTSConfig) *Synthesizer {
+	voice := cfg.Voice
+	if voice == "" {
+		voice = "alloy"
+	}
+	format := cfg.Format
+	if format == "" || audio.ContentType(format) == "" {
+		format = audio.FormatWAV
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &Synthesizer{
+		apiKey:   cfg.APIKey,
+		endpoint: cfg.Endpoint,
+		model:    cfg.Model,
+		voice:    voice,
+		voices:   cfg.Voices,
+		format:   format,
+		client:   &http.Client{Timeout: timeout},
+	}
+}
+
+type speechRequest struct {
+	Model          string  `json:"model"`
+	Input          string  `json:"input"`
+	Voice          string  `json:"voice"`
+	ResponseFormat string  `json:"response_format"`
+	Speed          float64 `json:"speed,omitempty"`
+}
+
+// Synthesize sends text to the speech API and returns WAV, MP3 or Opus audio.
+//
+// For WAV, raw PCM is requested and wrapped locally: the API's own WAV output
+// is streamed with placeholder sizes in its header, which many players and
+// the dispatcher's duration calculation can't use.
+//
+// Rate maps to the API's speed. Volume and SampleRate are applied locally to
+// WAV output only; pitch is not supported.
+func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.SynthesizeOpts) (*tts.SynthesizeResult, error) {
+	if text == "" {
+		return nil, fmt.Errorf("empty text for synthesis")
+	}
+
+	// Select voice based on language or explicit override.
+	voice := opts.Voice
+	if voice == "" {
+		voice = s.voices[opts.Language]
+	}
+	if voice == "" {
+		voice = s.voice
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = s.format
+	}
+	contentType := audio.ContentType(format)
+	if contentType == "" {
+		return nil, fmt.Errorf("unsupported audio format %q", format)
+	}
+	responseFormat := format
+	if format == audio.FormatWAV {
+		responseFormat = "pcm"
+	}
+
+	reqBody, err := json.Marshal(speechRequest{
+		Model:          s.model,
+		Input:          text,
+		Voice:          voice,
+		ResponseFormat: responseFormat,
+		Speed:          speed(opts.Rate),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshalling request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	slog.Debug("openai synthesize", "text_length", len(text), "voice", voice, "language", opts.Language, "model", s.model)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("speech request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading speech response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("speech synthesis failed (status %d): %s", resp.StatusCode, data)
+	}
+
+	if format != audio.FormatWAV {
+		return &tts.SynthesizeResult{
+			Audio:       data,
+			ContentType: contentType,
+			SampleRate:  pcmSampleRate,
+			Channels:    1,
+		}, nil
+	}
+	if opts.Volume > 0 {
+		audio.ScalePCM16(data, opts.Volume)
+	}
+	rate := pcmSampleRate
+	if opts.SampleRate > 0 {
+		if data, err = audio.ResamplePCM16(data, 1, pcmSampleRate, opts.SampleRate); err != nil {
+			return nil, err
+		}
+		rate = opts.SampleRate
+	}
+	return &tts.SynthesizeResult{
+		Audio:       audio.EncodeWAV(data, rate, 1, 2),
+		ContentType: contentType,
+		SampleRate:  rate,
+		Channels:    1,
+	}, nil
+}
+
+// speed maps a rate multiplier to the API's speed parameter, clamped to the
+// supported range. 0 leaves it unset.
+func speed(rate float64) float64 {
+	if rate <= 0 {
+		return 0
+	}
+	return max(min(rate, 4), 0.25)
+}
+
+// Close is a no-op — requests are independent.
+func (s *Synthesizer) Close() error { return nil }
+
+// HealthCheck verifies that the speech endpoint accepts connections.
+func (s *Synthesizer) HealthCheck(ctx context.Context) error {
+	return health.DialURL(ctx, s.endpoint)
+}
@@ -0,0 +1,68 @@
+package piper
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// Piper's output format, assumed for fields a server never announces.
+const (
+	defaultRate     = 22050
+	defaultWidth    = 2
+	defaultChannels = 1
+)
+
+// audioFormat is the PCM format announced in audio-start and audio-chunk
+// events. Zero fields have not been announced yet.
+type audioFormat struct {
+	rate     int
+	width    int // bytes per sample
+	channels int
+}
+
+// update records the format fields present in an event's data. Wyoming
+// repeats them on every audio-chunk, which covers servers that leave them
+// out of audio-start. A field that changes mid-stream is an error: one WAV
+// header cannot describe the data.
+func (f *audioFormat) update(data map[string]any) error {
+	for _, field := range []struct {
+		name string
+		dst  *int
+	}{{"rate", &f.rate}, {"width", &f.width}, {"channels", &f.channels}} {
+		v, ok := data[field.name].(float64)
+		if !ok || v <= 0 {
+			continue
+		}
+		if *field.dst != 0 && *field.dst != int(v) {
+			return fmt.Errorf("piper changed audio %s from %d to %d mid-stream", field.name, *field.dst, int(v))
+		}
+		*field.dst = int(v)
+	}
+	return nil
+}
+
+// resolve fills fields that were never announced with Piper's defaults,
+// warning about each, and checks that pcmBytes holds whole frames.
+func (f *audioFormat) resolve(pcmBytes int) error {
+	f.fill()
+	if frame := f.width * f.channels; pcmBytes%frame != 0 {
+		return fmt.Errorf("piper sent %d bytes of audio, not a whole number of %d-byte frames (width %d, channels %d)",
+			pcmBytes, frame, f.width, f.channels)
+	}
+	return nil
+}
+
+// fill sets fields that were never announced to Piper's defaults, warning
+// about each.
+func (f *audioFormat) fill() {
+	for _, field := range []struct {
+		name string
+		dst  *int
+		def  int
+	}{{"rate", &f.rate, defaultRate}, {"width", &f.width, defaultWidth}, {"channels", &f.channels, defaultChannels}} {
+		if *field.dst == 0 {
+			slog.Warn("piper did not announce audio format, assuming default", "field", field.name, "value", field.def)
+			*field.dst = field.def
+		}
+	}
+}
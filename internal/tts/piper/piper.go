@@ -7,15 +7,15 @@
 // Wyoming protocol format (per event):
 //
 //	<json_length> <payload_length>\n
-//	<json_bytes>\n
+//	<json_bytes>\n     (the newline is optional)
 //	<payload_bytes>   (if payload_length > 0)
 package piper
 
 import (
 	"bytes"
 	"context"
-	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -24,7 +24,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/nadzzz/switchyard/internal/audio"
 	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/health"
 	"github.com/nadzzz/switchyard/internal/tts"
 )
 
@@ -46,11 +48,20 @@ var defaultVoices = map[string]string{
 
 // Synthesizer implements tts.Synthesizer using the Wyoming protocol.
 type Synthesizer struct {
-	endpoint  string            // default host:port of the Piper Wyoming server
-	endpoints map[string]string // language -> host:port for per-language Piper instances
-	voices    map[string]string // language -> voice name overrides
+	endpoint   string              // default host:port of the Piper Wyoming server
+	endpoints  map[string]string   // language -> host:port for per-language Piper instances
+	voices     map[string]string   // language -> voice name overrides
+	fallbacks  map[string][]string // language -> languages to try when it has no voice
+	english    bool                // try English after the fallback chain
+	pool       *connPool
+	attempts   int           // connection attempts per synthesis
+	retryDelay time.Duration // backoff before the first retry
+	ffmpegPath string        // encoder for MP3/Opus output
 }
 
+// maxRetryDelay caps the backoff between connection attempts.
+const maxRetryDelay = 5 * time.Second
+
 // New creates a new Piper synthesizer from config.
 func New(cfg config.PiperConfig) *Synthesizer {
 	// Merge user-configured voices with defaults.
@@ -75,47 +86,231 @@ func New(cfg config.PiperConfig) *Synthesizer {
 		endpoints[lang] = cleanEndpoint(ep)
 	}
 
+	retryDelay := cfg.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = 250 * time.Millisecond
+	}
+
 	return &Synthesizer{
-		endpoint:  endpoint,
-		endpoints: endpoints,
-		voices:    voices,
+		endpoint:   endpoint,
+		endpoints:  endpoints,
+		voices:     voices,
+		fallbacks:  cfg.Fallbacks,
+		english:    cfg.EnglishFallback,
+		pool:       newConnPool(cfg.MaxIdleConns, cfg.IdleTimeout),
+		attempts:   max(cfg.MaxAttempts, 1),
+		retryDelay: retryDelay,
+		ffmpegPath: cfg.FFmpegPath,
 	}
 }
 
-// Synthesize sends text to the Piper server and returns synthesized audio as WAV.
+// Synthesize sends text to the Piper server and returns synthesized audio as
+// WAV, or as MP3/Opus encoded with ffmpeg when opts.Format asks for it. The
+// PCM is resampled first if opts.SampleRate differs from the voice's rate.
 func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.SynthesizeOpts) (*tts.SynthesizeResult, error) {
+	if audio.ContentType(opts.Format) == "" {
+		return nil, fmt.Errorf("unsupported audio format %q", opts.Format)
+	}
+
+	var pcmBuf bytes.Buffer
+	format, err := s.run(ctx, text, opts, func(_ audioFormat, pcm []byte) error {
+		pcmBuf.Write(pcm)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pcm := pcmBuf.Bytes()
+	sampleRate, channels, width := format.rate, format.channels, format.width
+	if opts.Volume > 0 && width == 2 {
+		audio.ScalePCM16(pcm, opts.Volume)
+	}
+	if opts.SampleRate > 0 && opts.SampleRate != sampleRate {
+		if width != 2 {
+			return nil, fmt.Errorf("resampling %d-bit audio is not supported", width*8)
+		}
+		if pcm, err = audio.ResamplePCM16(pcm, channels, sampleRate, opts.SampleRate); err != nil {
+			return nil, err
+		}
+		sampleRate = opts.SampleRate
+	}
+	res := &tts.SynthesizeResult{
+		Audio:       audio.EncodeWAV(pcm, sampleRate, channels, width),
+		ContentType: "audio/wav",
+		SampleRate:  sampleRate,
+		Channels:    channels,
+	}
+
+	// Piper only produces PCM; compressed formats are encoded from the WAV.
+	if opts.Format != "" && opts.Format != audio.FormatWAV {
+		encoded, err := audio.EncodeWithFFmpeg(ctx, s.ffmpegPath, res.Audio, opts.Format)
+		if err != nil {
+			return nil, err
+		}
+		res.Audio = encoded
+		res.ContentType = audio.ContentType(opts.Format)
+	}
+	return res, nil
+}
+
+// SynthesizeStream writes WAV audio to w as Piper produces it: a streaming
+// header as soon as the format is known, then each audio-chunk's PCM.
+func (s *Synthesizer) SynthesizeStream(ctx context.Context, text string, opts tts.SynthesizeOpts, w io.Writer) error {
+	if opts.Format != "" && opts.Format != audio.FormatWAV {
+		return fmt.Errorf("streaming supports wav only, got %q", opts.Format)
+	}
+
+	headerSent := false
+	sendHeader := func(f audioFormat) error {
+		if headerSent {
+			return nil
+		}
+		if opts.SampleRate > 0 && opts.SampleRate != f.rate {
+			return fmt.Errorf("resampling is not supported when streaming (voice rate %d Hz)", f.rate)
+		}
+		headerSent = true
+		_, err := w.Write(audio.StreamingWAVHeader(f.rate, f.channels, f.width))
+		return err
+	}
+
+	// Volume scaling works on whole samples; a chunk ending mid-sample
+	// carries its last byte over to the next one.
+	var carry []byte
+	format, err := s.run(ctx, text, opts, func(f audioFormat, pcm []byte) error {
+		if err := sendHeader(f); err != nil {
+			return err
+		}
+		if opts.Volume > 0 && f.width == 2 {
+			pcm = append(carry, pcm...)
+			whole := len(pcm) &^ 1
+			carry = append([]byte(nil), pcm[whole:]...)
+			pcm = pcm[:whole]
+			audio.ScalePCM16(pcm, opts.Volume)
+		}
+		_, err := w.Write(pcm)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return sendHeader(format) // for audio without any chunks
+}
+
+// run synthesizes text on a pooled connection to the endpoint for its
+// voice, passing each audio chunk to onAudio, and returns the audio format.
+func (s *Synthesizer) run(ctx context.Context, text string, opts tts.SynthesizeOpts, onAudio func(audioFormat, []byte) error) (audioFormat, error) {
 	if text == "" {
-		return nil, fmt.Errorf("empty text for synthesis")
+		return audioFormat{}, fmt.Errorf("empty text for synthesis")
 	}
 
 	// Select voice based on language or explicit override.
-	voice := opts.Voice
+	voice, lang := opts.Voice, opts.Language
 	if voice == "" {
-		voice = s.voices[opts.Language]
-	}
-	if voice == "" {
-		voice = s.voices["en"] // fallback to English
+		var err error
+		if voice, lang, err = s.selectVoice(opts.Language); err != nil {
+			return audioFormat{}, err
+		}
 	}
 
 	// Select endpoint: per-language endpoint if available, else fallback.
-	endpoint := s.endpoints[opts.Language]
+	endpoint := s.endpoints[lang]
 	if endpoint == "" {
 		endpoint = s.endpoint
 	}
 	if endpoint == "" {
-		return nil, fmt.Errorf("no piper endpoint configured for language %q", opts.Language)
+		return audioFormat{}, fmt.Errorf("no piper endpoint configured for language %q", opts.Language)
 	}
 
 	slog.Debug("piper synthesize", "text_length", len(text), "voice", voice, "language", opts.Language, "endpoint", endpoint)
 
-	// Connect to the Wyoming server.
-	dialer := net.Dialer{Timeout: 10 * time.Second}
-	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
-	if err != nil {
-		return nil, fmt.Errorf("connecting to piper: %w", err)
+	// Reuse a pooled connection if one is idle. The server may have dropped
+	// it since; synthesis is idempotent, so retry once on a fresh connection
+	// as long as no audio was passed on. A server that can't be reached, or
+	// drops the connection before answering, is retried with backoff up to
+	// the attempt limit; an error event from Piper is not.
+	delivered := false
+	deliver := func(f audioFormat, pcm []byte) error {
+		delivered = true
+		return onAudio(f, pcm)
+	}
+	fromPool := true
+	for attempt := 1; ; attempt++ {
+		var (
+			conn   *wyomingConn
+			reused bool
+			err    error
+		)
+		if fromPool {
+			conn, reused, err = s.pool.get(ctx, endpoint)
+			fromPool = false
+		} else {
+			conn, err = s.pool.dial(ctx, endpoint)
+		}
+		if err != nil {
+			err = notAnsweredError{fmt.Errorf("connecting to piper: %w", err)}
+		} else {
+			var format audioFormat
+			format, err = s.synthesize(ctx, conn, text, voice, opts, deliver)
+			if err == nil {
+				s.pool.put(endpoint, conn)
+				return format, nil
+			}
+			conn.Close()
+			if reused && !delivered && ctx.Err() == nil {
+				slog.Debug("pooled piper connection failed, redialing", "endpoint", endpoint, "error", err)
+				attempt--
+				continue
+			}
+		}
+
+		var notAnswered notAnsweredError
+		if !errors.As(err, &notAnswered) || delivered || attempt >= s.attempts || ctx.Err() != nil {
+			return audioFormat{}, err
+		}
+		wait := min(s.retryDelay<<(attempt-1), maxRetryDelay)
+		slog.Warn("piper unavailable, retrying", "endpoint", endpoint, "attempt", attempt, "wait", wait, "error", err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return audioFormat{}, fmt.Errorf("%w (retry aborted: %w)", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// notAnsweredError wraps failures from before Piper sent its first event:
+// the connection could not be made, or was lost before the server answered.
+// The request was not processed, so it is safe to send again.
+type notAnsweredError struct{ err error }
+
+func (e notAnsweredError) Error() string { return e.err.Error() }
+func (e notAnsweredError) Unwrap() error { return e.err }
+
+// selectVoice returns the voice for lang, walking its fallback chain and then
+// English (if enabled) when lang has no voice. It also returns the language
+// whose voice was chosen, which selects the endpoint.
+func (s *Synthesizer) selectVoice(lang string) (voice, voiceLang string, err error) {
+	chain := append([]string{lang}, s.fallbacks[lang]...)
+	if s.english {
+		chain = append(chain, "en")
+	}
+	for _, l := range chain {
+		if v := s.voices[l]; v != "" {
+			if l != lang {
+				slog.Debug("no piper voice for language, using fallback", "language", lang, "fallback", l)
+			}
+			return v, l, nil
+		}
 	}
-	defer conn.Close()
+	return "", "", fmt.Errorf("piper: %w %q (tried %s)", tts.ErrNoVoice, lang, strings.Join(chain, ", "))
+}
 
+// synthesize runs one synthesize exchange on conn, passing PCM to onAudio as
+// it arrives; the format is complete from the first call on. The connection
+// is left open; on error its state is undefined and it must not be reused.
+func (s *Synthesizer) synthesize(ctx context.Context, conn *wyomingConn, text, voice string, opts tts.SynthesizeOpts, onAudio func(audioFormat, []byte) error) (audioFormat, error) {
 	// Set deadline from context.
 	if deadline, ok := ctx.Deadline(); ok {
 		_ = conn.SetDeadline(deadline)
@@ -123,7 +318,9 @@ func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.Synt
 		_ = conn.SetDeadline(time.Now().Add(30 * time.Second))
 	}
 
-	// Send synthesize event.
+	// Send synthesize event. Piper expresses speaking rate as length_scale
+	// (phoneme duration); servers that don't support it ignore the field.
+	// Pitch has no Piper equivalent and is not sent.
 	synthEvent := wyomingEvent{
 		Type: "synthesize",
 		Data: map[string]any{
@@ -133,58 +330,66 @@ func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.Synt
 			},
 		},
 	}
+	if opts.Rate > 0 && opts.Rate != 1 {
+		synthEvent.Data["length_scale"] = 1 / opts.Rate
+	}
 	if err := writeEvent(conn, synthEvent, nil); err != nil {
-		return nil, fmt.Errorf("sending synthesize event: %w", err)
+		return audioFormat{}, notAnsweredError{fmt.Errorf("sending synthesize event: %w", err)}
 	}
 
-	// Read response events: audio-start → audio-chunk* → audio-stop
+	// Read response events: audio-start → audio-chunk* → audio-stop. A
+	// repeated audio-start before audio-stop continues the same audio.
 	var (
-		pcmBuf     bytes.Buffer
-		sampleRate = 22050
-		channels   = 1
-		width      = 2
+		format   audioFormat
+		started  bool
+		pcmBytes int
+		answered bool
 	)
 
 	for {
-		evt, payload, err := readEvent(conn)
+		evt, payload, err := conn.readEvent()
 		if err != nil {
-			return nil, fmt.Errorf("reading piper event: %w", err)
+			err = fmt.Errorf("reading piper event: %w", err)
+			if !answered && !isTimeout(err) {
+				err = notAnsweredError{err}
+			}
+			return format, err
 		}
+		answered = true
 
 		switch evt.Type {
 		case "audio-start":
-			if rate, ok := evt.Data["rate"].(float64); ok {
-				sampleRate = int(rate)
-			}
-			if ch, ok := evt.Data["channels"].(float64); ok {
-				channels = int(ch)
+			if started {
+				slog.Debug("piper repeated audio-start, concatenating audio")
 			}
-			if w, ok := evt.Data["width"].(float64); ok {
-				width = int(w)
+			started = true
+			if err := format.update(evt.Data); err != nil {
+				return format, err
 			}
-			slog.Debug("piper audio-start", "rate", sampleRate, "channels", channels, "width", width)
+			slog.Debug("piper audio-start", "rate", format.rate, "channels", format.channels, "width", format.width)
 
 		case "audio-chunk":
+			if err := format.update(evt.Data); err != nil {
+				return format, err
+			}
 			if len(payload) > 0 {
-				pcmBuf.Write(payload)
+				format.fill()
+				pcmBytes += len(payload)
+				if err := onAudio(format, payload); err != nil {
+					return format, err
+				}
 			}
 
 		case "audio-stop":
-			slog.Debug("piper audio-stop", "pcm_bytes", pcmBuf.Len())
-			wav := pcmToWAV(pcmBuf.Bytes(), sampleRate, channels, width)
-			return &tts.SynthesizeResult{
-				Audio:       wav,
-				ContentType: "audio/wav",
-				SampleRate:  sampleRate,
-				Channels:    channels,
-			}, nil
+			slog.Debug("piper audio-stop", "pcm_bytes", pcmBytes)
+			return format, format.resolve(pcmBytes)
 
 		case "error":
 			msg := "unknown error"
 			if text, ok := evt.Data["text"].(string); ok {
 				msg = text
 			}
-			return nil, fmt.Errorf("piper error: %s", msg)
+			return format, fmt.Errorf("piper error: %s", msg)
 
 		default:
 			slog.Debug("piper unknown event", "type", evt.Type)
@@ -192,8 +397,35 @@ func (s *Synthesizer) Synthesize(ctx context.Context, text string, opts tts.Synt
 	}
 }
 
-// Close is a no-op — connections are per-request.
-func (s *Synthesizer) Close() error { return nil }
+// Close closes idle pooled connections.
+func (s *Synthesizer) Close() error {
+	s.pool.close()
+	return nil
+}
+
+// HealthCheck verifies that every configured Piper endpoint accepts connections.
+func (s *Synthesizer) HealthCheck(ctx context.Context) error {
+	var errs []error
+	if s.endpoint != "" {
+		if err := health.Dial(ctx, s.endpoint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for lang, ep := range s.endpoints {
+		if err := health.Dial(ctx, ep); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", lang, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isTimeout reports whether err is a connection deadline expiring. A
+// server that accepted a request but didn't answer in time may still be
+// working on it, so the request is not retried.
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
 
 // --- Wyoming protocol helpers ---
 
@@ -235,19 +467,49 @@ func writeEvent(w io.Writer, evt wyomingEvent, payload []byte) error {
 	return nil
 }
 
+// jsonNewline records whether a server follows each event's JSON with a
+// newline. It can't be told from the bytes after the JSON alone when a
+// payload follows, since PCM may start with 0x0A.
+type jsonNewline int
+
+const (
+	newlineUnknown jsonNewline = iota
+	newlineSent
+	newlineAbsent
+)
+
 // readEvent reads a Wyoming event from the connection.
-func readEvent(r io.Reader) (*wyomingEvent, []byte, error) {
+//
+// Whether the server sends the optional newline after the JSON is learned
+// from the first event without a payload (Piper starts every response with
+// one, audio-start), and only consumed before a payload once it is known.
+func (c *wyomingConn) readEvent() (*wyomingEvent, []byte, error) {
+	r := c.r
 	// Read header line: "<json_length> <payload_length>\n"
 	headerBuf := make([]byte, 0, 64)
-	oneByte := make([]byte, 1)
+	skipped := false
 	for {
-		if _, err := io.ReadFull(r, oneByte); err != nil {
+		b, err := r.ReadByte()
+		if err != nil {
 			return nil, nil, fmt.Errorf("reading header: %w", err)
 		}
-		if oneByte[0] == '\n' {
+		if b == '\n' {
+			if len(headerBuf) == 0 {
+				skipped = true // newline left over from the previous event
+				continue
+			}
 			break
 		}
-		headerBuf = append(headerBuf, oneByte[0])
+		headerBuf = append(headerBuf, b)
+	}
+	if c.checkHeader {
+		// The previous event had no payload: a newline before this header
+		// was the one ending its JSON.
+		c.checkHeader = false
+		c.newline = newlineAbsent
+		if skipped {
+			c.newline = newlineSent
+		}
 	}
 
 	parts := strings.SplitN(string(headerBuf), " ", 2)
@@ -264,12 +526,13 @@ func readEvent(r io.Reader) (*wyomingEvent, []byte, error) {
 		return nil, nil, fmt.Errorf("parsing payload_length: %w", err)
 	}
 
-	// Read JSON + trailing newline.
-	jsonBuf := make([]byte, jsonLen+1) // +1 for the \n
+	jsonBuf := make([]byte, jsonLen)
 	if _, err := io.ReadFull(r, jsonBuf); err != nil {
 		return nil, nil, fmt.Errorf("reading json: %w", err)
 	}
-	jsonBuf = jsonBuf[:jsonLen] // strip trailing newline
+	if err := c.skipJSONNewline(payloadLen); err != nil {
+		return nil, nil, err
+	}
 
 	var evt wyomingEvent
 	if err := json.Unmarshal(jsonBuf, &evt); err != nil {
@@ -288,35 +551,55 @@ func readEvent(r io.Reader) (*wyomingEvent, []byte, error) {
 	return &evt, payload, nil
 }
 
-// pcmToWAV wraps raw PCM data in a WAV container.
-func pcmToWAV(pcm []byte, sampleRate, channels, bytesPerSample int) []byte {
-	dataLen := len(pcm)
-	fileLen := 36 + dataLen // 44-byte header minus 8 bytes for RIFF header = 36
-
-	buf := &bytes.Buffer{}
-	buf.Grow(44 + dataLen)
-
-	// RIFF header
-	buf.WriteString("RIFF")
-	_ = binary.Write(buf, binary.LittleEndian, uint32(fileLen))
-	buf.WriteString("WAVE")
-
-	// fmt subchunk
-	buf.WriteString("fmt ")
-	_ = binary.Write(buf, binary.LittleEndian, uint32(16))         // subchunk1 size
-	_ = binary.Write(buf, binary.LittleEndian, uint16(1))          // audio format (PCM)
-	_ = binary.Write(buf, binary.LittleEndian, uint16(channels))   // channels
-	_ = binary.Write(buf, binary.LittleEndian, uint32(sampleRate)) // sample rate
-	byteRate := sampleRate * channels * bytesPerSample
-	_ = binary.Write(buf, binary.LittleEndian, uint32(byteRate)) // byte rate
-	blockAlign := channels * bytesPerSample
-	_ = binary.Write(buf, binary.LittleEndian, uint16(blockAlign))       // block align
-	_ = binary.Write(buf, binary.LittleEndian, uint16(bytesPerSample*8)) // bits per sample
-
-	// data subchunk
-	buf.WriteString("data")
-	_ = binary.Write(buf, binary.LittleEndian, uint32(dataLen))
-	buf.Write(pcm)
-
-	return buf.Bytes()
+// skipJSONNewline consumes the newline after an event's JSON when the
+// server sends one. Without a payload it is left for the next header read to
+// skip: after the last event of a synthesis nothing more arrives, so waiting
+// for it would block.
+func (c *wyomingConn) skipJSONNewline(payloadLen int) error {
+	switch c.newline {
+	case newlineAbsent:
+		return nil
+	case newlineSent:
+		if payloadLen == 0 {
+			return nil
+		}
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("reading json newline: %w", err)
+		}
+		if b != '\n' {
+			return fmt.Errorf("expected newline after event json, got 0x%02x", b)
+		}
+		return nil
+	}
+
+	if payloadLen == 0 {
+		if c.r.Buffered() == 0 {
+			c.checkHeader = true
+			return nil
+		}
+		next, _ := c.r.Peek(1)
+		if next[0] == '\n' {
+			c.newline = newlineSent
+			_, _ = c.r.Discard(1)
+		} else {
+			c.newline = newlineAbsent
+		}
+		return nil
+	}
+
+	// A payload on the connection's first event: a byte other than a
+	// newline settles it, but a newline might be the start of the payload.
+	// Servers that skip audio-start are rare; assume the newline.
+	next, err := c.r.Peek(1)
+	if err != nil {
+		return fmt.Errorf("reading payload: %w", err)
+	}
+	if next[0] != '\n' {
+		c.newline = newlineAbsent
+		return nil
+	}
+	c.newline = newlineSent
+	_, _ = c.r.Discard(1)
+	return nil
 }
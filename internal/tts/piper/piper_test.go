@@ -0,0 +1,166 @@
+package piper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/nadzzz/switchyard/internal/config"
+	"github.com/nadzzz/switchyard/internal/tts"
+)
+
+// encodeEvent frames one Wyoming event, with or without the newline after
+// the JSON.
+func encodeEvent(t *testing.T, newline bool, typ string, data map[string]any, payload []byte) []byte {
+	t.Helper()
+	js, err := json.Marshal(wyomingEvent{Type: typ, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d %d\n", len(js), len(payload))
+	b.Write(js)
+	if newline {
+		b.WriteByte('\n')
+	}
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// synthesisStream is a Piper response whose PCM chunks start with 0x0A.
+func synthesisStream(t *testing.T, newline bool) ([]byte, [][]byte) {
+	format := map[string]any{"rate": 22050, "width": 2, "channels": 1}
+	chunks := [][]byte{{0x0A, 0x00, 0x0A, 0x01}, {0x0A, 0x0A}}
+	var stream []byte
+	stream = append(stream, encodeEvent(t, newline, "audio-start", format, nil)...)
+	for _, c := range chunks {
+		stream = append(stream, encodeEvent(t, newline, "audio-chunk", format, c)...)
+	}
+	stream = append(stream, encodeEvent(t, newline, "audio-stop", nil, nil)...)
+	return stream, chunks
+}
+
+func TestReadEventFraming(t *testing.T) {
+	readers := map[string]func([]byte) io.Reader{
+		"buffered":     func(b []byte) io.Reader { return bytes.NewReader(b) },
+		"byte-by-byte": func(b []byte) io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) },
+	}
+	for _, newline := range []bool{true, false} {
+		for name, reader := range readers {
+			t.Run(fmt.Sprintf("newline=%v/%s", newline, name), func(t *testing.T) {
+				stream, chunks := synthesisStream(t, newline)
+				// Two syntheses on one connection, as when it is pooled.
+				conn := &wyomingConn{r: bufio.NewReader(reader(append(stream, stream...)))}
+				for round := range 2 {
+					var got [][]byte
+					for {
+						evt, payload, err := conn.readEvent()
+						if err != nil {
+							t.Fatalf("round %d: readEvent: %v", round, err)
+						}
+						if evt.Type == "audio-chunk" {
+							got = append(got, payload)
+						}
+						if evt.Type == "audio-stop" {
+							break
+						}
+					}
+					if len(got) != len(chunks) {
+						t.Fatalf("round %d: got %d chunks, want %d", round, len(got), len(chunks))
+					}
+					for i := range chunks {
+						if !bytes.Equal(got[i], chunks[i]) {
+							t.Errorf("round %d: chunk %d = % x, want % x", round, i, got[i], chunks[i])
+						}
+					}
+				}
+				want := newlineAbsent
+				if newline {
+					want = newlineSent
+				}
+				if conn.newline != want {
+					t.Errorf("detected framing %d, want %d", conn.newline, want)
+				}
+			})
+		}
+	}
+}
+
+func TestReadEventLastEventDoesNotBlock(t *testing.T) {
+	// Only audio-stop, with nothing after it: reading it must not wait for
+	// a newline that may never come.
+	stream := encodeEvent(t, false, "audio-stop", nil, nil)
+	conn := &wyomingConn{r: bufio.NewReader(iotest.OneByteReader(bytes.NewReader(stream)))}
+	evt, _, err := conn.readEvent()
+	if err != nil || evt.Type != "audio-stop" {
+		t.Fatalf("readEvent() = %v, %v", evt, err)
+	}
+}
+
+// flakyPiper serves Piper on a local port and closes the first drops
+// connections without answering. It returns the endpoint and a count of
+// accepted connections.
+func flakyPiper(t *testing.T, drops int) (string, *atomic.Int32) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	stream, _ := synthesisStream(t, true)
+	var accepted atomic.Int32
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if int(accepted.Add(1)) <= drops {
+				c.Close()
+				continue
+			}
+			conn := &wyomingConn{Conn: c, r: bufio.NewReader(c)}
+			if _, _, err := conn.readEvent(); err == nil {
+				_, _ = c.Write(stream)
+			}
+			c.Close()
+		}
+	}()
+	return ln.Addr().String(), &accepted
+}
+
+func TestSynthesizeRetriesUnansweredConnection(t *testing.T) {
+	endpoint, accepted := flakyPiper(t, 1)
+	s := New(config.PiperConfig{Endpoint: endpoint, MaxAttempts: 2, RetryDelay: time.Millisecond})
+	defer s.Close()
+	res, err := s.Synthesize(context.Background(), "hello", tts.SynthesizeOpts{Voice: "test"})
+	if err != nil {
+		t.Fatalf("Synthesize: %v", err)
+	}
+	if len(res.Audio) == 0 {
+		t.Error("no audio")
+	}
+	if got := accepted.Load(); got != 2 {
+		t.Errorf("connections = %d, want 2", got)
+	}
+}
+
+func TestSynthesizeGivesUpAfterMaxAttempts(t *testing.T) {
+	endpoint, accepted := flakyPiper(t, 5)
+	s := New(config.PiperConfig{Endpoint: endpoint, MaxAttempts: 3, RetryDelay: time.Millisecond})
+	defer s.Close()
+	if _, err := s.Synthesize(context.Background(), "hello", tts.SynthesizeOpts{Voice: "test"}); err == nil {
+		t.Fatal("Synthesize succeeded against a server that never answers")
+	}
+	if got := accepted.Load(); got != 3 {
+		t.Errorf("connections = %d, want 3", got)
+	}
+}
@@ -0,0 +1,107 @@
+package piper
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// connPool keeps idle Wyoming connections per endpoint for reuse.
+type connPool struct {
+	maxIdle     int           // per endpoint; 0 disables pooling
+	idleTimeout time.Duration // idle connections older than this are discarded
+
+	mu     sync.Mutex
+	idle   map[string][]idleConn // endpoint -> idle connections, most recent last
+	closed bool
+}
+
+type idleConn struct {
+	conn  *wyomingConn
+	since time.Time
+}
+
+// wyomingConn is a connection read through a buffer, which lets readEvent
+// peek at optional framing bytes. The buffer, and what has been learned
+// about the server's framing, stay with the connection while it is pooled.
+type wyomingConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	newline     jsonNewline // whether the server ends event JSON with a newline
+	checkHeader bool        // decide newline from the next header read
+}
+
+func (c *wyomingConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+func newConnPool(maxIdle int, idleTimeout time.Duration) *connPool {
+	return &connPool{
+		maxIdle:     maxIdle,
+		idleTimeout: idleTimeout,
+		idle:        make(map[string][]idleConn),
+	}
+}
+
+// get returns an idle connection to endpoint, or dials a new one. reused
+// reports whether the connection came from the pool; the server may have
+// closed it since, so callers should redial if it fails.
+func (p *connPool) get(ctx context.Context, endpoint string) (conn *wyomingConn, reused bool, err error) {
+	p.mu.Lock()
+	conns := p.idle[endpoint]
+	for len(conns) > 0 {
+		ic := conns[len(conns)-1]
+		conns = conns[:len(conns)-1]
+		if p.idleTimeout > 0 && time.Since(ic.since) > p.idleTimeout {
+			ic.conn.Close()
+			continue
+		}
+		p.idle[endpoint] = conns
+		p.mu.Unlock()
+		return ic.conn, true, nil
+	}
+	p.idle[endpoint] = conns
+	p.mu.Unlock()
+
+	conn, err = p.dial(ctx, endpoint)
+	return conn, false, err
+}
+
+// dial opens a new connection to endpoint.
+func (p *connPool) dial(ctx context.Context, endpoint string) (*wyomingConn, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return &wyomingConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// put returns a healthy connection to the pool, closing it if the pool is
+// full or closed.
+func (p *connPool) put(endpoint string, conn *wyomingConn) {
+	_ = conn.SetDeadline(time.Time{})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle[endpoint]) >= p.maxIdle {
+		conn.Close()
+		return
+	}
+	p.idle[endpoint] = append(p.idle[endpoint], idleConn{conn: conn, since: time.Now()})
+}
+
+// close closes all idle connections. Connections returned afterwards are
+// closed instead of pooled.
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for endpoint, conns := range p.idle {
+		for _, ic := range conns {
+			ic.conn.Close()
+		}
+		delete(p.idle, endpoint)
+	}
+}
@@ -5,7 +5,15 @@
 // interactions through the dispatch pipeline.
 package tts
 
-import "context"
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrNoVoice is wrapped by errors returned when no voice is available for
+// the requested language. Callers can fall back to a text-only response.
+var ErrNoVoice = errors.New("no voice for language")
 
 // SynthesizeOpts controls synthesis behavior.
 type SynthesizeOpts struct {
@@ -14,18 +22,45 @@ type SynthesizeOpts struct {
 
 	// Voice overrides automatic language-based voice selection.
 	Voice string
+
+	// Format is the desired output format: "wav" (default), "mp3" or "opus".
+	Format string
+
+	// SampleRate is the desired sample rate in Hz (0 = the voice's native
+	// rate). PCM output is resampled to it.
+	SampleRate int
+
+	// Rate, Pitch and Volume adjust prosody as multipliers of the voice's
+	// defaults (1.0 = unchanged, 0 = not set). Backends apply what they
+	// support and ignore the rest.
+	Rate   float64
+	Pitch  float64
+	Volume float64
 }
 
 // Synthesizer converts text to audio.
 type Synthesizer interface {
-	// Synthesize generates audio (raw PCM 16-bit LE) from the given text.
-	// The returned audio can be wrapped in a WAV container by the caller.
+	// Synthesize generates audio from the given text, encoded in opts.Format
+	// (WAV when empty). Unsupported formats return an error.
 	Synthesize(ctx context.Context, text string, opts SynthesizeOpts) (*SynthesizeResult, error)
 
 	// Close releases any resources held by the synthesizer.
 	Close() error
 }
 
+// StreamSynthesizer is implemented by synthesizers that can deliver audio
+// while it is being synthesized, so playback can start before synthesis ends.
+type StreamSynthesizer interface {
+	Synthesizer
+
+	// SynthesizeStream generates WAV audio from text and writes it to w as
+	// it is produced: a header with unknown lengths first (see
+	// audio.StreamingWAVHeader), then PCM chunks. Only WAV at the voice's
+	// native sample rate can be streamed; other formats and rates return an
+	// error before anything is written.
+	SynthesizeStream(ctx context.Context, text string, opts SynthesizeOpts, w io.Writer) error
+}
+
 // SynthesizeResult holds the output of TTS synthesis.
 type SynthesizeResult struct {
 	// Audio is the synthesized audio as a WAV file.